@@ -0,0 +1,175 @@
+// Copyright 2024 Canonical Ltd.
+// Licensed under Apache 2.0, see LICENCE file for details.
+
+package sqlair
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+)
+
+// BatchSize makes a bulk insert, run with [Query.Run], [Query.Get] or
+// [Query.RunAffected], insert its rows in batches of at most n rows, with one
+// statement per batch. This keeps the number of query parameters of a large
+// bulk insert below the limit of the database. For example:
+//
+//	err := db.Query(ctx, stmt, people).BatchSize(500).Run()
+//
+// The batches are run in order in a single transaction. On a [DB] or a [Conn]
+// a transaction is started for them and committed once all the batches have
+// run, so either all the rows are inserted or none are. On a [TX] the batches
+// are run in that transaction after a savepoint, which is rolled back to if a
+// batch fails, so the rows of the earlier batches are removed and the
+// transaction can still be used.
+//
+// The [Outcome] of the query holds a result whose RowsAffected is the sum over
+// the batches and whose LastInsertId is that of the last batch. If the rows
+// fit in one batch, or the query is not a bulk insert, the query is run as
+// usual. Queries with output expressions cannot be run in batches.
+func (q *Query) BatchSize(n int) *Query {
+	if q.err == nil && n <= 0 {
+		q.err = fmt.Errorf("cannot run batched query: batch size must be positive, got %d", n)
+	}
+	q.batchSize = n
+	return q
+}
+
+// batchResult is the sql.Result of a query run in batches.
+type batchResult struct {
+	results []sql.Result
+}
+
+// LastInsertId returns the last insert ID of the last batch.
+func (br batchResult) LastInsertId() (int64, error) {
+	return br.results[len(br.results)-1].LastInsertId()
+}
+
+// RowsAffected returns the sum of the rows affected by each batch.
+func (br batchResult) RowsAffected() (int64, error) {
+	var total int64
+	for _, r := range br.results {
+		n, err := r.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		total += n
+	}
+	return total, nil
+}
+
+// transactFunc runs fn in a transaction. The rebind function passed to fn
+// builds a Query from the Statement of the original query that is run in the
+// transaction.
+type transactFunc func(ctx context.Context, fn func(rebind func(inputArgs []any) *Query) error) error
+
+// transactNew returns a transactFunc that runs fn in a new transaction started
+// with begin, which is committed if fn succeeds and rolled back otherwise.
+func transactNew(db *DB, s *Statement, begin func(ctx context.Context) (*sql.Tx, error)) transactFunc {
+	return func(ctx context.Context, fn func(rebind func(inputArgs []any) *Query) error) error {
+		sqltx, err := begin(ctx)
+		if err != nil {
+			return err
+		}
+		tx := &TX{sqltx: sqltx, db: db}
+		err = fn(func(inputArgs []any) *Query {
+			return tx.Query(ctx, s, inputArgs...)
+		})
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		return tx.Commit()
+	}
+}
+
+// batchSavepoint is the name of the savepoint that the batches of a query run
+// on a TX are rolled back to if one fails. Savepoints with the same name can
+// be nested, so one name is enough.
+const batchSavepoint = "sqlair_batch"
+
+// transactSavepoint returns a transactFunc that runs fn in the transaction tx
+// after a savepoint. The transaction is rolled back to the savepoint if fn
+// fails, and the savepoint is released once fn is done.
+func transactSavepoint(tx *TX, rebind func(inputArgs []any) *Query) transactFunc {
+	return func(ctx context.Context, fn func(rebind func(inputArgs []any) *Query) error) error {
+		sp, err := tx.Savepoint(ctx, batchSavepoint)
+		if err != nil {
+			return err
+		}
+		if err := fn(rebind); err != nil {
+			if rbErr := sp.RollbackTo(ctx); rbErr != nil {
+				return fmt.Errorf("%s (cannot roll back to savepoint: %s)", err, rbErr)
+			}
+			sp.Release(ctx)
+			return err
+		}
+		return sp.Release(ctx)
+	}
+}
+
+// runBatches runs a bulk insert in batches of at most batchSize rows. It
+// returns false if the query is not a bulk insert, has output expressions or
+// its rows fit in one batch, in which case it should be run as usual.
+func (q *Query) runBatches(ctx context.Context) (result sql.Result, batched bool, err error) {
+	if q.pq.HasOutputs() {
+		return nil, false, nil
+	}
+	bulkIdxs, bulkVals := q.bulkArgs()
+	if len(bulkIdxs) == 0 || bulkVals[0].Len() <= q.batchSize {
+		return nil, false, nil
+	}
+	numRows := bulkVals[0].Len()
+	for _, v := range bulkVals {
+		if v.Len() != numRows {
+			// Let the bulk insert report the mismatch.
+			return nil, false, nil
+		}
+	}
+
+	var br batchResult
+	inputArgs := make([]any, len(q.inputArgs))
+	copy(inputArgs, q.inputArgs)
+	err = q.transact(ctx, func(rebind func(inputArgs []any) *Query) error {
+		for start := 0; start < numRows; start += q.batchSize {
+			end := start + q.batchSize
+			if end > numRows {
+				end = numRows
+			}
+			for i, idx := range bulkIdxs {
+				inputArgs[idx] = bulkVals[i].Slice(start, end).Interface()
+			}
+			result, err := rebind(inputArgs).exec(ctx)
+			if err != nil {
+				return fmt.Errorf("cannot run batch of rows %d to %d: %s", start, end-1, err)
+			}
+			br.results = append(br.results, result)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, true, err
+	}
+	return br, true, nil
+}
+
+// bulkArgs returns the indexes and values of the input arguments that are
+// slices of rows for a bulk insert.
+func (q *Query) bulkArgs() (idxs []int, vals []reflect.Value) {
+	sliceInputs := map[reflect.Type]bool{}
+	for _, t := range q.sliceInputTypes {
+		sliceInputs[t] = true
+	}
+	for i, arg := range q.inputArgs {
+		v := reflect.ValueOf(arg)
+		for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+			v = v.Elem()
+		}
+		if v.Kind() == reflect.Slice && !sliceInputs[v.Type()] {
+			idxs = append(idxs, i)
+			vals = append(vals, v)
+		}
+	}
+	return idxs, vals
+}
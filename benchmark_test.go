@@ -0,0 +1,121 @@
+// Copyright 2024 Canonical Ltd.
+// Licensed under Apache 2.0, see LICENCE file for details.
+
+package sqlair_test
+
+import (
+	"database/sql"
+	"reflect"
+	"strconv"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/canonical/sqlair"
+)
+
+// WideRow is a struct with many NOT NULL columns for benchmarking.
+type WideRow struct {
+	C0  int    `db:"c0,notnull"`
+	C1  int    `db:"c1,notnull"`
+	C2  int    `db:"c2,notnull"`
+	C3  int    `db:"c3,notnull"`
+	C4  int    `db:"c4,notnull"`
+	C5  int    `db:"c5,notnull"`
+	C6  int    `db:"c6,notnull"`
+	C7  int    `db:"c7,notnull"`
+	C8  string `db:"c8,notnull"`
+	C9  string `db:"c9,notnull"`
+	C10 string `db:"c10,notnull"`
+	C11 string `db:"c11,notnull"`
+	C12 string `db:"c12,notnull"`
+	C13 string `db:"c13,notnull"`
+	C14 string `db:"c14,notnull"`
+	C15 string `db:"c15,notnull"`
+}
+
+// NullableWideRow has the same columns as WideRow without the notnull option.
+type NullableWideRow struct {
+	C0  int    `db:"c0"`
+	C1  int    `db:"c1"`
+	C2  int    `db:"c2"`
+	C3  int    `db:"c3"`
+	C4  int    `db:"c4"`
+	C5  int    `db:"c5"`
+	C6  int    `db:"c6"`
+	C7  int    `db:"c7"`
+	C8  string `db:"c8"`
+	C9  string `db:"c9"`
+	C10 string `db:"c10"`
+	C11 string `db:"c11"`
+	C12 string `db:"c12"`
+	C13 string `db:"c13"`
+	C14 string `db:"c14"`
+	C15 string `db:"c15"`
+}
+
+// wideDB returns a database with a table of 1000 rows of WideRow.
+func wideDB(b *testing.B) *sqlair.DB {
+	sqldb, err := sql.Open("sqlite3", "file:"+b.Name()+".db?cache=shared&mode=memory")
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { sqldb.Close() })
+	db := sqlair.NewDB(sqldb)
+
+	create := "CREATE TABLE wide (c0 integer, c1 integer, c2 integer, c3 integer, c4 integer, c5 integer, c6 integer, c7 integer, " +
+		"c8 text, c9 text, c10 text, c11 text, c12 text, c13 text, c14 text, c15 text)"
+	if err := db.Query(nil, sqlair.MustPrepare(create)).Run(); err != nil {
+		b.Fatal(err)
+	}
+	rows := make([]WideRow, 1000)
+	for i := range rows {
+		rows[i] = WideRow{C0: i, C8: strconv.Itoa(i)}
+	}
+	if err := db.Query(nil, sqlair.MustPrepare("INSERT INTO wide (*) VALUES ($WideRow.*)", WideRow{}), rows).Run(); err != nil {
+		b.Fatal(err)
+	}
+	return db
+}
+
+func benchmarkWideRows(b *testing.B, typeSample any, newSlice func() any) {
+	db := wideDB(b)
+	name := reflect.TypeOf(typeSample).Name()
+	stmt := sqlair.MustPrepare("SELECT &"+name+".* FROM wide", typeSample)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := db.Query(nil, stmt).GetAll(newSlice()); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkGetAllWideNotNull(b *testing.B) {
+	benchmarkWideRows(b, WideRow{}, func() any { return &[]WideRow{} })
+}
+
+func BenchmarkGetAllWideNullable(b *testing.B) {
+	benchmarkWideRows(b, NullableWideRow{}, func() any { return &[]NullableWideRow{} })
+}
+
+// BenchmarkIterWideNullable reads the rows one at a time into the same
+// struct.
+func BenchmarkIterWideNullable(b *testing.B) {
+	db := wideDB(b)
+	stmt := sqlair.MustPrepare("SELECT &NullableWideRow.* FROM wide", NullableWideRow{})
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var row NullableWideRow
+		iter := db.Query(nil, stmt).Iter()
+		for iter.Next() {
+			if err := iter.Get(&row); err != nil {
+				b.Fatal(err)
+			}
+		}
+		if err := iter.Close(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+// Copyright 2024 Canonical Ltd.
+// Licensed under Apache 2.0, see LICENCE file for details.
+
+package sqlair
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/canonical/sqlair/internal/expr"
+)
+
+// busyRetry is a DBOption that retries single statements that fail because
+// the database is busy.
+type busyRetry struct {
+	maxAttempts int
+	backoff     time.Duration
+	isBusy      func(error) bool
+}
+
+// applyToDB sets the busy retry policy of the database.
+func (br busyRetry) applyToDB(db *DB) {
+	if br.maxAttempts < 2 || br.isBusy == nil {
+		db.busyRetry = nil
+		return
+	}
+	db.busyRetry = &br
+}
+
+// WithBusyRetry returns a [DBOption] that retries a query run with [DB.Query]
+// or [Conn.Query] when the database reports that it is busy, as SQLite does
+// with SQLITE_BUSY when another connection holds a lock. Since busy errors are
+// specific to the driver, isBusy is called with the error returned when the
+// statement is started and reports whether it is a busy error. For example,
+// with github.com/mattn/go-sqlite3:
+//
+//	sqlair.WithBusyRetry(5, 10*time.Millisecond, func(err error) bool {
+//		var sqliteErr sqlite3.Error
+//		return errors.As(err, &sqliteErr) && sqliteErr.Code == sqlite3.ErrBusy
+//	})
+//
+// The statement is run at most maxAttempts times. The first retry waits for
+// backoff and the wait doubles with each retry after that. The wait ends early
+// if the context of the query is done, in which case the last busy error is
+// returned. If maxAttempts is less than 2 or isBusy is nil, queries are not
+// retried.
+//
+// Only the error returned when the statement is started is retried. Errors
+// returned by a driver while the rows of a query are read, which is where some
+// drivers report that a read is busy, are returned as usual. Queries run in a
+// transaction are never retried, since a busy error in a transaction usually
+// means that the whole transaction must be rolled back and run again.
+//
+// A busy error means that the statement was not run, so retrying it is safe
+// even if it is not idempotent, provided that isBusy only matches errors with
+// that meaning.
+func WithBusyRetry(maxAttempts int, backoff time.Duration, isBusy func(error) bool) DBOption {
+	return busyRetry{maxAttempts: maxAttempts, backoff: backoff, isBusy: isBusy}
+}
+
+// retry returns a run function that calls run again while it returns an
+// error matching the busy predicate, following the retry policy. If br is nil
+// run is returned unchanged.
+func (br *busyRetry) retry(run func(context.Context, *expr.PrimedQuery) (*sql.Rows, sql.Result, *driverStmt, error)) func(context.Context, *expr.PrimedQuery) (*sql.Rows, sql.Result, *driverStmt, error) {
+	if br == nil {
+		return run
+	}
+	return func(ctx context.Context, pq *expr.PrimedQuery) (rows *sql.Rows, result sql.Result, ds *driverStmt, err error) {
+		wait := br.backoff
+		for attempt := 1; ; attempt++ {
+			rows, result, ds, err = run(ctx, pq)
+			if err == nil || attempt >= br.maxAttempts || !br.isBusy(err) {
+				return rows, result, ds, err
+			}
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return rows, result, ds, err
+			case <-timer.C:
+			}
+			wait *= 2
+		}
+	}
+}
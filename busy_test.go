@@ -0,0 +1,142 @@
+// Copyright 2024 Canonical Ltd.
+// Licensed under Apache 2.0, see LICENCE file for details.
+
+package sqlair
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	. "gopkg.in/check.v1"
+
+	"github.com/canonical/sqlair/internal/expr"
+)
+
+type BusySuite struct{}
+
+var _ = Suite(&BusySuite{})
+
+func isSQLiteBusy(err error) bool {
+	var sqliteErr sqlite3.Error
+	return errors.As(err, &sqliteErr) && sqliteErr.Code == sqlite3.ErrBusy
+}
+
+func (s *BusySuite) TestRetry(c *C) {
+	errBusy := errors.New("busy")
+	errOther := errors.New("other")
+	isBusy := func(err error) bool { return err == errBusy }
+	tests := []struct {
+		summary  string
+		retry    *busyRetry
+		errs     []error
+		attempts int
+		err      error
+	}{{
+		summary:  "no retry policy",
+		retry:    nil,
+		errs:     []error{errBusy, nil},
+		attempts: 1,
+		err:      errBusy,
+	}, {
+		summary:  "success after busy",
+		retry:    &busyRetry{maxAttempts: 3, isBusy: isBusy},
+		errs:     []error{errBusy, errBusy, nil},
+		attempts: 3,
+		err:      nil,
+	}, {
+		summary:  "attempts exhausted",
+		retry:    &busyRetry{maxAttempts: 2, isBusy: isBusy},
+		errs:     []error{errBusy, errBusy, nil},
+		attempts: 2,
+		err:      errBusy,
+	}, {
+		summary:  "other errors are not retried",
+		retry:    &busyRetry{maxAttempts: 3, isBusy: isBusy},
+		errs:     []error{errOther, nil},
+		attempts: 1,
+		err:      errOther,
+	}}
+	for i, t := range tests {
+		attempts := 0
+		run := func(ctx context.Context, _ *expr.PrimedQuery) (*sql.Rows, sql.Result, *driverStmt, error) {
+			err := t.errs[attempts]
+			attempts++
+			return nil, nil, nil, err
+		}
+		_, _, _, err := t.retry.retry(run)(context.Background(), nil)
+		c.Check(err, Equals, t.err, Commentf("test %d failed:\nsummary: %s", i, t.summary))
+		c.Check(attempts, Equals, t.attempts, Commentf("test %d failed:\nsummary: %s", i, t.summary))
+	}
+
+	// The wait ends when the context is done.
+	ctx, cancel := context.WithCancel(context.Background())
+	attempts := 0
+	run := func(ctx context.Context, _ *expr.PrimedQuery) (*sql.Rows, sql.Result, *driverStmt, error) {
+		attempts++
+		cancel()
+		return nil, nil, nil, errBusy
+	}
+	br := &busyRetry{maxAttempts: 3, backoff: time.Hour, isBusy: isBusy}
+	_, _, _, err := br.retry(run)(ctx, nil)
+	c.Check(err, Equals, errBusy)
+	c.Check(attempts, Equals, 1)
+}
+
+func (s *BusySuite) TestWithBusyRetry(c *C) {
+	// A busy timeout of zero makes SQLite return SQLITE_BUSY immediately.
+	dsn := "file:" + filepath.Join(c.MkDir(), "busy.db") + "?_busy_timeout=0"
+	sqldb, err := sql.Open("sqlite3", dsn)
+	c.Assert(err, IsNil)
+	defer sqldb.Close()
+	lockdb, err := sql.Open("sqlite3", dsn)
+	c.Assert(err, IsNil)
+	defer lockdb.Close()
+
+	_, err = sqldb.Exec("CREATE TABLE t (id integer)")
+	c.Assert(err, IsNil)
+
+	type T struct {
+		ID int `db:"id"`
+	}
+	stmt := MustPrepare("INSERT INTO t (*) VALUES ($T.*)", T{})
+
+	// lock takes the write lock of the database from another connection and
+	// returns a function that releases it.
+	lock := func() func() {
+		tx, err := lockdb.Begin()
+		c.Assert(err, IsNil)
+		_, err = tx.Exec("INSERT INTO t (id) VALUES (0)")
+		c.Assert(err, IsNil)
+		return func() { c.Check(tx.Rollback(), IsNil) }
+	}
+
+	// Without the option the insert fails while the database is locked.
+	release := lock()
+	err = NewDB(sqldb).Query(nil, stmt, T{ID: 1}).Run()
+	c.Check(isSQLiteBusy(err), Equals, true, Commentf("error: %v", err))
+	release()
+
+	// With the option the insert is retried until the lock is released.
+	attempts := 0
+	db := NewDB(sqldb, WithBusyRetry(10, 10*time.Millisecond, func(err error) bool {
+		attempts++
+		return isSQLiteBusy(err)
+	}))
+	release = lock()
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		release()
+	}()
+	err = db.Query(nil, stmt, T{ID: 1}).Run()
+	c.Assert(err, IsNil)
+	c.Check(attempts > 0, Equals, true)
+
+	var got []T
+	err = db.Query(nil, MustPrepare("SELECT &T.* FROM t", T{})).GetAll(&got)
+	c.Assert(err, IsNil)
+	c.Check(got, DeepEquals, []T{{ID: 1}})
+}
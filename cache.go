@@ -6,6 +6,7 @@ package sqlair
 import (
 	"context"
 	"database/sql"
+	"fmt"
 	"runtime"
 	"sync"
 	"sync/atomic"
@@ -31,7 +32,9 @@ import (
 // sqlair.Statement pair. If the sqlair.Statement is re-prepared with different
 // generated SQL then the previous sql.Stmt is evicted from the cache. A
 // finalizer is set on the evicted sql.Stmt to ensure it is closed once all
-// references die.
+// references die. If the sqlair.DB has a maximum number of cached statements,
+// the least recently used sql.Stmt of the DB is evicted in the same way to
+// make room for a new one.
 //
 // The mutex must be locked when accessing either the stmtDBCache or the
 // dbStmtCache.
@@ -51,6 +54,16 @@ type statementCache struct {
 	stmtIDCount uint64
 	dbIDCount   uint64
 
+	// hits and misses count the queries that found a driver statement in
+	// the cache and those that had to prepare one.
+	hits   uint64
+	misses uint64
+
+	// useClock is a counter incremented each time a cached driver statement
+	// is used, to order the statements of a DB from least to most recently
+	// used.
+	useClock uint64
+
 	mutex sync.RWMutex
 }
 
@@ -58,6 +71,9 @@ type statementCache struct {
 type driverStmt struct {
 	stmt *sql.Stmt
 	sql  string
+	// lastUsed is the value of the cache use clock when the statement was
+	// last used. It is accessed atomically.
+	lastUsed uint64
 }
 
 var once sync.Once
@@ -102,6 +118,35 @@ func (sc *statementCache) newDB(sqldb *sql.DB) *DB {
 	return db
 }
 
+// CacheState describes whether running a [Query] will use a driver prepared
+// statement from the statement cache.
+type CacheState int
+
+const (
+	// CacheUncacheable means the query will not use the statement cache, for
+	// example because it is invalid or it is run on a transaction without a
+	// statement already prepared on the DB.
+	CacheUncacheable CacheState = iota
+	// CacheHit means the query will reuse a cached driver prepared statement.
+	CacheHit
+	// CacheMiss means the query will prepare a new driver statement and add
+	// it to the cache.
+	CacheMiss
+)
+
+// String returns the name of the cache state.
+func (cs CacheState) String() string {
+	switch cs {
+	case CacheHit:
+		return "hit"
+	case CacheMiss:
+		return "miss"
+	case CacheUncacheable:
+		return "uncacheable"
+	}
+	return fmt.Sprintf("CacheState(%d)", int(cs))
+}
+
 // lookupStmt checks if a Statement has been prepared on the db driver with the
 // given primedSQL. If it has, the driverStmt is returned.
 func (sc *statementCache) lookupStmt(db *DB, s *Statement, primedSQL string) (dStmt *driverStmt, ok bool) {
@@ -119,12 +164,139 @@ func (sc *statementCache) lookupStmt(db *DB, s *Statement, primedSQL string) (dS
 	return ds, ok
 }
 
+// maxCachedStatements is a DBOption that bounds the number of driver
+// statements cached for the database.
+type maxCachedStatements int
+
+// applyToDB sets the maximum number of cached statements of the database.
+func (n maxCachedStatements) applyToDB(db *DB) {
+	db.maxCachedStmts = int(n)
+}
+
+// WithMaxCachedStatements returns a [DBOption] that limits the number of
+// driver prepared statements kept in the statement cache for the [DB]. When a
+// query prepares a new statement and the limit is reached, the least recently
+// used statement of the DB is removed from the cache. It is closed once no
+// running query uses it. Without the option, or with a limit of zero or less,
+// statements stay in the cache until the [Statement] or the DB is garbage
+// collected. The limit also applies to the statements prepared on each [Conn]
+// of the DB.
+func WithMaxCachedStatements(n int) DBOption {
+	return maxCachedStatements(n)
+}
+
+// markUsed records that the driver statement has just been used, making it
+// the most recently used statement of its DB.
+func (sc *statementCache) markUsed(ds *driverStmt) {
+	atomic.StoreUint64(&ds.lastUsed, atomic.AddUint64(&sc.useClock, 1))
+}
+
+// evictLeastRecentlyUsed removes the least recently used driver statements
+// of the database from the cache until fewer than the maximum remain, making
+// room for a new one. A finalizer is set on each evicted statement to close it
+// once concurrent users have finished with it. The mutex must be held.
+func (sc *statementCache) evictLeastRecentlyUsed(db *DB) {
+	if db.maxCachedStmts <= 0 {
+		return
+	}
+	stmtCache := sc.dbStmtCache[db.cacheID]
+	for len(stmtCache) >= db.maxCachedStmts {
+		var lruID uint64
+		var lru *driverStmt
+		for statementCacheID := range stmtCache {
+			ds := sc.stmtDBCache[statementCacheID][db.cacheID]
+			if lru == nil || atomic.LoadUint64(&ds.lastUsed) < atomic.LoadUint64(&lru.lastUsed) {
+				lruID, lru = statementCacheID, ds
+			}
+		}
+		runtime.SetFinalizer(lru, closeDriverStmt)
+		delete(sc.stmtDBCache[lruID], db.cacheID)
+		delete(stmtCache, lruID)
+	}
+}
+
+// countLookup records in the cache statistics whether a query found its driver
+// statement in the cache.
+func (sc *statementCache) countLookup(hit bool) {
+	if hit {
+		atomic.AddUint64(&sc.hits, 1)
+	} else {
+		atomic.AddUint64(&sc.misses, 1)
+	}
+}
+
+// CacheStats returns the number of driver prepared statements held in the
+// statement cache across all databases, along with the number of queries
+// that have reused a cached statement (hits) and the number that have
+// prepared a new one (misses) since the program started. Queries that do not
+// use the cache, such as queries on a transaction without a statement already
+// prepared on the [DB], are not counted.
+func CacheStats() (entries int, hits, misses uint64) {
+	stmtCache.mutex.RLock()
+	for _, dbCache := range stmtCache.stmtDBCache {
+		entries += len(dbCache)
+	}
+	stmtCache.mutex.RUnlock()
+	return entries, atomic.LoadUint64(&stmtCache.hits), atomic.LoadUint64(&stmtCache.misses)
+}
+
+// ClearStatementCache removes from the statement cache all the driver
+// statements prepared on the database. Later queries prepare their
+// statements again. A statement still in use by a query that is running is
+// closed once the query is finished with it, so clearing the cache does not
+// interrupt running queries.
+func (db *DB) ClearStatementCache() {
+	stmtCache.removeDBStmts(db)
+}
+
+// PrepareContext prepares the statement on the database ahead of its first
+// query and stores the driver prepared statement in the statement cache, so
+// that the first query run with it does not wait for the database to prepare
+// it. If the statement is already prepared on the database nothing is done.
+// It is safe to call concurrently with queries and other calls to
+// PrepareContext.
+//
+// The SQL sent to the database can depend on the input arguments, for
+// example on the length of a slice input or the keys of a map. The SQL is
+// generated from inputArgs, which are passed as to [DB.Query], so they should
+// be like the arguments of the queries to come.
+func (db *DB) PrepareContext(ctx context.Context, s *Statement, inputArgs ...any) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	pq, err := s.te.BindInputsWithDialect(s.dialectOn(db), inputArgs...)
+	if err != nil {
+		return err
+	}
+	if _, ok := stmtCache.lookupStmt(db, s, pq.SQL()); ok {
+		return nil
+	}
+	_, err = stmtCache.driverPrepareStmt(ctx, db, s, pq.SQL())
+	return err
+}
+
+// removeDBStmts removes all sql.Stmt objects prepared on the database from
+// the cache and sets a finalizer on each to close it once concurrent users
+// have finished with it. The database itself stays in the cache.
+func (sc *statementCache) removeDBStmts(db *DB) {
+	sc.mutex.Lock()
+	defer sc.mutex.Unlock()
+	for statementCacheID := range sc.dbStmtCache[db.cacheID] {
+		dbCache := sc.stmtDBCache[statementCacheID]
+		runtime.SetFinalizer(dbCache[db.cacheID], closeDriverStmt)
+		delete(dbCache, db.cacheID)
+	}
+	sc.dbStmtCache[db.cacheID] = map[uint64]bool{}
+}
+
 // driverPrepareStatement prepares a statement on the database and then stores
 // the prepared driverStmt in the cache.
 func (sc *statementCache) driverPrepareStmt(ctx context.Context, db *DB, s *Statement, primedSQL string) (*driverStmt, error) {
-	sqlstmt, err := db.sqldb.PrepareContext(ctx, primedSQL)
+	prepareCtx, cancel := db.prepareContext(ctx)
+	defer cancel()
+	sqlstmt, err := db.sqldb.PrepareContext(prepareCtx, primedSQL)
 	if err != nil {
-		return nil, err
+		return nil, prepareError(prepareCtx, err)
 	}
 
 	sc.mutex.Lock()
@@ -137,8 +309,12 @@ func (sc *statementCache) driverPrepareStmt(ctx context.Context, db *DB, s *Stat
 	// finished with it.
 	if ds, ok := sc.stmtDBCache[s.cacheID][db.cacheID]; ok {
 		runtime.SetFinalizer(ds, closeDriverStmt)
+		delete(sc.stmtDBCache[s.cacheID], db.cacheID)
+		delete(sc.dbStmtCache[db.cacheID], s.cacheID)
 	}
+	sc.evictLeastRecentlyUsed(db)
 	ds := &driverStmt{sql: primedSQL, stmt: sqlstmt}
+	sc.markUsed(ds)
 	sc.stmtDBCache[s.cacheID][db.cacheID] = ds
 	sc.dbStmtCache[db.cacheID][s.cacheID] = true
 	return ds, nil
@@ -160,6 +160,48 @@ func (s *CacheSuite) TestPreparedStatementsInTX(c *C) {
 	c.Assert(err, IsNil)
 }
 
+// TestStmtReusedInTX checks that a prepared statement is registered on a
+// transaction once and that the registered statements are dropped when the
+// transaction is done.
+func (s *CacheSuite) TestStmtReusedInTX(c *C) {
+	db := s.openDB(c)
+
+	stmt, err := Prepare(`SELECT 'test'`)
+	c.Assert(err, IsNil)
+
+	// Prepare the query on the database by running it.
+	err = db.Query(context.Background(), stmt).Run()
+	c.Assert(err, IsNil)
+	ds, ok := stmtCache.lookupStmt(db, stmt, "SELECT 'test'")
+	c.Assert(ok, Equals, true)
+
+	for _, done := range []func(tx *TX) error{(*TX).Commit, (*TX).Rollback} {
+		tx, err := db.Begin(context.Background(), nil)
+		c.Assert(err, IsNil)
+
+		err = tx.Query(context.Background(), stmt).Run()
+		c.Assert(err, IsNil)
+		c.Assert(tx.stmts, HasLen, 1)
+		txstmt := tx.stmts[ds.stmt]
+		c.Assert(txstmt, NotNil)
+
+		// Running the statement again reuses the registered statement.
+		for i := 0; i < 3; i++ {
+			err = tx.Query(context.Background(), stmt).Run()
+			c.Assert(err, IsNil)
+		}
+		c.Assert(tx.stmts, HasLen, 1)
+		c.Assert(tx.stmts[ds.stmt], Equals, txstmt)
+
+		err = done(tx)
+		c.Assert(err, IsNil)
+		c.Assert(tx.stmts, IsNil)
+
+		err = tx.Query(context.Background(), stmt).Run()
+		c.Assert(err, Equals, ErrTXDone)
+	}
+}
+
 // TestLateQuery checks that a Query that outlives a Statement does not throw a
 // statement is closed error.
 func (s *CacheSuite) TestLateQuery(c *C) {
@@ -248,6 +290,217 @@ CREATE TABLE t (
 	c.Assert(colsFromDB, DeepEquals, []dbCol{{Col: 2}, {Col: 4}})
 }
 
+func (s *CacheSuite) TestQueryCacheState(c *C) {
+	db := s.openDB(c)
+
+	stmt, err := Prepare(`SELECT 'test'`)
+	c.Assert(err, IsNil)
+	q := db.Query(nil, stmt)
+	c.Check(q.CacheState(), Equals, CacheMiss)
+	c.Assert(q.Run(), IsNil)
+	c.Check(db.Query(nil, stmt).CacheState(), Equals, CacheHit)
+
+	// The SQL generated for a statement with a slice input depends on the
+	// length of the slice.
+	type ints []int
+	sliceStmt, err := Prepare(`SELECT 'test' WHERE 1 IN ($ints[:])`, ints{})
+	c.Assert(err, IsNil)
+	err = db.Query(nil, sliceStmt, ints{1, 2}).Run()
+	c.Assert(err, IsNil)
+	c.Check(db.Query(nil, sliceStmt, ints{3, 4}).CacheState(), Equals, CacheHit)
+	c.Check(db.Query(nil, sliceStmt, ints{1, 2, 3}).CacheState(), Equals, CacheMiss)
+
+	// Invalid queries do not use the cache.
+	c.Check(db.Query(nil, sliceStmt).CacheState(), Equals, CacheUncacheable)
+
+	// Transactions only use statements already prepared on the DB.
+	otherStmt, err := Prepare(`SELECT 'other'`)
+	c.Assert(err, IsNil)
+	tx, err := db.Begin(nil, nil)
+	c.Assert(err, IsNil)
+	c.Check(tx.Query(nil, stmt).CacheState(), Equals, CacheHit)
+	c.Check(tx.Query(nil, otherStmt).CacheState(), Equals, CacheUncacheable)
+	c.Assert(tx.Commit(), IsNil)
+}
+
+func (s *CacheSuite) TestCacheStats(c *C) {
+	db := s.openDB(c)
+	stmt, err := Prepare(`SELECT 'test'`)
+	c.Assert(err, IsNil)
+
+	entries, hits, misses := CacheStats()
+	c.Assert(db.Query(nil, stmt).Run(), IsNil)
+	c.Assert(db.Query(nil, stmt).Run(), IsNil)
+	newEntries, newHits, newMisses := CacheStats()
+	c.Check(newEntries, Equals, entries+1)
+	c.Check(newHits, Equals, hits+1)
+	c.Check(newMisses, Equals, misses+1)
+
+	// Queries on a transaction count as hits when they use a statement
+	// prepared on the DB, and are not counted otherwise.
+	otherStmt, err := Prepare(`SELECT 'other'`)
+	c.Assert(err, IsNil)
+	tx, err := db.Begin(nil, nil)
+	c.Assert(err, IsNil)
+	c.Assert(tx.Query(nil, stmt).Run(), IsNil)
+	c.Assert(tx.Query(nil, otherStmt).Run(), IsNil)
+	c.Assert(tx.Commit(), IsNil)
+	_, hits, misses = CacheStats()
+	c.Check(hits, Equals, newHits+1)
+	c.Check(misses, Equals, newMisses)
+}
+
+func (s *CacheSuite) TestClearStatementCache(c *C) {
+	db := s.openDB(c)
+	otherDB := s.openDB(c)
+	stmt, err := Prepare(`SELECT 'test'`)
+	c.Assert(err, IsNil)
+	c.Assert(db.Query(nil, stmt).Run(), IsNil)
+	c.Assert(otherDB.Query(nil, stmt).Run(), IsNil)
+
+	// A query started before the cache is cleared can still be run.
+	iter := db.Query(nil, stmt).Iter()
+
+	db.ClearStatementCache()
+	s.checkNumDBStmts(c, db.cacheID, 0)
+	s.checkNumDBStmts(c, otherDB.cacheID, 1)
+	c.Check(db.Query(nil, stmt).CacheState(), Equals, CacheMiss)
+	c.Check(otherDB.Query(nil, stmt).CacheState(), Equals, CacheHit)
+
+	s.triggerFinalizers()
+	for iter.Next() {
+	}
+	c.Assert(iter.Close(), IsNil)
+
+	// The evicted statement is closed once the query is done with it.
+	iter = nil
+	s.triggerFinalizers()
+	stmtRegistryMutex.RLock()
+	c.Check(closedStmts[c.TestName()], HasLen, 1)
+	stmtRegistryMutex.RUnlock()
+
+	// The statement is prepared again. The statement on the other DB is
+	// still cached.
+	c.Assert(db.Query(nil, stmt).Run(), IsNil)
+	s.checkNumDBStmts(c, db.cacheID, 1)
+	c.Check(otherDB.Query(nil, stmt).CacheState(), Equals, CacheHit)
+}
+
+func (s *CacheSuite) TestMaxCachedStatements(c *C) {
+	sqldb, err := sql.Open("sqlite3_stmtChecked", "file:test.db?cache=shared&mode=memory&testName="+c.TestName())
+	c.Assert(err, IsNil)
+	db := NewDB(sqldb, WithMaxCachedStatements(2))
+
+	stmt1, err := Prepare(`SELECT 1`)
+	c.Assert(err, IsNil)
+	stmt2, err := Prepare(`SELECT 2`)
+	c.Assert(err, IsNil)
+	stmt3, err := Prepare(`SELECT 3`)
+	c.Assert(err, IsNil)
+
+	c.Assert(db.Query(nil, stmt1).Run(), IsNil)
+	c.Assert(db.Query(nil, stmt2).Run(), IsNil)
+	// Use stmt1 again so that stmt2 is the least recently used.
+	c.Assert(db.Query(nil, stmt1).Run(), IsNil)
+	c.Assert(db.Query(nil, stmt3).Run(), IsNil)
+	s.checkNumDBStmts(c, db.cacheID, 2)
+	s.checkStmtInCache(c, db.cacheID, stmt1.cacheID)
+	s.checkStmtInCache(c, db.cacheID, stmt3.cacheID)
+	c.Check(db.Query(nil, stmt2).CacheState(), Equals, CacheMiss)
+
+	// The evicted statement is closed.
+	s.triggerFinalizers()
+	stmtRegistryMutex.RLock()
+	c.Check(closedStmts[c.TestName()], HasLen, 1)
+	stmtRegistryMutex.RUnlock()
+	runtime.KeepAlive(stmt1)
+
+	// Preparing a statement again with different SQL replaces it without
+	// evicting another statement.
+	type ints []int
+	sliceStmt, err := Prepare(`SELECT 4 WHERE 1 IN ($ints[:])`, ints{})
+	c.Assert(err, IsNil)
+	c.Assert(db.Query(nil, sliceStmt, ints{1}).Run(), IsNil)
+	c.Assert(db.Query(nil, stmt3).Run(), IsNil)
+	c.Assert(db.Query(nil, sliceStmt, ints{1, 2}).Run(), IsNil)
+	s.checkNumDBStmts(c, db.cacheID, 2)
+	s.checkStmtInCache(c, db.cacheID, stmt3.cacheID)
+	s.checkStmtInCache(c, db.cacheID, sliceStmt.cacheID)
+}
+
+func (s *CacheSuite) TestMaxCachedStatementsConn(c *C) {
+	sqldb, err := sql.Open("sqlite3_stmtChecked", "file:test.db?cache=shared&mode=memory&testName="+c.TestName())
+	c.Assert(err, IsNil)
+	db := NewDB(sqldb, WithMaxCachedStatements(2))
+	conn, err := db.Conn(context.Background())
+	c.Assert(err, IsNil)
+
+	stmt1, err := Prepare(`SELECT 1`)
+	c.Assert(err, IsNil)
+	stmt2, err := Prepare(`SELECT 2`)
+	c.Assert(err, IsNil)
+	stmt3, err := Prepare(`SELECT 3`)
+	c.Assert(err, IsNil)
+
+	c.Assert(conn.Query(nil, stmt1).Run(), IsNil)
+	c.Assert(conn.Query(nil, stmt2).Run(), IsNil)
+	// Use stmt1 again so that stmt2 is the least recently used.
+	c.Assert(conn.Query(nil, stmt1).Run(), IsNil)
+	c.Assert(conn.Query(nil, stmt3).Run(), IsNil)
+	conn.stmtsMutex.Lock()
+	c.Check(conn.stmts, HasLen, 2)
+	conn.stmtsMutex.Unlock()
+	c.Check(conn.Query(nil, stmt1).CacheState(), Equals, CacheHit)
+	c.Check(conn.Query(nil, stmt2).CacheState(), Equals, CacheMiss)
+	c.Check(conn.Query(nil, stmt3).CacheState(), Equals, CacheHit)
+
+	// The evicted statement is closed.
+	s.triggerFinalizers()
+	stmtRegistryMutex.RLock()
+	c.Check(closedStmts[c.TestName()], HasLen, 1)
+	stmtRegistryMutex.RUnlock()
+
+	c.Assert(conn.Close(), IsNil)
+	stmtRegistryMutex.RLock()
+	c.Check(closedStmts[c.TestName()], HasLen, 3)
+	stmtRegistryMutex.RUnlock()
+}
+
+func (s *CacheSuite) TestPrepareContext(c *C) {
+	db := s.openDB(c)
+	stmt, err := Prepare(`SELECT 'test'`)
+	c.Assert(err, IsNil)
+
+	c.Assert(db.PrepareContext(context.Background(), stmt), IsNil)
+	s.checkStmtInCache(c, db.cacheID, stmt.cacheID)
+	s.checkDriverStmtsOpened(c, 1)
+	c.Check(db.Query(nil, stmt).CacheState(), Equals, CacheHit)
+
+	// Preparing again, even concurrently, reuses the driver statement.
+	done := make(chan error)
+	for i := 0; i < 5; i++ {
+		go func() {
+			done <- db.PrepareContext(context.Background(), stmt)
+		}()
+	}
+	for i := 0; i < 5; i++ {
+		c.Assert(<-done, IsNil)
+	}
+	c.Assert(db.Query(nil, stmt).Run(), IsNil)
+	s.checkDriverStmtsOpened(c, 1)
+	s.checkQueriesRunOnStmt(c, 1)
+
+	// The SQL of a statement with a slice input depends on the input
+	// arguments.
+	sliceStmt, err := Prepare(`SELECT 'test' WHERE 1 IN ($S[:])`, S{})
+	c.Assert(err, IsNil)
+	err = db.PrepareContext(context.Background(), sliceStmt)
+	c.Check(err, ErrorMatches, `invalid input parameter: parameter with type "S" missing`)
+	c.Assert(db.PrepareContext(context.Background(), sliceStmt, S{1, 2}), IsNil)
+	c.Check(db.Query(nil, sliceStmt, S{3, 4}).CacheState(), Equals, CacheHit)
+	c.Check(db.Query(nil, sliceStmt, S{3}).CacheState(), Equals, CacheMiss)
+}
+
 func (s *CacheSuite) openDB(c *C) *DB {
 	db, err := sql.Open("sqlite3_stmtChecked", "file:test.db?cache=shared&mode=memory&testName="+c.TestName())
 	c.Assert(err, IsNil)
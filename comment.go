@@ -0,0 +1,119 @@
+// Copyright 2024 Canonical Ltd.
+// Licensed under Apache 2.0, see LICENCE file for details.
+
+package sqlair
+
+import (
+	"context"
+	"database/sql"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/canonical/sqlair/internal/expr"
+)
+
+// sqlComment is a DBOption that sets the function providing the key-values of
+// the SQL comment appended to queries.
+type sqlComment func(ctx context.Context) map[string]string
+
+// applyToDB sets the SQL comment function of the database.
+func (sc sqlComment) applyToDB(db *DB) {
+	db.sqlComment = sc
+}
+
+// WithSQLComment returns a [DBOption] that appends a comment with metadata to
+// the SQL of every query run on the database, following the sqlcommenter
+// convention. The function is called with the context of the query each time
+// it is run, and the key-values it returns are appended as a trailing comment
+// of the form:
+//
+//	/*key1='value1',key2='value2'*/
+//
+// The keys are sorted and the keys and values are URL encoded, so they cannot
+// end the comment or change the meaning of the query. If the function returns
+// no key-values nothing is appended.
+//
+// Since the comment may change with each query, queries with a comment are
+// not run with driver prepared statements from the statement cache.
+func WithSQLComment(fn func(ctx context.Context) map[string]string) DBOption {
+	return sqlComment(fn)
+}
+
+// traceIDKey is the context key of the trace ID set with WithTraceID.
+type traceIDKey struct{}
+
+// traceIDCommentKey is the key of the trace ID in the SQL comment.
+const traceIDCommentKey = "trace_id"
+
+// WithTraceID returns a copy of ctx carrying a trace ID. Queries run with the
+// context, or a context derived from it, have the trace ID appended to their
+// SQL in a comment of the form:
+//
+//	/*trace_id='id'*/
+//
+// so that they can be correlated with the database logs. The trace ID is
+// added to the key-values of the comment set with [WithSQLComment], if any,
+// unless the function returns its own "trace_id" key. As with WithSQLComment,
+// queries with a trace ID are not run with driver prepared statements from the
+// statement cache.
+func WithTraceID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, traceIDKey{}, id)
+}
+
+// commentFor returns the SQL comment to append to a query run with the given
+// context, or the empty string if there is none.
+func (db *DB) commentFor(ctx context.Context) string {
+	var kvs map[string]string
+	if db.sqlComment != nil {
+		kvs = db.sqlComment(ctx)
+	}
+	if id, ok := ctx.Value(traceIDKey{}).(string); ok && id != "" {
+		if _, ok := kvs[traceIDCommentKey]; !ok {
+			withID := map[string]string{traceIDCommentKey: id}
+			for k, v := range kvs {
+				withID[k] = v
+			}
+			kvs = withID
+		}
+	}
+	return formatSQLComment(kvs)
+}
+
+// formatSQLComment formats the key-values as a sqlcommenter comment, preceded
+// by a space. The empty string is returned if there are no key-values.
+func formatSQLComment(kvs map[string]string) string {
+	if len(kvs) == 0 {
+		return ""
+	}
+	var pairs []string
+	for k, v := range kvs {
+		pairs = append(pairs, commentEscape(k)+"='"+commentEscape(v)+"'")
+	}
+	sort.Strings(pairs)
+	return " /*" + strings.Join(pairs, ",") + "*/"
+}
+
+// commentEscape URL encodes a key or value of a SQL comment. The encoded
+// string only contains letters, digits and the characters "-_.~%".
+func commentEscape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+// plainQueryer is implemented by *sql.DB, *sql.Tx and *sql.Conn.
+type plainQueryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// runCommented runs the primed query with the comment appended to its SQL
+// without using a driver prepared statement.
+func runCommented(ctx context.Context, pq *expr.PrimedQuery, comment string, queryer plainQueryer) (rows *sql.Rows, result sql.Result, err error) {
+	commentedSQL := pq.SQL() + comment
+	if pq.HasOutputs() {
+		rows, err = queryer.QueryContext(ctx, commentedSQL, pq.Params()...)
+	} else {
+		result, err = queryer.ExecContext(ctx, commentedSQL, pq.Params()...)
+	}
+	return rows, result, err
+}
@@ -0,0 +1,114 @@
+// Copyright 2024 Canonical Ltd.
+// Licensed under Apache 2.0, see LICENCE file for details.
+
+package sqlair
+
+import (
+	"context"
+	"database/sql"
+
+	. "gopkg.in/check.v1"
+)
+
+type CommentSuite struct{}
+
+var _ = Suite(&CommentSuite{})
+
+func (s *CommentSuite) TestFormatSQLComment(c *C) {
+	tests := []struct {
+		kvs      map[string]string
+		expected string
+	}{{
+		kvs:      nil,
+		expected: "",
+	}, {
+		kvs:      map[string]string{"route": "/users/{id}", "action": "get"},
+		expected: " /*action='get',route='%2Fusers%2F%7Bid%7D'*/",
+	}, {
+		kvs:      map[string]string{"a b": "it's */ DROP TABLE t; --"},
+		expected: " /*a%20b='it%27s%20%2A%2F%20DROP%20TABLE%20t%3B%20--'*/",
+	}}
+	for i, t := range tests {
+		c.Check(formatSQLComment(t.kvs), Equals, t.expected, Commentf("test %d", i))
+	}
+}
+
+type commentKey struct{}
+
+func (s *CommentSuite) TestWithSQLComment(c *C) {
+	sqldb, err := sql.Open("sqlite3_stmtChecked", "file:comment.db?cache=shared&mode=memory&testName="+c.TestName())
+	c.Assert(err, IsNil)
+	db := NewDB(sqldb, WithSQLComment(func(ctx context.Context) map[string]string {
+		if v, ok := ctx.Value(commentKey{}).(string); ok {
+			return map[string]string{"request": v}
+		}
+		return nil
+	}))
+
+	type Row struct {
+		N int `db:"n"`
+	}
+	stmt := MustPrepare("SELECT n AS &Row.n FROM (SELECT $Row.n AS n)", Row{})
+
+	// Queries with a comment are not prepared.
+	ctx := context.WithValue(context.Background(), commentKey{}, "abc")
+	q := db.Query(ctx, stmt, Row{N: 1})
+	c.Check(q.CacheState(), Equals, CacheUncacheable)
+	var row Row
+	c.Assert(q.Get(&row), IsNil)
+	c.Check(row.N, Equals, 1)
+	stmtRegistryMutex.RLock()
+	c.Check(openedStmts[c.TestName()], HasLen, 0)
+	stmtRegistryMutex.RUnlock()
+
+	// Queries without a comment use the statement cache.
+	q = db.Query(nil, stmt, Row{N: 2})
+	c.Check(q.CacheState(), Equals, CacheMiss)
+	c.Assert(q.Get(&row), IsNil)
+	c.Check(row.N, Equals, 2)
+	stmtRegistryMutex.RLock()
+	c.Check(openedStmts[c.TestName()], HasLen, 1)
+	stmtRegistryMutex.RUnlock()
+
+	// The comment is also added on transactions.
+	tx, err := db.Begin(ctx, nil)
+	c.Assert(err, IsNil)
+	q = tx.Query(ctx, stmt, Row{N: 3})
+	c.Check(q.CacheState(), Equals, CacheUncacheable)
+	c.Assert(q.Get(&row), IsNil)
+	c.Check(row.N, Equals, 3)
+	c.Assert(tx.Commit(), IsNil)
+}
+
+func (s *CommentSuite) TestWithTraceID(c *C) {
+	sqldb, err := sql.Open("sqlite3_stmtChecked", "file:comment.db?cache=shared&mode=memory&testName="+c.TestName())
+	c.Assert(err, IsNil)
+	ctx := WithTraceID(context.Background(), "4bf92f3577b34da6")
+
+	// The trace ID is the only key-value without a comment function.
+	db := NewDB(sqldb)
+	c.Check(db.commentFor(context.Background()), Equals, "")
+	c.Check(db.commentFor(ctx), Equals, " /*trace_id='4bf92f3577b34da6'*/")
+
+	// It is added to the key-values of the comment function unless the
+	// function sets it.
+	db = NewDB(sqldb, WithSQLComment(func(ctx context.Context) map[string]string {
+		if v, ok := ctx.Value(commentKey{}).(string); ok {
+			return map[string]string{"trace_id": v}
+		}
+		return map[string]string{"route": "/users"}
+	}))
+	c.Check(db.commentFor(ctx), Equals, " /*route='%2Fusers',trace_id='4bf92f3577b34da6'*/")
+	c.Check(db.commentFor(context.WithValue(ctx, commentKey{}, "own")), Equals, " /*trace_id='own'*/")
+
+	type Row struct {
+		N int `db:"n"`
+	}
+	stmt := MustPrepare("SELECT n AS &Row.n FROM (SELECT $Row.n AS n)", Row{})
+	db = NewDB(sqldb)
+	q := db.Query(ctx, stmt, Row{N: 1})
+	c.Check(q.CacheState(), Equals, CacheUncacheable)
+	var row Row
+	c.Assert(q.Get(&row), IsNil)
+	c.Check(row.N, Equals, 1)
+}
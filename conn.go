@@ -0,0 +1,177 @@
+// Copyright 2024 Canonical Ltd.
+// Licensed under Apache 2.0, see LICENCE file for details.
+
+package sqlair
+
+import (
+	"context"
+	"database/sql"
+	"runtime"
+	"sync"
+	"sync/atomic"
+
+	"github.com/canonical/sqlair/internal/expr"
+)
+
+// Conn is a single connection to the database. All queries run on a Conn use
+// the same connection, so session state such as temporary tables and session
+// variables is shared between them without the need for a transaction.
+//
+// A Conn must be closed with [Conn.Close] to return the connection to the
+// connection pool of the database.
+//
+// The driver statements prepared on the connection are kept until Close. If
+// the database was opened with [WithMaxCachedStatements], the same limit
+// applies to the statements of each Conn.
+type Conn struct {
+	sqlconn *sql.Conn
+	db      *DB
+
+	// stmtsMutex protects stmts.
+	stmtsMutex sync.Mutex
+	// stmts holds the driver prepared statements prepared on the connection,
+	// keyed by their SQL. database/sql has no way to run a statement
+	// prepared on the DB on a specific connection, so the statements are
+	// prepared again on the connection and closed along with it.
+	stmts map[string]*driverStmt
+}
+
+// Conn returns a single connection from the connection pool of the database.
+// The connection is held until [Conn.Close] is called.
+func (db *DB) Conn(ctx context.Context) (*Conn, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	sqlconn, err := db.sqldb.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &Conn{sqlconn: sqlconn, db: db, stmts: map[string]*driverStmt{}}, nil
+}
+
+// PlainConn returns the underlying connection object.
+func (c *Conn) PlainConn() *sql.Conn {
+	return c.sqlconn
+}
+
+// Close closes the driver prepared statements of the connection and returns
+// the connection to the connection pool. Queries run on the connection after
+// Close return [sql.ErrConnDone].
+func (c *Conn) Close() error {
+	c.stmtsMutex.Lock()
+	for primedSQL, ds := range c.stmts {
+		ds.stmt.Close()
+		delete(c.stmts, primedSQL)
+	}
+	c.stmtsMutex.Unlock()
+	return c.sqlconn.Close()
+}
+
+// prepareStmt returns the driver prepared statement for the SQL on the
+// connection, preparing it if needed.
+func (c *Conn) prepareStmt(ctx context.Context, primedSQL string) (*driverStmt, error) {
+	c.stmtsMutex.Lock()
+	defer c.stmtsMutex.Unlock()
+	if ds, ok := c.stmts[primedSQL]; ok {
+		stmtCache.markUsed(ds)
+		return ds, nil
+	}
+	prepareCtx, cancel := c.db.prepareContext(ctx)
+	defer cancel()
+	stmt, err := c.sqlconn.PrepareContext(prepareCtx, primedSQL)
+	if err != nil {
+		return nil, prepareError(prepareCtx, err)
+	}
+	c.evictLeastRecentlyUsed()
+	ds := &driverStmt{stmt: stmt, sql: primedSQL}
+	stmtCache.markUsed(ds)
+	c.stmts[primedSQL] = ds
+	return ds, nil
+}
+
+// evictLeastRecentlyUsed removes the least recently used driver statements of
+// the connection until fewer than the maximum of the database remain. As in
+// the statement cache of the database, a finalizer closes each evicted
+// statement once running queries have finished with it. The mutex must be
+// held.
+func (c *Conn) evictLeastRecentlyUsed() {
+	if c.db.maxCachedStmts <= 0 {
+		return
+	}
+	for len(c.stmts) >= c.db.maxCachedStmts {
+		var lru *driverStmt
+		for _, ds := range c.stmts {
+			if lru == nil || atomic.LoadUint64(&ds.lastUsed) < atomic.LoadUint64(&lru.lastUsed) {
+				lru = ds
+			}
+		}
+		runtime.SetFinalizer(lru, closeDriverStmt)
+		delete(c.stmts, lru.sql)
+	}
+}
+
+// Query builds a new query from a context, a [Statement] and the input
+// arguments. The query is run on the connection when one of [Query.Iter],
+// [Query.Run], [Query.Get] or [Query.GetAll] is executed.
+//
+// A new [Query] object should be created every time the statement is run on
+// the connection. The [Query] is designed to be used immediately and run once.
+func (c *Conn) Query(ctx context.Context, s *Statement, inputArgs ...any) *Query {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	pq, err := s.te.BindInputsWithDialect(s.dialectOn(c.db), inputArgs...)
+	if err != nil {
+		return &Query{ctx: ctx, err: err}
+	}
+
+	run := func(innerCtx context.Context, pq *expr.PrimedQuery) (rows *sql.Rows, result sql.Result, ds *driverStmt, err error) {
+		if comment := c.db.commentFor(innerCtx); comment != "" {
+			rows, result, err = runCommented(innerCtx, pq, comment, c.sqlconn)
+			return rows, result, nil, err
+		}
+		ds, err = c.prepareStmt(innerCtx, pq.SQL())
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		if pq.HasOutputs() {
+			rows, err = ds.stmt.QueryContext(innerCtx, pq.Params()...)
+		} else {
+			result, err = ds.stmt.ExecContext(innerCtx, pq.Params()...)
+		}
+		return rows, result, ds, err
+	}
+
+	cacheState := func(ctx context.Context) CacheState {
+		if c.db.commentFor(ctx) != "" {
+			return CacheUncacheable
+		}
+		c.stmtsMutex.Lock()
+		defer c.stmtsMutex.Unlock()
+		if _, ok := c.stmts[pq.SQL()]; ok {
+			return CacheHit
+		}
+		return CacheMiss
+	}
+
+	rebind := func(inputArgs []any) *Query {
+		return c.Query(ctx, s, inputArgs...)
+	}
+
+	return &Query{
+		pq:                  pq,
+		ctx:                 ctx,
+		run:                 c.db.busyRetry.retry(run),
+		cacheState:          cacheState,
+		err:                 nil,
+		inputArgs:           inputArgs,
+		sliceInputTypes:     s.te.SliceInputTypes(),
+		rebind:              rebind,
+		ignoreUnusedOutputs: s.ignoreUnusedOutputs,
+		observer:            c.db.observer,
+		transact: transactNew(c.db, s, func(ctx context.Context) (*sql.Tx, error) {
+			return c.sqlconn.BeginTx(ctx, nil)
+		}),
+	}
+}
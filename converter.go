@@ -0,0 +1,112 @@
+// Copyright 2024 Canonical Ltd.
+// Licensed under Apache 2.0, see LICENCE file for details.
+
+package sqlair
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"reflect"
+
+	"github.com/canonical/sqlair/internal/typeinfo"
+)
+
+// RegisterScanConverter registers a function that converts the values
+// returned by a driver into the type T. It is useful for types that do not
+// implement [sql.Scanner], such as decimal types from other packages, when the
+// driver returns a NUMERIC or DECIMAL column as a []byte or a string.
+//
+// When a query result is scanned into a struct field of type T, or *T, the
+// value is first read into an any and then passed, as returned by the driver,
+// to convert. For example:
+//
+//	sqlair.RegisterScanConverter(func(src any) (decimal.Decimal, error) {
+//		switch v := src.(type) {
+//		case []byte:
+//			return decimal.NewFromString(string(v))
+//		case string:
+//			return decimal.NewFromString(v)
+//		case float64:
+//			return decimal.NewFromFloat(v), nil
+//		case int64:
+//			return decimal.NewFromInt(v), nil
+//		}
+//		return decimal.Decimal{}, fmt.Errorf("unexpected type %T", src)
+//	})
+//
+// A NULL result sets the field to its zero value, or to nil for a pointer
+// field, without calling convert. A converter takes precedence over a
+// [sql.Scanner] implementation of T, but not over the "text" option of a db
+// tag. Converters do not apply to map values, which are returned as read by
+// the driver.
+//
+// Converters are global. They should be registered before any query uses
+// the type, for example in an init function. Registering a converter for a
+// type that already has one replaces it.
+func RegisterScanConverter[T any](convert func(src any) (T, error)) {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	typeinfo.RegisterScanConverter(t, func(src any) (any, error) {
+		return convert(src)
+	})
+}
+
+// RegisterInputConverter registers a function that converts query input
+// values of type T into the values passed to the driver. It is useful for
+// types that do not implement driver.Valuer, or that a driver does not
+// accept, such as unsigned integers that some drivers reject. For example:
+//
+//	sqlair.RegisterInputConverter(func(v uint64) (any, error) {
+//		if v > math.MaxInt64 {
+//			return nil, fmt.Errorf("value %d overflows int64", v)
+//		}
+//		return int64(v), nil
+//	})
+//
+// The converter applies to every query parameter of type T, whether it comes
+// from a struct field, a map value or a slice element. Pointers to T are not
+// converted. If convert returns an error the query fails with it.
+//
+// Converters are global. They should be registered before any query uses
+// the type, for example in an init function. Registering a converter for a
+// type that already has one replaces it.
+func RegisterInputConverter[T any](convert func(v T) (any, error)) {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	typeinfo.RegisterInputConverter(t, func(v any) (any, error) {
+		return convert(v.(T))
+	})
+}
+
+// RegisterConverter registers a pair of functions that convert values of type
+// T to and from the values passed to and returned by a driver. It is useful
+// to change how a type is stored without changing the types of struct fields,
+// for example to store time.Time as an integer number of nanoseconds so that
+// it round-trips without losing precision:
+//
+//	sqlair.RegisterConverter(
+//		func(t time.Time) (any, error) {
+//			return t.UnixNano(), nil
+//		},
+//		func(src any) (time.Time, error) {
+//			n, ok := src.(int64)
+//			if !ok {
+//				return time.Time{}, fmt.Errorf("unexpected type %T", src)
+//			}
+//			return time.Unix(0, n).UTC(), nil
+//		},
+//	)
+//
+// toDriver is registered as with [RegisterInputConverter] and fromDriver as
+// with [RegisterScanConverter]. Either may be nil to leave that direction
+// unchanged. Methods of T take precedence: if T implements [driver.Valuer]
+// toDriver is not registered, and if *T implements [sql.Scanner] fromDriver
+// is not registered. Fields of other types that implement these interfaces,
+// such as sql.NullTime, are not affected.
+func RegisterConverter[T any](toDriver func(v T) (any, error), fromDriver func(src any) (T, error)) {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	if toDriver != nil && !t.Implements(reflect.TypeOf((*driver.Valuer)(nil)).Elem()) {
+		RegisterInputConverter(toDriver)
+	}
+	if fromDriver != nil && !reflect.PointerTo(t).Implements(reflect.TypeOf((*sql.Scanner)(nil)).Elem()) {
+		RegisterScanConverter(fromDriver)
+	}
+}
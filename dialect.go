@@ -0,0 +1,137 @@
+// Copyright 2024 Canonical Ltd.
+// Licensed under Apache 2.0, see LICENCE file for details.
+
+package sqlair
+
+import (
+	"sync"
+
+	"github.com/canonical/sqlair/internal/expr"
+)
+
+// Dialect specifies the SQL dialect spoken by a database. It determines how
+// SQLair writes query parameters in the generated SQL and how they are passed
+// to the driver.
+//
+// A Dialect can be passed to [NewDB] to set the dialect of the database, or
+// passed to [Prepare] alongside the type samples to override the dialect of
+// the database when the [Statement] is run.
+type Dialect struct {
+	dialect *expr.Dialect
+}
+
+var (
+	// SQLite is the dialect of SQLite databases. Query parameters are
+	// written as "@sqlair_N" and passed to the driver as [sql.NamedArg]
+	// values. SQLite is the default dialect.
+	SQLite = Dialect{dialect: expr.SQLite}
+
+	// Postgres is the dialect of PostgreSQL databases. Query parameters are
+	// written as "$N" and passed to the driver in order as plain values.
+	Postgres = Dialect{dialect: expr.Postgres}
+
+	// MySQL is the dialect of MySQL and MariaDB databases. Query parameters
+	// are written as "?" and passed to the driver as plain values in the
+	// order they appear in the query.
+	MySQL = Dialect{dialect: expr.MySQL}
+)
+
+// String returns the name of the dialect.
+func (d Dialect) String() string {
+	if d.dialect == nil {
+		return ""
+	}
+	return d.dialect.String()
+}
+
+// WithMaxParams returns a copy of the dialect that allows at most n query
+// parameters. Running a query with more parameters than this returns an error
+// before the query is sent to the database. If n is zero or negative there is
+// no limit.
+//
+// By default, SQLite allows 32766 parameters, and Postgres and MySQL allow
+// 65535.
+// SQLite databases compiled with a lower SQLITE_MAX_VARIABLE_NUMBER should set
+// the limit accordingly.
+func (d Dialect) WithMaxParams(n int) Dialect {
+	if d.dialect == nil {
+		d = SQLite
+	}
+	return Dialect{dialect: d.dialect.WithMaxParams(n)}
+}
+
+// WithStatementTimeout returns a copy of the dialect that enforces the deadline
+// of the context of a query on the database server as well as on the client.
+// Before each query run on a [TX] whose context has a deadline, the
+// server-side statement timeout of the transaction is set to the time left
+// until the deadline, so that the server aborts the query even if the client
+// goes away. For Postgres this runs
+//
+//	SET LOCAL statement_timeout = <milliseconds>
+//
+// SET LOCAL only lasts until the end of the transaction, so the timeout is
+// only set for queries run on a TX. Queries run on a [DB] or a [Conn] are not
+// affected. If a later query on the same TX has no deadline the timeout is
+// reset to the server default.
+//
+// The option has no effect on dialects without server-side statement
+// timeouts, such as SQLite.
+func (d Dialect) WithStatementTimeout() Dialect {
+	if d.dialect == nil {
+		d = SQLite
+	}
+	return Dialect{dialect: d.dialect.WithStatementTimeout()}
+}
+
+// WithEmptySliceNull returns a copy of the dialect that writes an empty slice
+// input as NULL, so that
+//
+//	SELECT name FROM person WHERE id IN ($S[:])
+//
+// becomes "... WHERE id IN (NULL)" rather than "... WHERE id IN ()" when the
+// slice is empty. An empty IN list is valid in SQLite but a syntax error in
+// MySQL and Postgres. "IN (NULL)" matches no rows, as does "IN ()" in SQLite.
+// However "NOT IN (NULL)" also matches no rows, where "NOT IN ()" matches
+// every row, so queries using NOT IN with a slice that may be empty should
+// check for that case.
+func (d Dialect) WithEmptySliceNull() Dialect {
+	if d.dialect == nil {
+		d = SQLite
+	}
+	return Dialect{dialect: d.dialect.WithEmptySliceNull()}
+}
+
+// applyToDB sets the dialect of the database.
+func (d Dialect) applyToDB(db *DB) {
+	db.dialect = d.dialect
+}
+
+// applyToPrepare sets the dialect of the statement.
+func (d Dialect) applyToPrepare(opts *prepareOptions) {
+	opts.dialect = d.dialect
+}
+
+var defaultDialectMutex sync.RWMutex
+var defaultDialect = SQLite
+
+// SetDefaultDialect sets the dialect inherited by every [DB] subsequently
+// created with [NewDB] that is not given an explicit dialect. Databases that
+// have already been created are not affected.
+//
+// SetDefaultDialect is safe to call concurrently, but it is intended to be
+// called once during program initialisation, before any [DB] is created.
+func SetDefaultDialect(d Dialect) {
+	if d.dialect == nil {
+		d = SQLite
+	}
+	defaultDialectMutex.Lock()
+	defaultDialect = d
+	defaultDialectMutex.Unlock()
+}
+
+// getDefaultDialect returns the current default dialect.
+func getDefaultDialect() Dialect {
+	defaultDialectMutex.RLock()
+	defer defaultDialectMutex.RUnlock()
+	return defaultDialect
+}
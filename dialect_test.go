@@ -0,0 +1,198 @@
+// Copyright 2024 Canonical Ltd.
+// Licensed under Apache 2.0, see LICENCE file for details.
+
+package sqlair
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+type DialectSuite struct{}
+
+var _ = Suite(&DialectSuite{})
+
+func (s *DialectSuite) TearDownTest(c *C) {
+	SetDefaultDialect(SQLite)
+}
+
+func (s *DialectSuite) openDB(c *C, opts ...DBOption) *DB {
+	sqldb, err := sql.Open("sqlite3_stmtChecked", "file:dialect.db?cache=shared&mode=memory&testName="+c.TestName())
+	c.Assert(err, IsNil)
+	db := NewDB(sqldb, opts...)
+	create := MustPrepare("CREATE TABLE IF NOT EXISTS t (id integer, name text)")
+	c.Assert(db.Query(nil, create).Run(), IsNil)
+	return db
+}
+
+// checkPreparedSQL checks that a statement with the given SQL has been
+// prepared on the driver in this test.
+func (s *DialectSuite) checkPreparedSQL(c *C, expected string) {
+	stmtRegistryMutex.RLock()
+	defer stmtRegistryMutex.RUnlock()
+	for _, query := range openedStmts[c.TestName()] {
+		if query == expected {
+			return
+		}
+	}
+	c.Errorf("statement %q not prepared on the driver", expected)
+}
+
+type dialectRow struct {
+	ID   int    `db:"id"`
+	Name string `db:"name"`
+}
+
+func (s *DialectSuite) TestDefaultDialect(c *C) {
+	c.Check(getDefaultDialect(), Equals, SQLite)
+	SetDefaultDialect(Postgres)
+	c.Check(getDefaultDialect(), Equals, Postgres)
+
+	db := s.openDB(c)
+	c.Check(db.dialect, Equals, Postgres.dialect)
+
+	insert := MustPrepare("INSERT INTO t (*) VALUES ($dialectRow.*)", dialectRow{})
+	c.Assert(db.Query(nil, insert, dialectRow{ID: 1, Name: "Fred"}).Run(), IsNil)
+	s.checkPreparedSQL(c, "INSERT INTO t (id, name) VALUES ($1, $2)")
+
+	sel := MustPrepare("SELECT &dialectRow.* FROM t WHERE id = $dialectRow.id", dialectRow{})
+	var row dialectRow
+	c.Assert(db.Query(nil, sel, dialectRow{ID: 1}).Get(&row), IsNil)
+	c.Check(row, Equals, dialectRow{ID: 1, Name: "Fred"})
+	s.checkPreparedSQL(c, "SELECT id AS _sqlair_0, name AS _sqlair_1 FROM t WHERE id = $1")
+
+	// Databases created before the default is changed are not affected.
+	SetDefaultDialect(SQLite)
+	c.Check(db.dialect, Equals, Postgres.dialect)
+	c.Check(s.openDB(c).dialect, Equals, SQLite.dialect)
+}
+
+func (s *DialectSuite) TestDialectOverride(c *C) {
+	SetDefaultDialect(Postgres)
+
+	// The dialect passed to NewDB overrides the default.
+	db := s.openDB(c, SQLite)
+	c.Check(db.dialect, Equals, SQLite.dialect)
+
+	sel := MustPrepare("SELECT name FROM t WHERE id = $dialectRow.id", dialectRow{})
+	c.Assert(db.Query(nil, sel, dialectRow{ID: 1}).Run(), IsNil)
+	s.checkPreparedSQL(c, "SELECT name FROM t WHERE id = @sqlair_0")
+
+	// The dialect passed to Prepare overrides the dialect of the DB.
+	selPostgres, err := Prepare("SELECT name FROM t WHERE name = $dialectRow.name", Postgres, dialectRow{})
+	c.Assert(err, IsNil)
+	c.Check(selPostgres.dialect, Equals, Postgres.dialect)
+	c.Assert(db.Query(nil, selPostgres, dialectRow{Name: "Fred"}).Run(), IsNil)
+	s.checkPreparedSQL(c, "SELECT name FROM t WHERE name = $1")
+}
+
+func (s *DialectSuite) TestDialectWithMaxParams(c *C) {
+	db := s.openDB(c, SQLite.WithMaxParams(2))
+	c.Check(db.dialect.MaxParams(), Equals, 2)
+	c.Check(SQLite.dialect.MaxParams(), Equals, 32766)
+	c.Check(SQLite.WithMaxParams(2).String(), Equals, "SQLite")
+
+	type IDs []int
+	sel := MustPrepare("SELECT name FROM t WHERE id IN ($IDs[:])", IDs{})
+	c.Assert(db.Query(nil, sel, IDs{1, 2}).Run(), IsNil)
+	err := db.Query(nil, sel, IDs{1, 2, 3}).Run()
+	c.Assert(err, ErrorMatches, "invalid input parameter: query has 3 parameters, exceeds SQLite limit of 2; reduce slice size or batch")
+
+	// The limit of a dialect passed to Prepare overrides that of the DB.
+	sel = MustPrepare("SELECT name FROM t WHERE id IN ($IDs[:])", IDs{}, SQLite.WithMaxParams(0))
+	c.Assert(db.Query(nil, sel, IDs{1, 2, 3}).Run(), IsNil)
+}
+
+func (s *DialectSuite) TestDialectWithEmptySliceNull(c *C) {
+	sqldb, err := sql.Open("sqlite3_stmtChecked", "file:empty_slice.db?cache=shared&mode=memory&testName="+c.TestName())
+	c.Assert(err, IsNil)
+	defer sqldb.Close()
+	db := NewDB(sqldb, SQLite.WithEmptySliceNull())
+	create := MustPrepare("CREATE TABLE empty_slice (id integer, name text)")
+	c.Assert(db.Query(nil, create).Run(), IsNil)
+	insert := MustPrepare("INSERT INTO empty_slice (*) VALUES ($dialectRow.*)", dialectRow{})
+	c.Assert(db.Query(nil, insert, dialectRow{ID: 1, Name: "Fred"}).Run(), IsNil)
+
+	type IDs []int
+	sel := MustPrepare("SELECT &dialectRow.* FROM empty_slice WHERE id IN ($IDs[:])", dialectRow{}, IDs{})
+	var rows []dialectRow
+	err = db.Query(nil, sel, IDs{}).GetAll(&rows)
+	c.Assert(err, ErrorMatches, "sql: no rows in result set")
+	s.checkPreparedSQL(c, "SELECT id AS _sqlair_0, name AS _sqlair_1 FROM empty_slice WHERE id IN (NULL)")
+
+	err = db.Query(nil, sel, IDs{1}).GetAll(&rows)
+	c.Assert(err, IsNil)
+	c.Check(rows, DeepEquals, []dialectRow{{ID: 1, Name: "Fred"}})
+}
+
+func (s *DialectSuite) TestDialectWithStatementTimeout(c *C) {
+	c.Check(Postgres.WithStatementTimeout().String(), Equals, "Postgres")
+
+	// SQLite does not understand SET LOCAL, so the error shows that the
+	// timeout is set before the query.
+	db := s.openDB(c, Postgres.WithStatementTimeout())
+	sel := MustPrepare("SELECT name FROM t")
+	deadlineCtx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	tx, err := db.Begin(nil, nil)
+	c.Assert(err, IsNil)
+	err = tx.Query(deadlineCtx, sel).Run()
+	c.Check(err, ErrorMatches, `near "SET": syntax error`)
+	// A query without a deadline resets the timeout set by the earlier
+	// query.
+	err = tx.Query(context.Background(), sel).Run()
+	c.Check(err, ErrorMatches, `near "SET": syntax error`)
+	// The timeout has been reset, so it is not reset again.
+	err = tx.Query(context.Background(), sel).Run()
+	c.Check(err, IsNil)
+	c.Assert(tx.Rollback(), IsNil)
+
+	// Queries outside a transaction are not affected.
+	c.Check(db.Query(deadlineCtx, sel).Run(), IsNil)
+
+	// Dialects without statement timeouts are not affected.
+	tx, err = db.Begin(nil, nil)
+	c.Assert(err, IsNil)
+	c.Check(tx.Query(deadlineCtx, MustPrepare("SELECT name FROM t", SQLite.WithStatementTimeout())).Run(), IsNil)
+	c.Check(tx.Query(deadlineCtx, MustPrepare("SELECT name FROM t", Postgres)).Run(), IsNil)
+	c.Assert(tx.Rollback(), IsNil)
+}
+
+func (s *DialectSuite) TestMySQLDialect(c *C) {
+	c.Check(MySQL.String(), Equals, "MySQL")
+	c.Check(MySQL.dialect.MaxParams(), Equals, 65535)
+
+	// SQLite also accepts "?" placeholders, so the parameters can be checked
+	// against the database.
+	sqldb, err := sql.Open("sqlite3_stmtChecked", "file:mysql.db?cache=shared&mode=memory&testName="+c.TestName())
+	c.Assert(err, IsNil)
+	defer sqldb.Close()
+	db := NewDB(sqldb, MySQL)
+	create := MustPrepare("CREATE TABLE t (id integer, name text)")
+	c.Assert(db.Query(nil, create).Run(), IsNil)
+	insert := MustPrepare("INSERT INTO t (*) VALUES ($dialectRow.*)", dialectRow{})
+	c.Assert(db.Query(nil, insert, []dialectRow{{ID: 1, Name: "Fred"}, {ID: 2, Name: "Mark"}, {ID: 3, Name: "Mary"}}).Run(), IsNil)
+	s.checkPreparedSQL(c, "INSERT INTO t (id, name) VALUES (?, ?), (?, ?), (?, ?)")
+
+	type IDs []int
+	sel := MustPrepare("SELECT &dialectRow.* FROM t WHERE id IN ($IDs[:]) AND name <> $dialectRow.name AND id <> $dialectRow.id ORDER BY id", dialectRow{}, IDs{})
+	var rows []dialectRow
+	err = db.Query(nil, sel, IDs{1, 2, 3}, dialectRow{ID: 1, Name: "Mark"}).GetAll(&rows)
+	c.Assert(err, IsNil)
+	c.Check(rows, DeepEquals, []dialectRow{{ID: 3, Name: "Mary"}})
+	s.checkPreparedSQL(c, "SELECT id AS _sqlair_0, name AS _sqlair_1 FROM t WHERE id IN (?, ?, ?) AND name <> ? AND id <> ? ORDER BY id")
+
+	// A member shared between the SET clause and the WHERE clause is passed
+	// for each placeholder.
+	update := MustPrepare("UPDATE t SET (*) = ($dialectRow.*) WHERE id = $dialectRow.id", dialectRow{})
+	c.Assert(db.Query(nil, update, dialectRow{ID: 3, Name: "Maria"}).Run(), IsNil)
+	s.checkPreparedSQL(c, "UPDATE t SET id = ?, name = ? WHERE id = ?")
+	var row dialectRow
+	err = db.Query(nil, MustPrepare("SELECT &dialectRow.* FROM t WHERE id = 3", dialectRow{})).Get(&row)
+	c.Assert(err, IsNil)
+	c.Check(row, Equals, dialectRow{ID: 3, Name: "Maria"})
+}
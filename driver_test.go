@@ -39,7 +39,7 @@ type Driver struct {
 	driver.Driver
 }
 
-type Conn struct {
+type driverConn struct {
 	testName string
 	*sqlite3.SQLiteConn
 }
@@ -61,7 +61,7 @@ func (s *Stmt) Close() error {
 	return s.SQLiteStmt.Close()
 }
 
-func (c *Conn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+func (c *driverConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
 	s, err := c.SQLiteConn.PrepareContext(ctx, query)
 	if err != nil {
 		return nil, err
@@ -83,11 +83,11 @@ func (c *Conn) PrepareContext(ctx context.Context, query string) (driver.Stmt, e
 	}
 }
 
-func (c *Conn) Prepare(query string) (driver.Stmt, error) {
+func (c *driverConn) Prepare(query string) (driver.Stmt, error) {
 	return c.PrepareContext(context.Background(), query)
 }
 
-func (c *Conn) Query(query string, args []driver.Value) (driver.Rows, error) {
+func (c *driverConn) Query(query string, args []driver.Value) (driver.Rows, error) {
 	rows, err := c.SQLiteConn.Query(query, args)
 	if err == nil {
 		queriesRunMutex.Lock()
@@ -101,7 +101,7 @@ func (c *Conn) Query(query string, args []driver.Value) (driver.Rows, error) {
 	return rows, err
 }
 
-func (c *Conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+func (c *driverConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
 	rows, err := c.SQLiteConn.QueryContext(ctx, query, args)
 	if err == nil {
 		queriesRunMutex.Lock()
@@ -115,7 +115,7 @@ func (c *Conn) QueryContext(ctx context.Context, query string, args []driver.Nam
 	return rows, err
 }
 
-func (c *Conn) Exec(query string, args []driver.Value) (driver.Result, error) {
+func (c *driverConn) Exec(query string, args []driver.Value) (driver.Result, error) {
 	res, err := c.SQLiteConn.Exec(query, args)
 	if err == nil {
 		queriesRunMutex.Lock()
@@ -129,7 +129,7 @@ func (c *Conn) Exec(query string, args []driver.Value) (driver.Result, error) {
 	return res, err
 }
 
-func (c *Conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+func (c *driverConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
 	res, err := c.SQLiteConn.ExecContext(ctx, query, args)
 	if err == nil {
 		queriesRunMutex.Lock()
@@ -220,7 +220,7 @@ func (d *Driver) Open(name string) (driver.Conn, error) {
 		return nil, err
 	}
 	if baseConn, ok := baseConn.(*sqlite3.SQLiteConn); ok {
-		return &Conn{SQLiteConn: baseConn, testName: testName}, err
+		return &driverConn{SQLiteConn: baseConn, testName: testName}, err
 	} else {
 		panic("internal error: base driver is not SQLite")
 	}
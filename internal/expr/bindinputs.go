@@ -5,6 +5,7 @@ package expr
 
 import (
 	"fmt"
+	"reflect"
 
 	"github.com/canonical/sqlair/internal/typeinfo"
 )
@@ -20,11 +21,78 @@ type typedExpr interface {
 // the SQLair query.
 type TypeBoundExpr struct {
 	typedExprs []typedExpr
+	// coerceNumeric is true if numeric struct fields should accept any
+	// numeric value that fits in them.
+	coerceNumeric bool
+	// textBool is true if bool struct fields should accept textual
+	// booleans.
+	textBool bool
+	// secretKeys holds the map keys whose values are redacted from logging
+	// output.
+	secretKeys map[string]bool
+	// resolveValuers is true if the driver.Valuer elements of slice inputs
+	// are replaced with their values before the query is run.
+	resolveValuers bool
+	// description describes the inputs and outputs of the statement.
+	description Description
+}
+
+// HasOutputs returns true if the expression contains at least one output
+// expression.
+func (tbe *TypeBoundExpr) HasOutputs() bool {
+	for _, te := range tbe.typedExprs {
+		if _, ok := te.(*typedOutputExpr); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// HasInputs returns true if the expression contains at least one input
+// expression.
+func (tbe *TypeBoundExpr) HasInputs() bool {
+	for _, te := range tbe.typedExprs {
+		switch te.(type) {
+		case *bypass, *typedOutputExpr:
+		default:
+			return true
+		}
+	}
+	return false
+}
+
+// SliceInputTypes returns the types of the slices used in slice input
+// expressions such as "$S[:]". Each type is returned once.
+func (tbe *TypeBoundExpr) SliceInputTypes() []reflect.Type {
+	var types []reflect.Type
+	seen := map[reflect.Type]bool{}
+	for _, te := range tbe.typedExprs {
+		var input typeinfo.Input
+		switch te := te.(type) {
+		case *typedInputExpr:
+			input = te.input
+		case *typedNullSafeInExpr:
+			input = te.input
+		default:
+			continue
+		}
+		if t := input.ArgType(); t.Kind() == reflect.Slice && !seen[t] {
+			seen[t] = true
+			types = append(types, t)
+		}
+	}
+	return types
 }
 
 // BindInputs takes the SQLair input arguments and returns the PrimedQuery ready
-// for use with the database.
+// for use with a SQLite database.
 func (tbe *TypeBoundExpr) BindInputs(args ...any) (pq *PrimedQuery, err error) {
+	return tbe.BindInputsWithDialect(SQLite, args...)
+}
+
+// BindInputsWithDialect takes the SQLair input arguments and returns the
+// PrimedQuery ready for use with a database using the given SQL dialect.
+func (tbe *TypeBoundExpr) BindInputsWithDialect(dialect *Dialect, args ...any) (pq *PrimedQuery, err error) {
 	defer func() {
 		if err != nil {
 			err = fmt.Errorf("invalid input parameter: %s", err)
@@ -36,7 +104,9 @@ func (tbe *TypeBoundExpr) BindInputs(args ...any) (pq *PrimedQuery, err error) {
 		return nil, err
 	}
 
-	qb := newQueryBuilder()
+	qb := newQueryBuilder(dialect)
+	qb.secretKeys = tbe.secretKeys
+	qb.resolveValuers = tbe.resolveValuers
 	for _, te := range tbe.typedExprs {
 		if err := te.addToQuery(qb, typeToValue); err != nil {
 			return nil, err
@@ -46,18 +116,40 @@ func (tbe *TypeBoundExpr) BindInputs(args ...any) (pq *PrimedQuery, err error) {
 	if err := qb.checkAllArgsUsed(typeToValue); err != nil {
 		return nil, err
 	}
+	if err := qb.convertInputs(); err != nil {
+		return nil, err
+	}
+	if err := dialect.checkParamCount(qb.numParams()); err != nil {
+		return nil, err
+	}
 
-	return &PrimedQuery{outputs: qb.outputs, sql: qb.sqlBuilder.getSQL(), params: qb.namedInputs}, nil
+	return &PrimedQuery{
+		outputs:       qb.outputs,
+		sql:           qb.sqlBuilder.getSQL(),
+		params:        qb.params(),
+		debugParams:   qb.debugParams(),
+		bulkRows:      qb.sqlBuilder.bulkRows,
+		coerceNumeric: tbe.coerceNumeric,
+		textBool:      tbe.textBool,
+		positional:    qb.positional,
+		catchAll:      qb.catchAll,
+	}, nil
 }
 
 // typedInputExpr stores information about a Go value to use as a standalone query
 // input.
 type typedInputExpr struct {
 	input typeinfo.Input
+	// integerClause is the keyword of the LIMIT or OFFSET clause that the
+	// input is the value of, if it is passed to the driver as an int64.
+	integerClause string
 }
 
 // addToQuery adds the typed input expressions to the query builder.
 func (te *typedInputExpr) addToQuery(qb *queryBuilder, typeToValue typeinfo.TypeToValue) error {
+	if err := checkSingleValue(te.input, typeToValue); err != nil {
+		return err
+	}
 	params, err := te.input.LocateParams(typeToValue)
 	if err != nil {
 		return err
@@ -65,38 +157,316 @@ func (te *typedInputExpr) addToQuery(qb *queryBuilder, typeToValue typeinfo.Type
 	if params.Omit {
 		return omitEmptyInputError(te.input.Desc())
 	}
-	if params.Bulk {
-		return fmt.Errorf("cannot use bulk inputs outside an insert statement")
+	qb.markArgUsed(params.ArgTypeUsed)
+
+	// Members holding an identifier or sort direction are written directly
+	// into the SQL.
+	if len(params.Vals) == 1 {
+		switch v := params.Vals[0].(type) {
+		case typeinfo.Ident:
+			if err := typeinfo.ValidateIdent(string(v)); err != nil {
+				return fmt.Errorf("%s: %s", te.input.Desc(), err)
+			}
+			qb.addIdent(string(v))
+			return nil
+		case typeinfo.SortDirection:
+			direction, err := typeinfo.ValidateSortDirection(string(v))
+			if err != nil {
+				return fmt.Errorf("%s: %s", te.input.Desc(), err)
+			}
+			qb.addIdent(direction)
+			return nil
+		}
+	}
+
+	// A member used several times in the query shares one parameter. Slice
+	// inputs are expanded into a parameter for each element every time.
+	if te.input.ArgType().Kind() != reflect.Slice {
+		if te.integerClause != "" && len(params.Vals) == 1 {
+			val, err := typeinfo.ToInt64(params.Vals[0])
+			if err != nil {
+				return fmt.Errorf("cannot use %s as %s value: %s", te.input.Desc(), te.integerClause, err)
+			}
+			params.Vals[0] = val
+		}
+		if len(params.Vals) == 1 {
+			qb.addMemberInput(te.input.Identifier(), params.Vals[0], qb.isSecret(te.input, params))
+		} else {
+			qb.addInputs(params.Vals, qb.isSecret(te.input, params))
+		}
+		return nil
+	}
+	vals, err := qb.sliceVals(te.input, params)
+	if err != nil {
+		return err
+	}
+	qb.addSliceInputs(vals, qb.isSecret(te.input, params))
+	return nil
+}
+
+// checkSingleValue returns an error if the argument of an input that takes a
+// single value was provided as a slice. Slices of arguments are only valid in
+// bulk inserts. Slice inputs such as "$S[:]" take the slice itself and are
+// not affected.
+func checkSingleValue(input typeinfo.Input, typeToValue typeinfo.TypeToValue) error {
+	if t := input.ArgType(); typeinfo.ProvidedAsSlice(typeToValue, t) {
+		return fmt.Errorf("type %q provided as slice but query uses it as a single value", typeinfo.TypeName(t))
+	}
+	return nil
+}
+
+// typedIdentExpr stores information about an identifier to write directly into
+// the query.
+type typedIdentExpr struct {
+	input typeinfo.Input
+}
+
+// addToQuery validates the identifier and writes it to the query builder.
+func (te *typedIdentExpr) addToQuery(qb *queryBuilder, typeToValue typeinfo.TypeToValue) error {
+	if err := checkSingleValue(te.input, typeToValue); err != nil {
+		return err
+	}
+	params, err := te.input.LocateParams(typeToValue)
+	if err != nil {
+		return err
 	}
 	qb.markArgUsed(params.ArgTypeUsed)
 
-	qb.addInputs(params.Vals)
+	ident, ok := params.Vals[0].(string)
+	if !ok {
+		return fmt.Errorf("internal error: identifier has type %T", params.Vals[0])
+	}
+	if err := typeinfo.ValidateIdent(ident); err != nil {
+		return err
+	}
+	qb.addIdent(ident)
 	return nil
 }
 
+// typedUpdateSetExpr stores the columns and input locators of the SET clause
+// of an UPDATE statement.
+type typedUpdateSetExpr struct {
+	columns []string
+	inputs  []typeinfo.Input
+	// explicit reports, for each input, if the member was named explicitly
+	// in the query rather than through an asterisk. If nil, no member was.
+	explicit []bool
+	// excluded is true if the clause follows "ON CONFLICT ... DO UPDATE" in
+	// an upsert. The columns are then assigned the values of the row that
+	// was not inserted, e.g. "col = excluded.col", instead of query inputs.
+	excluded bool
+}
+
+// addToQuery writes the SET clause to the query builder. Members with the
+// omitempty option that hold the zero value are left out of the clause, unless
+// they were named explicitly, in which case it is an error.
+func (te *typedUpdateSetExpr) addToQuery(qb *queryBuilder, typeToValue typeinfo.TypeToValue) error {
+	if te.excluded && !qb.dialect.excludedRow {
+		return fmt.Errorf("cannot assign the inserted row in an upsert: %s has no excluded row", qb.dialect.name)
+	}
+	first := true
+	for i, input := range te.inputs {
+		// The values of an upsert come from the inserted rows, so the type
+		// may be provided as a slice for a bulk insert.
+		if !te.excluded {
+			if err := checkSingleValue(input, typeToValue); err != nil {
+				return err
+			}
+		}
+		params, err := input.LocateParams(typeToValue)
+		if err != nil {
+			return err
+		}
+		qb.markArgUsed(params.ArgTypeUsed)
+		if params.Omit {
+			if te.explicit != nil && te.explicit[i] {
+				return omitEmptyInputError(input.Desc())
+			}
+			continue
+		}
+		if first {
+			qb.sqlBuilder.write("SET ")
+			first = false
+		} else {
+			qb.sqlBuilder.write(", ")
+		}
+		if te.excluded {
+			qb.sqlBuilder.write(te.columns[i] + " = excluded." + te.columns[i])
+			continue
+		}
+		qb.sqlBuilder.write(te.columns[i] + " = ")
+		qb.addMemberInput(input.Identifier(), params.Vals[0], qb.isSecret(input, params))
+	}
+	if first {
+		return fmt.Errorf("no columns to update: every member is omitted")
+	}
+	return nil
+}
+
+// typedUpdateSetMapExpr is the SET clause of an UPDATE statement with an
+// assignment for each key of a map, except the excluded columns.
+type typedUpdateSetMapExpr struct {
+	mapInfo  typeinfo.ArgInfo
+	excluded map[string]bool
+}
+
+// addToQuery writes an assignment for every key of the map that is not
+// excluded to the query builder, in alphabetical order of the keys. The SQL
+// therefore depends on the keys present in the map.
+func (te *typedUpdateSetMapExpr) addToQuery(qb *queryBuilder, typeToValue typeinfo.TypeToValue) error {
+	mapType := te.mapInfo.Typ()
+	if typeinfo.ProvidedAsSlice(typeToValue, mapType) {
+		return fmt.Errorf("type %q provided as slice but query uses it as a single value", mapType.Name())
+	}
+	keys, argType, err := typeinfo.MapKeys(typeToValue, mapType)
+	if err != nil {
+		return err
+	}
+	qb.markArgUsed(argType)
+
+	first := true
+	for _, key := range keys {
+		if te.excluded[key] {
+			continue
+		}
+		if err := typeinfo.ValidateIdent(key); err != nil {
+			return fmt.Errorf("key of map %q is not a valid column name: %s", mapType.Name(), err)
+		}
+		vl, err := te.mapInfo.GetMember(key)
+		if err != nil {
+			return err
+		}
+		input, ok := vl.(typeinfo.Input)
+		if !ok {
+			return fmt.Errorf("internal error: %s cannot be used as input", vl.ArgType().Kind())
+		}
+		params, err := input.LocateParams(typeToValue)
+		if err != nil {
+			return err
+		}
+		if first {
+			qb.sqlBuilder.write("SET ")
+			first = false
+		} else {
+			qb.sqlBuilder.write(", ")
+		}
+		qb.sqlBuilder.write(key + " = ")
+		qb.addMemberInput(input.Identifier(), params.Vals[0], qb.isSecret(input, params))
+	}
+	if first {
+		return fmt.Errorf("no columns to update: map %q has no keys to set", mapType.Name())
+	}
+	return nil
+}
+
+// typedNullSafeInExpr is an IN expression of the form "col IN ($S[:])" that
+// also matches NULL if the slice contains nil. For "col NOT IN ($S[:])", a nil
+// in the slice excludes NULL instead.
+type typedNullSafeInExpr struct {
+	column string
+	not    bool
+	input  typeinfo.Input
+}
+
+// addToQuery writes the IN expression to the query builder. Nil elements of the
+// slice are removed from the IN list and replaced by an IS NULL check, or an
+// IS NOT NULL check for NOT IN.
+func (te *typedNullSafeInExpr) addToQuery(qb *queryBuilder, typeToValue typeinfo.TypeToValue) error {
+	params, err := te.input.LocateParams(typeToValue)
+	if err != nil {
+		return err
+	}
+	qb.markArgUsed(params.ArgTypeUsed)
+
+	secret := qb.isSecret(te.input, params)
+	sliceVals, err := qb.sliceVals(te.input, params)
+	if err != nil {
+		return err
+	}
+	var vals []any
+	hasNil := false
+	for _, val := range sliceVals {
+		if isNil(val) {
+			hasNil = true
+			continue
+		}
+		vals = append(vals, val)
+	}
+
+	in, isNull, or := " IN (", " IS NULL", ") OR "
+	if te.not {
+		in, isNull, or = " NOT IN (", " IS NOT NULL", ") AND "
+	}
+	switch {
+	case !hasNil:
+		qb.sqlBuilder.write(te.column + in)
+		qb.addSliceInputs(vals, secret)
+		qb.sqlBuilder.write(")")
+	case len(vals) == 0:
+		qb.sqlBuilder.write(te.column + isNull)
+	default:
+		qb.sqlBuilder.write("(" + te.column + in)
+		qb.addInputs(vals, secret)
+		qb.sqlBuilder.write(or + te.column + isNull + ")")
+	}
+	return nil
+}
+
+// isNil returns true if the value is nil or a nil pointer, both of which are
+// passed to the database as NULL.
+func isNil(val any) bool {
+	if val == nil {
+		return true
+	}
+	v := reflect.ValueOf(val)
+	return v.Kind() == reflect.Pointer && v.IsNil()
+}
+
 // typedColumn represents a column and input locator in an insert statement.
 type typedColumn interface {
 	// bindInputs binds a concrete value to a typedColumn to generate a
 	// boundInsertColumn.
-	bindInputs(tv typeinfo.TypeToValue, ia *inputAssigner) (*boundInsertColumn, error)
+	bindInputs(tv typeinfo.TypeToValue, qb *queryBuilder) (*boundInsertColumn, error)
 }
 
 // typedInsertExpr stores information about the Go values to use as inputs inside
 // an INSERT statement.
 type typedInsertExpr struct {
 	insertColumns []typedColumn
+	// overflow, if not nil, generates the columns that follow insertColumns
+	// from the keys of a map.
+	overflow *overflowMapColumns
+	// extraRows are the columns of the rows that follow insertColumns in an
+	// insert expression with several rows of values.
+	extraRows [][]typedColumn
 }
 
 // addToQuery adds the typed insert expressions to the query builder.
 func (te *typedInsertExpr) addToQuery(qb *queryBuilder, typeToValue typeinfo.TypeToValue) error {
+	if len(te.extraRows) > 0 {
+		return te.addRowsToQuery(qb, typeToValue)
+	}
+	insertColumns := te.insertColumns
+	if te.overflow != nil {
+		overflowColumns, argType, err := te.overflow.insertColumns(typeToValue)
+		if err != nil {
+			return err
+		}
+		qb.markArgUsed(argType)
+		insertColumns = append(insertColumns[:len(insertColumns):len(insertColumns)], overflowColumns...)
+		if len(insertColumns) == 0 {
+			return fmt.Errorf("no columns to insert: map %q is empty", typeinfo.TypeName(te.overflow.mapInfo.Typ()))
+		}
+	}
+
 	var boundColumns []*boundInsertColumn
 	bulk := false
 	numRows := 1
 	// firstBulkColumn stores the type name of the first column used in
 	// a bulk insert. This is used for error messages.
 	var firstBulkColumn string
-	for _, ic := range te.insertColumns {
-		bc, err := ic.bindInputs(typeToValue, qb.inputAssigner)
+	for _, ic := range insertColumns {
+		bc, err := ic.bindInputs(typeToValue, qb)
 		if err != nil {
 			return err
 		}
@@ -121,20 +491,69 @@ func (te *typedInsertExpr) addToQuery(qb *queryBuilder, typeToValue typeinfo.Typ
 	return qb.addInsert(boundColumns, numRows)
 }
 
+// addRowsToQuery adds an insert expression with several rows of values to the
+// query builder. Each row is bound separately, so a bulk insert of a slice
+// cannot be used in it.
+func (te *typedInsertExpr) addRowsToQuery(qb *queryBuilder, typeToValue typeinfo.TypeToValue) error {
+	var rows [][]*boundInsertColumn
+	for _, row := range append([][]typedColumn{te.insertColumns}, te.extraRows...) {
+		var boundColumns []*boundInsertColumn
+		for _, ic := range row {
+			bc, err := ic.bindInputs(typeToValue, qb)
+			if err != nil {
+				return err
+			}
+			if bc.bulk {
+				return fmt.Errorf("cannot use slice of %q in insert expression with more than one row of values", bc.inputName)
+			}
+			if bc.argType != nil {
+				qb.markArgUsed(bc.argType)
+			}
+			boundColumns = append(boundColumns, bc)
+		}
+		rows = append(rows, boundColumns)
+	}
+	return qb.addInsertRows(rows)
+}
+
 // typedOutputExpr contains the columns to fetch from the database and
 // information about the Go values to read the query results into.
 type typedOutputExpr struct {
 	outputColumns []outputColumn
+	// positionalColumns is the asterisk column, e.g. "*" or "t.*", selecting
+	// the columns that are read by position into a struct with positional db
+	// tags. It is empty if the columns are read by name.
+	positionalColumns string
+	// catchAll, if set, is a map that the columns selected by its asterisk
+	// are read into by name, e.g. "* AS &M.*".
+	catchAll *catchAllMap
+}
+
+// catchAllMap is a map output that reads every result column not read by
+// another output expression, keyed by column name.
+type catchAllMap struct {
+	// columns is the asterisk column, e.g. "*" or "t.*".
+	columns string
+	mapInfo typeinfo.ArgInfo
+	label   string
 }
 
 // addToQuery adds the typed output expressions to the query builder.
 func (te *typedOutputExpr) addToQuery(qb *queryBuilder, _ typeinfo.TypeToValue) error {
+	if te.catchAll != nil {
+		qb.addCatchAllOutput(te.catchAll)
+		return nil
+	}
 	var columns []string
-	var outputs []typeinfo.Output
+	var outputs []labelledOutput
 	for _, oc := range te.outputColumns {
-		outputs = append(outputs, oc.output)
+		outputs = append(outputs, labelledOutput{output: oc.output, label: oc.label})
 		columns = append(columns, oc.column)
 	}
+	if te.positionalColumns != "" {
+		qb.addPositionalOutput(te.positionalColumns, outputs)
+		return nil
+	}
 	qb.addOutput(columns, outputs)
 	return nil
 }
@@ -160,7 +579,7 @@ func newInsertColumn(input typeinfo.Input, column string, explicit bool) insertC
 
 // bindInputs generates and verifies the query parameters corresponding to the
 // insertColumn and returns them as a boundInsertColumn.
-func (ic insertColumn) bindInputs(tv typeinfo.TypeToValue, ia *inputAssigner) (*boundInsertColumn, error) {
+func (ic insertColumn) bindInputs(tv typeinfo.TypeToValue, qb *queryBuilder) (*boundInsertColumn, error) {
 	params, err := ic.input.LocateParams(tv)
 	if err != nil {
 		return nil, err
@@ -174,10 +593,18 @@ func (ic insertColumn) bindInputs(tv typeinfo.TypeToValue, ia *inputAssigner) (*
 		return nil, omitEmptyInputError(ic.input.Desc())
 	}
 	var firstInputNum int
-	if !params.Omit {
+	shared := false
+	switch {
+	case params.Omit:
+	case !params.Bulk && len(params.Vals) == 1:
+		// A member used several times in the query shares one parameter.
+		var isNew bool
+		firstInputNum, isNew = qb.memberInputNum(ic.input.Identifier())
+		shared = !isNew
+	default:
 		// Reserve input numbers for all values that are getting inserted in the
 		// boundColumn.
-		firstInputNum = ia.assignInputs(len(params.Vals))
+		firstInputNum = qb.inputAssigner.assignInputs(len(params.Vals))
 	}
 	bc := &boundInsertColumn{
 		vals:          params.Vals,
@@ -185,13 +612,55 @@ func (ic insertColumn) bindInputs(tv typeinfo.TypeToValue, ia *inputAssigner) (*
 		omit:          params.Omit,
 		bulk:          params.Bulk,
 		argType:       params.ArgTypeUsed,
-		inputName:     ic.input.ArgType().Name(),
+		inputName:     typeinfo.TypeName(ic.input.ArgType()),
 		literal:       "",
 		column:        ic.column,
+		secret:        qb.isSecret(ic.input, params),
+		shared:        shared,
 	}
 	return bc, nil
 }
 
+// overflowMapColumns represents the columns of an asterisk insert expression
+// that are generated from the keys of a map with an asterisk. The columns are
+// only known once the map is bound.
+type overflowMapColumns struct {
+	mapInfo typeinfo.ArgInfo
+	// providedColumns are the columns provided by the other types in the
+	// insert expression.
+	providedColumns map[string]bool
+}
+
+// insertColumns returns an insert column for every key of the map in
+// alphabetical order, along with the type of the argument used. It is an error
+// for a key to match a column provided by another type.
+func (oc *overflowMapColumns) insertColumns(tv typeinfo.TypeToValue) ([]typedColumn, reflect.Type, error) {
+	mapName := typeinfo.TypeName(oc.mapInfo.Typ())
+	keys, argType, err := typeinfo.MapKeys(tv, oc.mapInfo.Typ())
+	if err != nil {
+		return nil, nil, err
+	}
+	var cols []typedColumn
+	for _, key := range keys {
+		if oc.providedColumns[key] {
+			return nil, nil, fmt.Errorf("key %q of map %q is also provided by another type", key, mapName)
+		}
+		if err := typeinfo.ValidateIdent(key); err != nil {
+			return nil, nil, fmt.Errorf("key of map %q is not a valid column name: %s", mapName, err)
+		}
+		vl, err := oc.mapInfo.GetMember(key)
+		if err != nil {
+			return nil, nil, err
+		}
+		input, ok := vl.(typeinfo.Input)
+		if !ok {
+			return nil, nil, fmt.Errorf("internal error: %s cannot be used as input", vl.ArgType().Kind())
+		}
+		cols = append(cols, newInsertColumn(input, key, false))
+	}
+	return cols, argType, nil
+}
+
 // literalColumn represents a column in an insert statement populated with a
 // literal value.
 type literalColumn struct {
@@ -201,7 +670,7 @@ type literalColumn struct {
 
 // bindInputs creates a boundInsertColumn from a literalColumn. It is part of the
 // typedColumn interface.
-func (lc literalColumn) bindInputs(_ typeinfo.TypeToValue, _ *inputAssigner) (*boundInsertColumn, error) {
+func (lc literalColumn) bindInputs(_ typeinfo.TypeToValue, _ *queryBuilder) (*boundInsertColumn, error) {
 	bc := &boundInsertColumn{
 		column:        lc.column,
 		vals:          []any{},
@@ -228,15 +697,18 @@ func newLiteralColumn(column, literal string) literalColumn {
 type outputColumn struct {
 	output typeinfo.Output
 	column string
+	// label is the label of the output argument to scan into. It is empty if
+	// the output is not labelled.
+	label string
 }
 
 // newOutputColumn generates an output column with the correct column string to
 // write in the generated query.
-func newOutputColumn(tableName string, columnName string, output typeinfo.Output) outputColumn {
+func newOutputColumn(tableName string, columnName string, output typeinfo.Output, label string) outputColumn {
 	if tableName == "" {
-		return outputColumn{column: columnName, output: output}
+		return outputColumn{column: columnName, output: output, label: label}
 	}
-	return outputColumn{column: tableName + "." + columnName, output: output}
+	return outputColumn{column: tableName + "." + columnName, output: output, label: label}
 }
 
 func omitEmptyInputError(valueDesc string) error {
@@ -7,6 +7,7 @@ import (
 	"bytes"
 	"fmt"
 	"reflect"
+	"strings"
 
 	"github.com/canonical/sqlair/internal/typeinfo"
 )
@@ -36,23 +37,118 @@ func (pe *ParsedExpr) String() string {
 // the query. The expressions are checked for validity and required information
 // is generated from the types.
 func (pe *ParsedExpr) BindTypes(args ...any) (tbe *TypeBoundExpr, err error) {
+	return pe.BindTypesWithOptions(BindOptions{}, args...)
+}
+
+// BindOptions are options that change how a TypeBoundExpr generates SQL.
+type BindOptions struct {
+	// NullSafeIn makes a slice input that is the only value in an IN list,
+	// e.g. "col IN ($S[:])", also match NULL if the slice contains nil.
+	NullSafeIn bool
+	// CoerceNumeric makes numeric struct fields accept any numeric value
+	// returned by the driver that can be represented exactly by the type of
+	// the field.
+	CoerceNumeric bool
+	// TextBool makes bool struct fields accept textual representations of
+	// booleans, such as "true" and "f".
+	TextBool bool
+	// CaseInsensitiveTags makes member names and columns match struct db
+	// tags, and input member names match map keys, ignoring case.
+	CaseInsensitiveTags bool
+	// JSONTypes holds the names of the struct types that are encoded as a
+	// single JSON value when used without a member, as in "$Doc".
+	JSONTypes map[string]bool
+	// ColumnPrefix sets how the table name of columns generated from an
+	// asterisk is added to the column names.
+	ColumnPrefix ColumnPrefix
+	// SecretKeys holds the map keys whose values are redacted from logging
+	// output.
+	SecretKeys map[string]bool
+	// Strict rejects queries with output expressions that also select
+	// columns with an asterisk outside of an output expression, as in
+	// "SELECT *, &Person.id FROM person".
+	Strict bool
+	// TagName is the key of the struct tags that map struct fields to
+	// columns. If it is empty, "db" tags are used.
+	TagName string
+	// ResolveValuers makes the elements of slice inputs that implement
+	// driver.Valuer be replaced with their values before the query is run.
+	ResolveValuers bool
+	// IntegerLimits makes inputs used as the value of a LIMIT or OFFSET
+	// clause be passed to the driver as an int64.
+	IntegerLimits bool
+	// OmitZeroPK makes asterisk insert expressions omit the primary key
+	// field of a struct, marked with the "pk" option, when it is zero.
+	OmitZeroPK bool
+}
+
+// prepareError is returned when the types cannot be bound to a query. It
+// unwraps to the underlying error so that errors such as a
+// typeinfo.UnexportedFieldError can be matched by type.
+type prepareError struct {
+	err error
+}
+
+func (e *prepareError) Error() string {
+	return "cannot prepare statement: " + e.err.Error()
+}
+
+// Unwrap returns the error found binding the types.
+func (e *prepareError) Unwrap() error {
+	return e.err
+}
+
+// ColumnPrefix is the way the table name of columns generated from an
+// asterisk, e.g. "t" in "t.* AS &T.*", is added to the column names.
+type ColumnPrefix int
+
+const (
+	// TableQualifier qualifies the column with the table name, e.g. "t.col".
+	TableQualifier ColumnPrefix = iota
+	// UnderscorePrefix joins the table name and the column name with an
+	// underscore, e.g. "t_col".
+	UnderscorePrefix
+)
+
+// BindTypesWithOptions binds the types like BindTypes and applies the options
+// to the TypeBoundExpr.
+func (pe *ParsedExpr) BindTypesWithOptions(opts BindOptions, args ...any) (tbe *TypeBoundExpr, err error) {
 	defer func() {
 		if err != nil {
-			err = fmt.Errorf("cannot prepare statement: %s", err)
+			err = &prepareError{err: err}
 		}
 	}()
 
-	argInfo, err := typeinfo.GenerateArgInfo(args)
+	tagName := opts.TagName
+	if tagName == "" {
+		tagName = typeinfo.DefaultTagName
+	}
+	argInfo, err := typeinfo.GenerateArgInfoWithTag(args, tagName)
 	if err != nil {
 		return nil, err
 	}
 
+	if opts.CaseInsensitiveTags {
+		argInfo = typeinfo.CaseInsensitive(argInfo)
+	}
+
 	// Bind types to each expression.
 	teb := newTypedExprBuilder(argInfo)
+	teb.opts = opts
 	for _, expr := range pe.exprs {
+		n := len(teb.typedExprs)
 		if err := expr.bindTypes(teb); err != nil {
 			return nil, err
 		}
+		teb.description.describe(exprText(expr), teb.typedExprs[n:])
+	}
+	if opts.Strict {
+		if err := checkBareAsterisks(pe.exprs); err != nil {
+			return nil, err
+		}
+	}
+	if err := checkNestedOutputs(pe.exprs); err != nil {
+		return nil, err
 	}
 
 	return teb.Build()
@@ -114,6 +210,38 @@ func (e *memberInputExpr) bindTypes(teb *typedExprBuilder) error {
 	return nil
 }
 
+// identTypeName is the name of the type used to pass SQL identifiers.
+const identTypeName = "Ident"
+
+// identInputExpr is an input expression of the form "$Ident.name". If Ident is
+// the SQLair identifier type then the identifier is written directly into the
+// SQL instead of being passed as a query parameter.
+type identInputExpr struct {
+	raw string
+	ma  memberAccessor
+}
+
+// String returns a text representation for debugging and testing purposes.
+func (e *identInputExpr) String() string {
+	return fmt.Sprintf("IdentInput[%+v]", e.ma)
+}
+
+// bindTypes generates a typed identifier expression and adds it to the
+// typedExprBuilder. If the type named Ident is not the SQLair identifier type
+// then a regular typed input expression is added instead.
+func (e *identInputExpr) bindTypes(teb *typedExprBuilder) error {
+	input, err := teb.InputMember(e.ma.typeName, e.ma.memberName)
+	if err != nil {
+		return fmt.Errorf("input expression: %s: %s", err, e.raw)
+	}
+	if input.ArgType() == typeinfo.IdentType {
+		teb.AddTypedIdentExpr(input)
+	} else {
+		teb.AddTypedInputExpr(input)
+	}
+	return nil
+}
+
 // asteriskInsertExpr is an input expression occurring within an INSERT
 // statement that consists of an asterisk on the left and explicit type accessors
 // on the right. This means that SQLair generates the columns.
@@ -138,29 +266,260 @@ func (e *asteriskInsertExpr) bindTypes(teb *typedExprBuilder) (err error) {
 	}()
 
 	var cols []typedColumn
-	for _, source := range e.sources {
+	// providedColumns are the columns provided by the sources. A map with an
+	// asterisk cannot provide any of these columns.
+	providedColumns := map[string]bool{}
+	for i, source := range e.sources {
 		if source.memberName == "*" {
+			kind, err := teb.Kind(source.typeName)
+			if err != nil {
+				return err
+			}
+			// A trailing map with an asterisk provides the columns for each of
+			// its keys. These are only known when the map is bound.
+			if kind == reflect.Map {
+				if i != len(e.sources)-1 {
+					return fmt.Errorf("map with asterisk must be the last value")
+				}
+				mapInfo, err := teb.InputMap(source.typeName)
+				if err != nil {
+					return err
+				}
+				teb.AddTypedOverflowInsertExpr(cols, &overflowMapColumns{mapInfo: mapInfo, providedColumns: providedColumns})
+				return nil
+			}
 			inputs, tags, err := teb.AllStructInputs(source.typeName)
 			if err != nil {
 				return err
 			}
 			for i, input := range inputs {
+				if teb.opts.OmitZeroPK {
+					input = typeinfo.OmitZeroPK(input)
+				}
 				c := newInsertColumn(input, tags[i], false)
 				cols = append(cols, c)
+				providedColumns[tags[i]] = true
 			}
 		} else {
+			if source.memberName == "" {
+				return wholeTypeColumnError(source.typeName)
+			}
 			input, err := teb.InputMember(source.typeName, source.memberName)
 			if err != nil {
 				return err
 			}
-			c := newInsertColumn(input, source.memberName, true)
+			column := typeinfo.MemberColumn(source.memberName)
+			c := newInsertColumn(input, column, true)
 			cols = append(cols, c)
+			providedColumns[column] = true
 		}
 	}
 	teb.AddTypedInsertExpr(cols)
 	return nil
 }
 
+// updateAssignExpr is the SET clause of an UPDATE statement that assigns the
+// members of the types on the right to the columns on the left, in the style
+// of an insert expression. If columns is nil the left is an asterisk and the
+// columns are generated from the types, leaving out the columns in except.
+// e.g. "SET (*) = ($Type.*)", "SET (* EXCEPT (col1)) = ($Type.*)" or
+// "SET (col1, col2) = ($Type.*)".
+type updateAssignExpr struct {
+	columns []columnAccessor
+	except  []columnAccessor
+	sources []memberAccessor
+	raw     string
+}
+
+// String returns a text representation for debugging and testing purposes.
+func (e *updateAssignExpr) String() string {
+	if e.columns == nil && e.except != nil {
+		return fmt.Sprintf("UpdateAssign[[* EXCEPT %v] %v]", e.except, e.sources)
+	}
+	if e.columns == nil {
+		return fmt.Sprintf("UpdateAssign[[*] %v]", e.sources)
+	}
+	return fmt.Sprintf("UpdateAssign[%v %v]", e.columns, e.sources)
+}
+
+// bindTypes generates a typed update set expression assigning the members of
+// the sources to columns. This is added to the typedExprBuilder.
+func (e *updateAssignExpr) bindTypes(teb *typedExprBuilder) (err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("input expression: %s: %s", err, e.raw)
+		}
+	}()
+	if e.columns == nil {
+		return e.bindAsteriskTypes(teb)
+	}
+	return e.bindColumnsTypes(teb)
+}
+
+// bindAsteriskTypes binds an update assignment with an asterisk on the left.
+// Every tagged member of a struct with an asterisk, and every explicit member,
+// is assigned to the column of the same name, except for the excluded columns.
+// A map with an asterisk assigns its keys and must be the only type on the
+// right.
+func (e *updateAssignExpr) bindAsteriskTypes(teb *typedExprBuilder) error {
+	excluded := map[string]bool{}
+	for _, col := range e.except {
+		bc, ok := col.(basicColumn)
+		if !ok || bc.table != "" || bc.column == "*" {
+			return fmt.Errorf("invalid column %q in EXCEPT", col)
+		}
+		column := teb.columnKey(bc.column)
+		if excluded[column] {
+			return fmt.Errorf("column %q excluded more than once", bc.column)
+		}
+		excluded[column] = true
+	}
+
+	var columns []string
+	var inputs []typeinfo.Input
+	var explicit []bool
+	provided := map[string]bool{}
+	add := func(column string, input typeinfo.Input, isExplicit bool) error {
+		key := teb.columnKey(column)
+		if provided[key] {
+			return fmt.Errorf("more than one type provides column %q", column)
+		}
+		provided[key] = true
+		if excluded[key] {
+			return nil
+		}
+		columns = append(columns, column)
+		inputs = append(inputs, input)
+		explicit = append(explicit, isExplicit)
+		return nil
+	}
+	for _, source := range e.sources {
+		if source.memberName == "*" {
+			kind, err := teb.Kind(source.typeName)
+			if err != nil {
+				return err
+			}
+			if kind == reflect.Map {
+				if len(e.sources) != 1 {
+					return fmt.Errorf("map with asterisk must be the only value")
+				}
+				mapInfo, err := teb.InputMap(source.typeName)
+				if err != nil {
+					return err
+				}
+				teb.AddTypedUpdateSetMapExpr(mapInfo, excluded)
+				return nil
+			}
+			structInputs, tags, err := teb.AllStructInputs(source.typeName)
+			if err != nil {
+				return err
+			}
+			for i, input := range structInputs {
+				if err := add(tags[i], input, false); err != nil {
+					return err
+				}
+			}
+		} else {
+			if source.memberName == "" {
+				return wholeTypeColumnError(source.typeName)
+			}
+			input, err := teb.InputMember(source.typeName, source.memberName)
+			if err != nil {
+				return err
+			}
+			if err := add(typeinfo.MemberColumn(source.memberName), input, true); err != nil {
+				return err
+			}
+		}
+	}
+	for _, col := range e.except {
+		if column := col.(basicColumn).column; !provided[teb.columnKey(column)] {
+			return fmt.Errorf("excluded column %q is not provided by any type", column)
+		}
+	}
+	if len(columns) == 0 {
+		return fmt.Errorf("no columns to update: every column is excluded")
+	}
+	teb.AddTypedUpdateAssignExpr(columns, inputs, explicit)
+	return nil
+}
+
+// bindColumnsTypes binds an update assignment with a list of columns on the
+// left. Each column is assigned the member of the same name of the types on
+// the right. A map with an asterisk provides the columns not provided by the
+// other types.
+func (e *updateAssignExpr) bindColumnsTypes(teb *typedExprBuilder) error {
+	colToInput := make(map[string][]typeinfo.Input)
+	var remainingMap *string
+	for _, source := range e.sources {
+		if source.memberName == "*" {
+			kind, err := teb.Kind(source.typeName)
+			if err != nil {
+				return err
+			}
+			if kind == reflect.Map {
+				if remainingMap != nil {
+					return fmt.Errorf("cannot use more than one map with asterisk")
+				}
+				remainingMap = &source.typeName
+				continue
+			}
+			structInputs, tags, err := teb.AllStructInputs(source.typeName)
+			if err != nil {
+				return err
+			}
+			for i := range tags {
+				col := teb.columnKey(tags[i])
+				colToInput[col] = append(colToInput[col], structInputs[i])
+			}
+		} else {
+			if source.memberName == "" {
+				return wholeTypeColumnError(source.typeName)
+			}
+			input, err := teb.InputMember(source.typeName, source.memberName)
+			if err != nil {
+				return err
+			}
+			col := teb.columnKey(typeinfo.MemberColumn(source.memberName))
+			colToInput[col] = append(colToInput[col], input)
+		}
+	}
+
+	var columns []string
+	var inputs []typeinfo.Input
+	var explicit []bool
+	assigned := map[string]bool{}
+	for _, col := range e.columns {
+		bc, ok := col.(basicColumn)
+		if !ok || bc.table != "" || bc.column == "*" {
+			return fmt.Errorf("invalid column %q in SET", col)
+		}
+		key := teb.columnKey(bc.column)
+		if assigned[key] {
+			return fmt.Errorf("column %q set more than once", bc.column)
+		}
+		assigned[key] = true
+		input, ok := colToInput[key]
+		if !ok && remainingMap != nil {
+			inp, err := teb.InputMember(*remainingMap, bc.column)
+			if err != nil {
+				return err
+			}
+			input = []typeinfo.Input{inp}
+		} else if !ok {
+			return fmt.Errorf("missing type that provides column %q", bc.column)
+		}
+		if len(input) > 1 {
+			return fmt.Errorf("more than one type provides column %q", bc.column)
+		}
+		columns = append(columns, bc.column)
+		inputs = append(inputs, input[0])
+		explicit = append(explicit, true)
+	}
+	teb.AddTypedUpdateAssignExpr(columns, inputs, explicit)
+	return nil
+}
+
 // columnsInsertExpr is an input expression occurring within an INSERT statement
 // that consists of explicit columns on the left and type accessors on the right.
 // e.g. "(col1, col2, col3) VALUES ($Type.*, $Type2.col1)".
@@ -217,14 +576,18 @@ func (e *columnsInsertExpr) bindTypes(teb *typedExprBuilder) (err error) {
 				return err
 			}
 			for i := range tags {
-				colToInput[tags[i]] = append(colToInput[tags[i]], inps[i])
+				col := teb.columnKey(tags[i])
+				colToInput[col] = append(colToInput[col], inps[i])
 			}
 		} else {
+			if source.memberName == "" {
+				return wholeTypeColumnError(source.typeName)
+			}
 			inp, err := teb.InputMember(source.typeName, source.memberName)
 			if err != nil {
 				return err
 			}
-			colToInput[source.memberName] = []typeinfo.Input{inp}
+			colToInput[teb.columnKey(typeinfo.MemberColumn(source.memberName))] = []typeinfo.Input{inp}
 		}
 	}
 
@@ -233,9 +596,14 @@ func (e *columnsInsertExpr) bindTypes(teb *typedExprBuilder) (err error) {
 	// If a map with an asterisk is present, we use it to supply the spare
 	// columns. If it is not present, a spare column is an error.
 	var cols []typedColumn
+	listed := map[string]bool{}
 	for _, column := range e.columns {
 		columnStr := column.String()
-		input, ok := colToInput[columnStr]
+		if listed[teb.columnKey(columnStr)] {
+			return fmt.Errorf("column %q listed more than once in insert expression", columnStr)
+		}
+		listed[teb.columnKey(columnStr)] = true
+		input, ok := colToInput[teb.columnKey(columnStr)]
 		if !ok && remainingMap != nil {
 			// The spare columns must belong to the map.
 			inp, err := teb.InputMember(*remainingMap, columnStr)
@@ -263,38 +631,56 @@ func (e *columnsInsertExpr) bindTypes(teb *typedExprBuilder) (err error) {
 // right. Unlike the columnInsertExpr, the values on the right are independent
 // of the columns on the left and are matched by position rather than by name.
 // e.g. (col1, col2, col3) VALUES ($M.key, "literal value", $T.value).
+// There may be several rows of values, each referencing different types.
+// e.g. (col1, col2) VALUES ($T.a, $T.b), ($U.a, $U.b).
 type basicInsertExpr struct {
 	columns []columnAccessor
-	sources []valueAccessor
+	rows    [][]valueAccessor
 	raw     string
 }
 
 // String returns a text representation for debugging and testing purposes.
 func (e *basicInsertExpr) String() string {
-	return fmt.Sprintf("BasicInsert[%v %v]", e.columns, e.sources)
+	var rows []string
+	for _, row := range e.rows {
+		rows = append(rows, fmt.Sprint(row))
+	}
+	return fmt.Sprintf("BasicInsert[%v %s]", e.columns, strings.Join(rows, " "))
 }
 
 // bindTypes generates a typed insert expression containing type information
-// about the values to be inserted in the basicInsertExpr. The typed expression
-// is added to the typedExprBuilder.
+// about the values to be inserted in the basicInsertExpr. Every row must have
+// a value for each column. The typed expression is added to the
+// typedExprBuilder.
 func (e *basicInsertExpr) bindTypes(teb *typedExprBuilder) (err error) {
 	defer func() {
 		if err != nil {
 			err = fmt.Errorf("input expression: %s: %s", err, e.raw)
 		}
 	}()
-	if len(e.columns) != len(e.sources) {
-		return fmt.Errorf("mismatched number of columns and values: %d != %d", len(e.columns), len(e.sources))
-	}
-	var cols []typedColumn
-	for i, source := range e.sources {
-		col, err := source.typedColumn(teb, e.columns[i].columnName())
-		if err != nil {
-			return err
+	var rows [][]typedColumn
+	for rowNum, row := range e.rows {
+		if len(e.columns) != len(row) {
+			if len(e.rows) == 1 {
+				return fmt.Errorf("mismatched number of columns and values: %d != %d", len(e.columns), len(row))
+			}
+			return fmt.Errorf("mismatched number of columns and values in row %d: %d != %d", rowNum+1, len(e.columns), len(row))
+		}
+		var cols []typedColumn
+		for i, source := range row {
+			col, err := source.typedColumn(teb, e.columns[i].columnName())
+			if err != nil {
+				return err
+			}
+			cols = append(cols, col)
 		}
-		cols = append(cols, col)
+		rows = append(rows, cols)
 	}
-	teb.AddTypedInsertExpr(cols)
+	if len(rows) == 1 {
+		teb.AddTypedInsertExpr(rows[0])
+		return nil
+	}
+	teb.AddTypedMultiRowInsertExpr(rows)
 	return nil
 }
 
@@ -321,6 +707,50 @@ func (e *sliceInputExpr) bindTypes(teb *typedExprBuilder) error {
 	return nil
 }
 
+// inListExpr is an IN list that holds only a slice input, e.g.
+// "col IN ($S[:])" or "col NOT IN ($S[:])".
+type inListExpr struct {
+	column basicColumn
+	not    bool
+	slice  *sliceInputExpr
+	// open is the SQL from the column to the slice input, e.g. "col IN (",
+	// and close is the SQL from the slice input to the closing parenthesis.
+	open, close string
+}
+
+// String returns a text representation for debugging and testing purposes.
+func (e *inListExpr) String() string {
+	in := "IN"
+	if e.not {
+		in = "NOT IN"
+	}
+	return fmt.Sprintf("InList[%s %s %s[:]]", e.column, in, e.slice.sliceTypeName)
+}
+
+// raw returns the SQL of the whole IN list.
+func (e *inListExpr) raw() string {
+	return e.open + e.slice.raw + e.close
+}
+
+// bindTypes generates the typed expressions of the IN list. With the
+// NullSafeIn option the whole list is written when the query is built, so
+// that NULL can be matched. Otherwise the SQL around the slice input is kept
+// as it is.
+func (e *inListExpr) bindTypes(teb *typedExprBuilder) error {
+	input, err := teb.InputSlice(e.slice.sliceTypeName)
+	if err != nil {
+		return fmt.Errorf("input expression: %s: %s", err, e.slice.raw)
+	}
+	if teb.opts.NullSafeIn {
+		teb.typedExprs = append(teb.typedExprs, &typedNullSafeInExpr{column: e.column.String(), not: e.not, input: input})
+		return nil
+	}
+	teb.AddBypass(&bypass{e.open})
+	teb.AddTypedInputExpr(input)
+	teb.AddBypass(&bypass{e.close})
+	return nil
+}
+
 // outputExpr represents columns to be read from the database and Go values to
 // scan them into.
 type outputExpr struct {
@@ -349,6 +779,30 @@ func (e *outputExpr) bindTypes(teb *typedExprBuilder) (err error) {
 	starTypes := starCountTypes(e.targetTypes)
 	starColumns := starCountColumns(e.sourceColumns)
 
+	for _, t := range e.targetTypes {
+		if t.memberName != "*" {
+			continue
+		}
+		positional, err := teb.Positional(t.typeName)
+		if err != nil {
+			return err
+		}
+		if positional {
+			return e.bindPositional(teb, t.typeName)
+		}
+	}
+
+	if numTypes == 1 && starTypes == 1 && numColumns == 1 && starColumns == 1 {
+		t := e.targetTypes[0]
+		kind, err := teb.Kind(t.typeName)
+		if err != nil {
+			return err
+		}
+		if kind == reflect.Map {
+			return e.bindCatchAllMap(teb)
+		}
+	}
+
 	var outputColumns []outputColumn
 
 	// Case 1: Generated columns e.g. "* AS (&P.*, &A.id)" or "&P.*".
@@ -362,26 +816,33 @@ func (e *outputExpr) bindTypes(teb *typedExprBuilder) (err error) {
 		for _, t := range e.targetTypes {
 			if t.memberName == "*" {
 				// Generate asterisk columns.
-				outputs, memberNames, err := teb.AllStructOutputs(t.typeName)
+				outputs, memberNames, err := teb.AllStructOutputs(t.typeName, t.label)
 				if err != nil {
 					return err
 				}
 				for i, output := range outputs {
-					oc := newOutputColumn(pref, memberNames[i], output)
+					oc := teb.prefixedOutputColumn(pref, memberNames[i], output, t.label)
 					outputColumns = append(outputColumns, oc)
 				}
 			} else {
+				if t.memberName == "" {
+					return wholeTypeColumnError(t.typeName)
+				}
 				// Generate explicit columns.
-				output, err := teb.OutputMember(t.typeName, t.memberName)
+				output, err := teb.OutputMember(t.typeName, t.memberName, t.label)
 				if err != nil {
 					return err
 				}
-				oc := newOutputColumn(pref, t.memberName, output)
+				oc := teb.prefixedOutputColumn(pref, typeinfo.MemberColumn(t.memberName), output, t.label)
 				outputColumns = append(outputColumns, oc)
 			}
 		}
 		teb.AddTypedOutputExpr(outputColumns)
 		return nil
+	} else if numColumns > 1 && starColumns == numColumns && numTypes == 1 && starTypes == 1 {
+		// Case 1b: Several tables into one struct with prefixed tags e.g.
+		// "(p.*, a.*) AS (&PA.*)".
+		return e.bindCombinedTables(teb)
 	} else if numColumns > 1 && starColumns > 0 {
 		return fmt.Errorf("invalid asterisk in columns")
 	}
@@ -389,11 +850,12 @@ func (e *outputExpr) bindTypes(teb *typedExprBuilder) (err error) {
 	// Case 2: Explicit columns, single asterisk type e.g. "(col1, t.col2) AS &P.*".
 	if starTypes == 1 && numTypes == 1 {
 		for _, c := range e.sourceColumns {
-			output, err := teb.OutputMember(e.targetTypes[0].typeName, c.columnName())
+			t := e.targetTypes[0]
+			output, err := teb.OutputMember(t.typeName, c.columnName(), t.label)
 			if err != nil {
 				return err
 			}
-			oc := newOutputColumn(c.tableName(), c.columnName(), output)
+			oc := newOutputColumn(c.tableName(), c.columnName(), output, t.label)
 			outputColumns = append(outputColumns, oc)
 		}
 		teb.AddTypedOutputExpr(outputColumns)
@@ -406,11 +868,11 @@ func (e *outputExpr) bindTypes(teb *typedExprBuilder) (err error) {
 	if numColumns == numTypes {
 		for i, c := range e.sourceColumns {
 			t := e.targetTypes[i]
-			output, err := teb.OutputMember(t.typeName, t.memberName)
+			output, err := teb.OutputMember(t.typeName, t.memberName, t.label)
 			if err != nil {
 				return err
 			}
-			oc := newOutputColumn(c.tableName(), c.columnName(), output)
+			oc := newOutputColumn(c.tableName(), c.columnName(), output, t.label)
 			outputColumns = append(outputColumns, oc)
 		}
 	} else {
@@ -421,6 +883,111 @@ func (e *outputExpr) bindTypes(teb *typedExprBuilder) (err error) {
 	return nil
 }
 
+// bindPositional binds an output expression that reads all the columns
+// selected by an asterisk into a struct with positional db tags, e.g. "&P.*"
+// or "t.* AS &P.*". The asterisk is written to the query unchanged and the
+// result columns are read into the fields in order.
+func (e *outputExpr) bindPositional(teb *typedExprBuilder, typeName string) error {
+	if len(e.targetTypes) != 1 || len(e.sourceColumns) > 1 ||
+		(len(e.sourceColumns) == 1 && starCountColumns(e.sourceColumns) != 1) {
+		return fmt.Errorf(`struct with positional db tags can only be read with "&%[1]s.*", "* AS &%[1]s.*" or "t.* AS &%[1]s.*"`, typeName)
+	}
+	t := e.targetTypes[0]
+	columns := "*"
+	if len(e.sourceColumns) == 1 && e.sourceColumns[0].tableName() != "" {
+		columns = e.sourceColumns[0].tableName() + ".*"
+	}
+	outputs, _, err := teb.AllStructOutputs(t.typeName, t.label)
+	if err != nil {
+		return err
+	}
+	var outputColumns []outputColumn
+	for _, output := range outputs {
+		outputColumns = append(outputColumns, outputColumn{output: output, label: t.label})
+	}
+	teb.AddTypedPositionalOutputExpr(columns, outputColumns)
+	return nil
+}
+
+// bindCatchAllMap binds an output expression that reads every result column
+// selected by an asterisk into a map keyed by column name, e.g. "* AS &M.*"
+// or "t.* AS &M.*". The asterisk is written to the query unchanged. Columns
+// read by other output expressions are not put in the map.
+func (e *outputExpr) bindCatchAllMap(teb *typedExprBuilder) error {
+	t := e.targetTypes[0]
+	mapInfo, err := teb.InputMap(t.typeName)
+	if err != nil {
+		return err
+	}
+	columns := "*"
+	if table := e.sourceColumns[0].tableName(); table != "" {
+		columns = table + ".*"
+	}
+	teb.AddTypedCatchAllOutputExpr(columns, mapInfo, t.label)
+	return nil
+}
+
+// combinedTableSeparator separates the table name from the column name in the
+// db tags of a struct that combines the columns of several tables.
+const combinedTableSeparator = "_"
+
+// bindCombinedTables binds an output expression that reads the columns of
+// several tables into a single struct, e.g. "(p.*, a.*) AS (&PA.*)". Each db
+// tag of the struct must start with one of the table names followed by
+// combinedTableSeparator. The rest of the tag is the column name in that
+// table, so the tag "p_id" is read from "p.id", or from "p_id" with the
+// UnderscorePrefix column prefix. If several tables match, the longest table
+// name is used.
+func (e *outputExpr) bindCombinedTables(teb *typedExprBuilder) error {
+	var tables []string
+	for _, c := range e.sourceColumns {
+		if c.tableName() == "" {
+			return fmt.Errorf("invalid asterisk in columns")
+		}
+		tables = append(tables, c.tableName())
+	}
+	t := e.targetTypes[0]
+	kind, err := teb.Kind(t.typeName)
+	if err != nil {
+		return err
+	}
+	if kind != reflect.Struct {
+		return fmt.Errorf("cannot read columns of several tables into %s %q", kind, t.typeName)
+	}
+	outputs, memberNames, err := teb.AllStructOutputs(t.typeName, t.label)
+	if err != nil {
+		return err
+	}
+
+	tableUsed := map[string]bool{}
+	var outputColumns []outputColumn
+	for i, output := range outputs {
+		table := ""
+		for _, tn := range tables {
+			if strings.HasPrefix(memberNames[i], tn+combinedTableSeparator) && len(tn) > len(table) {
+				table = tn
+			}
+		}
+		column := strings.TrimPrefix(memberNames[i], table+combinedTableSeparator)
+		if table == "" || column == "" {
+			var prefixes []string
+			for _, tn := range tables {
+				prefixes = append(prefixes, tn+combinedTableSeparator)
+			}
+			return fmt.Errorf(`tag %q of struct %q does not start with one of the table prefixes "%s"`, memberNames[i], t.typeName, strings.Join(prefixes, `", "`))
+		}
+		tableUsed[table] = true
+		outputColumns = append(outputColumns, teb.prefixedOutputColumn(table, column, output, t.label))
+	}
+	for _, tn := range tables {
+		if !tableUsed[tn] {
+			return fmt.Errorf("no tag of struct %q starts with %q", t.typeName, tn+combinedTableSeparator)
+		}
+	}
+	teb.AddTypedOutputExpr(outputColumns)
+	return nil
+}
+
 // valueAccessor defines an accessor that can be used to generate a typedColumn
 // with the given column name.
 type valueAccessor interface {
@@ -434,6 +1001,9 @@ type valueAccessor interface {
 // of a struct, or a key of a map.
 type memberAccessor struct {
 	typeName, memberName string
+	// label distinguishes between output arguments of the same type. It is
+	// empty if the accessor is not labelled.
+	label string
 }
 
 // literal represents a literal expression be pasted verbatim as the value in an
@@ -453,6 +1023,12 @@ func (l literal) typedColumn(_ *typedExprBuilder, columnName string) (typedColum
 }
 
 func (ma memberAccessor) String() string {
+	if ma.memberName == "" {
+		return ma.typeName
+	}
+	if ma.label != "" {
+		return ma.typeName + ":" + ma.label + "." + ma.memberName
+	}
 	return ma.typeName + "." + ma.memberName
 }
 
@@ -467,6 +1043,12 @@ func (ma memberAccessor) typedColumn(teb *typedExprBuilder, columnName string) (
 	return ic, nil
 }
 
+// wholeTypeColumnError returns the error for a whole type used where the
+// column name is taken from the member name.
+func wholeTypeColumnError(typeName string) error {
+	return fmt.Errorf("cannot use whole type %q without an explicit column", typeName)
+}
+
 // starCountColumns counts the number of asterisks in a list of columns.
 func starCountColumns(cs []columnAccessor) int {
 	s := 0
@@ -0,0 +1,146 @@
+// Copyright 2024 Canonical Ltd.
+// Licensed under Apache 2.0, see LICENCE file for details.
+
+package expr
+
+import (
+	"reflect"
+
+	"github.com/canonical/sqlair/internal/typeinfo"
+)
+
+// Description describes the Go values used as the inputs and outputs of a
+// statement, in the order they appear in the query.
+type Description struct {
+	Inputs  []MemberDescription
+	Outputs []MemberDescription
+}
+
+// MemberDescription describes a Go value used as an input or output of a
+// statement.
+type MemberDescription struct {
+	// Expr is the text of the SQLair expression in the query, for example
+	// "&Person.*".
+	Expr string
+	// Column is the SQL column that the value is read from or written to. For
+	// outputs it is the column expression in the generated SQL, which may be
+	// qualified with a table or be a function call. It is empty for inputs
+	// that are not assigned to a column.
+	Column string
+	// Type is the type of the argument that holds the value.
+	Type reflect.Type
+	// Member is the db tag of the struct field or the key of the map. It is
+	// "*" for the keys of a map that are only known at query time and empty
+	// for slice inputs.
+	Member string
+	// Field is the name of the struct field. It is empty if Type is not a
+	// struct.
+	Field string
+	// Kind is the kind of the value. For slice inputs it is the kind of the
+	// slice elements.
+	Kind reflect.Kind
+	// Label is the label of an output, for example "p" in "&Person:p.*".
+	Label string
+}
+
+// Describe returns a description of the inputs and outputs of the statement.
+func (tbe *TypeBoundExpr) Describe() Description {
+	return Description{
+		Inputs:  append([]MemberDescription(nil), tbe.description.Inputs...),
+		Outputs: append([]MemberDescription(nil), tbe.description.Outputs...),
+	}
+}
+
+// describe adds descriptions of the inputs and outputs of typed expressions
+// generated from the SQLair expression with the given text to d.
+func (d *Description) describe(raw string, typedExprs []typedExpr) {
+	for _, te := range typedExprs {
+		switch te := te.(type) {
+		case *typedInputExpr:
+			d.Inputs = append(d.Inputs, describeMember(raw, "", te.input))
+		case *typedIdentExpr:
+			d.Inputs = append(d.Inputs, describeMember(raw, "", te.input))
+		case *typedNullSafeInExpr:
+			d.Inputs = append(d.Inputs, describeMember(raw, "", te.input))
+		case *typedUpdateSetExpr:
+			for i, input := range te.inputs {
+				d.Inputs = append(d.Inputs, describeMember(raw, te.columns[i], input))
+			}
+		case *typedUpdateSetMapExpr:
+			d.Inputs = append(d.Inputs, describeMapKeys(raw, te.mapInfo.Typ()))
+		case *typedInsertExpr:
+			for _, c := range te.insertColumns {
+				if ic, ok := c.(insertColumn); ok {
+					d.Inputs = append(d.Inputs, describeMember(raw, ic.column, ic.input))
+				}
+			}
+			if te.overflow != nil {
+				d.Inputs = append(d.Inputs, describeMapKeys(raw, te.overflow.mapInfo.Typ()))
+			}
+			for _, row := range te.extraRows {
+				for _, c := range row {
+					if ic, ok := c.(insertColumn); ok {
+						d.Inputs = append(d.Inputs, describeMember(raw, ic.column, ic.input))
+					}
+				}
+			}
+		case *typedOutputExpr:
+			for _, oc := range te.outputColumns {
+				md := describeMember(raw, oc.column, oc.output)
+				md.Label = oc.label
+				d.Outputs = append(d.Outputs, md)
+			}
+			if te.catchAll != nil {
+				md := describeMapKeys(raw, te.catchAll.mapInfo.Typ())
+				md.Column = te.catchAll.columns
+				md.Label = te.catchAll.label
+				d.Outputs = append(d.Outputs, md)
+			}
+		}
+	}
+}
+
+// describeMember returns the description of the value located by vl.
+func describeMember(raw string, column string, vl typeinfo.ValueLocator) MemberDescription {
+	m := vl.Member()
+	return MemberDescription{
+		Expr:   raw,
+		Column: column,
+		Type:   vl.ArgType(),
+		Member: m.Name,
+		Field:  m.Field,
+		Kind:   m.Type.Kind(),
+	}
+}
+
+// describeMapKeys returns the description of the values of a map whose keys
+// are only known at query time.
+func describeMapKeys(raw string, mapType reflect.Type) MemberDescription {
+	return MemberDescription{Expr: raw, Type: mapType, Member: "*", Kind: mapType.Elem().Kind()}
+}
+
+// exprText returns the text of a SQLair expression in the query. It is empty
+// for bypass parts.
+func exprText(e expression) string {
+	switch e := e.(type) {
+	case *memberInputExpr:
+		return e.raw
+	case *identInputExpr:
+		return e.raw
+	case *sliceInputExpr:
+		return e.raw
+	case *inListExpr:
+		return e.slice.raw
+	case *asteriskInsertExpr:
+		return e.raw
+	case *columnsInsertExpr:
+		return e.raw
+	case *basicInsertExpr:
+		return e.raw
+	case *updateAssignExpr:
+		return e.raw
+	case *outputExpr:
+		return e.raw
+	}
+	return ""
+}
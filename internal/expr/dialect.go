@@ -0,0 +1,197 @@
+// Copyright 2024 Canonical Ltd.
+// Licensed under Apache 2.0, see LICENCE file for details.
+
+package expr
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Dialect describes the parts of a database's SQL dialect that affect the SQL
+// generated by SQLair.
+type Dialect struct {
+	// name is the name of the dialect used in error messages.
+	name string
+	// placeholders specifies how query parameters are written in the SQL and
+	// passed to the driver.
+	placeholders placeholderStyle
+	// maxParams is the maximum number of query parameters accepted by the
+	// database. If it is zero there is no limit.
+	maxParams int
+	// timeoutSetting is the name of the setting that holds the server-side
+	// statement timeout in milliseconds. It is empty if the database has no
+	// such setting.
+	timeoutSetting string
+	// statementTimeout is true if the server-side statement timeout is set
+	// from the context deadline of queries run in a transaction.
+	statementTimeout bool
+	// emptySliceNull is true if an empty slice input is written as NULL
+	// rather than as nothing, so that "IN ($S[:])" becomes "IN (NULL)".
+	emptySliceNull bool
+	// excludedRow is true if the row that could not be inserted by an upsert
+	// can be referred to as "excluded" in the DO UPDATE clause.
+	excludedRow bool
+}
+
+// placeholderStyle specifies the syntax of query parameters.
+type placeholderStyle int
+
+const (
+	// namedPlaceholders are written as "@sqlair_N" and the parameters are
+	// passed to the driver as sql.NamedArg values.
+	namedPlaceholders placeholderStyle = iota
+	// numberedPlaceholders are written as "$N", with N starting at 1, and the
+	// parameters are passed to the driver in order as plain values.
+	numberedPlaceholders
+	// positionalPlaceholders are written as "?" and the parameters are passed
+	// to the driver as plain values in the order the placeholders appear in
+	// the SQL. A parameter whose placeholder is written more than once is
+	// passed once for each placeholder.
+	positionalPlaceholders
+)
+
+// SQLite is the dialect of SQLite databases. It is the default dialect. The
+// parameter limit is the default SQLITE_MAX_VARIABLE_NUMBER of SQLite 3.32.0
+// and later.
+var SQLite = &Dialect{name: "SQLite", placeholders: namedPlaceholders, maxParams: 32766, excludedRow: true}
+
+// Postgres is the dialect of PostgreSQL databases. The wire protocol limits the
+// number of parameters to 65535.
+var Postgres = &Dialect{name: "Postgres", placeholders: numberedPlaceholders, maxParams: 65535, timeoutSetting: "statement_timeout", excludedRow: true}
+
+// MySQL is the dialect of MySQL and MariaDB databases. The binary protocol
+// limits the number of parameters of a prepared statement to 65535. MySQL has
+// no "excluded" row, so the SET clause of an upsert cannot be generated.
+var MySQL = &Dialect{name: "MySQL", placeholders: positionalPlaceholders, maxParams: 65535}
+
+// String returns the name of the dialect.
+func (d *Dialect) String() string {
+	return d.name
+}
+
+// MaxParams returns the maximum number of query parameters accepted by the
+// database. Zero means there is no limit.
+func (d *Dialect) MaxParams() int {
+	return d.maxParams
+}
+
+// WithMaxParams returns a copy of the dialect with the maximum number of query
+// parameters set to n. If n is zero or negative there is no limit.
+func (d *Dialect) WithMaxParams(n int) *Dialect {
+	if n < 0 {
+		n = 0
+	}
+	nd := *d
+	nd.maxParams = n
+	return &nd
+}
+
+// WithStatementTimeout returns a copy of the dialect with server-side statement
+// timeouts enabled.
+func (d *Dialect) WithStatementTimeout() *Dialect {
+	nd := *d
+	nd.statementTimeout = true
+	return &nd
+}
+
+// WithEmptySliceNull returns a copy of the dialect that writes an empty slice
+// input as NULL.
+func (d *Dialect) WithEmptySliceNull() *Dialect {
+	nd := *d
+	nd.emptySliceNull = true
+	return &nd
+}
+
+// HasStatementTimeout returns true if the dialect has a server-side statement
+// timeout and statement timeouts are enabled.
+func (d *Dialect) HasStatementTimeout() bool {
+	return d.statementTimeout && d.timeoutSetting != ""
+}
+
+// StatementTimeoutSQL returns the SQL that sets the server-side timeout of the
+// statements that follow it in the current transaction. The timeout is rounded
+// up to whole milliseconds.
+func (d *Dialect) StatementTimeoutSQL(timeout time.Duration) string {
+	// A timeout of zero disables the server-side timeout, so a deadline that
+	// has already passed is set as the shortest timeout instead.
+	ms := (timeout + time.Millisecond - 1) / time.Millisecond
+	if ms < 1 {
+		ms = 1
+	}
+	return "SET LOCAL " + d.timeoutSetting + " = " + strconv.FormatInt(int64(ms), 10)
+}
+
+// ResetStatementTimeoutSQL returns the SQL that resets the server-side timeout
+// of the statements that follow it in the current transaction to its default.
+func (d *Dialect) ResetStatementTimeoutSQL() string {
+	return "SET LOCAL " + d.timeoutSetting + " TO DEFAULT"
+}
+
+// dialects are the known dialects, which can be decoded from JSON by name.
+var dialects = []*Dialect{SQLite, Postgres, MySQL}
+
+// encodedDialect is the JSON encoding of a Dialect.
+type encodedDialect struct {
+	Name             string `json:"name"`
+	MaxParams        int    `json:"maxParams"`
+	StatementTimeout bool   `json:"statementTimeout,omitempty"`
+	EmptySliceNull   bool   `json:"emptySliceNull,omitempty"`
+}
+
+// MarshalJSON encodes the dialect as its name, parameter limit and options.
+func (d *Dialect) MarshalJSON() ([]byte, error) {
+	return json.Marshal(encodedDialect{
+		Name:             d.name,
+		MaxParams:        d.maxParams,
+		StatementTimeout: d.statementTimeout,
+		EmptySliceNull:   d.emptySliceNull,
+	})
+}
+
+// UnmarshalJSON decodes a dialect encoded with MarshalJSON. The name must be
+// the name of a known dialect.
+func (d *Dialect) UnmarshalJSON(data []byte) error {
+	var ed encodedDialect
+	if err := json.Unmarshal(data, &ed); err != nil {
+		return err
+	}
+	for _, known := range dialects {
+		if known.name == ed.Name {
+			*d = *known.WithMaxParams(ed.MaxParams)
+			d.statementTimeout = ed.StatementTimeout
+			d.emptySliceNull = ed.EmptySliceNull
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown dialect %q", ed.Name)
+}
+
+// checkParamCount returns an error if the number of query parameters exceeds
+// the limit of the dialect.
+func (d *Dialect) checkParamCount(n int) error {
+	if d.maxParams > 0 && n > d.maxParams {
+		return fmt.Errorf("query has %d parameters, exceeds %s limit of %d; reduce slice size or batch", n, d.name, d.maxParams)
+	}
+	return nil
+}
+
+// placeholder returns the SQL placeholder for the query parameter with the
+// input number n.
+func (d *Dialect) placeholder(n int) string {
+	switch d.placeholders {
+	case numberedPlaceholders:
+		return "$" + strconv.Itoa(n+1)
+	case positionalPlaceholders:
+		return "?"
+	default:
+		return "@" + inputName(n)
+	}
+}
+
+// inputName returns the name of the named query parameter with input number n.
+func inputName(n int) string {
+	return "sqlair_" + strconv.Itoa(n)
+}
@@ -6,9 +6,14 @@ package expr_test
 import (
 	"database/sql"
 	"database/sql/driver"
+	"encoding/json"
+	"math"
+	"reflect"
+	"regexp"
 	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/canonical/sqlair"
 	"github.com/canonical/sqlair/internal/expr"
@@ -22,6 +27,18 @@ type ExprSuite struct{}
 
 var _ = Suite(&ExprSuite{})
 
+type Sort struct {
+	Column    sqlair.Ident         `db:"column"`
+	Direction sqlair.SortDirection `db:"direction"`
+}
+
+type PersonAddress struct {
+	PersonID      int    `db:"p_id"`
+	Name          string `db:"p_name"`
+	AddressID     int    `db:"address_id"`
+	AddressStreet string `db:"address_street"`
+}
+
 type Address struct {
 	ID       int    `db:"id"`
 	District string `db:"district"`
@@ -36,6 +53,12 @@ type Person struct {
 
 type Manager Person
 
+type Resident struct {
+	ID   int `db:"id"`
+	Home Address
+	Work *Address
+}
+
 type HardMaths struct {
 	X    int `db:"x"`
 	Y    int `db:"y"`
@@ -65,6 +88,8 @@ type IntSlice []int
 
 type StringSlice []string
 
+type PairSlice [][2]int
+
 type Unicode我Struct struct {
 	X人 int    `db:"საფოსტო"`
 	X我 int    `db:"住所"`
@@ -331,8 +356,8 @@ AND z = @sqlair_0 -- The line with $Person.id on it
 	expectedParsed: "[Bypass[SELECT ] Output[[p.*] [Person.*]] Bypass[ FROM person WHERE p.name IN (SELECT name FROM table WHERE table.n = ] Input[Person.name] Bypass[) UNION SELECT ] Output[[a.district a.street] [Address.*]] Bypass[ FROM person WHERE p.name IN (SELECT name FROM table WHERE table.n = ] Input[Person.name] Bypass[)]]",
 	typeSamples:    []any{Person{}, Address{}},
 	inputArgs:      []any{Person{Fullname: "Foo"}},
-	expectedParams: []any{"Foo", "Foo"},
-	expectedSQL:    `SELECT p.address_id AS _sqlair_0, p.id AS _sqlair_1, p.name AS _sqlair_2 FROM person WHERE p.name IN (SELECT name FROM table WHERE table.n = @sqlair_0) UNION SELECT a.district AS _sqlair_3, a.street AS _sqlair_4 FROM person WHERE p.name IN (SELECT name FROM table WHERE table.n = @sqlair_1)`,
+	expectedParams: []any{"Foo"},
+	expectedSQL:    `SELECT p.address_id AS _sqlair_0, p.id AS _sqlair_1, p.name AS _sqlair_2 FROM person WHERE p.name IN (SELECT name FROM table WHERE table.n = @sqlair_0) UNION SELECT a.district AS _sqlair_3, a.street AS _sqlair_4 FROM person WHERE p.name IN (SELECT name FROM table WHERE table.n = @sqlair_0)`,
 }, {
 	summary:        "complex query v5",
 	query:          "SELECT p.* AS &Person.* FROM person AS p JOIN address AS a ON p.address_id = a.id WHERE p.name = $Person.name AND p.address_id = $Person.address_id",
@@ -401,9 +426,9 @@ AND z = @sqlair_0 -- The line with $Person.id on it
 	(] Input[HardMaths.coef] Bypass[%] Input[HardMaths.x] Bypass[)-] Input[HardMaths.y] Bypass[|] Input[HardMaths.z] Bypass[<] Input[HardMaths.z] Bypass[<>] Input[HardMaths.x]]`,
 	typeSamples:    []any{HardMaths{}},
 	inputArgs:      []any{HardMaths{X: 1, Y: 2, Z: 3, Coef: 4}},
-	expectedParams: []any{1, 2, 3, 4, 1, 2, 3, 3, 1},
+	expectedParams: []any{1, 2, 3, 4},
 	expectedSQL: `SELECT name FROM person WHERE id =@sqlair_0+@sqlair_1/@sqlair_2-
-	(@sqlair_3%@sqlair_4)-@sqlair_5|@sqlair_6<@sqlair_7<>@sqlair_8`,
+	(@sqlair_3%@sqlair_0)-@sqlair_1|@sqlair_2<@sqlair_2<>@sqlair_0`,
 }, {
 	summary:        "insert array",
 	query:          "INSERT INTO arr VALUES (ARRAY[[1,2],[$HardMaths.x,4]], ARRAY[[5,6],[$HardMaths.y,8]]);",
@@ -420,14 +445,56 @@ AND z = @sqlair_0 -- The line with $Person.id on it
 	inputArgs:      []any{},
 	expectedParams: []any{},
 	expectedSQL:    `SELECT max(AVG(id), AVG(address_id), length("((((''""((")) AS _sqlair_0, IFNULL(name, "Mr &Person.id of $M.name") AS _sqlair_1, random() AS _sqlair_2 FROM person`,
+}, {
+	summary:        "function containing inputs",
+	query:          "SELECT EXISTS(SELECT 1 FROM person WHERE id = $Person.id) AS &M.exists",
+	expectedParsed: "[Bypass[SELECT EXISTS(SELECT 1 FROM person WHERE id = ] Input[Person.id] Bypass[) ] Output[[<expr>] [M.exists]]]",
+	typeSamples:    []any{Person{}, sqlair.M{}},
+	inputArgs:      []any{Person{ID: 1}},
+	expectedParams: []any{1},
+	expectedSQL:    "SELECT EXISTS(SELECT 1 FROM person WHERE id = @sqlair_0) AS _sqlair_0",
+}, {
+	summary:        "delete slice returning",
+	query:          "DELETE FROM person WHERE id IN ($S[:]) AND name = $Person.name RETURNING &Person.*",
+	expectedParsed: "[Bypass[DELETE FROM person WHERE ] InList[id IN S[:]] Bypass[ AND name = ] Input[Person.name] Bypass[ RETURNING ] Output[[] [Person.*]]]",
+	typeSamples:    []any{Person{}, sqlair.S{}},
+	inputArgs:      []any{Person{Fullname: "Fred"}, sqlair.S{1, 2}},
+	expectedParams: []any{1, 2, "Fred"},
+	expectedSQL:    "DELETE FROM person WHERE id IN (@sqlair_0, @sqlair_1) AND name = @sqlair_2 RETURNING address_id AS _sqlair_0, id AS _sqlair_1, name AS _sqlair_2",
+}, {
+	summary:        "insert default values returning",
+	query:          "INSERT INTO person DEFAULT VALUES RETURNING &Person.*",
+	expectedParsed: "[Bypass[INSERT INTO person DEFAULT VALUES RETURNING ] Output[[] [Person.*]]]",
+	typeSamples:    []any{Person{}},
+	expectedSQL:    "INSERT INTO person DEFAULT VALUES RETURNING address_id AS _sqlair_0, id AS _sqlair_1, name AS _sqlair_2",
+}, {
+	summary:        "insert default values returning columns",
+	query:          "insert into person default values returning (id, name) AS (&Person.id, &M.name)",
+	expectedParsed: "[Bypass[insert into person default values returning ] Output[[id name] [Person.id M.name]]]",
+	typeSamples:    []any{Person{}, sqlair.M{}},
+	expectedSQL:    "insert into person default values returning id AS _sqlair_0, name AS _sqlair_1",
+}, {
+	summary:        "several tables into one struct",
+	query:          "SELECT (p.*, address.*) AS (&PersonAddress.*) FROM person AS p JOIN address",
+	expectedParsed: "[Bypass[SELECT ] Output[[p.* address.*] [PersonAddress.*]] Bypass[ FROM person AS p JOIN address]]",
+	typeSamples:    []any{PersonAddress{}},
+	expectedSQL:    "SELECT address.id AS _sqlair_0, address.street AS _sqlair_1, p.id AS _sqlair_2, p.name AS _sqlair_3 FROM person AS p JOIN address",
 }, {
 	summary:        "single slice",
 	query:          "SELECT name FROM person WHERE id IN ($S[:])",
-	expectedParsed: "[Bypass[SELECT name FROM person WHERE id IN (] Input[S[:]] Bypass[)]]",
+	expectedParsed: "[Bypass[SELECT name FROM person WHERE ] InList[id IN S[:]]]",
 	typeSamples:    []any{sqlair.S{}},
 	inputArgs:      []any{sqlair.S{1, 2, 3}},
 	expectedParams: []any{1, 2, 3},
 	expectedSQL:    "SELECT name FROM person WHERE id IN (@sqlair_0, @sqlair_1, @sqlair_2)",
+}, {
+	summary:        "slice in NOT IN list",
+	query:          `SELECT name FROM person WHERE p."id" not in( $S[:] ) AND id NOTIN ($IntSlice[:])`,
+	expectedParsed: `[Bypass[SELECT name FROM person WHERE ] InList[p."id" NOT IN S[:]] Bypass[ AND id NOTIN (] Input[IntSlice[:]] Bypass[)]]`,
+	typeSamples:    []any{sqlair.S{}, IntSlice{}},
+	inputArgs:      []any{sqlair.S{1, 2}, IntSlice{3}},
+	expectedParams: []any{1, 2, 3},
+	expectedSQL:    `SELECT name FROM person WHERE p."id" not in( @sqlair_0, @sqlair_1 ) AND id NOTIN (@sqlair_2)`,
 }, {
 	summary:        "many slices",
 	query:          "SELECT * AS &Person.* FROM person WHERE id IN ($Person.id, $S[:], $Manager.id, $IntSlice[:], $StringSlice[:])",
@@ -447,7 +514,7 @@ AND z = @sqlair_0 -- The line with $Person.id on it
 }, {
 	summary:        "slice of mixed types",
 	query:          "SELECT name FROM person WHERE id IN ($S[:])",
-	expectedParsed: "[Bypass[SELECT name FROM person WHERE id IN (] Input[S[:]] Bypass[)]]",
+	expectedParsed: "[Bypass[SELECT name FROM person WHERE ] InList[id IN S[:]]]",
 	typeSamples:    []any{sqlair.S{}},
 	inputArgs:      []any{sqlair.S{1, "two", 3.0}},
 	expectedParams: []any{1, "two", 3.0},
@@ -457,7 +524,7 @@ AND z = @sqlair_0 -- The line with $Person.id on it
 	// not want to limit the use of slices to only the cases we have foreseen.
 	summary:        "empty slice",
 	query:          "SELECT name FROM person WHERE id IN ($S[:])",
-	expectedParsed: "[Bypass[SELECT name FROM person WHERE id IN (] Input[S[:]] Bypass[)]]",
+	expectedParsed: "[Bypass[SELECT name FROM person WHERE ] InList[id IN S[:]]]",
 	typeSamples:    []any{sqlair.S{}},
 	inputArgs:      []any{sqlair.S{}},
 	expectedParams: []any{},
@@ -468,7 +535,7 @@ AND z = @sqlair_0 -- The line with $Person.id on it
 	// allowed as well.
 	summary:        "nil slice",
 	query:          "SELECT name FROM person WHERE id IN ($S[:])",
-	expectedParsed: "[Bypass[SELECT name FROM person WHERE id IN (] Input[S[:]] Bypass[)]]",
+	expectedParsed: "[Bypass[SELECT name FROM person WHERE ] InList[id IN S[:]]]",
 	typeSamples:    []any{sqlair.S{}},
 	inputArgs:      []any{(sqlair.S)(nil)},
 	expectedParams: []any{},
@@ -482,6 +549,158 @@ AND z = @sqlair_0 -- The line with $Person.id on it
 	inputArgs:      []any{Address{Street: "Wallaby Way"}, Person{ID: 34, Fullname: "Dory", PostalCode: 11111}, sqlair.M{"team": "OCTO"}},
 	expectedParams: []any{"Wallaby Way", 11111, 34, "Dory", "OCTO"},
 	expectedSQL:    "INSERT INTO person (street, address_id, id, name, team) VALUES (@sqlair_0, @sqlair_1, @sqlair_2, @sqlair_3, @sqlair_4)",
+}, {
+	summary:        "update set asterisk with except",
+	query:          "UPDATE person SET (* EXCEPT (id)) = ($Person.*) WHERE id = $Person.id",
+	expectedParsed: "[Bypass[UPDATE person ] UpdateAssign[[* EXCEPT [id]] [Person.*]] Bypass[ WHERE id = ] Input[Person.id]]",
+	typeSamples:    []any{Person{}},
+	inputArgs:      []any{Person{ID: 34, Fullname: "Dory", PostalCode: 11111}},
+	expectedParams: []any{11111, "Dory", 34},
+	expectedSQL:    "UPDATE person SET address_id = @sqlair_0, name = @sqlair_1 WHERE id = @sqlair_2",
+}, {
+	summary:        "update set asterisk",
+	query:          "UPDATE address set ( * ) = ( $Address.* ) WHERE id = $M.id",
+	expectedParsed: "[Bypass[UPDATE address ] UpdateAssign[[*] [Address.*]] Bypass[ WHERE id = ] Input[M.id]]",
+	typeSamples:    []any{Address{}, sqlair.M{}},
+	inputArgs:      []any{Address{ID: 1, District: "Kings", Street: "Main"}, sqlair.M{"id": 1}},
+	expectedParams: []any{"Kings", 1, "Main", 1},
+	expectedSQL:    "UPDATE address SET district = @sqlair_0, id = @sqlair_1, street = @sqlair_2 WHERE id = @sqlair_3",
+}, {
+	summary:        "update set asterisk shares parameters with member inputs",
+	query:          "UPDATE person SET (*) = ($Person.*) WHERE id = $Person.id",
+	expectedParsed: "[Bypass[UPDATE person ] UpdateAssign[[*] [Person.*]] Bypass[ WHERE id = ] Input[Person.id]]",
+	typeSamples:    []any{Person{}},
+	inputArgs:      []any{Person{ID: 34, Fullname: "Dory", PostalCode: 11111}},
+	expectedParams: []any{11111, 34, "Dory"},
+	expectedSQL:    "UPDATE person SET address_id = @sqlair_0, id = @sqlair_1, name = @sqlair_2 WHERE id = @sqlair_1",
+}, {
+	summary:        "update set map shares parameters with member inputs",
+	query:          "UPDATE person SET (*) = ($M.*) WHERE id = $M.id",
+	expectedParsed: "[Bypass[UPDATE person ] UpdateAssign[[*] [M.*]] Bypass[ WHERE id = ] Input[M.id]]",
+	typeSamples:    []any{sqlair.M{}},
+	inputArgs:      []any{sqlair.M{"id": 34, "name": "Dory"}},
+	expectedParams: []any{34, "Dory"},
+	expectedSQL:    "UPDATE person SET id = @sqlair_0, name = @sqlair_1 WHERE id = @sqlair_0",
+}, {
+	summary:        "upsert shares parameters with member inputs",
+	query:          "INSERT INTO person (*) VALUES ($Person.*) ON CONFLICT (id) DO UPDATE SET (name) = ($Person.*) WHERE person.id = $Person.id",
+	expectedParsed: "[Bypass[INSERT INTO person ] AsteriskInsert[[*] [Person.*]] Bypass[ ON CONFLICT (id) DO UPDATE ] UpdateAssign[[name] [Person.*]] Bypass[ WHERE person.id = ] Input[Person.id]]",
+	typeSamples:    []any{Person{}},
+	inputArgs:      []any{Person{ID: 34, Fullname: "Dory", PostalCode: 11111}},
+	expectedParams: []any{11111, 34, "Dory"},
+	expectedSQL:    "INSERT INTO person (address_id, id, name) VALUES (@sqlair_0, @sqlair_1, @sqlair_2) ON CONFLICT (id) DO UPDATE SET name = excluded.name WHERE person.id = @sqlair_1",
+}, {
+	summary:        "insert columns share parameters",
+	query:          "INSERT INTO person (id, name, alias) VALUES ($Person.id, $Person.name, $Person.name) RETURNING $Person.id",
+	expectedParsed: "[Bypass[INSERT INTO person ] BasicInsert[[id name alias] [Person.id Person.name Person.name]] Bypass[ RETURNING ] Input[Person.id]]",
+	typeSamples:    []any{Person{}},
+	inputArgs:      []any{Person{ID: 34, Fullname: "Dory"}},
+	expectedParams: []any{34, "Dory"},
+	expectedSQL:    "INSERT INTO person (id, name, alias) VALUES (@sqlair_0, @sqlair_1, @sqlair_1) RETURNING @sqlair_0",
+}, {
+	summary:        "update set map",
+	query:          "UPDATE person SET (* EXCEPT (id)) = ($M.*) WHERE id = $M.id",
+	expectedParsed: "[Bypass[UPDATE person ] UpdateAssign[[* EXCEPT [id]] [M.*]] Bypass[ WHERE id = ] Input[M.id]]",
+	typeSamples:    []any{sqlair.M{}},
+	inputArgs:      []any{sqlair.M{"id": 34, "name": "Dory", "address_id": 11111}},
+	expectedParams: []any{11111, "Dory", 34},
+	expectedSQL:    "UPDATE person SET address_id = @sqlair_0, name = @sqlair_1 WHERE id = @sqlair_2",
+}, {
+	summary:        "update set map with only some keys",
+	query:          "UPDATE person SET (*) = ($M.*) WHERE id = $Person.id",
+	expectedParsed: "[Bypass[UPDATE person ] UpdateAssign[[*] [M.*]] Bypass[ WHERE id = ] Input[Person.id]]",
+	typeSamples:    []any{sqlair.M{}, Person{}},
+	inputArgs:      []any{sqlair.M{"name": "Dory"}, Person{ID: 34}},
+	expectedParams: []any{"Dory", 34},
+	expectedSQL:    "UPDATE person SET name = @sqlair_0 WHERE id = @sqlair_1",
+}, {
+	summary:        "upsert update assign asterisk",
+	query:          "INSERT INTO person (*) VALUES ($Person.*) ON CONFLICT (id) DO UPDATE SET (*) = ($Person.*)",
+	expectedParsed: "[Bypass[INSERT INTO person ] AsteriskInsert[[*] [Person.*]] Bypass[ ON CONFLICT (id) DO UPDATE ] UpdateAssign[[*] [Person.*]]]",
+	typeSamples:    []any{Person{}},
+	inputArgs:      []any{Person{ID: 34, Fullname: "Dory", PostalCode: 11111}},
+	expectedParams: []any{11111, 34, "Dory"},
+	expectedSQL:    "INSERT INTO person (address_id, id, name) VALUES (@sqlair_0, @sqlair_1, @sqlair_2) ON CONFLICT (id) DO UPDATE SET address_id = excluded.address_id, id = excluded.id, name = excluded.name",
+}, {
+	summary:        "upsert update assign asterisk with except",
+	query:          "INSERT INTO person (*) VALUES ($Person.*) ON CONFLICT (id) DO UPDATE SET (* EXCEPT (id)) = ($Person.*)",
+	expectedParsed: "[Bypass[INSERT INTO person ] AsteriskInsert[[*] [Person.*]] Bypass[ ON CONFLICT (id) DO UPDATE ] UpdateAssign[[* EXCEPT [id]] [Person.*]]]",
+	typeSamples:    []any{Person{}},
+	inputArgs:      []any{Person{ID: 34, Fullname: "Dory", PostalCode: 11111}},
+	expectedParams: []any{11111, 34, "Dory"},
+	expectedSQL:    "INSERT INTO person (address_id, id, name) VALUES (@sqlair_0, @sqlair_1, @sqlair_2) ON CONFLICT (id) DO UPDATE SET address_id = excluded.address_id, name = excluded.name",
+}, {
+	summary:        "update assign asterisk with except and members",
+	query:          "UPDATE person SET (* EXCEPT (id, street)) = ($Person.*, $Address.street, $Address.district) WHERE id = $Person.id",
+	expectedParsed: "[Bypass[UPDATE person ] UpdateAssign[[* EXCEPT [id street]] [Person.* Address.street Address.district]] Bypass[ WHERE id = ] Input[Person.id]]",
+	typeSamples:    []any{Person{}, Address{}},
+	inputArgs:      []any{Person{ID: 34, Fullname: "Dory", PostalCode: 11111}, Address{District: "Kings", Street: "Main"}},
+	expectedParams: []any{11111, "Dory", "Kings", 34},
+	expectedSQL:    "UPDATE person SET address_id = @sqlair_0, name = @sqlair_1, district = @sqlair_2 WHERE id = @sqlair_3",
+}, {
+	summary:        "upsert update assign columns",
+	query:          "INSERT INTO person (*) VALUES ($Person.*) ON CONFLICT (id) do update SET (name) = ($Person.*) WHERE name <> 'Fred'",
+	expectedParsed: "[Bypass[INSERT INTO person ] AsteriskInsert[[*] [Person.*]] Bypass[ ON CONFLICT (id) do update ] UpdateAssign[[name] [Person.*]] Bypass[ WHERE name <> 'Fred']]",
+	typeSamples:    []any{Person{}},
+	inputArgs:      []any{Person{ID: 34, Fullname: "Dory", PostalCode: 11111}},
+	expectedParams: []any{11111, 34, "Dory"},
+	expectedSQL:    "INSERT INTO person (address_id, id, name) VALUES (@sqlair_0, @sqlair_1, @sqlair_2) ON CONFLICT (id) do update SET name = excluded.name WHERE name <> 'Fred'",
+}, {
+	summary:        "upsert update assign leaves out omitted columns",
+	query:          "INSERT INTO person (*) VALUES ($OmitEmptyPerson.*) ON CONFLICT (name) DO UPDATE SET (*) = ($OmitEmptyPerson.*)",
+	expectedParsed: "[Bypass[INSERT INTO person ] AsteriskInsert[[*] [OmitEmptyPerson.*]] Bypass[ ON CONFLICT (name) DO UPDATE ] UpdateAssign[[*] [OmitEmptyPerson.*]]]",
+	typeSamples:    []any{OmitEmptyPerson{}},
+	inputArgs:      []any{OmitEmptyPerson{Fullname: "Dory", PostalCode: 11111}},
+	expectedParams: []any{11111, "Dory"},
+	expectedSQL:    "INSERT INTO person (address_id, name) VALUES (@sqlair_0, @sqlair_1) ON CONFLICT (name) DO UPDATE SET address_id = excluded.address_id, name = excluded.name",
+}, {
+	summary:        "update assign asterisk",
+	query:          "UPDATE address SET (*) = ($Address.*) WHERE id = $Address.id",
+	expectedParsed: "[Bypass[UPDATE address ] UpdateAssign[[*] [Address.*]] Bypass[ WHERE id = ] Input[Address.id]]",
+	typeSamples:    []any{Address{}},
+	inputArgs:      []any{Address{ID: 1, District: "Kings", Street: "Main"}},
+	expectedParams: []any{"Kings", 1, "Main"},
+	expectedSQL:    "UPDATE address SET district = @sqlair_0, id = @sqlair_1, street = @sqlair_2 WHERE id = @sqlair_1",
+}, {
+	summary:        "update assign asterisk with members",
+	query:          "UPDATE person SET (*) = ($Person.name, $Address.street) WHERE id = $Person.id",
+	expectedParsed: "[Bypass[UPDATE person ] UpdateAssign[[*] [Person.name Address.street]] Bypass[ WHERE id = ] Input[Person.id]]",
+	typeSamples:    []any{Person{}, Address{}},
+	inputArgs:      []any{Person{ID: 34, Fullname: "Dory"}, Address{Street: "Main"}},
+	expectedParams: []any{"Dory", "Main", 34},
+	expectedSQL:    "UPDATE person SET name = @sqlair_0, street = @sqlair_1 WHERE id = @sqlair_2",
+}, {
+	summary:        "update assign columns",
+	query:          "UPDATE person SET (name, address_id) = ($Person.*) WHERE id = $Person.id",
+	expectedParsed: "[Bypass[UPDATE person ] UpdateAssign[[name address_id] [Person.*]] Bypass[ WHERE id = ] Input[Person.id]]",
+	typeSamples:    []any{Person{}},
+	inputArgs:      []any{Person{ID: 34, Fullname: "Dory", PostalCode: 11111}},
+	expectedParams: []any{"Dory", 11111, 34},
+	expectedSQL:    "UPDATE person SET name = @sqlair_0, address_id = @sqlair_1 WHERE id = @sqlair_2",
+}, {
+	summary:        "update assign columns with map",
+	query:          "UPDATE person SET (name, team) = ($Person.*, $M.*) WHERE id = $Person.id",
+	expectedParsed: "[Bypass[UPDATE person ] UpdateAssign[[name team] [Person.* M.*]] Bypass[ WHERE id = ] Input[Person.id]]",
+	typeSamples:    []any{Person{}, sqlair.M{}},
+	inputArgs:      []any{Person{ID: 34, Fullname: "Dory"}, sqlair.M{"team": "OCTO"}},
+	expectedParams: []any{"Dory", "OCTO", 34},
+	expectedSQL:    "UPDATE person SET name = @sqlair_0, team = @sqlair_1 WHERE id = @sqlair_2",
+}, {
+	summary:        "update assign map",
+	query:          "UPDATE person SET (*) = ($M.*) WHERE id = $Person.id",
+	expectedParsed: "[Bypass[UPDATE person ] UpdateAssign[[*] [M.*]] Bypass[ WHERE id = ] Input[Person.id]]",
+	typeSamples:    []any{sqlair.M{}, Person{}},
+	inputArgs:      []any{sqlair.M{"name": "Dory", "address_id": 11111}, Person{ID: 34}},
+	expectedParams: []any{11111, "Dory", 34},
+	expectedSQL:    "UPDATE person SET address_id = @sqlair_0, name = @sqlair_1 WHERE id = @sqlair_2",
+}, {
+	summary:        "update row value without asterisk types",
+	query:          "UPDATE person SET (name, id) = ($Person.name, $Person.id)",
+	expectedParsed: "[Bypass[UPDATE person SET (name, id) = (] Input[Person.name] Bypass[, ] Input[Person.id] Bypass[)]]",
+	typeSamples:    []any{Person{}},
+	inputArgs:      []any{Person{ID: 34, Fullname: "Dory"}},
+	expectedParams: []any{"Dory", 34},
+	expectedSQL:    "UPDATE person SET (name, id) = (@sqlair_0, @sqlair_1)",
 }, {
 	summary:        "insert specified columns to single struct",
 	query:          "INSERT INTO person (id, street) VALUES ($Address.*)",
@@ -490,6 +709,30 @@ AND z = @sqlair_0 -- The line with $Person.id on it
 	inputArgs:      []any{Address{ID: 34, Street: "Wallaby Way"}},
 	expectedParams: []any{34, "Wallaby Way"},
 	expectedSQL:    "INSERT INTO person (id, street) VALUES (@sqlair_0, @sqlair_1)",
+}, {
+	summary:        "insert asterisk with overflow map",
+	query:          "INSERT INTO person (*) VALUES ($Address.*, $M.*)",
+	expectedParsed: "[Bypass[INSERT INTO person ] AsteriskInsert[[*] [Address.* M.*]]]",
+	typeSamples:    []any{Address{}, sqlair.M{}},
+	inputArgs:      []any{Address{ID: 1, Street: "Main Street"}, sqlair.M{"name": "Fred", "age": 30}},
+	expectedParams: []any{"", 1, "Main Street", 30, "Fred"},
+	expectedSQL:    "INSERT INTO person (district, id, street, age, name) VALUES (@sqlair_0, @sqlair_1, @sqlair_2, @sqlair_3, @sqlair_4)",
+}, {
+	summary:        "insert asterisk with only overflow map",
+	query:          "INSERT INTO person (*) VALUES ($M.*)",
+	expectedParsed: "[Bypass[INSERT INTO person ] AsteriskInsert[[*] [M.*]]]",
+	typeSamples:    []any{sqlair.M{}},
+	inputArgs:      []any{sqlair.M{"name": "Fred", "id": 30}},
+	expectedParams: []any{30, "Fred"},
+	expectedSQL:    "INSERT INTO person (id, name) VALUES (@sqlair_0, @sqlair_1)",
+}, {
+	summary:        "bulk insert asterisk with overflow map",
+	query:          "INSERT INTO person (*) VALUES ($Person.id, $M.*)",
+	expectedParsed: "[Bypass[INSERT INTO person ] AsteriskInsert[[*] [Person.id M.*]]]",
+	typeSamples:    []any{Person{}, sqlair.M{}},
+	inputArgs:      []any{[]Person{{ID: 1}, {ID: 2}}, []sqlair.M{{"name": "Fred"}, {"name": "Mark"}}},
+	expectedParams: []any{1, 2, "Fred", "Mark"},
+	expectedSQL:    "INSERT INTO person (id, name) VALUES (@sqlair_0, @sqlair_2), (@sqlair_1, @sqlair_3)",
 }, {
 	summary:        "insert specified columns to single map",
 	query:          "INSERT INTO person (id, street) VALUES ($M.*)",
@@ -554,6 +797,14 @@ AND z = @sqlair_0 -- The line with $Person.id on it
 	inputArgs:      []any{Address{ID: 34, Street: "Wallaby Way", District: "Sydney"}},
 	expectedParams: []any{"Sydney", 34, "Wallaby Way"},
 	expectedSQL:    "INSERT INTO address(district, id, street) VALUES (@sqlair_0, @sqlair_1, @sqlair_2) RETURNING (district AS _sqlair_0, id AS _sqlair_1, street AS _sqlair_2)",
+}, {
+	summary:        "insert with returning clause into two types",
+	query:          "INSERT INTO person (*) VALUES ($Person.*) RETURNING id AS &Person.id, a.street AS &Address.street",
+	expectedParsed: "[Bypass[INSERT INTO person ] AsteriskInsert[[*] [Person.*]] Bypass[ RETURNING ] Output[[id] [Person.id]] Bypass[, ] Output[[a.street] [Address.street]]]",
+	typeSamples:    []any{Person{}, Address{}},
+	inputArgs:      []any{Person{ID: 34, Fullname: "Dory", PostalCode: 11111}},
+	expectedParams: []any{11111, 34, "Dory"},
+	expectedSQL:    "INSERT INTO person (address_id, id, name) VALUES (@sqlair_0, @sqlair_1, @sqlair_2) RETURNING id AS _sqlair_0, a.street AS _sqlair_1",
 }, {
 	summary: "insert rename columns with standalone inputs",
 	query: `INSERT INTO person (id, random_string, random_thing, number, equation, street) VALUES ($Person.address_id, "random string", rand(), 1000, 
@@ -571,6 +822,14 @@ AND z = @sqlair_0 -- The line with $Person.id on it
 	inputArgs:      []any{Person{Fullname: "John Doe"}},
 	expectedParams: []any{"John Doe"},
 	expectedSQL:    "INSERT INTO person (name) VALUES (@sqlair_0)",
+}, {
+	summary:        "insert multiple rows of values",
+	query:          `INSERT INTO person (id, name) VALUES ($Person.id, $Person.name), ($Address.id, $Address.street), (0, "nobody")`,
+	expectedParsed: `[Bypass[INSERT INTO person ] BasicInsert[[id name] [Person.id Person.name] [Address.id Address.street] [0 "nobody"]]]`,
+	typeSamples:    []any{Person{}, Address{}},
+	inputArgs:      []any{Person{ID: 1, Fullname: "Fred"}, Address{ID: 2, Street: "Wallaby Way"}},
+	expectedParams: []any{1, "Fred", 2, "Wallaby Way"},
+	expectedSQL:    `INSERT INTO person (id, name) VALUES (@sqlair_0, @sqlair_1), (@sqlair_2, @sqlair_3), (0, "nobody")`,
 }, {
 	summary:        "insert with standalone input expressions",
 	query:          `INSERT INTO person VALUES ($Person.name, "random string", $Person.id)`,
@@ -661,6 +920,22 @@ AND z = @sqlair_0 -- The line with $Person.id on it
 	expectedParsed: "[Bypass[SELECT ] Output[[] [Embeddings.col1]] Bypass[, ] Output[[] [Embeddings.col2]] Bypass[, ] Output[[] [Embeddings.col3]] Bypass[, ] Output[[] [Embeddings.col4]] Bypass[ FROM address WHERE id = 1000]]",
 	typeSamples:    []any{Embeddings{}},
 	expectedSQL:    "SELECT col1 AS _sqlair_0, col2 AS _sqlair_1, col3 AS _sqlair_2, col4 AS _sqlair_3 FROM address WHERE id = 1000",
+}, {
+	summary:        "nested struct members",
+	query:          "SELECT &Resident.Home.street, p.district AS &Resident.Work.district FROM person AS p WHERE id = $Resident.Home.id AND street = $Resident.Work.street",
+	expectedParsed: "[Bypass[SELECT ] Output[[] [Resident.Home.street]] Bypass[, ] Output[[p.district] [Resident.Work.district]] Bypass[ FROM person AS p WHERE id = ] Input[Resident.Home.id] Bypass[ AND street = ] Input[Resident.Work.street]]",
+	typeSamples:    []any{Resident{}},
+	inputArgs:      []any{Resident{Home: Address{ID: 5}, Work: &Address{Street: "High Street"}}},
+	expectedParams: []any{5, "High Street"},
+	expectedSQL:    "SELECT street AS _sqlair_0, p.district AS _sqlair_1 FROM person AS p WHERE id = @sqlair_0 AND street = @sqlair_1",
+}, {
+	summary:        "insert nested struct members",
+	query:          "INSERT INTO address (*) VALUES ($Resident.id, $Resident.Home.street)",
+	expectedParsed: "[Bypass[INSERT INTO address ] AsteriskInsert[[*] [Resident.id Resident.Home.street]]]",
+	typeSamples:    []any{Resident{}},
+	inputArgs:      []any{Resident{ID: 3, Home: Address{Street: "Low Street"}}},
+	expectedParams: []any{3, "Low Street"},
+	expectedSQL:    "INSERT INTO address (id, street) VALUES (@sqlair_0, @sqlair_1)",
 }, {
 	summary:        "bulk insert",
 	query:          `INSERT INTO person (*) VALUES ($Person.*)`,
@@ -733,6 +1008,28 @@ AND z = @sqlair_0 -- The line with $Person.id on it
 	inputArgs:      []any{[]Address{{Street: "Wallaby Way"}, {Street: "Platypus Place"}}, []Person{{PostalCode: 11111}, {PostalCode: 22222}}},
 	expectedParams: []any{11111, 22222, "Wallaby Way", "Platypus Place"},
 	expectedSQL:    `INSERT INTO person (id, random_string, random_thing, number, street) VALUES (@sqlair_0, "random string", rand(), 1000, @sqlair_2), (@sqlair_1, "random string", rand(), 1000, @sqlair_3)`,
+}, {
+	summary:        "labelled outputs of the same type",
+	query:          "SELECT c.* AS &Person:child.*, p.name AS &Person:parent.name FROM person AS c JOIN person AS p ON c.address_id = p.id",
+	expectedParsed: "[Bypass[SELECT ] Output[[c.*] [Person:child.*]] Bypass[, ] Output[[p.name] [Person:parent.name]] Bypass[ FROM person AS c JOIN person AS p ON c.address_id = p.id]]",
+	typeSamples:    []any{Person{}},
+	expectedSQL:    "SELECT c.address_id AS _sqlair_0, c.id AS _sqlair_1, c.name AS _sqlair_2, p.name AS _sqlair_3 FROM person AS c JOIN person AS p ON c.address_id = p.id",
+}, {
+	summary:        "identifier input",
+	query:          "SELECT &Person.* FROM $Ident.name WHERE id = $Person.id",
+	expectedParsed: "[Bypass[SELECT ] Output[[] [Person.*]] Bypass[ FROM ] IdentInput[Ident.name] Bypass[ WHERE id = ] Input[Person.id]]",
+	typeSamples:    []any{Person{}, sqlair.Ident("")},
+	inputArgs:      []any{sqlair.Ident("person"), Person{ID: 1}},
+	expectedParams: []any{1},
+	expectedSQL:    "SELECT address_id AS _sqlair_0, id AS _sqlair_1, name AS _sqlair_2 FROM person WHERE id = @sqlair_0",
+}, {
+	summary:        "identifier and sort direction members",
+	query:          "SELECT &Person.* FROM person WHERE id > $Person.id ORDER BY $Sort.column $Sort.direction, $M.col $M.dir",
+	expectedParsed: "[Bypass[SELECT ] Output[[] [Person.*]] Bypass[ FROM person WHERE id > ] Input[Person.id] Bypass[ ORDER BY ] Input[Sort.column] Bypass[ ] Input[Sort.direction] Bypass[, ] Input[M.col] Bypass[ ] Input[M.dir]]",
+	typeSamples:    []any{Person{}, Sort{}, sqlair.M{}},
+	inputArgs:      []any{Person{ID: 1}, Sort{Column: "name", Direction: "desc"}, sqlair.M{"col": sqlair.Ident(`"id"`), "dir": sqlair.Ascending}},
+	expectedParams: []any{1},
+	expectedSQL:    `SELECT address_id AS _sqlair_0, id AS _sqlair_1, name AS _sqlair_2 FROM person WHERE id > @sqlair_0 ORDER BY name DESC, "id" ASC`,
 }, {
 	summary:        "ending in multiple semicolons",
 	query:          "SELECT p.*	AS &Person.*;;;;;;",
@@ -794,6 +1091,19 @@ func (s *ExprSuite) TestParseErrors(c *C) {
 		query string
 		err   string
 	}{{
+		query: "UPDATE person SET (* EXCEPT id) = ($Person.*)",
+		err:   `cannot parse expression: column 29: missing parenthesised columns after "EXCEPT"`,
+	}, {
+		query: "UPDATE person SET (* EXCEPTION (id)) = ($Person.*)",
+		err:   `cannot parse expression: column 19: missing closing parentheses`,
+	}, {
+		// SETX is not the SET keyword, so there is no assignment.
+		query: "UPDATE person SETX (*) = ($Person.*)",
+		err:   `cannot parse expression: column 27: invalid asterisk placement in input "$Person.*"`,
+	}, {
+		query: "UPDATE person SET (* WHERE id = 1",
+		err:   `cannot parse expression: column 19: missing closing parentheses`,
+	}, {
 		query: "SELECT foo FROM t WHERE x = 'dddd",
 		err:   "cannot parse expression: column 29: missing closing quote in string literal",
 	}, {
@@ -835,10 +1145,13 @@ comment */ WHERE x = $Address.&d`,
 		err:   `cannot parse expression: column 38: invalid identifier suffix following "Address"`,
 	}, {
 		query: "SELECT foo FROM t WHERE x = $Address",
-		err:   `cannot parse expression: column 29: unqualified type, expected Address.* or Address.<db tag> or Address[:]`,
+		err:   `cannot parse expression: column 29: unqualified type, expected Address.* or Address.<db tag> or Address[:], or a type marked as JSON`,
 	}, {
 		query: "SELECT foo FROM t WHERE x = $Address [:]",
-		err:   `cannot parse expression: column 29: unqualified type, expected Address.* or Address.<db tag> or Address[:]`,
+		err:   `cannot parse expression: column 29: unqualified type, expected Address.* or Address.<db tag> or Address[:], or a type marked as JSON`,
+	}, {
+		query: "SELECT data AS &Address FROM t",
+		err:   `cannot parse expression: column 16: unqualified type, expected Address.* or Address.<db tag> or Address[:], or a type marked as JSON`,
 	}, {
 		query: "SELECT name AS (&Person.*)",
 		err:   `cannot parse expression: column 16: unexpected parentheses around types after "AS"`,
@@ -925,6 +1238,12 @@ of three lines' AND id = $Person.*`,
 	}, {
 		query: "INSERT INTO person VALUES ($Address.*)",
 		err:   `cannot parse expression: column 28: invalid asterisk placement in input "$Address.*"`,
+	}, {
+		query: "SELECT &Person:.* FROM t",
+		err:   `cannot parse expression: column 15: missing label following "Person:"`,
+	}, {
+		query: "SELECT &Person:parent FROM t",
+		err:   `cannot parse expression: column 15: unqualified type, expected Person:parent.* or Person:parent.<db tag>`,
 	}}
 
 	for _, t := range tests {
@@ -939,6 +1258,60 @@ of three lines' AND id = $Person.*`,
 	}
 }
 
+func (s *ExprSuite) TestParseAll(c *C) {
+	tests := []struct {
+		query string
+		errs  []string
+	}{{
+		query: "SELECT &Person.* FROM t",
+		errs:  nil,
+	}, {
+		query: "SELECT &Person:.* FROM t WHERE x = $Address.*",
+		errs: []string{
+			`column 15: missing label following "Person:"`,
+			`column 36: invalid asterisk placement in input "$Address.*"`,
+		},
+	}, {
+		query: "SELECT (&Person.*, &Address:a) FROM t\nWHERE x = $Address.* AND y = $M.id AND z = 'unterminated",
+		errs: []string{
+			`line 1, column 28: unqualified type, expected Address:a.* or Address:a.<db tag>`,
+			`line 2, column 11: invalid asterisk placement in input "$Address.*"`,
+			`line 2, column 44: missing closing quote in string literal`,
+		},
+	}, {
+		// An error in an expression nested in another is reported once.
+		query: "INSERT INTO t (data) VALUES ($Doc) WHERE x = $Address.*",
+		errs: []string{
+			`column 30: unqualified type, expected Doc.* or Doc.<db tag> or Doc[:], or a type marked as JSON`,
+			`column 46: invalid asterisk placement in input "$Address.*"`,
+		},
+	}}
+
+	for i, t := range tests {
+		parser := expr.NewParser()
+		pe, errs := parser.ParseAll(t.query)
+		if t.errs == nil {
+			c.Check(errs, IsNil, Commentf("test %d failed:\nquery: %s", i, t.query))
+			c.Check(pe, NotNil)
+			continue
+		}
+		c.Check(pe, IsNil)
+		var errStrs []string
+		for _, err := range errs {
+			errStrs = append(errStrs, err.Error())
+		}
+		c.Check(errStrs, DeepEquals, t.errs, Commentf("test %d failed:\nquery: %s", i, t.query))
+	}
+
+	// Errors are positioned.
+	_, errs := expr.NewParser().ParseAll("SELECT foo\nFROM t WHERE x = $Address.*")
+	c.Assert(errs, HasLen, 1)
+	parseErr, ok := errs[0].(*expr.ParseError)
+	c.Assert(ok, Equals, true)
+	c.Check(parseErr.Line, Equals, 2)
+	c.Check(parseErr.Column, Equals, 18)
+}
+
 func FuzzParser(f *testing.F) {
 	// Add some values to the corpus.
 	for _, test := range tests {
@@ -979,6 +1352,66 @@ func (s *ExprSuite) TestBindTypesErrors(c *C) {
 		typeSamples []any
 		err         string
 	}{{
+		query:       "SELECT foo FROM t WHERE x = $Resident.Office.street",
+		typeSamples: []any{Resident{}},
+		err:         `cannot prepare statement: input expression: type "Resident" has no exported field "Office": $Resident.Office.street`,
+	}, {
+		query:       "SELECT foo FROM t WHERE x = $Resident.ID.street",
+		typeSamples: []any{Resident{}},
+		err:         `cannot prepare statement: input expression: field "ID" of type "Resident" is not a struct: $Resident.ID.street`,
+	}, {
+		query:       "SELECT &Resident.Home.postcode FROM t",
+		typeSamples: []any{Resident{}},
+		err:         `cannot prepare statement: output expression: type "Address" has no "postcode" db tag: &Resident.Home.postcode`,
+	}, {
+		query:       "SELECT foo FROM t WHERE x = $M.a.b",
+		typeSamples: []any{sqlair.M{}},
+		err:         `cannot prepare statement: input expression: cannot get nested member "a.b" of map: $M.a.b`,
+	}, {
+		query:       "UPDATE person SET (* EXCEPT (email)) = ($Person.*) WHERE id = $Person.id",
+		typeSamples: []any{Person{}},
+		err:         `cannot prepare statement: input expression: excluded column "email" is not provided by any type: SET (* EXCEPT (email)) = ($Person.*)`,
+	}, {
+		query:       "UPDATE person SET (* EXCEPT (id, id)) = ($Person.*) WHERE id = $Person.id",
+		typeSamples: []any{Person{}},
+		err:         `cannot prepare statement: input expression: column "id" excluded more than once: SET (* EXCEPT (id, id)) = ($Person.*)`,
+	}, {
+		query:       "UPDATE person SET (* EXCEPT (p.id)) = ($Person.*) WHERE id = $Person.id",
+		typeSamples: []any{Person{}},
+		err:         `cannot prepare statement: input expression: invalid column "p.id" in EXCEPT: SET (* EXCEPT (p.id)) = ($Person.*)`,
+	}, {
+		query:       "UPDATE person SET (* EXCEPT (id, id)) = ($M.*) WHERE id = $M.id",
+		typeSamples: []any{sqlair.M{}},
+		err:         `cannot prepare statement: input expression: column "id" excluded more than once: SET (* EXCEPT (id, id)) = ($M.*)`,
+	}, {
+		query:       "UPDATE address SET (* EXCEPT (id, district, street)) = ($Address.*)",
+		typeSamples: []any{Address{}},
+		err:         `cannot prepare statement: input expression: no columns to update: every column is excluded: SET (* EXCEPT (id, district, street)) = ($Address.*)`,
+	}, {
+		query:       "UPDATE person SET (* EXCEPT (p.id)) = ($M.*)",
+		typeSamples: []any{sqlair.M{}},
+		err:         `cannot prepare statement: input expression: invalid column "p.id" in EXCEPT: SET (* EXCEPT (p.id)) = ($M.*)`,
+	}, {
+		query:       "UPDATE person SET (*) = ($Person.*, $Person.id)",
+		typeSamples: []any{Person{}},
+		err:         `cannot prepare statement: input expression: more than one type provides column "id": SET (*) = ($Person.*, $Person.id)`,
+	}, {
+		query:       "UPDATE person SET (*) = ($M.*, $Person.id)",
+		typeSamples: []any{sqlair.M{}, Person{}},
+		err:         `cannot prepare statement: input expression: map with asterisk must be the only value: SET (*) = ($M.*, $Person.id)`,
+	}, {
+		query:       "UPDATE person SET (name, name) = ($Person.*)",
+		typeSamples: []any{Person{}},
+		err:         `cannot prepare statement: input expression: column "name" set more than once: SET (name, name) = ($Person.*)`,
+	}, {
+		query:       "UPDATE person SET (email) = ($Person.*)",
+		typeSamples: []any{Person{}},
+		err:         `cannot prepare statement: input expression: missing type that provides column "email": SET (email) = ($Person.*)`,
+	}, {
+		query:       "UPDATE person SET (p.name) = ($Person.*)",
+		typeSamples: []any{Person{}},
+		err:         `cannot prepare statement: input expression: invalid column "p.name" in SET: SET (p.name) = ($Person.*)`,
+	}, {
 		query:       "SELECT (&M.id, &M.id) FROM t",
 		typeSamples: []any{sqlair.M{}},
 		err:         `cannot prepare statement: output expression: key "id" of map "M" is used in multiple output expressions including: &M.id`,
@@ -994,6 +1427,30 @@ func (s *ExprSuite) TestBindTypesErrors(c *C) {
 		query:       "SELECT (&Address.*, &Address.id) FROM t",
 		typeSamples: []any{Address{}, Person{}},
 		err:         `cannot prepare statement: output expression: tag "id" of struct "Address" is used in multiple output expressions including: &Address.id`,
+	}, {
+		query:       "SELECT &Person.*, id AS &Person.id FROM t",
+		typeSamples: []any{Person{}},
+		err:         `cannot prepare statement: output expression: tag "id" of struct "Person" is used in multiple output expressions including: id AS &Person.id`,
+	}, {
+		query:       "SELECT id AS &Person.id, &Person.* FROM t",
+		typeSamples: []any{Person{}},
+		err:         `cannot prepare statement: output expression: tag "id" of struct "Person" is used in multiple output expressions including: &Person.*`,
+	}, {
+		query:       "SELECT p.* AS &Person.*, p.id AS &Person.id FROM person AS p",
+		typeSamples: []any{Person{}},
+		err:         `cannot prepare statement: output expression: tag "id" of struct "Person" is used in multiple output expressions including: p.id AS &Person.id`,
+	}, {
+		query:       "SELECT &Person:a.*, id AS &Person:a.id FROM t",
+		typeSamples: []any{Person{}},
+		err:         `cannot prepare statement: output expression: tag "id" of struct "Person" is used in multiple output expressions including: id AS &Person:a.id`,
+	}, {
+		query:       "SELECT (id, name) AS (&M.*), id AS &M.id FROM t",
+		typeSamples: []any{sqlair.M{}},
+		err:         `cannot prepare statement: output expression: key "id" of map "M" is used in multiple output expressions including: id AS &M.id`,
+	}, {
+		query:       "SELECT (&Address:a.id, &Address:a.id) FROM t",
+		typeSamples: []any{Address{}},
+		err:         `cannot prepare statement: output expression: tag "id" of struct "Address" is used in multiple output expressions including: &Address:a.id`,
 	}, {
 		query:       "SELECT (p.*, t.name) AS (&Address.*) FROM t",
 		typeSamples: []any{Address{}},
@@ -1009,7 +1466,19 @@ func (s *ExprSuite) TestBindTypesErrors(c *C) {
 	}, {
 		query:       "SELECT (p.*, t.*) AS (&Address.*) FROM t",
 		typeSamples: []any{Address{}},
-		err:         "cannot prepare statement: output expression: invalid asterisk in columns: (p.*, t.*) AS (&Address.*)",
+		err:         `cannot prepare statement: output expression: tag "district" of struct "Address" does not start with one of the table prefixes "p_", "t_": (p.*, t.*) AS (&Address.*)`,
+	}, {
+		query:       "SELECT (p.*, address.*, a.*) AS (&PersonAddress.*) FROM t",
+		typeSamples: []any{PersonAddress{}},
+		err:         `cannot prepare statement: output expression: no tag of struct "PersonAddress" starts with "a_": (p.*, address.*, a.*) AS (&PersonAddress.*)`,
+	}, {
+		query:       "SELECT (*, p.*) AS (&PersonAddress.*) FROM t",
+		typeSamples: []any{PersonAddress{}},
+		err:         "cannot prepare statement: output expression: invalid asterisk in columns: (*, p.*) AS (&PersonAddress.*)",
+	}, {
+		query:       "SELECT (p.*, a.*) AS (&M.*) FROM t",
+		typeSamples: []any{sqlair.M{}},
+		err:         `cannot prepare statement: output expression: cannot read columns of several tables into map "M": (p.*, a.*) AS (&M.*)`,
 	}, {
 		query:       "SELECT (id, name) AS (&Person.id, &Address.*) FROM t",
 		typeSamples: []any{Address{}, Person{}},
@@ -1052,12 +1521,12 @@ func (s *ExprSuite) TestBindTypesErrors(c *C) {
 		err:         `cannot prepare statement: need supported type, got func`,
 	}, {
 		query:       "SELECT * AS &Person.* FROM t",
-		typeSamples: []any{&Person{}},
-		err:         `cannot prepare statement: need non-pointer type, got pointer to struct`,
+		typeSamples: []any{new(*Person)},
+		err:         `cannot prepare statement: need value or pointer to value, got pointer to pointer`,
 	}, {
 		query:       "SELECT * AS &Person.* FROM t",
-		typeSamples: []any{(*Person)(nil)},
-		err:         `cannot prepare statement: need non-pointer type, got pointer to struct`,
+		typeSamples: []any{(**Person)(nil)},
+		err:         `cannot prepare statement: need value or pointer to value, got pointer to pointer`,
 	}, {
 		query:       "SELECT * AS &Person.* FROM t",
 		typeSamples: []any{map[string]any{}},
@@ -1078,6 +1547,10 @@ func (s *ExprSuite) TestBindTypesErrors(c *C) {
 		query:       "SELECT street FROM t WHERE x = $Address[:]",
 		typeSamples: []any{Person{}, Manager{}, Address{}},
 		err:         `cannot prepare statement: input expression: cannot use slice syntax with a struct: $Address[:]`,
+	}, {
+		query:       "SELECT name FROM person WHERE (id, x) IN ($PairSlice[:])",
+		typeSamples: []any{PairSlice{}},
+		err:         `cannot prepare statement: input expression: cannot use slice "PairSlice": slice elements in an IN expression must be scalar values, got array: $PairSlice[:]`,
 	}, {
 		query:       "SELECT name FROM person WHERE id IN ($M[:])",
 		typeSamples: []any{M{}},
@@ -1099,9 +1572,9 @@ func (s *ExprSuite) TestBindTypesErrors(c *C) {
 		typeSamples: []any{myArray{}},
 		err:         `cannot prepare statement: need supported type, got array`,
 	}, {
-		query:       "INSERT INTO t (*) VALUES ($M.*)",
-		typeSamples: []any{sqlair.M{}},
-		err:         `cannot prepare statement: input expression: cannot use map with asterisk unless columns are specified: (*) VALUES ($M.*)`,
+		query:       "INSERT INTO t (*) VALUES ($M.*, $Person.*)",
+		typeSamples: []any{sqlair.M{}, Person{}},
+		err:         `cannot prepare statement: input expression: map with asterisk must be the last value: (*) VALUES ($M.*, $Person.*)`,
 	}, {
 		query:       "INSERT INTO person (id, street) VALUES ($M.*, $myMap.*)",
 		typeSamples: []any{sqlair.M{}, myMap{}},
@@ -1122,6 +1595,14 @@ func (s *ExprSuite) TestBindTypesErrors(c *C) {
 		query:       "INSERT INTO t (missing) VALUES ($Person.*)",
 		typeSamples: []any{Person{}},
 		err:         `cannot prepare statement: input expression: missing type that provides column "missing": (missing) VALUES ($Person.*)`,
+	}, {
+		query:       "INSERT INTO t (id, name, id) VALUES ($Person.*)",
+		typeSamples: []any{Person{}},
+		err:         `cannot prepare statement: input expression: column "id" listed more than once in insert expression: (id, name, id) VALUES ($Person.*)`,
+	}, {
+		query:       "INSERT INTO t (id, key, key) VALUES ($Person.id, $M.*)",
+		typeSamples: []any{Person{}, sqlair.M{}},
+		err:         `cannot prepare statement: input expression: column "key" listed more than once in insert expression: (id, key, key) VALUES ($Person.id, $M.*)`,
 	}, {
 		query:       "INSERT INTO t (id) VALUES ($Person.id, $Address.*)",
 		typeSamples: []any{Person{}, Address{}},
@@ -1137,7 +1618,7 @@ func (s *ExprSuite) TestBindTypesErrors(c *C) {
 	}, {
 		query:       "SELECT &AmbiguousEmbeddedTags.* FROM t",
 		typeSamples: []any{AmbiguousEmbeddedTags{}},
-		err:         `cannot prepare statement: db tag "id" appears in both field "ID2" and field "ID1" of struct "AmbiguousEmbeddedTags"`,
+		err:         `cannot prepare statement: db tag "id" appears in both field "A2.ID2" and field "A1.ID1" of struct "AmbiguousEmbeddedTags"`,
 	}, {
 		query:       "INSERT INTO t (id) VALUES ($Person.id, $Address.street)",
 		typeSamples: []any{Person{}, Address{}},
@@ -1146,6 +1627,10 @@ func (s *ExprSuite) TestBindTypesErrors(c *C) {
 		query:       "INSERT INTO t (id, street) VALUES ($Person.id)",
 		typeSamples: []any{Person{}, Address{}},
 		err:         `cannot prepare statement: input expression: mismatched number of columns and values: 2 != 1: (id, street) VALUES ($Person.id)`,
+	}, {
+		query:       "INSERT INTO t (id, street) VALUES ($Person.id, $Address.street), ($Address.id)",
+		typeSamples: []any{Person{}, Address{}},
+		err:         `cannot prepare statement: input expression: mismatched number of columns and values in row 2: 2 != 1: (id, street) VALUES ($Person.id, $Address.street), ($Address.id)`,
 	}, {
 		query:       "SELECT dist AS &Address.district FROM t",
 		typeSamples: []any{Address{}, Person{}},
@@ -1183,15 +1668,15 @@ func (s *ExprSuite) TestMapError(c *C) {
 		[]any{sqlair.M{}},
 		"cannot prepare statement: output expression: cannot use map with asterisk unless columns are specified: &M.*",
 	}, {
-		"all output into map star from table star",
-		"SELECT p.* AS &M.* FROM person WHERE name = 'Fred'",
-		[]any{sqlair.M{}},
-		"cannot prepare statement: output expression: cannot use map with asterisk unless columns are specified: p.* AS &M.*",
+		"map star with explicit member",
+		"SELECT (*) AS (&M.*, &CustomMap.id) FROM person WHERE name = 'Fred'",
+		[]any{sqlair.M{}, CustomMap{}},
+		"cannot prepare statement: output expression: cannot use map with asterisk unless columns are specified: (*) AS (&M.*, &CustomMap.id)",
 	}, {
-		"all output into map star from lone star",
-		"SELECT * AS &CustomMap.* FROM person WHERE name = 'Fred'",
-		[]any{CustomMap{}},
-		"cannot prepare statement: output expression: cannot use map with asterisk unless columns are specified: * AS &CustomMap.*",
+		"two maps reading all columns",
+		"SELECT p.* AS &M.*, a.* AS &CustomMap.* FROM person AS p, address AS a",
+		[]any{sqlair.M{}, CustomMap{}},
+		"cannot prepare statement: only one map can read all columns with an asterisk",
 	}, {
 		"invalid map",
 		"SELECT * AS &InvalidMap.* FROM person WHERE name = 'Fred'",
@@ -1201,7 +1686,7 @@ func (s *ExprSuite) TestMapError(c *C) {
 		"clashing map and struct names",
 		"SELECT * AS &M.* FROM person WHERE name = $M.id",
 		[]any{M{}, sqlair.M{}},
-		`cannot prepare statement: two types found with name "M": "expr_test.M" and "sqlair.M"`,
+		`cannot prepare statement: two types found with name "M": "github.com/canonical/sqlair/internal/expr_test.M" and "github.com/canonical/sqlair.M"`,
 	}}
 	for _, test := range tests {
 		parser := expr.NewParser()
@@ -1223,6 +1708,11 @@ func (s *ExprSuite) TestBindInputsError(c *C) {
 		typeSamples: []any{Address{}, Person{}},
 		inputArgs:   []any{Address{Street: "Dead end road"}},
 		err:         `invalid input parameter: parameter with type "Person" missing (have "Address")`,
+	}, {
+		query:       "SELECT street FROM t WHERE x = $Resident.Work.street",
+		typeSamples: []any{Resident{}},
+		inputArgs:   []any{Resident{}},
+		err:         `invalid input parameter: cannot get tag "Work.street" of struct "Resident": nil pointer at field Work`,
 	}, {
 		query:       "SELECT street FROM t WHERE x = $Address.street, y = $Person.name",
 		typeSamples: []any{Address{}, Person{}},
@@ -1238,6 +1728,66 @@ func (s *ExprSuite) TestBindInputsError(c *C) {
 		typeSamples: []any{Address{}, Person{}},
 		inputArgs:   []any{(*Person)(nil)},
 		err:         "invalid input parameter: got nil pointer to Person",
+	}, {
+		query:       "INSERT INTO person (*) VALUES ($Address.*, $M.*)",
+		typeSamples: []any{Address{}, sqlair.M{}},
+		inputArgs:   []any{Address{}, sqlair.M{"id": 1}},
+		err:         `invalid input parameter: key "id" of map "M" is also provided by another type`,
+	}, {
+		query:       "INSERT INTO person (*) VALUES ($Address.*, $M.*)",
+		typeSamples: []any{Address{}, sqlair.M{}},
+		inputArgs:   []any{Address{}, sqlair.M{"name) VALUES (1); --": 1}},
+		err:         `invalid input parameter: key of map "M" is not a valid column name: invalid identifier "name) VALUES (1); --"`,
+	}, {
+		query:       "INSERT INTO person (*) VALUES ($M.*)",
+		typeSamples: []any{sqlair.M{}},
+		inputArgs:   []any{sqlair.M{}},
+		err:         `invalid input parameter: no columns to insert: map "M" is empty`,
+	}, {
+		query:       "INSERT INTO person (*) VALUES ($M.*)",
+		typeSamples: []any{sqlair.M{}},
+		inputArgs:   []any{[]sqlair.M{{"id": 1}, {"name": "Fred"}}},
+		err:         `invalid input parameter: map at index 1 in slice of "M" has different keys to map at index 0`,
+	}, {
+		query:       "SELECT street FROM $Ident.name",
+		typeSamples: []any{sqlair.Ident("")},
+		inputArgs:   []any{sqlair.Ident("t; DROP TABLE t")},
+		err:         `invalid input parameter: invalid identifier "t; DROP TABLE t"`,
+	}, {
+		query:       "UPDATE person SET (* EXCEPT (id)) = ($M.*) WHERE id = $M.id",
+		typeSamples: []any{sqlair.M{}},
+		inputArgs:   []any{sqlair.M{"id": 1}},
+		err:         `invalid input parameter: no columns to update: map "M" has no keys to set`,
+	}, {
+		query:       "UPDATE person SET (*) = ($M.*)",
+		typeSamples: []any{sqlair.M{}},
+		inputArgs:   []any{sqlair.M{"name = 'x'; --": 1}},
+		err:         `invalid input parameter: key of map "M" is not a valid column name: invalid identifier "name = 'x'; --"`,
+	}, {
+		query:       "UPDATE person SET (*) = ($M.*)",
+		typeSamples: []any{sqlair.M{}},
+		inputArgs:   []any{[]sqlair.M{{"name": "Fred"}}},
+		err:         `invalid input parameter: type "M" provided as slice but query uses it as a single value`,
+	}, {
+		query:       "SELECT street FROM t ORDER BY $Sort.column $Sort.direction",
+		typeSamples: []any{Sort{}},
+		inputArgs:   []any{Sort{Column: "id; DROP TABLE t", Direction: sqlair.Ascending}},
+		err:         `invalid input parameter: tag "column" of struct "Sort": invalid identifier "id; DROP TABLE t"`,
+	}, {
+		query:       "SELECT street FROM t ORDER BY $Sort.column $Sort.direction",
+		typeSamples: []any{Sort{}},
+		inputArgs:   []any{Sort{Column: "id", Direction: "DESC; DROP TABLE t"}},
+		err:         `invalid input parameter: tag "direction" of struct "Sort": invalid sort direction "DESC; DROP TABLE t", expected ASC or DESC`,
+	}, {
+		query:       "SELECT street FROM t ORDER BY id $M.dir",
+		typeSamples: []any{sqlair.M{}},
+		inputArgs:   []any{sqlair.M{"dir": sqlair.SortDirection("")}},
+		err:         `invalid input parameter: key "dir" of map "M": invalid sort direction "", expected ASC or DESC`,
+	}, {
+		query:       "SELECT street FROM $Ident.name",
+		typeSamples: []any{sqlair.Ident("")},
+		inputArgs:   []any{},
+		err:         `invalid input parameter: parameter with type "Ident" missing`,
 	}, {
 		query:       "SELECT street FROM t WHERE x = $Address.street",
 		typeSamples: []any{Address{}},
@@ -1273,6 +1823,31 @@ func (s *ExprSuite) TestBindInputsError(c *C) {
 		typeSamples: []any{sqlair.S{}},
 		inputArgs:   []any{[]any{}},
 		err:         `invalid input parameter: cannot use anonymous slice outside bulk insert`,
+	}, {
+		query:       "SELECT street FROM t WHERE x = $Person.name",
+		typeSamples: []any{Person{}},
+		inputArgs:   []any{[]Person{{Fullname: "Fred"}}},
+		err:         `invalid input parameter: type "Person" provided as slice but query uses it as a single value`,
+	}, {
+		query:       "SELECT street FROM t WHERE x = $Person.name",
+		typeSamples: []any{Person{}},
+		inputArgs:   []any{[]Person{}},
+		err:         `invalid input parameter: type "Person" provided as slice but query uses it as a single value`,
+	}, {
+		query:       "SELECT street FROM t WHERE x = $M.street",
+		typeSamples: []any{sqlair.M{}},
+		inputArgs:   []any{[]*sqlair.M{}},
+		err:         `invalid input parameter: type "M" provided as slice but query uses it as a single value`,
+	}, {
+		query:       "UPDATE person SET (*) = ($Person.*)",
+		typeSamples: []any{Person{}},
+		inputArgs:   []any{[]Person{{ID: 1}}},
+		err:         `invalid input parameter: type "Person" provided as slice but query uses it as a single value`,
+	}, {
+		query:       "INSERT INTO person (*) VALUES ($Person.*) ON CONFLICT DO UPDATE SET name = $Person.name",
+		typeSamples: []any{Person{}},
+		inputArgs:   []any{[]Person{{ID: 1}}},
+		err:         `invalid input parameter: type "Person" provided as slice but query uses it as a single value`,
 	}, {
 		query:       "SELECT street FROM t WHERE x = $M.street",
 		typeSamples: []any{sqlair.M{}},
@@ -1308,6 +1883,11 @@ func (s *ExprSuite) TestBindInputsError(c *C) {
 		typeSamples: []any{Person{}, Address{}, M{}},
 		inputArgs:   []any{[]Address{{Street: "S2"}}, []Person{{ID: 0}, {ID: 1}}, M{"key": "value"}},
 		err:         `invalid input parameter: expected slices of matching length in bulk insert: slice of "Person" has length 2 but slice of "Address" has length 1`,
+	}, {
+		query:       "INSERT INTO person (id, street) VALUES ($Person.id, $Person.name), ($Address.id, $Address.street)",
+		typeSamples: []any{Person{}, Address{}},
+		inputArgs:   []any{[]Person{{ID: 0}, {ID: 1}}, Address{}},
+		err:         `invalid input parameter: cannot use slice of "Person" in insert expression with more than one row of values`,
 	}, {
 		query:       "INSERT INTO person (*) VALUES ($Person.id)",
 		typeSamples: []any{Person{}},
@@ -1363,7 +1943,7 @@ func (s *ExprSuite) TestBindInputsError(c *C) {
 		query:       "SELECT street FROM t WHERE y = $Person.name",
 		typeSamples: []any{outerP},
 		inputArgs:   []any{shadowedP},
-		err:         `invalid input parameter: parameter with type "expr_test.Person" missing, have type with same name: "expr_test.Person"`,
+		err:         `invalid input parameter: parameter with type "github.com/canonical/sqlair/internal/expr_test.Person" missing, have type with same name: "github.com/canonical/sqlair/internal/expr_test.Person"`,
 	}}
 
 	tests = append(tests, testsShadowed...)
@@ -1400,14 +1980,30 @@ func (s *ExprSuite) TestScanArgsErrors(c *C) {
 		inputArgs:   []any{},
 		columnNames: []string{},
 		outputArgs:  []any{&Address{}},
-		err:         `expected 3 column(s) in the query results, got 0`,
+		err: `expected 3 column(s) in the query results, got 0
+expected columns: _sqlair_0 (&Address.district), _sqlair_1 (&Address.id), _sqlair_2 (&Address.street)
+result columns: none
+first divergence at result column 0: expected _sqlair_0 (&Address.district), got end of results`,
 	}, {
 		query:       "SELECT &Address.* FROM t",
 		typeSamples: []any{Address{}},
 		inputArgs:   []any{},
 		columnNames: []string{"sqlair_0"},
 		outputArgs:  []any{&Address{}},
-		err:         `expected 3 column(s) in the query results, got 1`,
+		err: `expected 3 column(s) in the query results, got 1
+expected columns: _sqlair_0 (&Address.district), _sqlair_1 (&Address.id), _sqlair_2 (&Address.street)
+result columns: sqlair_0
+first divergence at result column 1: expected _sqlair_0 (&Address.district), got end of results`,
+	}, {
+		query:       "SELECT &Address.id, &Person:p.name FROM t",
+		typeSamples: []any{Address{}, Person{}},
+		inputArgs:   []any{},
+		columnNames: []string{"_sqlair_1", "extra"},
+		outputArgs:  []any{&Address{}, expr.LabelledArg{Label: "p", Arg: &Person{}}},
+		err: `column(s) for output "&Address" not found in query results
+expected columns: _sqlair_0 (&Address.id), _sqlair_1 (&Person:p.name)
+result columns: _sqlair_1, extra
+first divergence at result column 0: expected _sqlair_0 (&Address.id), got _sqlair_1`,
 	}, {
 		query:       "SELECT &Address.* FROM t",
 		typeSamples: []any{Address{}},
@@ -1421,7 +2017,38 @@ func (s *ExprSuite) TestScanArgsErrors(c *C) {
 		inputArgs:   []any{},
 		columnNames: []string{"wrong_column_name"},
 		outputArgs:  []any{&Address{}},
-		err:         `column(s) for output "&Address" not found in query results`,
+		err: `column(s) for output "&Address" not found in query results
+expected columns: _sqlair_0 (&Address.id)
+result columns: wrong_column_name
+first divergence at result column 1: expected _sqlair_0 (&Address.id), got end of results`,
+	}, {
+		query:       "SELECT &Address:a.id, &Address:b.id FROM t",
+		typeSamples: []any{Address{}},
+		inputArgs:   []any{},
+		columnNames: []string{"_sqlair_0", "_sqlair_1"},
+		outputArgs:  []any{expr.LabelledArg{Label: "a", Arg: &Address{}}},
+		err:         `no output argument labelled "b"`,
+	}, {
+		query:       "SELECT &Address:a.id FROM t",
+		typeSamples: []any{Address{}},
+		inputArgs:   []any{},
+		columnNames: []string{"_sqlair_0"},
+		outputArgs:  []any{expr.LabelledArg{Label: "a", Arg: &Address{}}, expr.LabelledArg{Label: "a", Arg: &Address{}}},
+		err:         `output argument labelled "a": type "Address" provided more than once`,
+	}, {
+		query:       "SELECT &Address:a.id FROM t",
+		typeSamples: []any{Address{}},
+		inputArgs:   []any{},
+		columnNames: []string{"_sqlair_0"},
+		outputArgs:  []any{expr.LabelledArg{Label: "a", Arg: &Address{}}, &Address{}},
+		err:         `"Address" not referenced in query`,
+	}, {
+		query:       "SELECT &Address:a.id FROM t",
+		typeSamples: []any{Address{}},
+		inputArgs:   []any{},
+		columnNames: []string{"_sqlair_0"},
+		outputArgs:  []any{expr.LabelledArg{Label: "a", Arg: &Address{}}, expr.LabelledArg{Label: "b", Arg: &Address{}}},
+		err:         `"Address:b" not referenced in query`,
 	}}
 
 	for i, t := range tests {
@@ -1444,3 +2071,1348 @@ func (s *ExprSuite) TestScanArgsErrors(c *C) {
 		}
 	}
 }
+
+func (s *ExprSuite) TestScanArgsUnmappedColumns(c *C) {
+	parser := expr.NewParser()
+	parsedExpr, err := parser.Parse("SELECT name, &Address.id, * AS &Person.*, x FROM t")
+	c.Assert(err, IsNil)
+	typedExpr, err := parsedExpr.BindTypes(Address{}, Person{})
+	c.Assert(err, IsNil)
+	pq, err := typedExpr.BindInputs()
+	c.Assert(err, IsNil)
+
+	columnNames := []string{"name", "_sqlair_0", "_sqlair_1", "id", "_sqlair_2", "_sqlair_3", "x"}
+	address := Address{}
+	person := Person{}
+	ptrs, onSuccess, err := pq.ScanArgs(columnNames, []any{&address, &person})
+	c.Assert(err, IsNil)
+	c.Assert(ptrs, HasLen, len(columnNames))
+
+	// The unmapped columns are scanned into a discarded *any, whatever their
+	// value.
+	for _, i := range []int{0, 3, 6} {
+		ptr, ok := ptrs[i].(*any)
+		c.Assert(ok, Equals, true, Commentf("column %d: got %T", i, ptrs[i]))
+		*ptr = "discarded"
+	}
+	c.Assert(onSuccess(), IsNil)
+	c.Check(address, Equals, Address{})
+	c.Check(person, Equals, Person{})
+}
+
+func (s *ExprSuite) TestBindInputsInterfaces(c *C) {
+	parsedExpr, err := expr.NewParser().Parse("SELECT street FROM t WHERE id = $Person.id")
+	c.Assert(err, IsNil)
+	typedExpr, err := parsedExpr.BindTypes(Person{})
+	c.Assert(err, IsNil)
+
+	// Input arguments held in interfaces, as in generic code storing them in
+	// a []any.
+	var personPtr any = &Person{ID: 1}
+	var personVal any = Person{ID: 2}
+	var ptrToIface any = &personPtr
+	var ptrToValIface any = &personVal
+	tests := []struct {
+		summary string
+		arg     any
+		id      int
+	}{{
+		summary: "interface holding pointer to struct",
+		arg:     personPtr,
+		id:      1,
+	}, {
+		summary: "interface holding struct",
+		arg:     personVal,
+		id:      2,
+	}, {
+		summary: "pointer to interface holding pointer to struct",
+		arg:     ptrToIface,
+		id:      1,
+	}, {
+		summary: "pointer to interface holding struct",
+		arg:     ptrToValIface,
+		id:      2,
+	}}
+	for _, t := range tests {
+		pq, err := typedExpr.BindInputs(t.arg)
+		c.Assert(err, IsNil, Commentf(t.summary))
+		c.Check(pq.Params(), DeepEquals, []any{sql.Named("sqlair_0", t.id)}, Commentf(t.summary))
+	}
+
+	var nilIface any
+	_, err = typedExpr.BindInputs(&nilIface)
+	c.Assert(err, ErrorMatches, "invalid input parameter: got nil argument")
+
+	var nilPtr any = (*Person)(nil)
+	_, err = typedExpr.BindInputs(&nilPtr)
+	c.Assert(err, ErrorMatches, "invalid input parameter: got nil pointer to Person")
+}
+
+func (s *ExprSuite) TestBindInputsPostgres(c *C) {
+	tests := []struct {
+		summary        string
+		query          string
+		typeSamples    []any
+		inputArgs      []any
+		expectedSQL    string
+		expectedParams []any
+	}{{
+		summary:        "member inputs",
+		query:          "SELECT &Person.* FROM person WHERE id = $Person.id AND name = $M.name",
+		typeSamples:    []any{Person{}, sqlair.M{}},
+		inputArgs:      []any{Person{ID: 1}, sqlair.M{"name": "Fred"}},
+		expectedSQL:    "SELECT address_id AS _sqlair_0, id AS _sqlair_1, name AS _sqlair_2 FROM person WHERE id = $1 AND name = $2",
+		expectedParams: []any{1, "Fred"},
+	}, {
+		summary:        "slice input",
+		query:          "SELECT name FROM person WHERE id IN ($S[:]) AND address_id = $Person.address_id",
+		typeSamples:    []any{Person{}, sqlair.S{}},
+		inputArgs:      []any{Person{PostalCode: 7}, sqlair.S{4, 5, 6}},
+		expectedSQL:    "SELECT name FROM person WHERE id IN ($1, $2, $3) AND address_id = $4",
+		expectedParams: []any{4, 5, 6, 7},
+	}, {
+		summary:        "bulk insert",
+		query:          "INSERT INTO person (*) VALUES ($Person.id, $Person.name, $M.address_id)",
+		typeSamples:    []any{Person{}, sqlair.M{}},
+		inputArgs:      []any{[]Person{{ID: 1, Fullname: "Fred"}, {ID: 2, Fullname: "Mark"}}, sqlair.M{"address_id": 9}},
+		expectedSQL:    "INSERT INTO person (id, name, address_id) VALUES ($1, $3, $5), ($2, $4, $5)",
+		expectedParams: []any{1, 2, "Fred", "Mark", 9},
+	}}
+
+	for i, t := range tests {
+		parser := expr.NewParser()
+		parsedExpr, err := parser.Parse(t.query)
+		c.Assert(err, IsNil)
+
+		typedExpr, err := parsedExpr.BindTypes(t.typeSamples...)
+		c.Assert(err, IsNil)
+
+		pq, err := typedExpr.BindInputsWithDialect(expr.Postgres, t.inputArgs...)
+		c.Assert(err, IsNil, Commentf("test %d failed:\nsummary: %s", i, t.summary))
+		c.Check(pq.SQL(), Equals, t.expectedSQL, Commentf("test %d failed:\nsummary: %s", i, t.summary))
+		c.Check(pq.Params(), DeepEquals, t.expectedParams, Commentf("test %d failed:\nsummary: %s", i, t.summary))
+	}
+}
+
+func (s *ExprSuite) TestBindInputsMySQL(c *C) {
+	tests := []struct {
+		summary        string
+		query          string
+		typeSamples    []any
+		inputArgs      []any
+		expectedSQL    string
+		expectedParams []any
+	}{{
+		summary:        "member inputs",
+		query:          "SELECT &Person.* FROM person WHERE id = $Person.id AND name = $M.name",
+		typeSamples:    []any{Person{}, sqlair.M{}},
+		inputArgs:      []any{Person{ID: 1}, sqlair.M{"name": "Fred"}},
+		expectedSQL:    "SELECT address_id AS _sqlair_0, id AS _sqlair_1, name AS _sqlair_2 FROM person WHERE id = ? AND name = ?",
+		expectedParams: []any{1, "Fred"},
+	}, {
+		summary:        "scalar and slice inputs",
+		query:          "SELECT name FROM person WHERE address_id = $Person.address_id AND id IN ($S[:]) AND name <> $M.name",
+		typeSamples:    []any{Person{}, sqlair.S{}, sqlair.M{}},
+		inputArgs:      []any{Person{PostalCode: 7}, sqlair.S{4, 5, 6}, sqlair.M{"name": "Fred"}},
+		expectedSQL:    "SELECT name FROM person WHERE address_id = ? AND id IN (?, ?, ?) AND name <> ?",
+		expectedParams: []any{7, 4, 5, 6, "Fred"},
+	}, {
+		summary:        "repeated member input",
+		query:          "SELECT name FROM person WHERE id = $Person.id OR address_id = $Person.id",
+		typeSamples:    []any{Person{}},
+		inputArgs:      []any{Person{ID: 3}},
+		expectedSQL:    "SELECT name FROM person WHERE id = ? OR address_id = ?",
+		expectedParams: []any{3, 3},
+	}, {
+		summary:        "bulk insert",
+		query:          "INSERT INTO person (*) VALUES ($Person.id, $Person.name, $M.address_id)",
+		typeSamples:    []any{Person{}, sqlair.M{}},
+		inputArgs:      []any{[]Person{{ID: 1, Fullname: "Fred"}, {ID: 2, Fullname: "Mark"}}, sqlair.M{"address_id": 9}},
+		expectedSQL:    "INSERT INTO person (id, name, address_id) VALUES (?, ?, ?), (?, ?, ?)",
+		expectedParams: []any{1, "Fred", 9, 2, "Mark", 9},
+	}}
+
+	for i, t := range tests {
+		parser := expr.NewParser()
+		parsedExpr, err := parser.Parse(t.query)
+		c.Assert(err, IsNil)
+
+		typedExpr, err := parsedExpr.BindTypes(t.typeSamples...)
+		c.Assert(err, IsNil)
+
+		pq, err := typedExpr.BindInputsWithDialect(expr.MySQL, t.inputArgs...)
+		c.Assert(err, IsNil, Commentf("test %d failed:\nsummary: %s", i, t.summary))
+		c.Check(pq.SQL(), Equals, t.expectedSQL, Commentf("test %d failed:\nsummary: %s", i, t.summary))
+		c.Check(pq.Params(), DeepEquals, t.expectedParams, Commentf("test %d failed:\nsummary: %s", i, t.summary))
+	}
+
+	// The parameter limit counts repeated placeholders.
+	parsedExpr, err := expr.NewParser().Parse("SELECT name FROM person WHERE id = $Person.id OR address_id = $Person.id")
+	c.Assert(err, IsNil)
+	typedExpr, err := parsedExpr.BindTypes(Person{})
+	c.Assert(err, IsNil)
+	_, err = typedExpr.BindInputsWithDialect(expr.MySQL.WithMaxParams(1), Person{ID: 3})
+	c.Check(err, ErrorMatches, `invalid input parameter: query has 2 parameters, exceeds MySQL limit of 1; reduce slice size or batch`)
+
+	// MySQL has no excluded row to assign the columns of an upsert from.
+	parsedExpr, err = expr.NewParser().Parse("INSERT INTO person (*) VALUES ($Person.*) ON CONFLICT (id) DO UPDATE SET (*) = ($Person.*)")
+	c.Assert(err, IsNil)
+	typedExpr, err = parsedExpr.BindTypes(Person{})
+	c.Assert(err, IsNil)
+	_, err = typedExpr.BindInputsWithDialect(expr.MySQL, Person{ID: 3})
+	c.Check(err, ErrorMatches, `invalid input parameter: cannot assign the inserted row in an upsert: MySQL has no excluded row`)
+}
+
+func (s *ExprSuite) TestBindInputsNullSafeIn(c *C) {
+	tests := []struct {
+		summary        string
+		query          string
+		typeSamples    []any
+		inputArgs      []any
+		expectedSQL    string
+		expectedParams []any
+	}{{
+		summary:        "no nil elements",
+		query:          "SELECT name FROM person WHERE id IN ($S[:])",
+		typeSamples:    []any{sqlair.S{}},
+		inputArgs:      []any{sqlair.S{1, 2}},
+		expectedSQL:    "SELECT name FROM person WHERE id IN ($1, $2)",
+		expectedParams: []any{1, 2},
+	}, {
+		summary:        "nil element",
+		query:          "SELECT name FROM person WHERE p.id IN ($S[:]) AND name = $Person.name",
+		typeSamples:    []any{sqlair.S{}, Person{}},
+		inputArgs:      []any{sqlair.S{1, nil, 2}, Person{Fullname: "Fred"}},
+		expectedSQL:    "SELECT name FROM person WHERE (p.id IN ($1, $2) OR p.id IS NULL) AND name = $3",
+		expectedParams: []any{1, 2, "Fred"},
+	}, {
+		summary:        "nil pointer element",
+		query:          "SELECT name FROM person WHERE id in ( $S[:] )",
+		typeSamples:    []any{sqlair.S{}},
+		inputArgs:      []any{sqlair.S{(*int)(nil), 3}},
+		expectedSQL:    "SELECT name FROM person WHERE (id IN ($1) OR id IS NULL)",
+		expectedParams: []any{3},
+	}, {
+		summary:        "only nil elements",
+		query:          "SELECT name FROM person WHERE id IN ($S[:])",
+		typeSamples:    []any{sqlair.S{}},
+		inputArgs:      []any{sqlair.S{nil}},
+		expectedSQL:    "SELECT name FROM person WHERE id IS NULL",
+		expectedParams: []any{},
+	}, {
+		summary:        "slice not alone in IN list",
+		query:          "SELECT name FROM person WHERE id IN ($S[:], $Person.id)",
+		typeSamples:    []any{sqlair.S{}, Person{}},
+		inputArgs:      []any{sqlair.S{1, nil}, Person{ID: 5}},
+		expectedSQL:    "SELECT name FROM person WHERE id IN ($1, $2, $3)",
+		expectedParams: []any{1, nil, 5},
+	}, {
+		summary:        "NOT IN with no nil elements",
+		query:          "SELECT name FROM person WHERE id NOT IN ($S[:])",
+		typeSamples:    []any{sqlair.S{}},
+		inputArgs:      []any{sqlair.S{1, 2}},
+		expectedSQL:    "SELECT name FROM person WHERE id NOT IN ($1, $2)",
+		expectedParams: []any{1, 2},
+	}, {
+		summary:        "NOT IN with nil element",
+		query:          "SELECT name FROM person WHERE p.id not in ($S[:]) AND name = $Person.name",
+		typeSamples:    []any{sqlair.S{}, Person{}},
+		inputArgs:      []any{sqlair.S{1, nil}, Person{Fullname: "Fred"}},
+		expectedSQL:    "SELECT name FROM person WHERE (p.id NOT IN ($1) AND p.id IS NOT NULL) AND name = $2",
+		expectedParams: []any{1, "Fred"},
+	}, {
+		summary:        "NOT IN with only nil elements",
+		query:          "SELECT name FROM person WHERE id NOT IN ($S[:])",
+		typeSamples:    []any{sqlair.S{}},
+		inputArgs:      []any{sqlair.S{nil}},
+		expectedSQL:    "SELECT name FROM person WHERE id IS NOT NULL",
+		expectedParams: []any{},
+	}}
+
+	for i, t := range tests {
+		parser := expr.NewParser()
+		parsedExpr, err := parser.Parse(t.query)
+		c.Assert(err, IsNil)
+
+		typedExpr, err := parsedExpr.BindTypesWithOptions(expr.BindOptions{NullSafeIn: true}, t.typeSamples...)
+		c.Assert(err, IsNil)
+
+		pq, err := typedExpr.BindInputsWithDialect(expr.Postgres, t.inputArgs...)
+		c.Assert(err, IsNil, Commentf("test %d failed:\nsummary: %s", i, t.summary))
+		c.Check(pq.SQL(), Equals, t.expectedSQL, Commentf("test %d failed:\nsummary: %s", i, t.summary))
+		c.Check(pq.Params(), DeepEquals, t.expectedParams, Commentf("test %d failed:\nsummary: %s", i, t.summary))
+	}
+
+	// Without the option the nil element is passed as a parameter.
+	parsedExpr, err := expr.NewParser().Parse("SELECT name FROM person WHERE id IN ($S[:])")
+	c.Assert(err, IsNil)
+	typedExpr, err := parsedExpr.BindTypes(sqlair.S{})
+	c.Assert(err, IsNil)
+	pq, err := typedExpr.BindInputsWithDialect(expr.Postgres, sqlair.S{1, nil})
+	c.Assert(err, IsNil)
+	c.Check(pq.SQL(), Equals, "SELECT name FROM person WHERE id IN ($1, $2)")
+}
+
+func (s *ExprSuite) TestBindInputsMaxParams(c *C) {
+	parsedExpr, err := expr.NewParser().Parse("SELECT name FROM person WHERE id IN ($S[:])")
+	c.Assert(err, IsNil)
+	typedExpr, err := parsedExpr.BindTypes(sqlair.S{})
+	c.Assert(err, IsNil)
+
+	dialect := expr.Postgres.WithMaxParams(2)
+	c.Check(dialect.MaxParams(), Equals, 2)
+	c.Check(expr.Postgres.MaxParams(), Equals, 65535)
+
+	_, err = typedExpr.BindInputsWithDialect(dialect, sqlair.S{1, 2})
+	c.Assert(err, IsNil)
+	_, err = typedExpr.BindInputsWithDialect(dialect, sqlair.S{1, 2, 3})
+	c.Assert(err, ErrorMatches, "invalid input parameter: query has 3 parameters, exceeds Postgres limit of 2; reduce slice size or batch")
+
+	// The default limits apply to the predefined dialects.
+	_, err = typedExpr.BindInputsWithDialect(expr.Postgres, make(sqlair.S, 65536))
+	c.Assert(err, ErrorMatches, "invalid input parameter: query has 65536 parameters, exceeds Postgres limit of 65535; reduce slice size or batch")
+	_, err = typedExpr.BindInputs(make(sqlair.S, 32767))
+	c.Assert(err, ErrorMatches, "invalid input parameter: query has 32767 parameters, exceeds SQLite limit of 32766; reduce slice size or batch")
+
+	// A limit of zero removes the limit.
+	_, err = typedExpr.BindInputsWithDialect(expr.SQLite.WithMaxParams(0), make(sqlair.S, 32767))
+	c.Assert(err, IsNil)
+}
+
+func (s *ExprSuite) TestDebugSQL(c *C) {
+	tests := []struct {
+		query       string
+		typeSamples []any
+		inputArgs   []any
+		expectedSQL string
+	}{{
+		query:       "INSERT INTO person (*) VALUES ($Person.*)",
+		typeSamples: []any{Person{}},
+		inputArgs:   []any{Person{ID: 1}},
+		expectedSQL: "INSERT INTO person (address_id, id, name) VALUES (@sqlair_0, @sqlair_1, @sqlair_2)",
+	}, {
+		query:       "INSERT INTO person (id, name) VALUES ($Person.id, $Person.name) RETURNING &Person.*",
+		typeSamples: []any{Person{}},
+		inputArgs:   []any{[]Person{{ID: 1}, {ID: 2}, {ID: 3}}},
+		expectedSQL: "INSERT INTO person (id, name) VALUES\n\t/* row 0 */ (@sqlair_0, @sqlair_3),\n\t/* row 1 */ (@sqlair_1, @sqlair_4),\n\t/* row 2 */ (@sqlair_2, @sqlair_5) RETURNING address_id AS _sqlair_0, id AS _sqlair_1, name AS _sqlair_2",
+	}}
+	for i, t := range tests {
+		parsedExpr, err := expr.NewParser().Parse(t.query)
+		c.Assert(err, IsNil)
+		typedExpr, err := parsedExpr.BindTypes(t.typeSamples...)
+		c.Assert(err, IsNil)
+		pq, err := typedExpr.BindInputs(t.inputArgs...)
+		c.Assert(err, IsNil)
+		c.Check(pq.DebugSQL(), Equals, t.expectedSQL, Commentf("test %d", i))
+	}
+}
+
+func (s *ExprSuite) TestBindTypesStrict(c *C) {
+	valid := []string{
+		"SELECT &Person.* FROM person",
+		"SELECT * AS &Person.* FROM person",
+		"SELECT p.* AS &Person.* FROM person AS p",
+		"SELECT count(*) AS &Person.id FROM person",
+		"SELECT id * 2 AS &Person.id FROM person",
+		"SELECT &Person.id, '*, x' FROM person /* SELECT *, */",
+		"SELECT * FROM person WHERE id = $Person.id",
+		"SELECT id AS &Person.id FROM (SELECT * FROM person)",
+		"SELECT * FROM (SELECT &Person.* FROM person)",
+		"SELECT &Person.* FROM person WHERE EXISTS (SELECT *, id FROM t)",
+	}
+	for i, query := range valid {
+		parsedExpr, err := expr.NewParser().Parse(query)
+		c.Assert(err, IsNil)
+		_, err = parsedExpr.BindTypesWithOptions(expr.BindOptions{Strict: true}, Person{})
+		c.Check(err, IsNil, Commentf("test %d failed:\nquery: %s", i, query))
+	}
+
+	invalid := []struct {
+		query string
+		err   string
+	}{{
+		query: "SELECT *, &Person.id FROM person",
+		err:   "cannot prepare statement: cannot mix asterisk with output expressions in strict mode: SELECT *,",
+	}, {
+		query: "SELECT &Person.id, * FROM person",
+		err:   "cannot prepare statement: cannot mix asterisk with output expressions in strict mode: , * FROM person",
+	}, {
+		query: "SELECT DISTINCT p.*, &Person.id FROM person AS p",
+		err:   "cannot prepare statement: cannot mix asterisk with output expressions in strict mode: SELECT DISTINCT p.*,",
+	}, {
+		query: `SELECT "p".*, &Person.id FROM person AS "p"`,
+		err:   `cannot prepare statement: cannot mix asterisk with output expressions in strict mode: SELECT "p".*,`,
+	}, {
+		query: "INSERT INTO person (*) VALUES ($Person.*) RETURNING *, &Person.id",
+		err:   "cannot prepare statement: cannot mix asterisk with output expressions in strict mode: RETURNING *,",
+	}, {
+		query: "SELECT * FROM (SELECT *, &Person.id FROM person)",
+		err:   "cannot prepare statement: cannot mix asterisk with output expressions in strict mode: SELECT * FROM (SELECT *,",
+	}}
+	for i, t := range invalid {
+		parsedExpr, err := expr.NewParser().Parse(t.query)
+		c.Assert(err, IsNil)
+		_, err = parsedExpr.BindTypesWithOptions(expr.BindOptions{Strict: true}, Person{})
+		c.Check(err, ErrorMatches, regexp.QuoteMeta(t.err), Commentf("test %d failed:\nquery: %s", i, t.query))
+
+		// The query is accepted without strict mode.
+		_, err = parsedExpr.BindTypes(Person{})
+		c.Check(err, IsNil, Commentf("test %d failed:\nquery: %s", i, t.query))
+	}
+}
+
+func (s *ExprSuite) TestBindTypesWithClause(c *C) {
+	valid := []string{
+		"WITH a(id) AS (SELECT id FROM person) SELECT &Person.* FROM person, a",
+		"WITH p AS (SELECT &Person.* FROM person) SELECT * FROM p",
+		"WITH p AS (SELECT &Person.* FROM person) SELECT p.* FROM p",
+		"WITH p AS (SELECT &Person.* FROM person) SELECT * FROM person JOIN p ON person.id = p.id",
+		"WITH p AS (SELECT &Person.* FROM person), q AS (SELECT * FROM p) SELECT * FROM q",
+		`WITH "p" AS (SELECT &Person.* FROM person) SELECT * FROM "p"`,
+		"WITH p AS (SELECT &Person.* FROM person) SELECT x.* FROM p AS x",
+		"WITH p AS (SELECT &Person.* FROM person) SELECT x.* FROM p x, address",
+		"WITH p AS (SELECT &Person.* FROM person) SELECT * FROM (SELECT * FROM p)",
+		"WITH RECURSIVE a(id) AS (SELECT 1 UNION SELECT id + 1 FROM a) SELECT id AS &Person.id FROM a",
+		"WITH a AS (SELECT id FROM person) DELETE FROM person WHERE id IN (SELECT id FROM a) RETURNING &Person.*",
+		"SELECT * FROM (SELECT &Person.* FROM person)",
+		"SELECT q.* FROM (SELECT &Person.* FROM person) AS q",
+		"SELECT * FROM ((SELECT &Person.* FROM person))",
+		"(SELECT &Person.* FROM person WHERE id = 1) UNION (SELECT * FROM person WHERE id = 2)",
+		"SELECT 'WITH' AS &Person.name FROM person",
+	}
+	for i, query := range valid {
+		parsedExpr, err := expr.NewParser().Parse(query)
+		c.Assert(err, IsNil)
+		_, err = parsedExpr.BindTypes(Person{})
+		c.Check(err, IsNil, Commentf("test %d failed:\nquery: %s", i, query))
+	}
+
+	invalid := []struct {
+		query string
+		err   string
+	}{{
+		query: "WITH a(id) AS (SELECT &Person.id FROM person) SELECT id FROM a",
+		err:   "cannot prepare statement: output expression &Person.id is in a WITH clause and its columns are not in the query results",
+	}, {
+		query: "with a AS (SELECT 1), b AS (SELECT &Person.* FROM person) SELECT id FROM b",
+		err:   "cannot prepare statement: output expression &Person.* is in a WITH clause and its columns are not in the query results",
+	}, {
+		query: "WITH p AS (SELECT &Person.* FROM person), q AS (SELECT 1) SELECT * FROM q",
+		err:   "cannot prepare statement: output expression &Person.* is in a WITH clause and its columns are not in the query results",
+	}, {
+		query: "WITH p AS (SELECT &Person.* FROM person), q AS (SELECT 1) SELECT q.* FROM p, q",
+		err:   "cannot prepare statement: output expression &Person.* is in a WITH clause and its columns are not in the query results",
+	}, {
+		query: "WITH p AS (SELECT &Person.* FROM person) SELECT * FROM (SELECT 1) AS p2, q WHERE EXISTS (SELECT * FROM p)",
+		err:   "cannot prepare statement: output expression &Person.* is in a WITH clause and its columns are not in the query results",
+	}, {
+		query: "WITH p AS (SELECT &Person.* FROM person) SELECT p.* FROM p AS x",
+		err:   "cannot prepare statement: output expression &Person.* is in a WITH clause and its columns are not in the query results",
+	}, {
+		query: "WITH p AS (SELECT &Person.* FROM person) SELECT id FROM (SELECT * FROM p)",
+		err:   "cannot prepare statement: output expression &Person.* is in a WITH clause and its columns are not in the query results",
+	}, {
+		query: "WITH a AS (SELECT id FROM person) SELECT id FROM a WHERE id IN (SELECT &Person.id FROM person)",
+		err:   "cannot prepare statement: output expression &Person.id is in a subquery and its columns are not in the query results",
+	}, {
+		query: "SELECT name FROM person WHERE EXISTS (SELECT &Person.* FROM person)",
+		err:   "cannot prepare statement: output expression &Person.* is in a subquery and its columns are not in the query results",
+	}, {
+		query: "SELECT id FROM (SELECT &Person.* FROM person)",
+		err:   "cannot prepare statement: output expression &Person.* is in a subquery and its columns are not in the query results",
+	}, {
+		query: "SELECT x.* FROM (SELECT &Person.* FROM person) AS q, person AS x",
+		err:   "cannot prepare statement: output expression &Person.* is in a subquery and its columns are not in the query results",
+	}, {
+		query: "SELECT (SELECT &Person.name FROM person WHERE id = 1) AS n FROM person",
+		err:   "cannot prepare statement: output expression &Person.name is in a subquery and its columns are not in the query results",
+	}}
+	for i, t := range invalid {
+		parsedExpr, err := expr.NewParser().Parse(t.query)
+		c.Assert(err, IsNil)
+		_, err = parsedExpr.BindTypes(Person{})
+		c.Check(err, ErrorMatches, regexp.QuoteMeta(t.err), Commentf("test %d failed:\nquery: %s", i, t.query))
+	}
+}
+
+type TableInfo struct {
+	Name    string `db:"#1"`
+	CID     int    `db:"#0"`
+	NotNull bool   `db:"#3"`
+	Type    string `db:"#2"`
+}
+
+func (s *ExprSuite) TestBindTypesPositional(c *C) {
+	valid := []struct {
+		query       string
+		typeSamples []any
+		inputArgs   []any
+		sql         string
+	}{{
+		query:       "SELECT &TableInfo.* FROM pragma_table_info('person')",
+		typeSamples: []any{TableInfo{}},
+		sql:         "SELECT * FROM pragma_table_info('person')",
+	}, {
+		query:       "SELECT * AS &TableInfo.* FROM pragma_table_info('person')",
+		typeSamples: []any{TableInfo{}},
+		sql:         "SELECT * FROM pragma_table_info('person')",
+	}, {
+		query:       "SELECT t.* AS &TableInfo.* FROM pragma_table_info('person') AS t WHERE t.name = $Person.name",
+		typeSamples: []any{TableInfo{}, Person{}},
+		inputArgs:   []any{Person{Fullname: "id"}},
+		sql:         "SELECT t.* FROM pragma_table_info('person') AS t WHERE t.name = @sqlair_0",
+	}}
+	for i, t := range valid {
+		parsedExpr, err := expr.NewParser().Parse(t.query)
+		c.Assert(err, IsNil)
+		typedExpr, err := parsedExpr.BindTypes(t.typeSamples...)
+		c.Assert(err, IsNil, Commentf("test %d failed:\nquery: %s", i, t.query))
+		pq, err := typedExpr.BindInputs(t.inputArgs...)
+		c.Assert(err, IsNil)
+		c.Check(pq.SQL(), Equals, t.sql, Commentf("test %d failed:\nquery: %s", i, t.query))
+	}
+
+	invalid := []struct {
+		query string
+		err   string
+	}{{
+		query: "SELECT &TableInfo.*, &Person.* FROM pragma_table_info('person')",
+		err:   "cannot prepare statement: output expression with positional db tags must be the only output expression in the query",
+	}, {
+		query: "SELECT (*) AS (&TableInfo.*, &Person.id) FROM pragma_table_info('person')",
+		err:   `cannot prepare statement: output expression: struct with positional db tags can only be read with "&TableInfo.*", "* AS &TableInfo.*" or "t.* AS &TableInfo.*": (*) AS (&TableInfo.*, &Person.id)`,
+	}, {
+		query: "SELECT (cid, name) AS (&TableInfo.*), &Person.* FROM pragma_table_info('person')",
+		err:   `cannot prepare statement: output expression: struct with positional db tags can only be read with "&TableInfo.*", "* AS &TableInfo.*" or "t.* AS &TableInfo.*": (cid, name) AS (&TableInfo.*)`,
+	}, {
+		query: "INSERT INTO t (*) VALUES ($TableInfo.*) RETURNING &Person.*",
+		err:   `cannot prepare statement: input expression: cannot use struct "TableInfo" with positional db tags as input: (*) VALUES ($TableInfo.*)`,
+	}}
+	for i, t := range invalid {
+		parsedExpr, err := expr.NewParser().Parse(t.query)
+		c.Assert(err, IsNil)
+		_, err = parsedExpr.BindTypes(TableInfo{}, Person{})
+		c.Check(err, ErrorMatches, regexp.QuoteMeta(t.err), Commentf("test %d failed:\nquery: %s", i, t.query))
+	}
+}
+
+func (s *ExprSuite) TestScanArgsPositional(c *C) {
+	parsedExpr, err := expr.NewParser().Parse("SELECT &TableInfo.* FROM pragma_table_info('person')")
+	c.Assert(err, IsNil)
+	typedExpr, err := parsedExpr.BindTypes(TableInfo{})
+	c.Assert(err, IsNil)
+	pq, err := typedExpr.BindInputs()
+	c.Assert(err, IsNil)
+
+	// The columns are read by position whatever their names.
+	ti := TableInfo{}
+	columns := []string{"cid", "name", "type", "notnull"}
+	scanArgs, _, err := pq.ScanArgs(columns, []any{&ti})
+	c.Assert(err, IsNil)
+	c.Check(scanArgs, HasLen, 4)
+
+	_, _, err = pq.ScanArgs(append(columns, "dflt_value"), []any{&ti})
+	c.Check(err, ErrorMatches, "expected 4 column\\(s\\) in the query results for positional output, got 5\nresult columns: cid, name, type, notnull, dflt_value")
+	_, _, err = pq.ScanArgs(columns[:3], []any{&ti})
+	c.Check(err, ErrorMatches, "expected 4 column\\(s\\) in the query results for positional output, got 3\nresult columns: cid, name, type")
+}
+
+func (s *ExprSuite) TestScanArgsCatchAllMap(c *C) {
+	parsedExpr, err := expr.NewParser().Parse("SELECT &Person.name, p.* AS &M.* FROM person AS p")
+	c.Assert(err, IsNil)
+	typedExpr, err := parsedExpr.BindTypes(Person{}, sqlair.M{})
+	c.Assert(err, IsNil)
+	pq, err := typedExpr.BindInputs()
+	c.Assert(err, IsNil)
+	c.Check(pq.SQL(), Equals, "SELECT name AS _sqlair_0, p.* FROM person AS p")
+
+	// The columns without a marker are read into the map by name.
+	p := Person{}
+	m := sqlair.M{}
+	scanArgs, _, err := pq.ScanArgs([]string{"_sqlair_0", "id", "address_id"}, []any{&p, &m})
+	c.Assert(err, IsNil)
+	c.Check(scanArgs, HasLen, 3)
+
+	_, _, err = pq.ScanArgs([]string{"id"}, []any{&p, &m})
+	c.Check(err, ErrorMatches, `column\(s\) for output "&Person" not found in query results\n(.|\n)*`)
+}
+
+func (s *ExprSuite) TestDebugParams(c *C) {
+	type Account struct {
+		ID  int    `db:"id"`
+		SSN string `db:"ssn,secret"`
+	}
+	tests := []struct {
+		summary     string
+		query       string
+		typeSamples []any
+		inputArgs   []any
+		dialect     *expr.Dialect
+		params      []any
+		debugParams []any
+	}{{
+		summary:     "no secrets",
+		query:       "SELECT name FROM person WHERE id = $Person.id",
+		typeSamples: []any{Person{}},
+		inputArgs:   []any{Person{ID: 1}},
+		params:      []any{sql.Named("sqlair_0", 1)},
+		debugParams: []any{sql.Named("sqlair_0", 1)},
+	}, {
+		summary:     "secret field",
+		query:       "SELECT id FROM account WHERE ssn = $Account.ssn AND id = $Account.id",
+		typeSamples: []any{Account{}},
+		inputArgs:   []any{Account{ID: 1, SSN: "123"}},
+		params:      []any{sql.Named("sqlair_0", "123"), sql.Named("sqlair_1", 1)},
+		debugParams: []any{sql.Named("sqlair_0", "***"), sql.Named("sqlair_1", 1)},
+	}, {
+		summary:     "bulk insert",
+		query:       "INSERT INTO account (*) VALUES ($Account.*)",
+		typeSamples: []any{Account{}},
+		inputArgs:   []any{[]Account{{ID: 1, SSN: "123"}, {ID: 2, SSN: "456"}}},
+		params:      []any{sql.Named("sqlair_0", 1), sql.Named("sqlair_2", "123"), sql.Named("sqlair_1", 2), sql.Named("sqlair_3", "456")},
+		debugParams: []any{sql.Named("sqlair_0", 1), sql.Named("sqlair_2", "***"), sql.Named("sqlair_1", 2), sql.Named("sqlair_3", "***")},
+	}, {
+		summary:     "update set",
+		query:       "UPDATE account SET (* EXCEPT (id)) = ($Account.*) WHERE id = $Account.id",
+		typeSamples: []any{Account{}},
+		inputArgs:   []any{Account{ID: 1, SSN: "123"}},
+		params:      []any{sql.Named("sqlair_0", "123"), sql.Named("sqlair_1", 1)},
+		debugParams: []any{sql.Named("sqlair_0", "***"), sql.Named("sqlair_1", 1)},
+	}, {
+		summary:     "secret map key",
+		query:       "INSERT INTO account (*) VALUES ($M.*)",
+		typeSamples: []any{sqlair.M{}},
+		inputArgs:   []any{sqlair.M{"id": 1, "ssn": "123"}},
+		params:      []any{sql.Named("sqlair_0", 1), sql.Named("sqlair_1", "123")},
+		debugParams: []any{sql.Named("sqlair_0", 1), sql.Named("sqlair_1", "***")},
+	}, {
+		summary:     "numbered placeholders",
+		query:       "SELECT id FROM account WHERE ssn = $M.ssn AND id = $Account.id",
+		typeSamples: []any{sqlair.M{}, Account{}},
+		inputArgs:   []any{sqlair.M{"ssn": "123"}, Account{ID: 1}},
+		dialect:     expr.Postgres,
+		params:      []any{"123", 1},
+		debugParams: []any{"***", 1},
+	}}
+	for i, t := range tests {
+		comment := Commentf("test %d failed:\nsummary: %s", i, t.summary)
+		parsedExpr, err := expr.NewParser().Parse(t.query)
+		c.Assert(err, IsNil, comment)
+		typedExpr, err := parsedExpr.BindTypesWithOptions(expr.BindOptions{SecretKeys: map[string]bool{"ssn": true}}, t.typeSamples...)
+		c.Assert(err, IsNil, comment)
+		dialect := t.dialect
+		if dialect == nil {
+			dialect = expr.SQLite
+		}
+		pq, err := typedExpr.BindInputsWithDialect(dialect, t.inputArgs...)
+		c.Assert(err, IsNil, comment)
+		c.Check(pq.Params(), DeepEquals, t.params, comment)
+		c.Check(pq.DebugParams(), DeepEquals, t.debugParams, comment)
+	}
+}
+
+func (s *ExprSuite) TestMarshalParsedExpr(c *C) {
+	parser := expr.NewParser()
+	for i, t := range tests {
+		parsedExpr, err := parser.Parse(t.query)
+		c.Assert(err, IsNil)
+
+		data, err := json.Marshal(parsedExpr)
+		c.Assert(err, IsNil, Commentf("test %d failed (Marshal):\nsummary: %s", i, t.summary))
+
+		var decoded expr.ParsedExpr
+		err = json.Unmarshal(data, &decoded)
+		c.Assert(err, IsNil, Commentf("test %d failed (Unmarshal):\nsummary: %s", i, t.summary))
+		c.Check(decoded.String(), Equals, t.expectedParsed, Commentf("test %d failed (Unmarshal):\nsummary: %s", i, t.summary))
+
+		typedExpr, err := decoded.BindTypes(t.typeSamples...)
+		c.Assert(err, IsNil, Commentf("test %d failed (BindTypes):\nsummary: %s", i, t.summary))
+		primedQuery, err := typedExpr.BindInputs(t.inputArgs...)
+		c.Assert(err, IsNil, Commentf("test %d failed (BindInputs):\nsummary: %s", i, t.summary))
+		c.Check(primedQuery.SQL(), Equals, t.expectedSQL, Commentf("test %d failed (SQL):\nsummary: %s", i, t.summary))
+	}
+}
+
+func (s *ExprSuite) TestUnmarshalParsedExprErrors(c *C) {
+	tests := []struct {
+		data string
+		err  string
+	}{{
+		data: `[{"kind":"foo","raw":"x"}]`,
+		err:  `unknown expression kind "foo"`,
+	}, {
+		data: `[{"kind":"input","raw":"$P.id"}]`,
+		err:  `input expression "\$P.id": need one member, got 0`,
+	}, {
+		data: `[{"kind":"output","raw":"x AS &P.x","columns":[{"kind":"bar"}],"members":[{"type":"P","member":"x"}]}]`,
+		err:  `unknown column kind "bar"`,
+	}, {
+		data: `[{"kind":"basic-insert","raw":"(x) VALUES (1)","columns":[{"kind":"column","column":"x"}],"values":[{}]}]`,
+		err:  `basic-insert expression "\(x\) VALUES \(1\)": need one of member or literal in value`,
+	}, {
+		data: `[{"kind":"in-list","raw":"id IN ($S[:], 1)"}]`,
+		err:  `in-list expression "id IN \(\$S\[:\], 1\)": not an IN list with a slice input`,
+	}}
+	for i, t := range tests {
+		var pe expr.ParsedExpr
+		err := json.Unmarshal([]byte(t.data), &pe)
+		c.Check(err, ErrorMatches, t.err, Commentf("test %d failed", i))
+	}
+}
+
+func (s *ExprSuite) TestMarshalDialect(c *C) {
+	data, err := json.Marshal(expr.Postgres.WithMaxParams(10))
+	c.Assert(err, IsNil)
+	c.Check(string(data), Equals, `{"name":"Postgres","maxParams":10}`)
+
+	var d expr.Dialect
+	err = json.Unmarshal(data, &d)
+	c.Assert(err, IsNil)
+	c.Check(d.String(), Equals, "Postgres")
+	c.Check(d.MaxParams(), Equals, 10)
+
+	err = json.Unmarshal([]byte(`{"name":"Oracle","maxParams":0}`), &d)
+	c.Check(err, ErrorMatches, `unknown dialect "Oracle"`)
+
+	data, err = json.Marshal(expr.Postgres.WithStatementTimeout())
+	c.Assert(err, IsNil)
+	c.Check(string(data), Equals, `{"name":"Postgres","maxParams":65535,"statementTimeout":true}`)
+	err = json.Unmarshal(data, &d)
+	c.Assert(err, IsNil)
+	c.Check(d.HasStatementTimeout(), Equals, true)
+
+	data, err = json.Marshal(expr.SQLite.WithEmptySliceNull())
+	c.Assert(err, IsNil)
+	c.Check(string(data), Equals, `{"name":"SQLite","maxParams":32766,"emptySliceNull":true}`)
+	err = json.Unmarshal(data, &d)
+	c.Assert(err, IsNil)
+	redata, err := json.Marshal(&d)
+	c.Assert(err, IsNil)
+	c.Check(string(redata), Equals, string(data))
+}
+
+func (s *ExprSuite) TestBindInputsEmptySliceNull(c *C) {
+	tests := []struct {
+		query       string
+		options     expr.BindOptions
+		inputArgs   []any
+		expectedSQL string
+	}{{
+		query:       "SELECT name FROM person WHERE id IN ($S[:])",
+		inputArgs:   []any{sqlair.S{}},
+		expectedSQL: "SELECT name FROM person WHERE id IN (NULL)",
+	}, {
+		query:       "SELECT name FROM person WHERE id IN ($S[:])",
+		inputArgs:   []any{sqlair.S{1, 2}},
+		expectedSQL: "SELECT name FROM person WHERE id IN (@sqlair_0, @sqlair_1)",
+	}, {
+		query:       "SELECT name FROM person WHERE id IN ($S[:])",
+		options:     expr.BindOptions{NullSafeIn: true},
+		inputArgs:   []any{sqlair.S{}},
+		expectedSQL: "SELECT name FROM person WHERE id IN (NULL)",
+	}}
+	for i, t := range tests {
+		parsedExpr, err := expr.NewParser().Parse(t.query)
+		c.Assert(err, IsNil)
+		typedExpr, err := parsedExpr.BindTypesWithOptions(t.options, sqlair.S{})
+		c.Assert(err, IsNil)
+		pq, err := typedExpr.BindInputsWithDialect(expr.SQLite.WithEmptySliceNull(), t.inputArgs...)
+		c.Assert(err, IsNil)
+		c.Check(pq.SQL(), Equals, t.expectedSQL, Commentf("test %d failed", i))
+
+		// Without the option the empty list is left empty.
+		pq, err = typedExpr.BindInputs(sqlair.S{})
+		c.Assert(err, IsNil)
+		c.Check(pq.SQL(), Equals, "SELECT name FROM person WHERE id IN ()", Commentf("test %d failed", i))
+	}
+}
+
+func (s *ExprSuite) TestBindInputsSliceContexts(c *C) {
+	valid := []struct {
+		query       string
+		inputArgs   []any
+		expectedSQL string
+	}{{
+		query:       "SELECT name FROM person WHERE id IN ($S[:])",
+		inputArgs:   []any{sqlair.S{1, 2}},
+		expectedSQL: "SELECT name FROM person WHERE id IN (@sqlair_0, @sqlair_1)",
+	}, {
+		query:       "SELECT name FROM person WHERE id NOT IN ($S[:])",
+		inputArgs:   []any{sqlair.S{1, 2}},
+		expectedSQL: "SELECT name FROM person WHERE id NOT IN (@sqlair_0, @sqlair_1)",
+	}, {
+		query:       "SELECT name FROM person WHERE id NOT IN ($S[:])",
+		inputArgs:   []any{sqlair.S{}},
+		expectedSQL: "SELECT name FROM person WHERE id NOT IN ()",
+	}, {
+		query:       "SELECT name FROM person WHERE id = ANY(ARRAY[$S[:]])",
+		inputArgs:   []any{sqlair.S{1, 2}},
+		expectedSQL: "SELECT name FROM person WHERE id = ANY(ARRAY[@sqlair_0, @sqlair_1])",
+	}, {
+		query:       "SELECT name FROM person WHERE id = ANY ($S[:])",
+		inputArgs:   []any{sqlair.S{1}},
+		expectedSQL: "SELECT name FROM person WHERE id = ANY (@sqlair_0)",
+	}, {
+		query:       "SELECT coalesce($S[:]) FROM person",
+		inputArgs:   []any{sqlair.S{1, 2, 3}},
+		expectedSQL: "SELECT coalesce(@sqlair_0, @sqlair_1, @sqlair_2) FROM person",
+	}, {
+		query:       "SELECT name FROM person WHERE id = max($S[:])",
+		inputArgs:   []any{sqlair.S{1, 2}},
+		expectedSQL: "SELECT name FROM person WHERE id = max(@sqlair_0, @sqlair_1)",
+	}, {
+		// A row value on the left takes several values.
+		query:       "SELECT name FROM person WHERE (id, name) = ($S[:])",
+		inputArgs:   []any{sqlair.S{1, "Fred"}},
+		expectedSQL: "SELECT name FROM person WHERE (id, name) = (@sqlair_0, @sqlair_1)",
+	}, {
+		// Operators ending with a comparison character are not comparisons.
+		query:       "SELECT name FROM person WHERE tags @> ($S[:])",
+		inputArgs:   []any{sqlair.S{"a", "b"}},
+		expectedSQL: "SELECT name FROM person WHERE tags @> (@sqlair_0, @sqlair_1)",
+	}, {
+		query:       "SELECT name FROM person WHERE data->$S[:] IS NOT NULL",
+		inputArgs:   []any{sqlair.S{"a"}},
+		expectedSQL: "SELECT name FROM person WHERE data->@sqlair_0 IS NOT NULL",
+	}, {
+		query:       "SELECT name FROM person WHERE data ->> $S[:] = 'x'",
+		inputArgs:   []any{sqlair.S{"a"}},
+		expectedSQL: "SELECT name FROM person WHERE data ->> @sqlair_0 = 'x'",
+	}, {
+		query:       "SELECT name FROM person WHERE data #> ($S[:]) IS NOT NULL",
+		inputArgs:   []any{sqlair.S{"a"}},
+		expectedSQL: "SELECT name FROM person WHERE data #> (@sqlair_0) IS NOT NULL",
+	}}
+	for i, t := range valid {
+		parsedExpr, err := expr.NewParser().Parse(t.query)
+		c.Assert(err, IsNil)
+		typedExpr, err := parsedExpr.BindTypes(sqlair.S{})
+		c.Assert(err, IsNil, Commentf("test %d failed", i))
+		pq, err := typedExpr.BindInputs(t.inputArgs...)
+		c.Assert(err, IsNil, Commentf("test %d failed", i))
+		c.Check(pq.SQL(), Equals, t.expectedSQL, Commentf("test %d failed", i))
+	}
+
+	// A slice compared with a single value is rejected whatever its length.
+	invalid := []struct {
+		query string
+		err   string
+	}{{
+		query: "SELECT name FROM person WHERE id = ($S[:])",
+		err:   `cannot prepare statement: cannot compare slice "S" with "=", the operator needs a single value: use "IN ($S[:])" to match any of the values`,
+	}, {
+		query: "SELECT name FROM person WHERE id<>( $S[:] )",
+		err:   `cannot prepare statement: cannot compare slice "S" with "<>", the operator needs a single value: use "IN ($S[:])" to match any of the values`,
+	}, {
+		query: "SELECT name FROM person WHERE id >= $S[:]",
+		err:   `cannot prepare statement: cannot compare slice "S" with ">=", the operator needs a single value: use "IN ($S[:])" to match any of the values`,
+	}, {
+		query: "SELECT name FROM person WHERE name != ($S[:]) AND id IN ($S[:])",
+		err:   `cannot prepare statement: cannot compare slice "S" with "!=", the operator needs a single value: use "IN ($S[:])" to match any of the values`,
+	}, {
+		query: "SELECT name FROM person WHERE id <=> $S[:]",
+		err:   `cannot prepare statement: cannot compare slice "S" with "<=>", the operator needs a single value: use "IN ($S[:])" to match any of the values`,
+	}, {
+		query: "SELECT name FROM person WHERE id>$S[:]",
+		err:   `cannot prepare statement: cannot compare slice "S" with ">", the operator needs a single value: use "IN ($S[:])" to match any of the values`,
+	}}
+	for i, t := range invalid {
+		parsedExpr, err := expr.NewParser().Parse(t.query)
+		c.Assert(err, IsNil)
+		_, err = parsedExpr.BindTypes(sqlair.S{})
+		c.Check(err, ErrorMatches, regexp.QuoteMeta(t.err), Commentf("test %d failed", i))
+	}
+}
+
+func (s *ExprSuite) TestDialectStatementTimeout(c *C) {
+	c.Check(expr.Postgres.HasStatementTimeout(), Equals, false)
+	c.Check(expr.SQLite.WithStatementTimeout().HasStatementTimeout(), Equals, false)
+
+	d := expr.Postgres.WithStatementTimeout()
+	c.Check(d.HasStatementTimeout(), Equals, true)
+	c.Check(d.StatementTimeoutSQL(2*time.Second), Equals, "SET LOCAL statement_timeout = 2000")
+	c.Check(d.StatementTimeoutSQL(1500*time.Microsecond), Equals, "SET LOCAL statement_timeout = 2")
+	// A deadline that has passed must not disable the timeout.
+	c.Check(d.StatementTimeoutSQL(-time.Second), Equals, "SET LOCAL statement_timeout = 1")
+	c.Check(d.StatementTimeoutSQL(0), Equals, "SET LOCAL statement_timeout = 1")
+	c.Check(d.ResetStatementTimeoutSQL(), Equals, "SET LOCAL statement_timeout TO DEFAULT")
+}
+
+func (s *ExprSuite) TestBindTypesCaseInsensitiveTags(c *C) {
+	type Mixed struct {
+		Lower string `db:"name"`
+		Upper string `db:"NAME"`
+	}
+	tests := []struct {
+		summary     string
+		query       string
+		typeSamples []any
+		inputArgs   []any
+		expectedSQL string
+		params      []any
+		err         string
+	}{{
+		summary:     "struct members",
+		query:       "SELECT &Person.NAME FROM person WHERE id = $Person.ID",
+		typeSamples: []any{Person{}},
+		inputArgs:   []any{Person{ID: 1}},
+		expectedSQL: "SELECT NAME AS _sqlair_0 FROM person WHERE id = @sqlair_0",
+		params:      []any{1},
+	}, {
+		summary:     "columns into asterisk",
+		query:       "SELECT (Name, ID) AS (&Person.*) FROM person",
+		typeSamples: []any{Person{}},
+		inputArgs:   []any{},
+		expectedSQL: "SELECT Name AS _sqlair_0, ID AS _sqlair_1 FROM person",
+	}, {
+		summary:     "insert columns",
+		query:       "INSERT INTO person (Name, ID) VALUES ($Person.*)",
+		typeSamples: []any{Person{}},
+		inputArgs:   []any{Person{ID: 1, Fullname: "Fred"}},
+		expectedSQL: "INSERT INTO person (Name, ID) VALUES (@sqlair_0, @sqlair_1)",
+		params:      []any{"Fred", 1},
+	}, {
+		summary:     "update except",
+		query:       "UPDATE person SET (* EXCEPT (ID, Address_ID)) = ($Person.*)",
+		typeSamples: []any{Person{}},
+		inputArgs:   []any{Person{Fullname: "Fred"}},
+		expectedSQL: "UPDATE person SET name = @sqlair_0",
+		params:      []any{"Fred"},
+	}, {
+		summary:     "map keys",
+		query:       "SELECT name FROM person WHERE id = $M.ID",
+		typeSamples: []any{sqlair.M{}},
+		inputArgs:   []any{sqlair.M{"id": 1}},
+		expectedSQL: "SELECT name FROM person WHERE id = @sqlair_0",
+		params:      []any{1},
+	}, {
+		summary:     "exact tag preferred",
+		query:       "SELECT &Mixed.NAME FROM t",
+		typeSamples: []any{Mixed{}},
+		inputArgs:   []any{},
+		expectedSQL: "SELECT NAME AS _sqlair_0 FROM t",
+	}, {
+		summary:     "ambiguous tag",
+		query:       "SELECT &Mixed.Name FROM t",
+		typeSamples: []any{Mixed{}},
+		err:         `cannot prepare statement: output expression: type "Mixed" has db tags "NAME" and "name" matching "Name" ignoring case: &Mixed.Name`,
+	}, {
+		summary:     "asterisk and member differing in case",
+		query:       "SELECT &Person.*, ID AS &Person.ID FROM t",
+		typeSamples: []any{Person{}},
+		err:         `cannot prepare statement: output expression: tag "id" of struct "Person" is used in multiple output expressions including: ID AS &Person.ID`,
+	}, {
+		summary:     "ambiguous map key",
+		query:       "SELECT name FROM person WHERE id = $M.Id",
+		typeSamples: []any{sqlair.M{}},
+		inputArgs:   []any{sqlair.M{"id": 1, "ID": 2}},
+		err:         `invalid input parameter: map "M" contains keys "ID" and "id" matching key "Id" ignoring case`,
+	}}
+	for i, t := range tests {
+		comment := Commentf("test %d failed:\nsummary: %s", i, t.summary)
+		parsedExpr, err := expr.NewParser().Parse(t.query)
+		c.Assert(err, IsNil, comment)
+		typedExpr, err := parsedExpr.BindTypesWithOptions(expr.BindOptions{CaseInsensitiveTags: true}, t.typeSamples...)
+		if err != nil {
+			c.Check(err, ErrorMatches, regexp.QuoteMeta(t.err), comment)
+			continue
+		}
+		pq, err := typedExpr.BindInputs(t.inputArgs...)
+		if t.err != "" {
+			c.Check(err, ErrorMatches, regexp.QuoteMeta(t.err), comment)
+			continue
+		}
+		c.Assert(err, IsNil, comment)
+		c.Check(pq.SQL(), Equals, t.expectedSQL, comment)
+		var params []any
+		for _, p := range pq.Params() {
+			params = append(params, p.(sql.NamedArg).Value)
+		}
+		c.Check(params, DeepEquals, t.params, comment)
+	}
+
+	// Exact matching is the default.
+	parsedExpr, err := expr.NewParser().Parse("SELECT &Person.NAME FROM person")
+	c.Assert(err, IsNil)
+	_, err = parsedExpr.BindTypes(Person{})
+	c.Check(err, ErrorMatches, `cannot prepare statement: output expression: type "Person" has no "NAME" db tag: &Person.NAME`)
+}
+
+func (s *ExprSuite) TestDescribe(c *C) {
+	personType := reflect.TypeOf(Person{})
+	addressType := reflect.TypeOf(Address{})
+	mType := reflect.TypeOf(sqlair.M{})
+	tests := []struct {
+		summary     string
+		query       string
+		typeSamples []any
+		opts        expr.BindOptions
+		expected    expr.Description
+	}{{
+		summary:     "outputs and inputs",
+		query:       "SELECT (p.name, a.id) AS (&Person.name, &Address.id), &M.count FROM person AS p, address AS a WHERE p.id = $Person.id",
+		typeSamples: []any{Person{}, Address{}, sqlair.M{}},
+		expected: expr.Description{
+			Inputs: []expr.MemberDescription{
+				{Expr: "$Person.id", Type: personType, Member: "id", Field: "ID", Kind: reflect.Int},
+			},
+			Outputs: []expr.MemberDescription{
+				{Expr: "(p.name, a.id) AS (&Person.name, &Address.id)", Column: "p.name", Type: personType, Member: "name", Field: "Fullname", Kind: reflect.String},
+				{Expr: "(p.name, a.id) AS (&Person.name, &Address.id)", Column: "a.id", Type: addressType, Member: "id", Field: "ID", Kind: reflect.Int},
+				{Expr: "&M.count", Column: "count", Type: mType, Member: "count", Kind: reflect.Interface},
+			},
+		},
+	}, {
+		summary:     "labelled output and function column",
+		query:       "SELECT &Person:p.id, (count(*)) AS (&Address.id) FROM person",
+		typeSamples: []any{Person{}, Address{}},
+		expected: expr.Description{
+			Outputs: []expr.MemberDescription{
+				{Expr: "&Person:p.id", Column: "id", Type: personType, Member: "id", Field: "ID", Kind: reflect.Int, Label: "p"},
+				{Expr: "(count(*)) AS (&Address.id)", Column: "count(*)", Type: addressType, Member: "id", Field: "ID", Kind: reflect.Int},
+			},
+		},
+	}, {
+		summary:     "insert with literal",
+		query:       "INSERT INTO person (id, name, address_id) VALUES ($Person.id, 'Fred', 1000)",
+		typeSamples: []any{Person{}},
+		expected: expr.Description{
+			Inputs: []expr.MemberDescription{
+				{Expr: "(id, name, address_id) VALUES ($Person.id, 'Fred', 1000)", Column: "id", Type: personType, Member: "id", Field: "ID", Kind: reflect.Int},
+			},
+		},
+	}, {
+		summary:     "update map keys and slice input",
+		query:       "UPDATE person SET (*) = ($M.*) WHERE id IN ($IntSlice[:])",
+		typeSamples: []any{sqlair.M{}, IntSlice{}},
+		expected: expr.Description{
+			Inputs: []expr.MemberDescription{
+				{Expr: "SET (*) = ($M.*)", Type: mType, Member: "*", Kind: reflect.Interface},
+				{Expr: "$IntSlice[:]", Type: reflect.TypeOf(IntSlice{}), Kind: reflect.Int},
+			},
+		},
+	}, {
+		summary:     "null safe in",
+		query:       "SELECT &Person.id FROM person WHERE id IN ($IntSlice[:])",
+		typeSamples: []any{Person{}, IntSlice{}},
+		opts:        expr.BindOptions{NullSafeIn: true},
+		expected: expr.Description{
+			Inputs: []expr.MemberDescription{
+				{Expr: "$IntSlice[:]", Type: reflect.TypeOf(IntSlice{}), Kind: reflect.Int},
+			},
+			Outputs: []expr.MemberDescription{
+				{Expr: "&Person.id", Column: "id", Type: personType, Member: "id", Field: "ID", Kind: reflect.Int},
+			},
+		},
+	}}
+	for _, test := range tests {
+		parsedExpr, err := expr.NewParser().Parse(test.query)
+		c.Assert(err, IsNil, Commentf("test %q failed", test.summary))
+		typedExpr, err := parsedExpr.BindTypesWithOptions(test.opts, test.typeSamples...)
+		c.Assert(err, IsNil, Commentf("test %q failed", test.summary))
+		c.Check(typedExpr.Describe(), DeepEquals, test.expected, Commentf("test %q failed", test.summary))
+	}
+}
+
+func (s *ExprSuite) TestBindTypesColumnPrefix(c *C) {
+	tests := []struct {
+		summary     string
+		query       string
+		typeSamples []any
+		expectedSQL string
+	}{{
+		summary:     "asterisk from table",
+		query:       "SELECT p.* AS &Person.* FROM v",
+		typeSamples: []any{Person{}},
+		expectedSQL: "SELECT p_address_id AS _sqlair_0, p_id AS _sqlair_1, p_name AS _sqlair_2 FROM v",
+	}, {
+		summary:     "member from table",
+		query:       "SELECT p.* AS &Person.id FROM v",
+		typeSamples: []any{Person{}},
+		expectedSQL: "SELECT p_id AS _sqlair_0 FROM v",
+	}, {
+		summary:     "several tables into one struct",
+		query:       "SELECT (p.*, address.*) AS (&PersonAddress.*) FROM v",
+		typeSamples: []any{PersonAddress{}},
+		expectedSQL: "SELECT address_id AS _sqlair_0, address_street AS _sqlair_1, p_id AS _sqlair_2, p_name AS _sqlair_3 FROM v",
+	}, {
+		summary:     "explicit columns are unchanged",
+		query:       "SELECT (p.id, p.name) AS (&Person.id, &Person.name) FROM person AS p",
+		typeSamples: []any{Person{}},
+		expectedSQL: "SELECT p.id AS _sqlair_0, p.name AS _sqlair_1 FROM person AS p",
+	}, {
+		summary:     "asterisk without table",
+		query:       "SELECT &Person.* FROM person",
+		typeSamples: []any{Person{}},
+		expectedSQL: "SELECT address_id AS _sqlair_0, id AS _sqlair_1, name AS _sqlair_2 FROM person",
+	}}
+	for i, t := range tests {
+		comment := Commentf("test %d failed:\nsummary: %s", i, t.summary)
+		parsedExpr, err := expr.NewParser().Parse(t.query)
+		c.Assert(err, IsNil, comment)
+		typedExpr, err := parsedExpr.BindTypesWithOptions(expr.BindOptions{ColumnPrefix: expr.UnderscorePrefix}, t.typeSamples...)
+		c.Assert(err, IsNil, comment)
+		pq, err := typedExpr.BindInputs()
+		c.Assert(err, IsNil, comment)
+		c.Check(pq.SQL(), Equals, t.expectedSQL, comment)
+	}
+}
+
+func (s *ExprSuite) TestBindTypesJSON(c *C) {
+	type Doc struct {
+		Title string `db:"title" json:"title"`
+		Pages int    `db:"pages" json:"pages"`
+	}
+	type DocMap map[string]any
+	opts := expr.BindOptions{JSONTypes: map[string]bool{"Doc": true, "DocMap": true}}
+
+	tests := []struct {
+		summary     string
+		query       string
+		typeSamples []any
+		inputArgs   []any
+		expectedSQL string
+		params      []any
+	}{{
+		summary:     "insert",
+		query:       "INSERT INTO t (id, data) VALUES ($Person.id, $Doc)",
+		typeSamples: []any{Person{}, Doc{}},
+		inputArgs:   []any{Person{ID: 1}, Doc{Title: "Go", Pages: 10}},
+		expectedSQL: "INSERT INTO t (id, data) VALUES (@sqlair_0, @sqlair_1)",
+		params:      []any{1, `{"title":"Go","pages":10}`},
+	}, {
+		summary:     "bulk insert",
+		query:       "INSERT INTO t (data) VALUES ($Doc)",
+		typeSamples: []any{Doc{}},
+		inputArgs:   []any{[]Doc{{Title: "A"}, {Title: "B"}}},
+		expectedSQL: "INSERT INTO t (data) VALUES (@sqlair_0), (@sqlair_1)",
+		params:      []any{`{"title":"A","pages":0}`, `{"title":"B","pages":0}`},
+	}, {
+		summary:     "standalone input",
+		query:       "SELECT &Person.* FROM t WHERE data = $Doc",
+		typeSamples: []any{Person{}, Doc{}},
+		inputArgs:   []any{Doc{Title: "Go"}},
+		expectedSQL: "SELECT address_id AS _sqlair_0, id AS _sqlair_1, name AS _sqlair_2 FROM t WHERE data = @sqlair_0",
+		params:      []any{`{"title":"Go","pages":0}`},
+	}, {
+		summary:     "output",
+		query:       "SELECT (id, data) AS (&Person.id, &Doc) FROM t",
+		typeSamples: []any{Person{}, Doc{}},
+		expectedSQL: "SELECT id AS _sqlair_0, data AS _sqlair_1 FROM t",
+	}, {
+		summary:     "members of a JSON type",
+		query:       "SELECT data AS &Doc FROM t WHERE title = $Doc.title",
+		typeSamples: []any{Doc{}},
+		inputArgs:   []any{Doc{Title: "Go"}},
+		expectedSQL: "SELECT data AS _sqlair_0 FROM t WHERE title = @sqlair_0",
+		params:      []any{"Go"},
+	}}
+	for i, t := range tests {
+		comment := Commentf("test %d failed:\nsummary: %s", i, t.summary)
+		parsedExpr, err := expr.NewParser().WithJSONTypes([]string{"Doc", "DocMap"}).Parse(t.query)
+		c.Assert(err, IsNil, comment)
+		typedExpr, err := parsedExpr.BindTypesWithOptions(opts, t.typeSamples...)
+		c.Assert(err, IsNil, comment)
+		pq, err := typedExpr.BindInputs(t.inputArgs...)
+		c.Assert(err, IsNil, comment)
+		c.Check(pq.SQL(), Equals, t.expectedSQL, comment)
+		var params []any
+		for _, p := range pq.Params() {
+			params = append(params, p.(sql.NamedArg).Value)
+		}
+		c.Check(params, DeepEquals, t.params, comment)
+	}
+
+	errTests := []struct {
+		query       string
+		typeSamples []any
+		err         string
+	}{{
+		query:       "SELECT &Doc FROM t",
+		typeSamples: []any{Doc{}},
+		err:         `cannot prepare statement: output expression: cannot use whole type "Doc" without an explicit column: &Doc`,
+	}, {
+		query:       "INSERT INTO t (*) VALUES ($Doc)",
+		typeSamples: []any{Doc{}},
+		err:         `cannot prepare statement: input expression: cannot use whole type "Doc" without an explicit column: (*) VALUES ($Doc)`,
+	}, {
+		query:       "INSERT INTO t (id, data) VALUES ($Person.*, $Doc)",
+		typeSamples: []any{Person{}, Doc{}},
+		err:         `cannot prepare statement: input expression: cannot use whole type "Doc" without an explicit column: (id, data) VALUES ($Person.*, $Doc)`,
+	}, {
+		query:       "INSERT INTO t (data) VALUES ($DocMap)",
+		typeSamples: []any{DocMap{}},
+		err:         `cannot prepare statement: input expression: cannot encode map "DocMap" as JSON, only structs can be used whole: (data) VALUES ($DocMap)`,
+	}, {
+		query:       "SELECT (data, data) AS (&Doc, &Doc) FROM t",
+		typeSamples: []any{Doc{}},
+		err:         `cannot prepare statement: output expression: JSON of struct "Doc" is used in multiple output expressions including: (data, data) AS (&Doc, &Doc)`,
+	}}
+	for i, t := range errTests {
+		parsedExpr, err := expr.NewParser().WithJSONTypes([]string{"Doc", "DocMap"}).Parse(t.query)
+		c.Assert(err, IsNil, Commentf("test %d failed", i))
+		_, err = parsedExpr.BindTypesWithOptions(opts, t.typeSamples...)
+		c.Check(err, ErrorMatches, regexp.QuoteMeta(t.err), Commentf("test %d failed", i))
+	}
+}
+
+func (s *ExprSuite) TestNormalizeSQL(c *C) {
+	tests := []struct {
+		sql        string
+		normalized string
+	}{{
+		sql:        "SELECT &Person.* FROM person",
+		normalized: "SELECT &Person.* FROM person",
+	}, {
+		sql:        "  SELECT   &Person.*\n\tFROM person\n",
+		normalized: "SELECT &Person.* FROM person",
+	}, {
+		sql:        "SELECT &Person.* -- all columns\nFROM /* the table */ person",
+		normalized: "SELECT &Person.* FROM person",
+	}, {
+		sql:        "SELECT/**/&Person.*/* trailing */",
+		normalized: "SELECT &Person.*",
+	}, {
+		sql:        "SELECT 'a  --  b', \"col  /* x */\"  FROM t",
+		normalized: "SELECT 'a  --  b', \"col  /* x */\" FROM t",
+	}, {
+		sql:        "SELECT 'it''s   here'  FROM t",
+		normalized: "SELECT 'it''s   here' FROM t",
+	}, {
+		sql:        "SELECT 'unterminated   ",
+		normalized: "SELECT 'unterminated   ",
+	}}
+	for i, t := range tests {
+		c.Check(expr.NormalizeSQL(t.sql), Equals, t.normalized, Commentf("test %d failed:\nsql: %q", i, t.sql))
+	}
+}
+
+func (s *ExprSuite) TestBindInputsReusesMemberParams(c *C) {
+	type S []any
+	tests := []struct {
+		summary     string
+		query       string
+		typeSamples []any
+		inputArgs   []any
+		dialect     *expr.Dialect
+		sql         string
+		params      []any
+	}{{
+		summary:     "struct field",
+		query:       "SELECT name FROM person WHERE name = $Person.name OR alias = $Person.name AND id = $Person.id",
+		typeSamples: []any{Person{}},
+		inputArgs:   []any{Person{ID: 1, Fullname: "Fred"}},
+		dialect:     expr.SQLite,
+		sql:         "SELECT name FROM person WHERE name = @sqlair_0 OR alias = @sqlair_0 AND id = @sqlair_1",
+		params:      []any{sql.Named("sqlair_0", "Fred"), sql.Named("sqlair_1", 1)},
+	}, {
+		summary:     "numbered placeholders",
+		query:       "SELECT name FROM person WHERE name = $Person.name OR id = $Person.id OR alias = $Person.name",
+		typeSamples: []any{Person{}},
+		inputArgs:   []any{Person{ID: 1, Fullname: "Fred"}},
+		dialect:     expr.Postgres,
+		sql:         "SELECT name FROM person WHERE name = $1 OR id = $2 OR alias = $1",
+		params:      []any{"Fred", 1},
+	}, {
+		summary:     "map key",
+		query:       "SELECT name FROM person WHERE name = $M.name OR alias = $M.name",
+		typeSamples: []any{sqlair.M{}},
+		inputArgs:   []any{sqlair.M{"name": "Fred"}},
+		dialect:     expr.SQLite,
+		sql:         "SELECT name FROM person WHERE name = @sqlair_0 OR alias = @sqlair_0",
+		params:      []any{sql.Named("sqlair_0", "Fred")},
+	}, {
+		summary:     "slices are not shared",
+		query:       "SELECT name FROM person WHERE id IN ($S[:]) OR address_id IN ($S[:])",
+		typeSamples: []any{S{}},
+		inputArgs:   []any{S{1, 2}},
+		dialect:     expr.SQLite,
+		sql:         "SELECT name FROM person WHERE id IN (@sqlair_0, @sqlair_1) OR address_id IN (@sqlair_2, @sqlair_3)",
+		params:      []any{sql.Named("sqlair_0", 1), sql.Named("sqlair_1", 2), sql.Named("sqlair_2", 1), sql.Named("sqlair_3", 2)},
+	}}
+	for i, t := range tests {
+		parsedExpr, err := expr.NewParser().Parse(t.query)
+		c.Assert(err, IsNil)
+		typedExpr, err := parsedExpr.BindTypes(t.typeSamples...)
+		c.Assert(err, IsNil)
+		pq, err := typedExpr.BindInputsWithDialect(t.dialect, t.inputArgs...)
+		c.Assert(err, IsNil)
+		c.Check(pq.SQL(), Equals, t.sql, Commentf("test %d failed:\nsummary: %s", i, t.summary))
+		c.Check(pq.Params(), DeepEquals, t.params, Commentf("test %d failed:\nsummary: %s", i, t.summary))
+	}
+}
+
+func (s *ExprSuite) TestBindInputsIntegerLimits(c *C) {
+	type Page struct {
+		Size   uint32 `db:"size"`
+		Offset *int8  `db:"offset"`
+		Name   string `db:"name"`
+	}
+	offset := int8(20)
+
+	tests := []struct {
+		summary     string
+		query       string
+		typeSamples []any
+		inputArgs   []any
+		sql         string
+		params      []any
+	}{{
+		summary:     "struct fields",
+		query:       "SELECT name FROM person LIMIT $Page.size OFFSET $Page.offset",
+		typeSamples: []any{Page{}},
+		inputArgs:   []any{Page{Size: 10, Offset: &offset}},
+		sql:         "SELECT name FROM person LIMIT @sqlair_0 OFFSET @sqlair_1",
+		params:      []any{sql.Named("sqlair_0", int64(10)), sql.Named("sqlair_1", int64(20))},
+	}, {
+		summary:     "map values and lower case keywords",
+		query:       "SELECT name FROM person limit $M.size offset $M.offset",
+		typeSamples: []any{sqlair.M{}},
+		inputArgs:   []any{sqlair.M{"size": uint(10), "offset": 20}},
+		sql:         "SELECT name FROM person limit @sqlair_0 offset @sqlair_1",
+		params:      []any{sql.Named("sqlair_0", int64(10)), sql.Named("sqlair_1", int64(20))},
+	}, {
+		summary:     "other inputs are not converted",
+		query:       "SELECT name FROM person WHERE id = $M.id AND name = $Page.name LIMIT $Page.size",
+		typeSamples: []any{sqlair.M{}, Page{}},
+		inputArgs:   []any{sqlair.M{"id": uint(1)}, Page{Name: "Fred", Size: 1}},
+		sql:         "SELECT name FROM person WHERE id = @sqlair_0 AND name = @sqlair_1 LIMIT @sqlair_2",
+		params:      []any{sql.Named("sqlair_0", uint(1)), sql.Named("sqlair_1", "Fred"), sql.Named("sqlair_2", int64(1))},
+	}}
+	for i, t := range tests {
+		parsedExpr, err := expr.NewParser().Parse(t.query)
+		c.Assert(err, IsNil)
+		typedExpr, err := parsedExpr.BindTypesWithOptions(expr.BindOptions{IntegerLimits: true}, t.typeSamples...)
+		c.Assert(err, IsNil, Commentf("test %d failed:\nsummary: %s", i, t.summary))
+		pq, err := typedExpr.BindInputs(t.inputArgs...)
+		c.Assert(err, IsNil, Commentf("test %d failed:\nsummary: %s", i, t.summary))
+		c.Check(pq.SQL(), Equals, t.sql, Commentf("test %d failed:\nsummary: %s", i, t.summary))
+		c.Check(pq.Params(), DeepEquals, t.params, Commentf("test %d failed:\nsummary: %s", i, t.summary))
+	}
+
+	// Without the option the values are passed as they are.
+	parsedExpr, err := expr.NewParser().Parse("SELECT name FROM person LIMIT $Page.size")
+	c.Assert(err, IsNil)
+	typedExpr, err := parsedExpr.BindTypes(Page{})
+	c.Assert(err, IsNil)
+	pq, err := typedExpr.BindInputs(Page{Size: 10})
+	c.Assert(err, IsNil)
+	c.Check(pq.Params(), DeepEquals, []any{sql.Named("sqlair_0", uint32(10))})
+
+	// A struct field that is not an integer is rejected when the types are
+	// bound.
+	parsedExpr, err = expr.NewParser().Parse("SELECT name FROM person LIMIT $Page.name")
+	c.Assert(err, IsNil)
+	_, err = parsedExpr.BindTypesWithOptions(expr.BindOptions{IntegerLimits: true}, Page{})
+	c.Check(err, ErrorMatches, `cannot prepare statement: cannot use tag "name" of struct "Page" as LIMIT value: type string is not an integer type`)
+
+	// Map values are checked when the inputs are bound.
+	parsedExpr, err = expr.NewParser().Parse("SELECT name FROM person LIMIT $M.size")
+	c.Assert(err, IsNil)
+	typedExpr, err = parsedExpr.BindTypesWithOptions(expr.BindOptions{IntegerLimits: true}, sqlair.M{})
+	c.Assert(err, IsNil)
+	_, err = typedExpr.BindInputs(sqlair.M{"size": "ten"})
+	c.Check(err, ErrorMatches, `invalid input parameter: cannot use key "size" of map "M" as LIMIT value: value of type string is not an integer`)
+	_, err = typedExpr.BindInputs(sqlair.M{"size": uint64(math.MaxUint64)})
+	c.Check(err, ErrorMatches, `invalid input parameter: cannot use key "size" of map "M" as LIMIT value: value 18446744073709551615 overflows int64`)
+}
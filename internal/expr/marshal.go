@@ -0,0 +1,332 @@
+// Copyright 2024 Canonical Ltd.
+// Licensed under Apache 2.0, see LICENCE file for details.
+
+package expr
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// The kinds of expression in an encoded ParsedExpr.
+const (
+	bypassKind         = "bypass"
+	memberInputKind    = "input"
+	identInputKind     = "ident"
+	sliceInputKind     = "slice"
+	inListKind         = "in-list"
+	asteriskInsertKind = "asterisk-insert"
+	columnsInsertKind  = "columns-insert"
+	basicInsertKind    = "basic-insert"
+	updateAssignKind   = "update-assign"
+	outputKind         = "output"
+)
+
+// The kinds of column in an encoded ParsedExpr.
+const (
+	basicColumnKind    = "column"
+	functionColumnKind = "function"
+	precedingExprKind  = "preceding"
+)
+
+// encodedExpr is the JSON encoding of an expression. The fields used depend
+// on the kind of the expression.
+type encodedExpr struct {
+	Kind string `json:"kind"`
+	// Raw is the SQL of the expression. For a bypass it is the chunk of SQL
+	// passed to the database.
+	Raw string `json:"raw"`
+	// Members are the member accessors of the expression.
+	Members []encodedMember `json:"members,omitempty"`
+	// Columns are the columns of the expression.
+	Columns []encodedColumn `json:"columns,omitempty"`
+	// Except are the columns excluded from the asterisk of an update
+	// assignment.
+	Except []encodedColumn `json:"except,omitempty"`
+	// Values are the values inserted by a basic insert expression.
+	Values []encodedValue `json:"values,omitempty"`
+	// ExtraRows are the values of the rows that follow the first in a basic
+	// insert expression with several rows of values.
+	ExtraRows [][]encodedValue `json:"extraRows,omitempty"`
+	// SliceType is the type name of a slice input expression.
+	SliceType string `json:"sliceType,omitempty"`
+}
+
+// encodedMember is the JSON encoding of a memberAccessor.
+type encodedMember struct {
+	Type   string `json:"type"`
+	Member string `json:"member"`
+	Label  string `json:"label,omitempty"`
+}
+
+// encodedColumn is the JSON encoding of a columnAccessor.
+type encodedColumn struct {
+	Kind   string `json:"kind"`
+	Table  string `json:"table,omitempty"`
+	Column string `json:"column,omitempty"`
+}
+
+// encodedValue is the JSON encoding of a valueAccessor. Exactly one of the
+// fields is set.
+type encodedValue struct {
+	Member  *encodedMember `json:"member,omitempty"`
+	Literal *string        `json:"literal,omitempty"`
+}
+
+// MarshalJSON encodes the ParsedExpr as a JSON list of its expressions. The
+// encoding contains everything needed to bind types to the expression again
+// without parsing the query.
+func (pe *ParsedExpr) MarshalJSON() ([]byte, error) {
+	encoded := []encodedExpr{}
+	for _, e := range pe.exprs {
+		ee, err := encodeExpr(e)
+		if err != nil {
+			return nil, err
+		}
+		encoded = append(encoded, ee)
+	}
+	return json.Marshal(encoded)
+}
+
+// UnmarshalJSON decodes a ParsedExpr encoded with MarshalJSON.
+func (pe *ParsedExpr) UnmarshalJSON(data []byte) error {
+	var encoded []encodedExpr
+	if err := json.Unmarshal(data, &encoded); err != nil {
+		return err
+	}
+	exprs := []expression{}
+	for _, ee := range encoded {
+		e, err := decodeExpr(ee)
+		if err != nil {
+			return err
+		}
+		exprs = append(exprs, e)
+	}
+	pe.exprs = exprs
+	return nil
+}
+
+// encodeExpr returns the JSON encoding of an expression.
+func encodeExpr(e expression) (encodedExpr, error) {
+	switch e := e.(type) {
+	case *bypass:
+		return encodedExpr{Kind: bypassKind, Raw: e.chunk}, nil
+	case *memberInputExpr:
+		return encodedExpr{Kind: memberInputKind, Raw: e.raw, Members: encodeMembers([]memberAccessor{e.ma})}, nil
+	case *identInputExpr:
+		return encodedExpr{Kind: identInputKind, Raw: e.raw, Members: encodeMembers([]memberAccessor{e.ma})}, nil
+	case *sliceInputExpr:
+		return encodedExpr{Kind: sliceInputKind, Raw: e.raw, SliceType: e.sliceTypeName}, nil
+	case *inListExpr:
+		return encodedExpr{Kind: inListKind, Raw: e.raw()}, nil
+	case *asteriskInsertExpr:
+		return encodedExpr{Kind: asteriskInsertKind, Raw: e.raw, Members: encodeMembers(e.sources)}, nil
+	case *columnsInsertExpr:
+		columns, err := encodeColumns(e.columns)
+		if err != nil {
+			return encodedExpr{}, err
+		}
+		return encodedExpr{Kind: columnsInsertKind, Raw: e.raw, Columns: columns, Members: encodeMembers(e.sources)}, nil
+	case *basicInsertExpr:
+		columns, err := encodeColumns(e.columns)
+		if err != nil {
+			return encodedExpr{}, err
+		}
+		var rows [][]encodedValue
+		for _, row := range e.rows {
+			values, err := encodeValues(row)
+			if err != nil {
+				return encodedExpr{}, err
+			}
+			rows = append(rows, values)
+		}
+		return encodedExpr{Kind: basicInsertKind, Raw: e.raw, Columns: columns, Values: rows[0], ExtraRows: rows[1:]}, nil
+	case *updateAssignExpr:
+		columns, err := encodeColumns(e.columns)
+		if err != nil {
+			return encodedExpr{}, err
+		}
+		except, err := encodeColumns(e.except)
+		if err != nil {
+			return encodedExpr{}, err
+		}
+		return encodedExpr{Kind: updateAssignKind, Raw: e.raw, Columns: columns, Except: except, Members: encodeMembers(e.sources)}, nil
+	case *outputExpr:
+		columns, err := encodeColumns(e.sourceColumns)
+		if err != nil {
+			return encodedExpr{}, err
+		}
+		return encodedExpr{Kind: outputKind, Raw: e.raw, Columns: columns, Members: encodeMembers(e.targetTypes)}, nil
+	}
+	return encodedExpr{}, fmt.Errorf("internal error: cannot encode expression of type %T", e)
+}
+
+// decodeExpr returns the expression encoded by encodeExpr.
+func decodeExpr(ee encodedExpr) (expression, error) {
+	switch ee.Kind {
+	case bypassKind:
+		return &bypass{chunk: ee.Raw}, nil
+	case memberInputKind, identInputKind:
+		if len(ee.Members) != 1 {
+			return nil, fmt.Errorf("%s expression %q: need one member, got %d", ee.Kind, ee.Raw, len(ee.Members))
+		}
+		ma := decodeMember(ee.Members[0])
+		if ee.Kind == identInputKind {
+			return &identInputExpr{raw: ee.Raw, ma: ma}, nil
+		}
+		return &memberInputExpr{raw: ee.Raw, ma: ma}, nil
+	case sliceInputKind:
+		return &sliceInputExpr{raw: ee.Raw, sliceTypeName: ee.SliceType}, nil
+	case inListKind:
+		// The IN list is parsed again from its SQL.
+		p := NewParser()
+		p.init(ee.Raw)
+		e, ok, err := p.parseInListExpr()
+		if err != nil || !ok || p.pos != len(ee.Raw) {
+			return nil, fmt.Errorf("%s expression %q: not an IN list with a slice input", ee.Kind, ee.Raw)
+		}
+		return e, nil
+	case asteriskInsertKind:
+		return &asteriskInsertExpr{raw: ee.Raw, sources: decodeMembers(ee.Members)}, nil
+	case columnsInsertKind:
+		columns, err := decodeColumns(ee.Columns)
+		if err != nil {
+			return nil, err
+		}
+		return &columnsInsertExpr{raw: ee.Raw, columns: columns, sources: decodeMembers(ee.Members)}, nil
+	case basicInsertKind:
+		columns, err := decodeColumns(ee.Columns)
+		if err != nil {
+			return nil, err
+		}
+		var rows [][]valueAccessor
+		for _, values := range append([][]encodedValue{ee.Values}, ee.ExtraRows...) {
+			row, err := decodeValues(ee, values)
+			if err != nil {
+				return nil, err
+			}
+			rows = append(rows, row)
+		}
+		return &basicInsertExpr{raw: ee.Raw, columns: columns, rows: rows}, nil
+	case updateAssignKind:
+		// An asterisk on the left is encoded without columns.
+		var columns []columnAccessor
+		if len(ee.Columns) > 0 {
+			var err error
+			columns, err = decodeColumns(ee.Columns)
+			if err != nil {
+				return nil, err
+			}
+		}
+		var except []columnAccessor
+		if len(ee.Except) > 0 {
+			var err error
+			except, err = decodeColumns(ee.Except)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return &updateAssignExpr{raw: ee.Raw, columns: columns, except: except, sources: decodeMembers(ee.Members)}, nil
+	case outputKind:
+		columns, err := decodeColumns(ee.Columns)
+		if err != nil {
+			return nil, err
+		}
+		return &outputExpr{raw: ee.Raw, sourceColumns: columns, targetTypes: decodeMembers(ee.Members)}, nil
+	}
+	return nil, fmt.Errorf("unknown expression kind %q", ee.Kind)
+}
+
+func encodeMember(ma memberAccessor) encodedMember {
+	return encodedMember{Type: ma.typeName, Member: ma.memberName, Label: ma.label}
+}
+
+func decodeMember(em encodedMember) memberAccessor {
+	return memberAccessor{typeName: em.Type, memberName: em.Member, label: em.Label}
+}
+
+func encodeMembers(mas []memberAccessor) []encodedMember {
+	var ems []encodedMember
+	for _, ma := range mas {
+		ems = append(ems, encodeMember(ma))
+	}
+	return ems
+}
+
+// decodeMembers returns the member accessors encoded by encodeMembers.
+func decodeMembers(ems []encodedMember) []memberAccessor {
+	mas := []memberAccessor{}
+	for _, em := range ems {
+		mas = append(mas, decodeMember(em))
+	}
+	return mas
+}
+
+func encodeColumns(cas []columnAccessor) ([]encodedColumn, error) {
+	var ecs []encodedColumn
+	for _, ca := range cas {
+		switch ca := ca.(type) {
+		case basicColumn:
+			ecs = append(ecs, encodedColumn{Kind: basicColumnKind, Table: ca.table, Column: ca.column})
+		case sqlFunctionCall:
+			ecs = append(ecs, encodedColumn{Kind: functionColumnKind, Column: ca.raw})
+		case precedingExpr:
+			ecs = append(ecs, encodedColumn{Kind: precedingExprKind})
+		default:
+			return nil, fmt.Errorf("internal error: cannot encode column of type %T", ca)
+		}
+	}
+	return ecs, nil
+}
+
+// decodeColumns returns the column accessors encoded by encodeColumns.
+func decodeColumns(ecs []encodedColumn) ([]columnAccessor, error) {
+	cas := []columnAccessor{}
+	for _, ec := range ecs {
+		switch ec.Kind {
+		case basicColumnKind:
+			cas = append(cas, basicColumn{table: ec.Table, column: ec.Column})
+		case functionColumnKind:
+			cas = append(cas, sqlFunctionCall{raw: ec.Column})
+		case precedingExprKind:
+			cas = append(cas, precedingExpr{})
+		default:
+			return nil, fmt.Errorf("unknown column kind %q", ec.Kind)
+		}
+	}
+	return cas, nil
+}
+
+// encodeValues returns the JSON encoding of a row of values.
+func encodeValues(vs []valueAccessor) ([]encodedValue, error) {
+	var values []encodedValue
+	for _, v := range vs {
+		switch v := v.(type) {
+		case memberAccessor:
+			em := encodeMember(v)
+			values = append(values, encodedValue{Member: &em})
+		case literal:
+			l := v.value
+			values = append(values, encodedValue{Literal: &l})
+		default:
+			return nil, fmt.Errorf("internal error: cannot encode value of type %T", v)
+		}
+	}
+	return values, nil
+}
+
+// decodeValues returns the row of values encoded by encodeValues.
+func decodeValues(ee encodedExpr, values []encodedValue) ([]valueAccessor, error) {
+	var vs []valueAccessor
+	for _, v := range values {
+		switch {
+		case v.Member != nil && v.Literal == nil:
+			vs = append(vs, decodeMember(*v.Member))
+		case v.Literal != nil && v.Member == nil:
+			vs = append(vs, literal{value: *v.Literal})
+		default:
+			return nil, fmt.Errorf("%s expression %q: need one of member or literal in value", ee.Kind, ee.Raw)
+		}
+	}
+	return vs, nil
+}
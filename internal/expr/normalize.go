@@ -0,0 +1,40 @@
+// Copyright 2024 Canonical Ltd.
+// Licensed under Apache 2.0, see LICENCE file for details.
+
+package expr
+
+import (
+	"strings"
+)
+
+// NormalizeSQL returns a canonical form of the query in which each run of
+// whitespace and comments is replaced with a single space, and leading and
+// trailing whitespace and comments are removed. String literals and quoted
+// identifiers are kept as they are. Two queries with the same normalized form
+// differ only in their layout and comments.
+func NormalizeSQL(sql string) string {
+	p := NewParser()
+	p.init(sql)
+	var b strings.Builder
+	for p.pos < len(p.input) {
+		if p.skipBlanks() {
+			if b.Len() > 0 && p.pos < len(p.input) {
+				b.WriteByte(' ')
+			}
+			continue
+		}
+		start := p.pos
+		if ok, err := p.skipStringLiteral(); ok || err != nil {
+			if err != nil {
+				// An unterminated literal runs to the end of the SQL.
+				b.WriteString(sql[start:])
+				break
+			}
+			b.WriteString(sql[start:p.pos])
+			continue
+		}
+		p.advanceChar()
+		b.WriteString(sql[start:p.pos])
+	}
+	return b.String()
+}
@@ -36,21 +36,55 @@ type Parser struct {
 	// lineStart is the position of the first char of the current line in the
 	// input.
 	lineStart int
+	// jsonTypes are the names of the types marked as JSON. Only these types
+	// can be used without a member, as in "$Doc".
+	jsonTypes map[string]bool
+}
+
+// WithJSONTypes sets the names of the types marked as JSON, which can be used
+// without a member. It returns the parser.
+func (p *Parser) WithJSONTypes(names []string) *Parser {
+	p.jsonTypes = map[string]bool{}
+	for _, name := range names {
+		p.jsonTypes[name] = true
+	}
+	return p
 }
 
 // Parse takes an SQLair query string and returns a ParsedExpr.
 func (p *Parser) Parse(input string) (pe *ParsedExpr, err error) {
-	defer func() {
-		if err != nil {
-			err = fmt.Errorf("cannot parse expression: %s", err)
-		}
-	}()
+	pe, errs := p.parse(input, false)
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("cannot parse expression: %s", errs[0])
+	}
+	return pe, nil
+}
+
+// ParseAll parses an SQLair query string like Parse but does not stop at the
+// first error. After an error, the parser skips to the next expression
+// boundary and carries on. All the errors found are returned in the order
+// they occur in the input. Errors with a position in the input are of type
+// *ParseError. If any errors are found the ParsedExpr is nil.
+func (p *Parser) ParseAll(input string) (*ParsedExpr, []error) {
+	pe, errs := p.parse(input, true)
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return pe, nil
+}
 
+// parse parses the input string. If recoverErrors is false, parsing stops at
+// the first error found. Otherwise, it skips to the next expression boundary
+// after each error.
+func (p *Parser) parse(input string, recoverErrors bool) (*ParsedExpr, []error) {
 	p.init(input)
 
+	var errs []error
 	for {
 		if err := p.advanceToNextExpression(); err != nil {
-			return nil, err
+			// The rest of the input cannot be parsed after an unterminated
+			// string literal.
+			return nil, append(errs, err)
 		}
 
 		p.currentExprStart = p.pos
@@ -59,17 +93,22 @@ func (p *Parser) Parse(input string) (pe *ParsedExpr, err error) {
 			break
 		}
 
-		if out, ok, err := p.parseOutputExpr(); err != nil {
-			return nil, err
-		} else if ok {
-			p.add(out)
+		cp := p.save()
+		expr, ok, err := p.parseExpr()
+		if err != nil {
+			// After recovering, an expression nested in the one that
+			// failed can be parsed again and report the same error.
+			if !errorReported(errs, err) {
+				errs = append(errs, err)
+			}
+			if !recoverErrors {
+				return nil, errs
+			}
+			cp.restore()
+			p.skipToExpressionBoundary()
 			continue
-		}
-
-		if in, ok, err := p.parseInputExpr(); err != nil {
-			return nil, err
 		} else if ok {
-			p.add(in)
+			p.add(expr)
 			continue
 		}
 
@@ -78,11 +117,55 @@ func (p *Parser) Parse(input string) (pe *ParsedExpr, err error) {
 		p.advanceChar()
 	}
 
+	if len(errs) > 0 {
+		return nil, errs
+	}
 	// Add any remaining unparsed string input to the parser.
 	p.add(nil)
 	return &ParsedExpr{exprs: p.exprs}, nil
 }
 
+// parseExpr parses an output or input expression starting at the current
+// position.
+func (p *Parser) parseExpr() (expression, bool, error) {
+	if assign, ok, err := p.parseUpdateAssignExpr(); err != nil || ok {
+		return assign, ok, err
+	}
+	if out, ok, err := p.parseOutputExpr(); err != nil || ok {
+		return out, ok, err
+	}
+	return p.parseInputExpr()
+}
+
+// errorReported reports whether errs already holds an error at the same
+// position as err.
+func errorReported(errs []error, err error) bool {
+	pe, ok := err.(*ParseError)
+	if !ok {
+		return false
+	}
+	for _, e := range errs {
+		if reported, ok := e.(*ParseError); ok && reported.Line == pe.Line && reported.Column == pe.Column {
+			return true
+		}
+	}
+	return false
+}
+
+// skipToExpressionBoundary advances the parser past the current char and on
+// to the next blank, comma, semicolon or closing bracket, or the end of the
+// input. It is used to recover after an error.
+func (p *Parser) skipToExpressionBoundary() {
+	p.advanceChar()
+	for p.pos < len(p.input) {
+		switch p.char {
+		case ' ', '\t', '\n', '\r', ',', ';', ')':
+			return
+		}
+		p.advanceChar()
+	}
+}
+
 type columnAccessor interface {
 	String() string
 	tableName() string
@@ -126,6 +209,24 @@ func (sfc sqlFunctionCall) String() string {
 	return sfc.raw
 }
 
+// precedingExpr stands for the SQL expression preceding "AS" in an output
+// expression of the form "<expression> AS &Type.member" where the expression
+// is not a column, e.g. a function call containing input expressions. The
+// expression itself is left in the surrounding SQL.
+type precedingExpr struct{}
+
+func (pe precedingExpr) columnName() string {
+	return ""
+}
+
+func (pe precedingExpr) tableName() string {
+	return ""
+}
+
+func (pe precedingExpr) String() string {
+	return "<expr>"
+}
+
 // init resets the state of the parser and sets the input string.
 func (p *Parser) init(input string) {
 	p.input = input
@@ -167,13 +268,38 @@ func (p *Parser) advanceChar() bool {
 
 // errorAt wraps an error with line and column information.
 func errorAt(err error, line int, column int, input string) error {
-	if strings.ContainsRune(input, '\n') {
-		return fmt.Errorf("line %d, column %d: %w", line, column, err)
-	} else {
-		return fmt.Errorf("column %d: %w", column, err)
+	return &ParseError{
+		Line:      line,
+		Column:    column,
+		multiline: strings.ContainsRune(input, '\n'),
+		err:       err,
 	}
 }
 
+// ParseError is an error found at a position in the input to the parser.
+type ParseError struct {
+	// Line is the line number of the error, starting at 1.
+	Line int
+	// Column is the column number of the error within the line, starting at 1.
+	Column int
+	// multiline is true if the input contains more than one line.
+	multiline bool
+	err       error
+}
+
+// Error returns the error message prefixed with its position.
+func (e *ParseError) Error() string {
+	if e.multiline {
+		return fmt.Sprintf("line %d, column %d: %s", e.Line, e.Column, e.err)
+	}
+	return fmt.Sprintf("column %d: %s", e.Column, e.err)
+}
+
+// Unwrap returns the underlying error.
+func (e *ParseError) Unwrap() error {
+	return e.err
+}
+
 // A checkpoint struct for saving parser state to restore later. We only use a
 // checkpoint within an attempted parsing of an expression, not at a higher
 // level since we don't keep track of the expressions in the checkpoint.
@@ -417,6 +543,21 @@ func (p *Parser) skipString(s string) bool {
 	return false
 }
 
+// skipKeyword advances the parser past the SQL keyword kw if it is at the
+// current position and is not followed by a name char. It returns false and
+// leaves the parser unchanged otherwise. The match is case insensitive.
+func (p *Parser) skipKeyword(kw string) bool {
+	cp := p.save()
+	if !p.skipString(kw) {
+		return false
+	}
+	if p.pos < len(p.input) && isNameChar(p.char) {
+		cp.restore()
+		return false
+	}
+	return true
+}
+
 // skipLiteralInList advances the parser to the next comma or closing bracket
 // skipping string literals, comments and matching sets of parentheses.
 func (p *Parser) skipLiteralInList() (bool, error) {
@@ -603,16 +744,40 @@ func (p *Parser) parseColumnAccessor() (columnAccessor, bool, error) {
 	}
 
 	// Check if it is a function call instead of a lone identifier.
+	argsStart := p.pos
 	if ok, err := p.skipEnclosedParentheses(); err != nil {
 		cp.restore()
 		return nil, false, err
 	} else if ok {
+		// The input expressions in a function call must be parsed so it
+		// cannot be used as a column. It can still be read into a single
+		// member with "AS &Type.member".
+		if containsInputExpr(p.input[argsStart+1 : p.pos-1]) {
+			cp.restore()
+			return nil, false, nil
+		}
 		return sqlFunctionCall{raw: p.input[cp.pos:p.pos]}, true, nil
 	}
 
 	return basicColumn{column: id}, true, nil
 }
 
+// containsInputExpr returns true if the SQL contains an input expression.
+func containsInputExpr(sql string) bool {
+	pe, err := NewParser().Parse(sql)
+	if err != nil {
+		return false
+	}
+	for _, e := range pe.exprs {
+		switch e.(type) {
+		case *bypass, *outputExpr:
+		default:
+			return true
+		}
+	}
+	return false
+}
+
 func (p *Parser) parseTargetType() (memberAccessor, bool, error) {
 	startLine := p.lineNum
 	startCol := p.colNum()
@@ -625,12 +790,42 @@ func (p *Parser) parseTargetType() (memberAccessor, bool, error) {
 		} else if err != nil {
 			return memberAccessor{}, false, errorAt(fmt.Errorf("cannot use slice syntax in output expression"), startLine, startCol, p.input)
 		}
-		return p.parseTypeAndMember()
+		return p.parseLabelledTypeAndMember()
 	}
 
 	return memberAccessor{}, false, nil
 }
 
+// parseLabelledTypeAndMember parses a Go type name qualified by a tag name (or
+// asterisk) and optionally labelled. It is of the form "TypeName.col_name" or
+// "TypeName:label.col_name". The label distinguishes between output arguments
+// of the same type.
+func (p *Parser) parseLabelledTypeAndMember() (memberAccessor, bool, error) {
+	cp := p.save()
+
+	if id, ok := p.parseTypeName(); ok && p.peekChar(':') {
+		labelCol := p.colNum()
+		p.skipChar(':')
+		label, ok := p.parseTypeName()
+		if !ok {
+			return memberAccessor{}, false, errorAt(fmt.Errorf("missing label following %q", id+":"), p.lineNum, labelCol, p.input)
+		}
+		if !p.skipChar('.') {
+			return memberAccessor{}, false, errorAt(fmt.Errorf("unqualified type, expected %s:%s.* or %s:%s.<db tag>", id, label, id, label), p.lineNum, labelCol, p.input)
+		}
+		idField, ok, err := p.parseIdentifierAsterisk()
+		if err != nil {
+			return memberAccessor{}, false, err
+		} else if !ok {
+			return memberAccessor{}, false, errorAt(fmt.Errorf("invalid identifier suffix following %q", id+":"+label), p.lineNum, p.colNum(), p.input)
+		}
+		return memberAccessor{typeName: id, label: label, memberName: idField}, true, nil
+	}
+
+	cp.restore()
+	return p.parseTypeAndMember()
+}
+
 // parseSliceAccessor parses a slice accessor. A slice accessor is of the form
 // "SliceType[:]". It returns the parsed slice type name.
 func (p *Parser) parseSliceAccessor() (typeName string, ok bool, err error) {
@@ -656,7 +851,8 @@ func (p *Parser) parseSliceAccessor() (typeName string, ok bool, err error) {
 }
 
 // parseTypeAndMember parses a Go type name qualified by a tag name (or asterisk)
-// of the form "TypeName.col_name".
+// of the form "TypeName.col_name". A type marked as JSON may also be used
+// without a member, in which case the member name is empty.
 func (p *Parser) parseTypeAndMember() (memberAccessor, bool, error) {
 	cp := p.save()
 
@@ -664,7 +860,10 @@ func (p *Parser) parseTypeAndMember() (memberAccessor, bool, error) {
 	identifierCol := p.colNum() - 1
 	if id, ok := p.parseTypeName(); ok {
 		if !p.skipChar('.') {
-			return memberAccessor{}, false, errorAt(fmt.Errorf("unqualified type, expected %s.* or %s.<db tag> or %s[:]", id, id, id), p.lineNum, identifierCol, p.input)
+			if !p.jsonTypes[id] {
+				return memberAccessor{}, false, errorAt(fmt.Errorf("unqualified type, expected %[1]s.* or %[1]s.<db tag> or %[1]s[:], or a type marked as JSON", id), p.lineNum, identifierCol, p.input)
+			}
+			return memberAccessor{typeName: id}, true, nil
 		}
 
 		idField, ok, err := p.parseIdentifierAsterisk()
@@ -673,6 +872,17 @@ func (p *Parser) parseTypeAndMember() (memberAccessor, bool, error) {
 		} else if !ok {
 			return memberAccessor{}, false, errorAt(fmt.Errorf("invalid identifier suffix following %q", id), p.lineNum, p.colNum(), p.input)
 		}
+		// A member of a nested struct is accessed with a path, e.g.
+		// "Person.Addr.street".
+		for idField != "*" && p.skipChar('.') {
+			nested, ok, err := p.parseIdentifier()
+			if err != nil {
+				return memberAccessor{}, false, err
+			} else if !ok {
+				return memberAccessor{}, false, errorAt(fmt.Errorf("invalid identifier suffix following %q", id+"."+idField), p.lineNum, p.colNum(), p.input)
+			}
+			idField += "." + nested
+		}
 		return memberAccessor{typeName: id, memberName: idField}, true, nil
 	}
 
@@ -814,14 +1024,139 @@ func (p *Parser) parseOutputExpr() (*outputExpr, bool, error) {
 		}
 	}
 
+	cp.restore()
+
+	// Case 3: A single member aliasing the preceding SQL expression e.g.
+	// "AS &Result.exists" in "EXISTS(SELECT 1 FROM t WHERE id = $M.id) AS
+	// &Result.exists".
+	if p.skipString("AS") && p.skipBlanks() {
+		if targetType, ok, err := p.parseTargetType(); err != nil {
+			return nil, false, err
+		} else if ok && targetType.memberName != "*" {
+			return &outputExpr{
+				sourceColumns: []columnAccessor{precedingExpr{}},
+				targetTypes:   []memberAccessor{targetType},
+				raw:           p.input[start:p.pos],
+			}, true, nil
+		}
+	}
+
 	cp.restore()
 	return nil, false, nil
 }
 
+// parseUpdateAssignExpr parses the SET clause of an UPDATE statement that
+// assigns the members of types to columns in the style of an insert
+// expression, with an asterisk or a list of columns on the left. Columns can
+// be excluded from the asterisk.
+// e.g. "SET (*) = ($Type.*)", "SET (* EXCEPT (col1)) = ($Type.*)" or
+// "SET (col1, col2) = ($Type.*)".
+// A SET clause with a list of columns and no asterisk types on the right, such
+// as "SET (col1, col2) = ($Type.col1, $Type.col2)", is left to the database.
+func (p *Parser) parseUpdateAssignExpr() (expression, bool, error) {
+	cp := p.save()
+	if !p.skipKeyword("SET") {
+		return nil, false, nil
+	}
+	p.skipBlanks()
+
+	// The columns are generated from the types on the right if there is an
+	// asterisk on the left, in which case columns is nil.
+	var columns []columnAccessor
+	except, ok, err := p.parseAsteriskExceptColumns()
+	if err != nil {
+		cp.restore()
+		return nil, false, err
+	} else if !ok {
+		cols, paren, ok := p.parseColumns()
+		if !(ok && paren) {
+			cp.restore()
+			return nil, false, nil
+		}
+		columns = cols
+	}
+	p.skipBlanks()
+	if !p.skipChar('=') {
+		cp.restore()
+		return nil, false, nil
+	}
+	p.skipBlanks()
+
+	sources, ok, err := parseList(p, (*Parser).parseInputMemberAccessor)
+	if err != nil {
+		cp.restore()
+		return nil, false, err
+	} else if !ok || (columns != nil && starCountTypes(sources) == 0) {
+		cp.restore()
+		return nil, false, nil
+	}
+	return &updateAssignExpr{columns: columns, except: except, sources: sources, raw: p.input[cp.pos:p.pos]}, true, nil
+}
+
+// parseAsteriskExceptColumns parses an asterisk in parentheses, optionally
+// followed by a list of columns to exclude, e.g. "(*)" or
+// "(* EXCEPT (col1, col2))". It returns the excluded columns.
+func (p *Parser) parseAsteriskExceptColumns() ([]columnAccessor, bool, error) {
+	cp := p.save()
+	if !p.skipChar('(') {
+		return nil, false, nil
+	}
+	p.skipBlanks()
+	if !p.skipChar('*') {
+		cp.restore()
+		return nil, false, nil
+	}
+	p.skipBlanks()
+
+	var except []columnAccessor
+	if p.skipKeyword("EXCEPT") {
+		p.skipBlanks()
+		exceptCol := p.colNum()
+		cols, ok, err := parseList(p, (*Parser).parseColumnAccessor)
+		if err != nil {
+			cp.restore()
+			return nil, false, err
+		} else if !ok {
+			cp.restore()
+			return nil, false, errorAt(fmt.Errorf(`missing parenthesised columns after "EXCEPT"`), cp.lineNum, exceptCol, p.input)
+		}
+		except = cols
+		p.skipBlanks()
+	}
+
+	if !p.skipChar(')') {
+		err := errorAt(fmt.Errorf("missing closing parentheses"), cp.lineNum, cp.colNum(), p.input)
+		cp.restore()
+		return nil, false, err
+	}
+	return except, true, nil
+}
+
+// skipAsteriskColumns advances the parser past an asterisk in parentheses,
+// e.g. "(*)", and returns true. Otherwise it returns false without advancing.
+func (p *Parser) skipAsteriskColumns() bool {
+	cp := p.save()
+	if !p.skipChar('(') {
+		return false
+	}
+	p.skipBlanks()
+	if !p.skipChar('*') {
+		cp.restore()
+		return false
+	}
+	p.skipBlanks()
+	if !p.skipChar(')') {
+		cp.restore()
+		return false
+	}
+	return true
+}
+
 // parseInputExpr parses all forms of input expressions, that is, expressions
 // containing a "$".
 func (p *Parser) parseInputExpr() (expression, bool, error) {
 	inputExprParsers := []func(*Parser) (expression, bool, error){
+		(*Parser).parseInListExpr,
 		(*Parser).parseSliceInputExpr,
 		(*Parser).parseMemberInputExpr,
 		(*Parser).parseInsertExpr,
@@ -855,6 +1190,60 @@ func (p *Parser) parseSliceInputExpr() (expression, bool, error) {
 	return nil, false, nil
 }
 
+// parseInListExpr parses an IN list that holds only a slice input, of the form
+// "col IN ($Type[:])" or "col NOT IN ($Type[:])". Other IN lists are left to
+// be parsed as SQL and input expressions.
+func (p *Parser) parseInListExpr() (expression, bool, error) {
+	cp := p.save()
+	column, ok, err := p.parseColumnAccessor()
+	if err != nil || !ok {
+		cp.restore()
+		return nil, false, nil
+	}
+	bc, ok := column.(basicColumn)
+	if !ok || bc.column == "*" || !p.skipBlanks() {
+		cp.restore()
+		return nil, false, nil
+	}
+	not := false
+	if p.skipKeyword("NOT") {
+		not = true
+		if !p.skipBlanks() {
+			cp.restore()
+			return nil, false, nil
+		}
+	}
+	if !p.skipKeyword("IN") {
+		cp.restore()
+		return nil, false, nil
+	}
+	p.skipBlanks()
+	if !p.skipChar('(') {
+		cp.restore()
+		return nil, false, nil
+	}
+	p.skipBlanks()
+	sliceStart := p.pos
+	slice, ok, err := p.parseSliceInputExpr()
+	if err != nil || !ok {
+		cp.restore()
+		return nil, false, nil
+	}
+	sliceEnd := p.pos
+	p.skipBlanks()
+	if !p.skipChar(')') {
+		cp.restore()
+		return nil, false, nil
+	}
+	return &inListExpr{
+		column: bc,
+		not:    not,
+		slice:  slice.(*sliceInputExpr),
+		open:   p.input[cp.pos:sliceStart],
+		close:  p.input[sliceEnd:p.pos],
+	}, true, nil
+}
+
 // parseMemberInputExpr parses an input expression of the form "$Type.member".
 func (p *Parser) parseMemberInputExpr() (expression, bool, error) {
 	cp := p.save()
@@ -867,6 +1256,9 @@ func (p *Parser) parseMemberInputExpr() (expression, bool, error) {
 		cp.restore()
 		return nil, false, errorAt(fmt.Errorf("invalid asterisk placement in input %q", "$"+ma.String()), cp.lineNum, cp.colNum(), p.input)
 	}
+	if ma.typeName == identTypeName {
+		return &identInputExpr{ma: ma, raw: p.input[cp.pos:p.pos]}, true, nil
+	}
 	return &memberInputExpr{ma: ma, raw: p.input[cp.pos:p.pos]}, true, nil
 }
 
@@ -875,17 +1267,7 @@ func (p *Parser) parseMemberInputExpr() (expression, bool, error) {
 // It is of the form "(*) VALUES ($Type.*, $Type.member,...)".
 func (p *Parser) parseAsteriskInsertExpr() (expression, bool, error) {
 	cp := p.save()
-	if !p.skipChar('(') {
-		return nil, false, nil
-	}
-	p.skipBlanks()
-	if !p.skipChar('*') {
-		cp.restore()
-		return nil, false, nil
-	}
-	p.skipBlanks()
-	if !p.skipChar(')') {
-		cp.restore()
+	if !p.skipAsteriskColumns() {
 		return nil, false, nil
 	}
 	p.skipBlanks()
@@ -927,18 +1309,18 @@ func (p *Parser) parseInsertExpr() (expression, bool, error) {
 	p.skipBlanks()
 
 	colcp := p.save()
-	// Ignore the errors here, let parseBasicInsertValues handle them
+	// Ignore the errors here, let parseBasicInsertRows handle them
 	if sources, ok, _ := p.parseComplexInsertValues(); ok && starCountTypes(sources) != 0 {
 		// If there are no stars in the sources then it is a basicInsertExpr.
 		return &columnsInsertExpr{columns: columns, sources: sources, raw: p.input[cp.pos:p.pos]}, true, nil
 	}
 	colcp.restore()
 
-	if sources, ok, err := p.parseBasicInsertValues(); err != nil {
+	if rows, ok, err := p.parseBasicInsertRows(); err != nil {
 		cp.restore()
 		return nil, false, err
 	} else if ok {
-		return &basicInsertExpr{columns: columns, sources: sources, raw: p.input[cp.pos:p.pos]}, true, nil
+		return &basicInsertExpr{columns: columns, rows: rows, raw: p.input[cp.pos:p.pos]}, true, nil
 	}
 
 	cp.restore()
@@ -965,12 +1347,16 @@ func (p *Parser) parseComplexInsertValues() ([]memberAccessor, bool, error) {
 	return sources, true, nil
 }
 
-// parseBasicInsertValues parses the right hand side of a basic insert
-// expression, this includes literals, but not asterisk accessors.
-func (p *Parser) parseBasicInsertValues() ([]valueAccessor, bool, error) {
+// parseBasicInsertRows parses the right hand side of a basic insert
+// expression with one or more comma separated rows of values, e.g.
+// "($T.a, $T.b), ($U.a, 'literal')". Rows may contain literals, but not
+// asterisk accessors. At least one row must contain a SQLair input.
+func (p *Parser) parseBasicInsertRows() ([][]valueAccessor, bool, error) {
 	cp := p.save()
-	if !p.skipChar('(') {
-		var err error
+	vs, inputParsed, ok, err := p.parseInsertValueList()
+	if err != nil {
+		return nil, false, err
+	} else if !ok {
 		// Check for types with missing parentheses.
 		if _, ok, _ := p.parseInputMemberAccessor(); ok {
 			err = errorAt(fmt.Errorf(`missing parentheses around types after "VALUES"`), cp.lineNum, cp.colNum(), p.input)
@@ -978,10 +1364,44 @@ func (p *Parser) parseBasicInsertValues() ([]valueAccessor, bool, error) {
 		cp.restore()
 		return nil, false, err
 	}
+	rows := [][]valueAccessor{vs}
+	for {
+		rowcp := p.save()
+		p.skipBlanks()
+		if !p.skipChar(',') {
+			rowcp.restore()
+			break
+		}
+		p.skipBlanks()
+		vs, rowInputParsed, ok, err := p.parseInsertValueList()
+		if err != nil {
+			return nil, false, err
+		} else if !ok {
+			rowcp.restore()
+			break
+		}
+		inputParsed = inputParsed || rowInputParsed
+		rows = append(rows, vs)
+	}
+	// If we only parsed literals, and not SQLair inputs, then bypass the
+	// parsed expression.
+	if !inputParsed {
+		cp.restore()
+		return nil, false, nil
+	}
+	return rows, true, nil
+}
+
+// parseInsertValueList parses a parenthesised list of SQLair inputs and
+// literals. inputParsed is true if the list contains at least one SQLair
+// input.
+func (p *Parser) parseInsertValueList() (vs []valueAccessor, inputParsed bool, ok bool, err error) {
+	cp := p.save()
+	if !p.skipChar('(') {
+		return nil, false, false, nil
+	}
 
-	inputParsed := false
 	itemStart := p.pos
-	var vs []valueAccessor
 	// Invariant:
 	// - The previous char excluding blanks is ',' or '('.
 	// - The loop parser will not pass the matching ')'.
@@ -990,31 +1410,26 @@ func (p *Parser) parseBasicInsertValues() ([]valueAccessor, bool, error) {
 		itemStart = p.pos
 
 		if ma, ok, err := p.parseInputMemberAccessor(); err != nil {
-			return nil, false, err
+			return nil, false, false, err
 		} else if ok {
 			inputParsed = true
 			if ma.memberName == "*" {
-				return nil, false, fmt.Errorf("internal error: cannot have asterisk accessor in renaming expression")
+				return nil, false, false, fmt.Errorf("internal error: cannot have asterisk accessor in renaming expression")
 			}
 			vs = append(vs, ma)
 		} else if ok, err = p.skipLiteralInList(); err != nil {
-			return nil, false, err
+			return nil, false, false, err
 		} else if ok {
 			lit := literal{p.input[itemStart:p.pos]}
 			vs = append(vs, lit)
 		} else {
 			cp.restore()
-			return nil, false, nil
+			return nil, false, false, nil
 		}
 
 		p.skipBlanks()
 		if p.skipChar(')') {
-			// If we only parsed literals, and not SQLair inputs, then bypass
-			// the parsed expression.
-			if !inputParsed {
-				return nil, false, nil
-			}
-			return vs, true, nil
+			return vs, inputParsed, true, nil
 		}
 
 		if !p.skipChar(',') {
@@ -1022,5 +1437,5 @@ func (p *Parser) parseBasicInsertValues() ([]valueAccessor, bool, error) {
 		}
 	}
 	cp.restore()
-	return nil, false, nil
+	return nil, false, false, nil
 }
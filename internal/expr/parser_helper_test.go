@@ -310,38 +310,60 @@ func (s parseSuite) TestAdvanceToNextExpr(c *C) {
 func (s parseSuite) TestParseRenamingInsertValues(c *C) {
 	tests := []struct {
 		input    string
-		expected []valueAccessor
+		expected [][]valueAccessor
 	}{{
 		input: `($S.col1, "literal", $S.col2)`,
-		expected: []valueAccessor{
+		expected: [][]valueAccessor{{
 			memberAccessor{typeName: "S", memberName: "col1"},
 			literal{value: `"literal"`},
 			memberAccessor{typeName: "S", memberName: "col2"},
-		},
+		}},
 	}, {
 		input: `( CAST(1 as text) , $S.col1 , 1+7/2)`,
-		expected: []valueAccessor{
+		expected: [][]valueAccessor{{
 			literal{value: `CAST(1 as text) `},
 			memberAccessor{typeName: "S", memberName: "col1"},
 			literal{value: `1+7/2`},
-		},
+		}},
 	}, {
 		input: `((sub, list, (sub, sub, list)), NULL, TRUE, $S.col1)`,
-		expected: []valueAccessor{
+		expected: [][]valueAccessor{{
 			literal{value: `(sub, list, (sub, sub, list))`},
 			literal{value: `NULL`},
 			literal{value: `TRUE`},
 			memberAccessor{typeName: "S", memberName: "col1"},
-		},
+		}},
+	}, {
+		input: `($S.col1, $S.col2), ($T.col1, $T.col2) , ("literal", $T.col2)`,
+		expected: [][]valueAccessor{{
+			memberAccessor{typeName: "S", memberName: "col1"},
+			memberAccessor{typeName: "S", memberName: "col2"},
+		}, {
+			memberAccessor{typeName: "T", memberName: "col1"},
+			memberAccessor{typeName: "T", memberName: "col2"},
+		}, {
+			literal{value: `"literal"`},
+			memberAccessor{typeName: "T", memberName: "col2"},
+		}},
+	}, {
+		input: `(1, 2), ($S.col1, $S.col2)`,
+		expected: [][]valueAccessor{{
+			literal{value: `1`},
+			literal{value: `2`},
+		}, {
+			memberAccessor{typeName: "S", memberName: "col1"},
+			memberAccessor{typeName: "S", memberName: "col2"},
+		}},
 	}}
 
 	var p = NewParser()
 	for _, t := range tests {
 		p.init(t.input)
-		vs, ok, err := p.parseBasicInsertValues()
+		rows, ok, err := p.parseBasicInsertRows()
 		c.Assert(err, IsNil)
 		c.Assert(ok, Equals, true)
-		c.Assert(vs, DeepEquals, t.expected)
+		c.Assert(rows, DeepEquals, t.expected)
+		c.Assert(p.pos, Equals, len(t.input))
 	}
 }
 
@@ -350,12 +372,13 @@ func (s parseSuite) TestParseRenamingInsertValuesFalse(c *C) {
 		`( CAST(1 as text) , "literal" , 1+7/2)`,
 		`()`,
 		`("literal")`,
+		`("literal"), (1)`,
 		``,
 	}
 	var p = NewParser()
 	for _, input := range inputs {
 		p.init(input)
-		_, ok, err := p.parseBasicInsertValues()
+		_, ok, err := p.parseBasicInsertRows()
 		c.Assert(err, IsNil)
 		c.Assert(ok, Equals, false)
 	}
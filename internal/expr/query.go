@@ -6,6 +6,8 @@ package expr
 import (
 	"fmt"
 	"reflect"
+	"strconv"
+	"strings"
 
 	"github.com/canonical/sqlair/internal/typeinfo"
 )
@@ -16,8 +18,52 @@ type PrimedQuery struct {
 	sql string
 	// params are the query parameters to pass to the database.
 	params []any
+	// debugParams are the query parameters with the secret values redacted.
+	// It is nil if there are no secret values.
+	debugParams []any
 	// outputs specifies where to scan the query results.
-	outputs []typeinfo.Output
+	outputs []labelledOutput
+	// bulkRows contains, for each bulk insert, the offsets in sql at which
+	// the values of each row start.
+	bulkRows [][]int
+	// coerceNumeric is true if numeric struct fields should accept any
+	// numeric value that fits in them.
+	coerceNumeric bool
+	// textBool is true if bool struct fields should accept textual
+	// booleans.
+	textBool bool
+	// positional is true if the result columns are read into the outputs by
+	// position rather than by name.
+	positional bool
+	// scalar is true if the single result column of a query without output
+	// expressions is read into a pointer passed as the only output argument.
+	scalar bool
+	// catchAll, if set, is the map that result columns not generated by an
+	// output expression are read into, keyed by column name.
+	catchAll *catchAllMap
+}
+
+// labelledOutput is an output value locator along with the label of the
+// output argument it is scanned into.
+type labelledOutput struct {
+	output typeinfo.Output
+	// label is empty if the output is not labelled.
+	label string
+}
+
+// LabelledArg is an output argument along with the label used to refer to it
+// in the query. Labels allow multiple output arguments of the same type to be
+// used in a single query.
+type LabelledArg struct {
+	Label string
+	Arg   any
+}
+
+// UnclaimedArg is an output argument that receives the columns in the query
+// results that are not scanned into any output expression. Map must be a map
+// with string keys and interface values, such as map[string]any.
+type UnclaimedArg struct {
+	Map any
 }
 
 // Params returns the query parameters to pass with the SQL to a database.
@@ -25,10 +71,54 @@ func (pq *PrimedQuery) Params() []any {
 	return pq.params
 }
 
+// RedactedValue replaces the values of secret inputs in the parameters returned
+// by DebugParams.
+const RedactedValue = "***"
+
+// DebugParams returns the query parameters in a form intended for logging and
+// debugging. They are the same as the parameters returned by Params except that
+// the values of secret struct fields and map keys are replaced with
+// RedactedValue.
+func (pq *PrimedQuery) DebugParams() []any {
+	if pq.debugParams == nil {
+		return pq.params
+	}
+	return pq.debugParams
+}
+
 // HasOutputs returns true if the SQLair query contains at least one output
 // expression.
 func (pq *PrimedQuery) HasOutputs() bool {
-	return len(pq.outputs) > 0
+	return len(pq.outputs) > 0 || pq.catchAll != nil || pq.scalar
+}
+
+// ReadScalar returns a copy of a query without output expressions that
+// returns its results, so that the single column of the results can be read
+// by ScanArgs into a pointer passed as the only output argument. The original
+// query is not changed, since it may be shared by several queries.
+func (pq *PrimedQuery) ReadScalar() (*PrimedQuery, error) {
+	if len(pq.outputs) > 0 || pq.catchAll != nil {
+		return nil, fmt.Errorf("query has output expressions")
+	}
+	scalar := *pq
+	scalar.scalar = true
+	return &scalar, nil
+}
+
+// scalarScanArgs returns the scan argument of a query read with ReadScalar.
+func (pq *PrimedQuery) scalarScanArgs(columnNames []string, outputArgs []any) ([]any, func() error, error) {
+	if len(outputArgs) != 1 {
+		return nil, nil, fmt.Errorf("expected 1 output argument for scalar output, got %d", len(outputArgs))
+	}
+	v := reflect.ValueOf(outputArgs[0])
+	if v.Kind() != reflect.Pointer || v.IsNil() {
+		return nil, nil, fmt.Errorf("need non-nil pointer for scalar output, got %T", outputArgs[0])
+	}
+	if len(columnNames) != 1 {
+		return nil, nil, fmt.Errorf("expected 1 column in the query results for scalar output, got %d\nresult columns: %s",
+			len(columnNames), strings.Join(columnNames, ", "))
+	}
+	return []any{outputArgs[0]}, func() error { return nil }, nil
 }
 
 // SQL returns the SQL string to send to the database.
@@ -36,21 +126,95 @@ func (pq *PrimedQuery) SQL() string {
 	return pq.sql
 }
 
+// DebugSQL returns the SQL in a form intended for logging and debugging. The
+// rows of bulk inserts are written on separate lines, each preceded by a
+// comment with the row number. DebugSQL should not be sent to the database.
+func (pq *PrimedQuery) DebugSQL() string {
+	if len(pq.bulkRows) == 0 {
+		return pq.sql
+	}
+	var sb strings.Builder
+	prev := 0
+	for _, rowStarts := range pq.bulkRows {
+		for row, start := range rowStarts {
+			sb.WriteString(strings.TrimRight(pq.sql[prev:start], " "))
+			sb.WriteString("\n\t/* row " + strconv.Itoa(row) + " */ ")
+			prev = start
+		}
+	}
+	sb.WriteString(pq.sql[prev:])
+	return sb.String()
+}
+
 // ScanArgs produces a list of pointers to be passed to rows.Scan. After a
-// successful call, the onSuccess function must be invoked. The outputArgs will
+// successful call, the onSuccess function must be invoked and its error
+// checked. The outputArgs will
 // be populated with the query results. All the structs/maps/slices mentioned in
-// the query must be in outputArgs.
-func (pq *PrimedQuery) ScanArgs(columnNames []string, outputArgs []any) (scanArgs []any, onSuccess func(), err error) {
-	typeToValue, err := typeinfo.ValidateOutputs(outputArgs)
-	if err != nil {
-		return nil, nil, err
+// the query must be in outputArgs. Output arguments of type LabelledArg are
+// scanned into by the output expressions with a matching label.
+//
+// There is one pointer for each of the columnNames, so the results may contain
+// columns that are not generated by output expressions, in any position. Those
+// columns are scanned into a discarded *any, or into the UnclaimedArg map if
+// one is passed.
+func (pq *PrimedQuery) ScanArgs(columnNames []string, outputArgs []any) (scanArgs []any, onSuccess func() error, err error) {
+	if pq.scalar {
+		return pq.scalarScanArgs(columnNames, outputArgs)
+	}
+	// Group the output arguments by label. The unlabelled arguments are
+	// grouped under the empty label.
+	labels := []string{""}
+	argsByLabel := map[string][]any{"": nil}
+	var unclaimed reflect.Value
+	for _, arg := range outputArgs {
+		if ua, ok := arg.(UnclaimedArg); ok {
+			if unclaimed.IsValid() {
+				return nil, nil, fmt.Errorf("more than one output argument for unclaimed columns")
+			}
+			if unclaimed, err = unclaimedMap(ua.Map); err != nil {
+				return nil, nil, err
+			}
+			continue
+		}
+		la, ok := arg.(LabelledArg)
+		if !ok {
+			argsByLabel[""] = append(argsByLabel[""], arg)
+			continue
+		}
+		if la.Label == "" {
+			return nil, nil, fmt.Errorf("empty label for output argument")
+		}
+		if _, ok := argsByLabel[la.Label]; !ok {
+			labels = append(labels, la.Label)
+		}
+		argsByLabel[la.Label] = append(argsByLabel[la.Label], la.Arg)
+	}
+	typeToValueByLabel := map[string]typeinfo.TypeToValue{}
+	for _, label := range labels {
+		typeToValue, err := typeinfo.ValidateOutputs(argsByLabel[label])
+		if err != nil {
+			if label != "" {
+				return nil, nil, fmt.Errorf("output argument labelled %q: %s", label, err)
+			}
+			return nil, nil, err
+		}
+		typeToValueByLabel[label] = typeToValue
 	}
 
+	if pq.positional && len(columnNames) != len(pq.outputs) {
+		return nil, nil, fmt.Errorf(
+			"expected %d column(s) in the query results for positional output, got %d\nresult columns: %s",
+			len(pq.outputs),
+			len(columnNames),
+			strings.Join(columnNames, ", "),
+		)
+	}
 	if len(columnNames) < len(pq.outputs) {
 		return nil, nil, fmt.Errorf(
-			"expected %d column(s) in the query results, got %d",
+			"expected %d column(s) in the query results, got %d%s",
 			len(pq.outputs),
 			len(columnNames),
+			pq.columnReport(columnNames),
 		)
 	}
 
@@ -58,25 +222,64 @@ func (pq *PrimedQuery) ScanArgs(columnNames []string, outputArgs []any) (scanArg
 	var ptrs []any
 	var scanProxies []typeinfo.ScanProxy
 	var columnInResult = make([]bool, len(pq.outputs))
-	argTypeUsed := map[reflect.Type]bool{}
-	for _, column := range columnNames {
-		idx, ok := markerIndex(column)
+	var unclaimedCols []string
+	var unclaimedPtrs []*any
+	argTypeUsed := map[string]map[reflect.Type]bool{}
+	for i, column := range columnNames {
+		idx, ok := i, true
+		if !pq.positional {
+			idx, ok = markerIndex(column)
+		}
+		if !ok && pq.catchAll != nil {
+			ptr, scanProxy, err := pq.catchAllScanTarget(column, typeToValueByLabel)
+			if err != nil {
+				return nil, nil, err
+			}
+			if pq.coerceNumeric {
+				ptr, scanProxy = typeinfo.CoerceNumeric(ptr, scanProxy)
+			}
+			ptrs = append(ptrs, ptr)
+			if scanProxy != nil {
+				scanProxies = append(scanProxies, *scanProxy)
+			}
+			continue
+		}
 		if !ok {
 			// Columns not mentioned in output expressions are scanned into x.
 			var x any
 			ptrs = append(ptrs, &x)
+			if unclaimed.IsValid() {
+				unclaimedCols = append(unclaimedCols, column)
+				unclaimedPtrs = append(unclaimedPtrs, &x)
+			}
 			continue
 		}
 		if idx >= len(pq.outputs) {
 			return nil, nil, fmt.Errorf("internal error: sqlair column not in outputs (%d>=%d)", idx, len(pq.outputs))
 		}
 		columnInResult[idx] = true
-		output := pq.outputs[idx]
-		ptr, scanProxy, err := output.LocateScanTarget(typeToValue)
+		lo := pq.outputs[idx]
+		typeToValue, ok := typeToValueByLabel[lo.label]
+		if !ok {
+			return nil, nil, fmt.Errorf("no output argument labelled %q", lo.label)
+		}
+		ptr, scanProxy, err := lo.output.LocateScanTarget(typeToValue)
 		if err != nil {
+			if lo.label != "" {
+				return nil, nil, fmt.Errorf("output argument labelled %q: %s", lo.label, err)
+			}
 			return nil, nil, err
 		}
-		argTypeUsed[output.ArgType()] = true
+		if pq.coerceNumeric {
+			ptr, scanProxy = typeinfo.CoerceNumeric(ptr, scanProxy)
+		}
+		if pq.textBool {
+			ptr, scanProxy = typeinfo.TextBool(ptr, scanProxy)
+		}
+		if argTypeUsed[lo.label] == nil {
+			argTypeUsed[lo.label] = map[reflect.Type]bool{}
+		}
+		argTypeUsed[lo.label][lo.output.ArgType()] = true
 
 		ptrs = append(ptrs, ptr)
 		if scanProxy != nil {
@@ -87,23 +290,163 @@ func (pq *PrimedQuery) ScanArgs(columnNames []string, outputArgs []any) (scanArg
 	for i := 0; i < len(pq.outputs); i++ {
 		if !columnInResult[i] {
 			return nil, nil, fmt.Errorf(
-				`column(s) for output "&%s" not found in query results`,
-				pq.outputs[i].ArgType().Name(),
+				`column(s) for output "&%s" not found in query results%s`,
+				labelledTypeName(typeinfo.TypeName(pq.outputs[i].output.ArgType()), pq.outputs[i].label),
+				pq.columnReport(columnNames),
 			)
 		}
 	}
 
-	for argType := range typeToValue {
-		if !argTypeUsed[argType] {
-			return nil, nil, fmt.Errorf("%q not referenced in query", argType.Name())
+	if c := pq.catchAll; c != nil {
+		if argTypeUsed[c.label] == nil {
+			argTypeUsed[c.label] = map[reflect.Type]bool{}
+		}
+		argTypeUsed[c.label][c.mapInfo.Typ()] = true
+	}
+	for _, label := range labels {
+		for argType := range typeToValueByLabel[label] {
+			if !argTypeUsed[label][argType] {
+				return nil, nil, fmt.Errorf("%q not referenced in query", labelledTypeName(typeinfo.TypeName(argType), label))
+			}
 		}
 	}
 
-	onSuccess = func() {
+	onSuccess = func() error {
 		for _, sp := range scanProxies {
-			sp.OnSuccess()
+			if err := sp.OnSuccess(); err != nil {
+				return err
+			}
+		}
+		for i, column := range unclaimedCols {
+			val := reflect.ValueOf(*unclaimedPtrs[i])
+			if !val.IsValid() {
+				val = reflect.Zero(unclaimed.Type().Elem())
+			}
+			unclaimed.SetMapIndex(reflect.ValueOf(column).Convert(unclaimed.Type().Key()), val)
 		}
+		return nil
 	}
 
 	return ptrs, onSuccess, nil
 }
+
+// catchAllScanTarget returns the scan target for a result column read into
+// the key of the same name in the catch-all map.
+func (pq *PrimedQuery) catchAllScanTarget(column string, typeToValueByLabel map[string]typeinfo.TypeToValue) (any, *typeinfo.ScanProxy, error) {
+	c := pq.catchAll
+	typeToValue, ok := typeToValueByLabel[c.label]
+	if !ok {
+		return nil, nil, fmt.Errorf("no output argument labelled %q", c.label)
+	}
+	vl, err := c.mapInfo.GetMember(column)
+	if err != nil {
+		return nil, nil, err
+	}
+	output, ok := vl.(typeinfo.Output)
+	if !ok {
+		return nil, nil, fmt.Errorf("internal error: %s cannot be used as output", vl.ArgType().Kind())
+	}
+	ptr, scanProxy, err := output.LocateScanTarget(typeToValue)
+	if err != nil {
+		if c.label != "" {
+			return nil, nil, fmt.Errorf("output argument labelled %q: %s", c.label, err)
+		}
+		return nil, nil, err
+	}
+	return ptr, scanProxy, nil
+}
+
+// OutputField returns the index sequence of the field of the struct type t
+// that the member is read into by an unlabelled output expression. It returns
+// false if the query does not read the member.
+func (pq *PrimedQuery) OutputField(t reflect.Type, member string) ([]int, bool) {
+	for _, lo := range pq.outputs {
+		if lo.label == "" && lo.output.ArgType() == t && lo.output.Member().Name == member {
+			return typeinfo.FieldIndex(lo.output)
+		}
+	}
+	return nil, false
+}
+
+// ScanError adds a report of the expected and actual columns of the query
+// results to an error returned by rows.Scan when scanning into the arguments
+// from ScanArgs.
+func (pq *PrimedQuery) ScanError(columnNames []string, err error) error {
+	if pq.scalar {
+		return err
+	}
+	return fmt.Errorf("%s%s", err, pq.columnReport(columnNames))
+}
+
+// columnReport describes the columns that the query results are expected to
+// contain, each with the output it is scanned into, the columns actually in
+// the results and the first result column at which they diverge. Columns not
+// generated by output expressions are allowed anywhere in the results.
+func (pq *PrimedQuery) columnReport(columnNames []string) string {
+	var expected []string
+	for i := range pq.outputs {
+		expected = append(expected, fmt.Sprintf("%s (&%s)", markerName(i), pq.outputDesc(i)))
+	}
+	var b strings.Builder
+	b.WriteString("\nexpected columns: " + strings.Join(expected, ", "))
+	if len(columnNames) == 0 {
+		b.WriteString("\nresult columns: none")
+	} else {
+		b.WriteString("\nresult columns: " + strings.Join(columnNames, ", "))
+	}
+
+	next := 0
+	for i, column := range columnNames {
+		idx, ok := markerIndex(column)
+		if !ok {
+			continue
+		}
+		if next >= len(pq.outputs) {
+			b.WriteString(fmt.Sprintf("\nfirst divergence at result column %d: expected no more output columns, got %s", i, column))
+			return b.String()
+		}
+		if idx != next {
+			b.WriteString(fmt.Sprintf("\nfirst divergence at result column %d: expected %s, got %s", i, expected[next], column))
+			return b.String()
+		}
+		next++
+	}
+	if next < len(pq.outputs) {
+		b.WriteString(fmt.Sprintf("\nfirst divergence at result column %d: expected %s, got end of results", len(columnNames), expected[next]))
+	}
+	return b.String()
+}
+
+// outputDesc returns the output expression member that the output at index i
+// is scanned into, e.g. "Person:p.id".
+func (pq *PrimedQuery) outputDesc(i int) string {
+	lo := pq.outputs[i]
+	typeName := typeinfo.TypeName(lo.output.ArgType())
+	member := strings.TrimPrefix(lo.output.Identifier(), typeName+".")
+	return labelledTypeName(typeName, lo.label) + "." + member
+}
+
+// unclaimedMap checks that the argument for unclaimed columns is a non-nil map
+// with string keys and interface values and returns its value.
+func unclaimedMap(arg any) (reflect.Value, error) {
+	v := reflect.ValueOf(arg)
+	if v.Kind() == reflect.Pointer && !v.IsNil() {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Map || v.Type().Key().Kind() != reflect.String || v.Type().Elem().Kind() != reflect.Interface {
+		return reflect.Value{}, fmt.Errorf("need map with string keys and interface values for unclaimed columns, got %s", v.Kind())
+	}
+	if v.IsNil() {
+		return reflect.Value{}, fmt.Errorf("got nil map for unclaimed columns")
+	}
+	return v, nil
+}
+
+// labelledTypeName returns the type name as it appears in an output expression
+// with the given label.
+func labelledTypeName(typeName string, label string) string {
+	if label == "" {
+		return typeName
+	}
+	return typeName + ":" + label
+}
@@ -8,6 +8,7 @@ import (
 	"database/sql"
 	"fmt"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -17,6 +18,8 @@ import (
 // queryBuilder is used to build up a query to be passed to the database from a
 // type bound expression
 type queryBuilder struct {
+	// dialect is the SQL dialect of the generated query.
+	dialect *Dialect
 	// inputCount tracks the number of query inputs.
 	inputAssigner *inputAssigner
 	// outputCount tracks the number of query outputs.
@@ -27,22 +30,53 @@ type queryBuilder struct {
 
 	// sqlBuilder is used to accumulate the generated SQL.
 	sqlBuilder sqlBuilder
-	// namedInputs are the named input values corresponding to the placeholders
-	// in the SQL. They will be passed to the database at query time.
-	namedInputs []any
+	// inputs are the input values corresponding to the placeholders in the
+	// SQL. They will be passed to the database at query time.
+	inputs []queryInput
 	// outputs are the output value locators to be used when the SQL is scanned.
-	outputs []typeinfo.Output
+	outputs []labelledOutput
+	// secretKeys holds the map keys whose values are redacted from logging
+	// output.
+	secretKeys map[string]bool
+	// resolveValuers is true if the driver.Valuer elements of slice inputs
+	// are replaced with their values before the query is run.
+	resolveValuers bool
+	// positional is true if the outputs are scanned from the result columns
+	// by position rather than by name.
+	positional bool
+	// catchAll, if set, is the map that result columns not generated by an
+	// output expression are read into.
+	catchAll *catchAllMap
+	// memberInputs holds the input number of each single value member input
+	// added to the query, keyed by its identifier.
+	memberInputs map[string]int
+	// placeholderNums holds the input numbers of the placeholders in the
+	// order they are written in the SQL. It is only kept for dialects with
+	// positional placeholders.
+	placeholderNums []int
 }
 
-// newQueryBuilder builds a new queryBuilder with the inputs in typeToValue.
-func newQueryBuilder() *queryBuilder {
+// queryInput is a query parameter along with the input number of its
+// placeholder in the SQL.
+type queryInput struct {
+	num int
+	val any
+	// secret is true if the value is redacted from logging output.
+	secret bool
+}
+
+// newQueryBuilder builds a new queryBuilder that generates SQL in the given
+// dialect.
+func newQueryBuilder(dialect *Dialect) *queryBuilder {
 	return &queryBuilder{
+		dialect:       dialect,
 		sqlBuilder:    sqlBuilder{},
 		inputAssigner: &inputAssigner{},
 		outputCount:   0,
 		argUsed:       map[reflect.Type]bool{},
-		namedInputs:   []any{},
-		outputs:       []typeinfo.Output{},
+		inputs:        []queryInput{},
+		outputs:       []labelledOutput{},
+		memberInputs:  map[string]int{},
 	}
 }
 
@@ -51,14 +85,158 @@ func (qb *queryBuilder) markArgUsed(t reflect.Type) {
 	qb.argUsed[t] = true
 }
 
-// addInputs adds input placeholders and argument values to the query.
-func (qb *queryBuilder) addInputs(inputVals []any) {
+// addInputs adds input placeholders and argument values to the query. If
+// secret is true the values are redacted from logging output.
+func (qb *queryBuilder) addInputs(inputVals []any, secret bool) {
 	firstInputNum := qb.inputAssigner.assignInputs(len(inputVals))
 	for i, val := range inputVals {
-		namedInput := sql.Named("sqlair_"+strconv.Itoa(firstInputNum+i), val)
-		qb.namedInputs = append(qb.namedInputs, namedInput)
+		qb.inputs = append(qb.inputs, queryInput{num: firstInputNum + i, val: val, secret: secret})
+	}
+	qb.sqlBuilder.writeInputs(qb.placeholder, firstInputNum, len(inputVals))
+}
+
+// addSliceInputs adds input placeholders and argument values for the elements
+// of a slice input. An empty slice is written as NULL if the dialect requires
+// it, since some databases reject an empty list such as "IN ()".
+func (qb *queryBuilder) addSliceInputs(inputVals []any, secret bool) {
+	if len(inputVals) == 0 && qb.dialect.emptySliceNull {
+		qb.sqlBuilder.write("NULL")
+		return
+	}
+	qb.addInputs(inputVals, secret)
+}
+
+// sliceVals returns the parameters of a slice input, with the elements that
+// implement driver.Valuer replaced with their values if the option is set.
+func (qb *queryBuilder) sliceVals(input typeinfo.Input, params *typeinfo.Params) ([]any, error) {
+	if !qb.resolveValuers {
+		return params.Vals, nil
+	}
+	return typeinfo.ResolveValuers(params.Vals, input.ArgType())
+}
+
+// convertInputs replaces the values of the query inputs with the results of
+// the input converters registered for their types.
+func (qb *queryBuilder) convertInputs() error {
+	for i, in := range qb.inputs {
+		val, err := typeinfo.ConvertInput(in.val)
+		if err != nil {
+			return err
+		}
+		qb.inputs[i].val = val
+	}
+	return nil
+}
+
+// addMemberInput adds an input placeholder for the value of a struct field or
+// map key identified by id. If the member has already been added to the query
+// its placeholder is written again and no new parameter is added.
+func (qb *queryBuilder) addMemberInput(id string, val any, secret bool) {
+	num, isNew := qb.memberInputNum(id)
+	if isNew {
+		qb.inputs = append(qb.inputs, queryInput{num: num, val: val, secret: secret})
+	}
+	qb.sqlBuilder.writeInputs(qb.placeholder, num, 1)
+}
+
+// memberInputNum returns the input number of the struct field or map key
+// identified by id. A new number is assigned if the member has not been added
+// to the query, in which case isNew is true and the caller must add the
+// parameter.
+func (qb *queryBuilder) memberInputNum(id string) (num int, isNew bool) {
+	if num, ok := qb.memberInputs[id]; ok {
+		return num, false
+	}
+	num = qb.inputAssigner.assignInputs(1)
+	qb.memberInputs[id] = num
+	return num, true
+}
+
+// placeholder returns the SQL placeholder for the query parameter with the
+// input number n. It must be called in the order the placeholders are written
+// in the SQL.
+func (qb *queryBuilder) placeholder(n int) string {
+	if qb.dialect.placeholders == positionalPlaceholders {
+		qb.placeholderNums = append(qb.placeholderNums, n)
+	}
+	return qb.dialect.placeholder(n)
+}
+
+// numParams returns the number of query parameters passed to the database.
+func (qb *queryBuilder) numParams() int {
+	if qb.dialect.placeholders == positionalPlaceholders {
+		return len(qb.placeholderNums)
+	}
+	return len(qb.inputs)
+}
+
+// isSecret returns true if the values of the input are redacted from logging
+// output, either because the struct field is tagged as secret or because the
+// map key is one of the secret keys.
+func (qb *queryBuilder) isSecret(input typeinfo.Input, params *typeinfo.Params) bool {
+	if params.Secret {
+		return true
 	}
-	qb.sqlBuilder.writeInputs(firstInputNum, len(inputVals))
+	return input.ArgType().Kind() == reflect.Map && qb.secretKeys[input.Member().Name]
+}
+
+// params returns the query parameters to pass to the database in the form
+// expected by the dialect.
+func (qb *queryBuilder) params() []any {
+	return qb.paramsWith(func(in queryInput) any { return in.val })
+}
+
+// debugParams returns the query parameters with the values of secret inputs
+// replaced by RedactedValue. It returns nil if there are no secret inputs.
+func (qb *queryBuilder) debugParams() []any {
+	for _, in := range qb.inputs {
+		if in.secret {
+			return qb.paramsWith(redact)
+		}
+	}
+	return nil
+}
+
+// redact returns the value of the query input, or RedactedValue if it is
+// secret.
+func redact(in queryInput) any {
+	if in.secret {
+		return RedactedValue
+	}
+	return in.val
+}
+
+// paramsWith returns the values of the query inputs given by value in the
+// form expected by the dialect.
+func (qb *queryBuilder) paramsWith(value func(queryInput) any) []any {
+	params := make([]any, 0, qb.numParams())
+	switch qb.dialect.placeholders {
+	case positionalPlaceholders:
+		// Positional parameters are passed in the order of the placeholders
+		// in the SQL, so a repeated placeholder repeats its parameter.
+		inputs := make(map[int]queryInput, len(qb.inputs))
+		for _, in := range qb.inputs {
+			inputs[in.num] = in
+		}
+		for _, num := range qb.placeholderNums {
+			params = append(params, value(inputs[num]))
+		}
+	case numberedPlaceholders:
+		// Numbered parameters are passed in order of their input number.
+		inputs := make([]queryInput, len(qb.inputs))
+		copy(inputs, qb.inputs)
+		sort.SliceStable(inputs, func(i, j int) bool {
+			return inputs[i].num < inputs[j].num
+		})
+		for _, in := range inputs {
+			params = append(params, value(in))
+		}
+	default:
+		for _, in := range qb.inputs {
+			params = append(params, sql.Named(inputName(in.num), value(in)))
+		}
+	}
+	return params
 }
 
 // addInsert adds a typedInsertExpr to the queryBuilder
@@ -69,13 +247,13 @@ func (qb *queryBuilder) addInsert(boundColumns []*boundInsertColumn, numRows int
 		var rowSQL []string
 		for _, bc := range boundColumns {
 			if !bc.omit {
-				valueSQL, namedInput, newParam, err := bc.parameter(rowNum)
+				valueSQL, input, newParam, err := bc.parameter(qb.placeholder, rowNum)
 				if err != nil {
 					return err
 				}
 				rowSQL = append(rowSQL, valueSQL)
 				if newParam {
-					qb.namedInputs = append(qb.namedInputs, namedInput)
+					qb.inputs = append(qb.inputs, input)
 				}
 			}
 		}
@@ -90,13 +268,62 @@ func (qb *queryBuilder) addInsert(boundColumns []*boundInsertColumn, numRows int
 	return nil
 }
 
+// addInsertRows adds a typedInsertExpr with several rows of values to the
+// queryBuilder. The columns are taken from the first row.
+func (qb *queryBuilder) addInsertRows(rows [][]*boundInsertColumn) error {
+	var rowsSQL [][]string
+	var columnNames []string
+	for _, boundColumns := range rows {
+		var rowSQL []string
+		for _, bc := range boundColumns {
+			valueSQL, input, newParam, err := bc.parameter(qb.placeholder, 0)
+			if err != nil {
+				return err
+			}
+			rowSQL = append(rowSQL, valueSQL)
+			if newParam {
+				qb.inputs = append(qb.inputs, input)
+			}
+		}
+		rowsSQL = append(rowsSQL, rowSQL)
+	}
+	for _, bc := range rows[0] {
+		columnNames = append(columnNames, bc.column)
+	}
+	qb.sqlBuilder.writeInsert(columnNames, rowsSQL)
+	return nil
+}
+
 // addOutput adds a typedOutputExpr to the queryBuilder
-func (qb *queryBuilder) addOutput(columns []string, outputs []typeinfo.Output) {
+func (qb *queryBuilder) addOutput(columns []string, outputs []labelledOutput) {
 	qb.sqlBuilder.writeOutput(qb.outputCount, columns)
 	qb.outputCount += len(columns)
 	qb.outputs = append(qb.outputs, outputs...)
 }
 
+// addPositionalOutput writes the asterisk column of an output expression that
+// is read by position. The columns are not aliased since the outputs are
+// matched to the result columns in order.
+func (qb *queryBuilder) addPositionalOutput(columns string, outputs []labelledOutput) {
+	qb.sqlBuilder.write(columns)
+	qb.outputCount += len(outputs)
+	qb.outputs = append(qb.outputs, outputs...)
+	qb.positional = true
+}
+
+// addCatchAllOutput writes the asterisk column of a map output that reads all
+// the result columns not read by other outputs. The columns are not aliased
+// since they are read into the map by name.
+func (qb *queryBuilder) addCatchAllOutput(c *catchAllMap) {
+	qb.sqlBuilder.write(c.columns)
+	qb.catchAll = c
+}
+
+// addIdent writes a validated SQL identifier to the queryBuilder.
+func (qb *queryBuilder) addIdent(ident string) {
+	qb.sqlBuilder.write(ident)
+}
+
 // addBypass adds a bypass part to the queryBuilder
 func (qb *queryBuilder) addBypass(b *bypass) error {
 	qb.sqlBuilder.write(b.chunk)
@@ -153,26 +380,29 @@ type boundInsertColumn struct {
 	literal string
 	// column is the column name.
 	column string
+	// secret is true if the values are redacted from logging output.
+	secret bool
+	// shared is true if the value is the parameter of a member that has
+	// already been added to the query.
+	shared bool
 }
 
-// parameter returns the value to be inserted into the boundInsertColumn in the given
-// row. The inputCount it used to generate the parameter name.
-func (bc *boundInsertColumn) parameter(row int) (name string, v any, newParam bool, err error) {
+// parameter returns the SQL and the query input for the value to be inserted
+// into the boundInsertColumn in the given row. The firstInputNum is used to
+// generate the placeholder with the placeholder function.
+func (bc *boundInsertColumn) parameter(placeholder func(n int) string, row int) (valueSQL string, input queryInput, newParam bool, err error) {
 	switch {
 	case len(bc.vals) == 0:
-		return bc.literal, nil, false, nil
+		return bc.literal, queryInput{}, false, nil
 	case len(bc.vals) == 1:
-		name = "sqlair_" + strconv.Itoa(bc.firstInputNum)
-		newParam = false
-		if row == 0 {
-			newParam = true
-		}
-		return "@" + name, sql.Named(name, bc.vals[0]), newParam, nil
+		newParam = row == 0 && !bc.shared
+		input = queryInput{num: bc.firstInputNum, val: bc.vals[0], secret: bc.secret}
+		return placeholder(input.num), input, newParam, nil
 	case row < len(bc.vals):
-		name = "sqlair_" + strconv.Itoa(bc.firstInputNum+row)
-		return "@" + name, sql.Named(name, bc.vals[row]), true, nil
+		input = queryInput{num: bc.firstInputNum + row, val: bc.vals[row], secret: bc.secret}
+		return placeholder(input.num), input, true, nil
 	default:
-		return "", nil, false, fmt.Errorf("internal error: no bulk insert value for row %d, only have %d values", row, len(bc.vals))
+		return "", queryInput{}, false, fmt.Errorf("internal error: no bulk insert value for row %d, only have %d values", row, len(bc.vals))
 	}
 }
 
@@ -180,6 +410,9 @@ func (bc *boundInsertColumn) parameter(row int) (name string, v any, newParam bo
 // methods.
 type sqlBuilder struct {
 	buf bytes.Buffer
+	// bulkRows contains, for each bulk insert, the offsets in the SQL at
+	// which the values of each row start. It is only used for debugging.
+	bulkRows [][]int
 }
 
 // writeInsert writes the SQL for INSERT statements to the sqlBuilder.
@@ -191,28 +424,37 @@ func (b *sqlBuilder) writeInsert(columns []string, rows [][]string) {
 	})
 	b.buf.WriteString(") VALUES ")
 	// Write out the values.
+	var rowStarts []int
 	for i, row := range rows {
 		if i != 0 {
 			b.buf.WriteString(", ")
 		}
+		rowStarts = append(rowStarts, b.buf.Len())
 		b.buf.WriteString("(")
 		b.writeCommaSeparatedList(row, func(_ int, value string) string {
 			return value
 		})
 		b.buf.WriteString(")")
 	}
+	if len(rows) > 1 {
+		b.bulkRows = append(b.bulkRows, rowStarts)
+	}
 }
 
 // writeInputs writes the SQL for input placeholders to the sqlBuilder.
-func (b *sqlBuilder) writeInputs(inputCount, num int) {
+func (b *sqlBuilder) writeInputs(placeholder func(n int) string, inputCount, num int) {
 	b.writeCommaSeparatedList(make([]string, num), func(i int, column string) string {
-		return "@sqlair_" + strconv.Itoa(inputCount+i)
+		return placeholder(inputCount + i)
 	})
 }
 
 // writeOutput writes the SQL for output columns to the sqlBuilder.
 func (b *sqlBuilder) writeOutput(outputCount int, columns []string) {
 	b.writeCommaSeparatedList(columns, func(i int, column string) string {
+		// An empty column aliases the SQL expression already written.
+		if column == "" {
+			return "AS " + markerName(outputCount+i)
+		}
 		return column + " AS " + markerName(outputCount+i)
 	})
 }
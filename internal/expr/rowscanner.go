@@ -0,0 +1,173 @@
+// Copyright 2024 Canonical Ltd.
+// Licensed under Apache 2.0, see LICENCE file for details.
+
+package expr
+
+import (
+	"reflect"
+
+	"github.com/canonical/sqlair/internal/typeinfo"
+)
+
+// RowScanner generates the scan arguments for the rows of a query result. The
+// first row is checked by ScanArgs. If the outputs are all struct fields, the
+// struct field that each column is read into is then cached, and the
+// following rows with output arguments of the same types are scanned without
+// looking up the arguments and fields again.
+type RowScanner struct {
+	pq      *PrimedQuery
+	columns []string
+	plan    *scanPlan
+}
+
+// NewRowScanner returns a RowScanner for the rows of the query with the given
+// result columns.
+func (pq *PrimedQuery) NewRowScanner(columnNames []string) *RowScanner {
+	return &RowScanner{pq: pq, columns: columnNames}
+}
+
+// ScanArgs returns the scan arguments for the next row, as
+// PrimedQuery.ScanArgs does. The returned slice is reused for the next row.
+func (rs *RowScanner) ScanArgs(outputArgs []any) ([]any, func() error, error) {
+	if rs.plan != nil && rs.plan.matches(outputArgs) {
+		return rs.plan.scanArgs(outputArgs)
+	}
+	ptrs, onSuccess, err := rs.pq.ScanArgs(rs.columns, outputArgs)
+	if err != nil {
+		return nil, nil, err
+	}
+	rs.plan = rs.pq.newScanPlan(rs.columns, outputArgs)
+	return ptrs, onSuccess, nil
+}
+
+// scanPlan holds the struct field that each result column is read into for
+// output arguments of fixed types.
+type scanPlan struct {
+	// argTypes and labels are the types and labels of the output arguments
+	// the plan is for.
+	argTypes []reflect.Type
+	labels   []string
+	// columns holds, for each result column, the index of the output
+	// argument it is read into and the scanner for the struct field. The
+	// scanner is nil if the column is discarded.
+	columns []columnScan
+
+	structs   []reflect.Value
+	ptrs      []any
+	proxies   []typeinfo.ScanProxy
+	discard   []any
+	onSuccess func() error
+}
+
+// columnScan describes where a result column is read into.
+type columnScan struct {
+	arg     int
+	scanner *typeinfo.FieldScanner
+}
+
+// newScanPlan returns a scanPlan for outputArgs, which have been checked by
+// ScanArgs. It returns nil if the outputs cannot be scanned with a plan.
+func (pq *PrimedQuery) newScanPlan(columnNames []string, outputArgs []any) *scanPlan {
+	if pq.scalar || pq.catchAll != nil || pq.coerceNumeric || pq.textBool {
+		return nil
+	}
+	p := &scanPlan{}
+	for _, arg := range outputArgs {
+		label := ""
+		if la, ok := arg.(LabelledArg); ok {
+			label, arg = la.Label, la.Arg
+		}
+		t := reflect.TypeOf(arg)
+		if t == nil || t.Kind() != reflect.Pointer || t.Elem().Kind() != reflect.Struct {
+			return nil
+		}
+		p.argTypes = append(p.argTypes, t)
+		p.labels = append(p.labels, label)
+	}
+	scanners := make([]*typeinfo.FieldScanner, len(pq.outputs))
+	for i, lo := range pq.outputs {
+		fs, ok := typeinfo.NewFieldScanner(lo.output)
+		if !ok {
+			return nil
+		}
+		scanners[i] = fs
+	}
+	for i, column := range columnNames {
+		idx, ok := i, true
+		if !pq.positional {
+			idx, ok = markerIndex(column)
+		}
+		if !ok {
+			p.columns = append(p.columns, columnScan{arg: -1})
+			continue
+		}
+		lo := pq.outputs[idx]
+		arg := -1
+		for j, t := range p.argTypes {
+			if p.labels[j] == lo.label && t.Elem() == lo.output.ArgType() {
+				arg = j
+				break
+			}
+		}
+		if arg == -1 {
+			return nil
+		}
+		p.columns = append(p.columns, columnScan{arg: arg, scanner: scanners[idx]})
+	}
+	p.structs = make([]reflect.Value, len(p.argTypes))
+	p.ptrs = make([]any, len(p.columns))
+	p.discard = make([]any, len(p.columns))
+	p.onSuccess = p.runProxies
+	return p
+}
+
+// matches returns true if outputArgs are non-nil and have the types and
+// labels of the output arguments the plan is for.
+func (p *scanPlan) matches(outputArgs []any) bool {
+	if len(outputArgs) != len(p.argTypes) {
+		return false
+	}
+	for i, arg := range outputArgs {
+		label := ""
+		if la, ok := arg.(LabelledArg); ok {
+			label, arg = la.Label, la.Arg
+		}
+		if label != p.labels[i] || reflect.TypeOf(arg) != p.argTypes[i] || reflect.ValueOf(arg).IsNil() {
+			return false
+		}
+	}
+	return true
+}
+
+// scanArgs returns the scan arguments for outputArgs, which match the plan.
+func (p *scanPlan) scanArgs(outputArgs []any) ([]any, func() error, error) {
+	for i, arg := range outputArgs {
+		if la, ok := arg.(LabelledArg); ok {
+			arg = la.Arg
+		}
+		p.structs[i] = reflect.ValueOf(arg).Elem()
+	}
+	p.proxies = p.proxies[:0]
+	for i, c := range p.columns {
+		if c.scanner == nil {
+			p.ptrs[i] = &p.discard[i]
+			continue
+		}
+		ptr, proxy, ok := c.scanner.ScanTarget(p.structs[c.arg])
+		p.ptrs[i] = ptr
+		if ok {
+			p.proxies = append(p.proxies, proxy)
+		}
+	}
+	return p.ptrs, p.onSuccess, nil
+}
+
+// runProxies copies the scanned values of the last row into their fields.
+func (p *scanPlan) runProxies() error {
+	for _, sp := range p.proxies {
+		if err := sp.OnSuccess(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+// Copyright 2024 Canonical Ltd.
+// Licensed under Apache 2.0, see LICENCE file for details.
+
+package expr
+
+import (
+	"fmt"
+	"strings"
+)
+
+// checkBareAsterisks returns an error if a bypass part selects all columns
+// with an asterisk in the same query as an output expression, as in
+// "SELECT *, &Person.id FROM person" or "SELECT p.*, &Person.id FROM person".
+// The columns selected by the asterisk are not read into any output argument.
+// Asterisks in subqueries, which are nested in parentheses, only count if the
+// subquery also contains an output expression.
+func checkBareAsterisks(exprs []expression) error {
+	type asterisk struct {
+		depth int
+		chunk string
+	}
+	var asterisks []asterisk
+	outputDepths := map[int]bool{}
+	depth := 0
+	for _, e := range exprs {
+		switch e := e.(type) {
+		case *bypass:
+			var depths []int
+			depths, depth = bareAsterisks(e.chunk, depth)
+			for _, d := range depths {
+				asterisks = append(asterisks, asterisk{depth: d, chunk: e.chunk})
+			}
+		case *outputExpr:
+			outputDepths[depth] = true
+		}
+	}
+	for _, a := range asterisks {
+		if outputDepths[a.depth] {
+			return fmt.Errorf("cannot mix asterisk with output expressions in strict mode: %s", strings.TrimSpace(a.chunk))
+		}
+	}
+	return nil
+}
+
+// bareAsterisks returns the parenthesis depths of the asterisks in a list of
+// result columns in the SQL, e.g. "*" or "t.*", along with the depth at the end
+// of the SQL. The SQL starts at the given depth. Asterisks in string literals,
+// comments and function calls such as "count(*)", and those used for
+// multiplication, are ignored.
+func bareAsterisks(sql string, depth int) (depths []int, endDepth int) {
+	tokens := sqlTokens(sql)
+	for i, tok := range tokens {
+		switch tok {
+		case "(":
+			depth++
+		case ")":
+			depth--
+		}
+		if tok != "*" {
+			continue
+		}
+		// Skip over a table qualifier, e.g. "t." in "t.*".
+		j := i - 1
+		if j >= 1 && tokens[j] == "." {
+			j -= 2
+		}
+		if j < 0 {
+			continue
+		}
+		switch strings.ToUpper(tokens[j]) {
+		case "SELECT", "DISTINCT", "ALL", "RETURNING", ",":
+		default:
+			continue
+		}
+		if i+1 < len(tokens) && (tokens[i+1] == "," || strings.EqualFold(tokens[i+1], "FROM")) {
+			depths = append(depths, depth)
+		}
+	}
+	return depths, depth
+}
+
+// sqlTokens splits SQL into names and single characters, skipping blanks,
+// comments and string literals. Quoted identifiers and string literals are
+// returned as a single quote character.
+func sqlTokens(sql string) []string {
+	p := NewParser()
+	p.init(sql)
+	var tokens []string
+	for p.pos < len(p.input) {
+		if p.skipBlanks() {
+			continue
+		}
+		start := p.pos
+		if ok, err := p.skipStringLiteral(); ok || err != nil {
+			if err != nil {
+				// An unterminated literal runs to the end of the SQL.
+				break
+			}
+			tokens = append(tokens, sql[start:start+1])
+			continue
+		}
+		if p.skipName() {
+			tokens = append(tokens, sql[start:p.pos])
+			continue
+		}
+		p.advanceChar()
+		tokens = append(tokens, sql[start:p.pos])
+	}
+	return tokens
+}
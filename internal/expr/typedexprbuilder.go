@@ -6,7 +6,9 @@ package expr
 import (
 	"fmt"
 	"reflect"
+	"regexp"
 	"sort"
+	"strings"
 
 	"github.com/canonical/sqlair/internal/typeinfo"
 )
@@ -17,6 +19,12 @@ type typedExprBuilder struct {
 	argUsed    map[typeinfo.ArgInfo]bool
 	outputUsed map[string]bool
 	typedExprs []typedExpr
+	// opts are the options that change the generated SQL and the scanning
+	// of results.
+	opts BindOptions
+	// description describes the inputs and outputs of the typed
+	// expressions.
+	description Description
 }
 
 func newTypedExprBuilder(argInfos map[string]typeinfo.ArgInfo) *typedExprBuilder {
@@ -27,13 +35,32 @@ func newTypedExprBuilder(argInfos map[string]typeinfo.ArgInfo) *typedExprBuilder
 	}
 }
 
+// columnKey returns the key used to match a column name against the db tags
+// of structs. Columns are lowercased if tags are matched ignoring case.
+func (teb *typedExprBuilder) columnKey(column string) string {
+	if teb.opts.CaseInsensitiveTags {
+		return strings.ToLower(column)
+	}
+	return column
+}
+
+// prefixedOutputColumn generates an output column for a column generated from
+// an asterisk. The table name, if any, is added to the column name as set by
+// the ColumnPrefix option.
+func (teb *typedExprBuilder) prefixedOutputColumn(tableName string, columnName string, output typeinfo.Output, label string) outputColumn {
+	if tableName != "" && teb.opts.ColumnPrefix == UnderscorePrefix {
+		return newOutputColumn("", tableName+"_"+columnName, output, label)
+	}
+	return newOutputColumn(tableName, columnName, output, label)
+}
+
 // InputMember returns an input locator for a member of a struct or map.
 func (teb *typedExprBuilder) InputMember(typeName string, memberName string) (typeinfo.Input, error) {
 	arg, err := teb.getArg(typeName)
 	if err != nil {
 		return nil, err
 	}
-	vl, err := arg.GetMember(memberName)
+	vl, err := teb.getMember(arg, memberName)
 	if err != nil {
 		return nil, err
 	}
@@ -44,13 +71,14 @@ func (teb *typedExprBuilder) InputMember(typeName string, memberName string) (ty
 	return input, nil
 }
 
-// OutputMember returns an output locator for a member of a struct or map.
-func (teb *typedExprBuilder) OutputMember(typeName string, memberName string) (typeinfo.Output, error) {
+// OutputMember returns an output locator for a member of a struct or map. The
+// label distinguishes between output arguments of the same type.
+func (teb *typedExprBuilder) OutputMember(typeName string, memberName string, label string) (typeinfo.Output, error) {
 	arg, err := teb.getArg(typeName)
 	if err != nil {
 		return nil, err
 	}
-	vl, err := arg.GetMember(memberName)
+	vl, err := teb.getMember(arg, memberName)
 	if err != nil {
 		return nil, err
 	}
@@ -58,13 +86,26 @@ func (teb *typedExprBuilder) OutputMember(typeName string, memberName string) (t
 	if !ok {
 		return nil, fmt.Errorf("%s cannot be used as output", vl.ArgType().Kind())
 	}
-	if _, ok := teb.outputUsed[output.Identifier()]; ok {
+	if _, ok := teb.outputUsed[outputKey(output, label)]; ok {
 		return nil, usedInMultipleOutputsError(output.Desc())
 	}
-	teb.outputUsed[output.Identifier()] = true
+	teb.outputUsed[outputKey(output, label)] = true
 	return output, nil
 }
 
+// getMember returns a value locator for a member of the argument. An empty
+// member name locates the whole of a struct marked as JSON.
+func (teb *typedExprBuilder) getMember(arg typeinfo.ArgInfo, memberName string) (typeinfo.ValueLocator, error) {
+	if memberName != "" {
+		return arg.GetMember(memberName)
+	}
+	name := typeinfo.TypeName(arg.Typ())
+	if !teb.opts.JSONTypes[name] {
+		return nil, fmt.Errorf("unqualified type, expected %[1]s.* or %[1]s.<db tag> or %[1]s[:], or a type marked as JSON", name)
+	}
+	return typeinfo.JSONValue(arg)
+}
+
 // AllStructInputs returns a list of inputs locators that locate every member
 // of the named type along with the names of the members. If the type is not a
 // struct an error is returned.
@@ -73,6 +114,9 @@ func (teb *typedExprBuilder) AllStructInputs(typeName string) ([]typeinfo.Input,
 	if err != nil {
 		return nil, nil, err
 	}
+	if typeinfo.Positional(arg) {
+		return nil, nil, fmt.Errorf("cannot use struct %q with positional db tags as input", typeName)
+	}
 	members, names, err := arg.GetAllStructMembers()
 	if err != nil {
 		return nil, nil, err
@@ -91,8 +135,9 @@ func (teb *typedExprBuilder) AllStructInputs(typeName string) ([]typeinfo.Input,
 
 // AllStructOutputs returns a list of output locators that locate every member
 // of the named type along with the names of the members. If the type is not a
-// struct an error is returned.
-func (teb *typedExprBuilder) AllStructOutputs(typeName string) ([]typeinfo.Output, []string, error) {
+// struct an error is returned. The label distinguishes between output arguments
+// of the same type.
+func (teb *typedExprBuilder) AllStructOutputs(typeName string, label string) ([]typeinfo.Output, []string, error) {
 	arg, err := teb.getArg(typeName)
 	if err != nil {
 		return nil, nil, err
@@ -108,16 +153,29 @@ func (teb *typedExprBuilder) AllStructOutputs(typeName string) ([]typeinfo.Outpu
 		if !ok {
 			return nil, nil, fmt.Errorf("%s cannot be used as output", member.ArgType().Kind())
 		}
-		if _, ok := teb.outputUsed[output.Identifier()]; ok {
+		if _, ok := teb.outputUsed[outputKey(output, label)]; ok {
 			return nil, nil, usedInMultipleOutputsError(output.Desc())
 		}
-		teb.outputUsed[output.Identifier()] = true
+		teb.outputUsed[outputKey(output, label)] = true
 		outputs = append(outputs, output)
 	}
 
 	return outputs, names, nil
 }
 
+// InputMap returns the argument info for a map type used with an asterisk. If
+// the type is not a map an error is returned.
+func (teb *typedExprBuilder) InputMap(typeName string) (typeinfo.ArgInfo, error) {
+	arg, err := teb.getArg(typeName)
+	if err != nil {
+		return nil, err
+	}
+	if k := arg.Typ().Kind(); k != reflect.Map {
+		return nil, fmt.Errorf("need map, got %s", k)
+	}
+	return arg, nil
+}
+
 // InputSlice returns an input locator for a slice.
 func (teb *typedExprBuilder) InputSlice(typeName string) (typeinfo.Input, error) {
 	arg, err := teb.getArg(typeName)
@@ -144,15 +202,57 @@ func (teb *typedExprBuilder) Kind(typeName string) (reflect.Kind, error) {
 	return arg.Typ().Kind(), nil
 }
 
+// Positional looks up the type name and returns true if it is a struct whose
+// fields are read by position.
+func (teb *typedExprBuilder) Positional(typeName string) (bool, error) {
+	arg, err := teb.getArg(typeName)
+	if err != nil {
+		return false, err
+	}
+	return typeinfo.Positional(arg), nil
+}
+
 // AddTypedInsertExpr wraps and adds the columns of an insert expression to the
 // typed expressions.
 func (teb *typedExprBuilder) AddTypedInsertExpr(insertColumns []typedColumn) {
 	teb.typedExprs = append(teb.typedExprs, &typedInsertExpr{insertColumns: insertColumns})
 }
 
+// AddTypedMultiRowInsertExpr adds an insert expression with a row of columns
+// for each tuple of values to the list of typed expressions.
+func (teb *typedExprBuilder) AddTypedMultiRowInsertExpr(rows [][]typedColumn) {
+	teb.typedExprs = append(teb.typedExprs, &typedInsertExpr{insertColumns: rows[0], extraRows: rows[1:]})
+}
+
+// AddTypedOverflowInsertExpr wraps and adds the columns of an insert expression
+// to the typed expressions. The columns are followed by columns generated from
+// the keys of the map at query time.
+func (teb *typedExprBuilder) AddTypedOverflowInsertExpr(insertColumns []typedColumn, overflow *overflowMapColumns) {
+	teb.typedExprs = append(teb.typedExprs, &typedInsertExpr{insertColumns: insertColumns, overflow: overflow})
+}
+
+// AddTypedUpdateAssignExpr adds a typed update set expression to the
+// typedExprBuilder. It is an error if a member marked as explicit has the
+// omitempty option and holds the zero value when the query is built.
+func (teb *typedExprBuilder) AddTypedUpdateAssignExpr(columns []string, inputs []typeinfo.Input, explicit []bool) {
+	teb.typedExprs = append(teb.typedExprs, &typedUpdateSetExpr{columns: columns, inputs: inputs, explicit: explicit})
+}
+
+// AddTypedUpdateSetMapExpr adds a typed update set expression that generates
+// its assignments from the keys of a map to the typedExprBuilder.
+func (teb *typedExprBuilder) AddTypedUpdateSetMapExpr(mapInfo typeinfo.ArgInfo, excluded map[string]bool) {
+	teb.typedExprs = append(teb.typedExprs, &typedUpdateSetMapExpr{mapInfo: mapInfo, excluded: excluded})
+}
+
 // AddTypedInputExpr wrap and adds an input to the typed expressions.
 func (teb *typedExprBuilder) AddTypedInputExpr(input typeinfo.Input) {
-	teb.typedExprs = append(teb.typedExprs, &typedInputExpr{input})
+	teb.typedExprs = append(teb.typedExprs, &typedInputExpr{input: input})
+}
+
+// AddTypedIdentExpr wraps and adds an identifier input to the typed
+// expressions.
+func (teb *typedExprBuilder) AddTypedIdentExpr(input typeinfo.Input) {
+	teb.typedExprs = append(teb.typedExprs, &typedIdentExpr{input})
 }
 
 // AddTypedOutputExpr wraps and adds output columns to the typed expressions.
@@ -160,6 +260,19 @@ func (teb *typedExprBuilder) AddTypedOutputExpr(outputColumns []outputColumn) {
 	teb.typedExprs = append(teb.typedExprs, &typedOutputExpr{outputColumns: outputColumns})
 }
 
+// AddTypedPositionalOutputExpr adds an output expression that reads all the
+// columns selected by the asterisk columns, e.g. "*" or "t.*", by position.
+func (teb *typedExprBuilder) AddTypedPositionalOutputExpr(columns string, outputColumns []outputColumn) {
+	teb.typedExprs = append(teb.typedExprs, &typedOutputExpr{outputColumns: outputColumns, positionalColumns: columns})
+}
+
+// AddTypedCatchAllOutputExpr adds an output expression that reads all the
+// columns selected by the asterisk columns, e.g. "*" or "t.*", into a map by
+// name.
+func (teb *typedExprBuilder) AddTypedCatchAllOutputExpr(columns string, mapInfo typeinfo.ArgInfo, label string) {
+	teb.typedExprs = append(teb.typedExprs, &typedOutputExpr{catchAll: &catchAllMap{columns: columns, mapInfo: mapInfo, label: label}})
+}
+
 // AddBypass adds a bypass part to the typed expressions
 func (teb *typedExprBuilder) AddBypass(b *bypass) {
 	teb.typedExprs = append(teb.typedExprs, b)
@@ -170,8 +283,150 @@ func (teb *typedExprBuilder) Build() (*TypeBoundExpr, error) {
 	if err := teb.checkAllArgsUsed(); err != nil {
 		return nil, err
 	}
+	if err := checkPositionalOutputs(teb.typedExprs); err != nil {
+		return nil, err
+	}
 
-	return &TypeBoundExpr{typedExprs: teb.typedExprs}, nil
+	if err := checkScalarComparisons(teb.typedExprs); err != nil {
+		return nil, err
+	}
+	markUpsertAssignments(teb.typedExprs)
+	if teb.opts.IntegerLimits {
+		if err := markIntegerClauses(teb.typedExprs); err != nil {
+			return nil, err
+		}
+	}
+	return &TypeBoundExpr{
+		typedExprs:     teb.typedExprs,
+		coerceNumeric:  teb.opts.CoerceNumeric,
+		textBool:       teb.opts.TextBool,
+		secretKeys:     teb.opts.SecretKeys,
+		resolveValuers: teb.opts.ResolveValuers,
+		description:    teb.description,
+	}, nil
+}
+
+// closingParenStart matches the start of SQL that closes a parenthesis.
+var closingParenStart = regexp.MustCompile(`^\s*\)`)
+
+// scalarComparisonStart matches the end of SQL that compares a single value
+// with the value that follows, e.g. "id = (" or "id = ", capturing the
+// operator and the opening parenthesis if there is one. A row value on the
+// left, as in "(a, b) = (", is not matched. Neither are operators that end
+// with a comparison character, such as "@>" and "->>" in Postgres, since
+// the character before the comparison must not be an operator character.
+var scalarComparisonStart = regexp.MustCompile(`(?:^|[^\s)<>=!~@#%^&|*/+\-?:])\s*(<=>|<=|>=|<>|!=|=|<|>)\s*(\(?)\s*$`)
+
+// checkScalarComparisons returns an error if a slice input is compared with a
+// single value, e.g. "id = ($S[:])" or "id = $S[:]". The slice is expanded into
+// a list of values, which is only valid SQL in this position if the slice
+// holds exactly one value, so the expression is rejected whatever the length.
+func checkScalarComparisons(typedExprs []typedExpr) error {
+	for i := 1; i < len(typedExprs); i++ {
+		ie, ok := typedExprs[i].(*typedInputExpr)
+		if !ok || ie.input.ArgType().Kind() != reflect.Slice {
+			continue
+		}
+		before, ok := typedExprs[i-1].(*bypass)
+		if !ok {
+			continue
+		}
+		m := scalarComparisonStart.FindStringSubmatch(before.chunk)
+		if m == nil {
+			continue
+		}
+		if m[2] != "" {
+			// The slice must be the only value in the parentheses.
+			if i == len(typedExprs)-1 {
+				continue
+			}
+			after, ok := typedExprs[i+1].(*bypass)
+			if !ok || !closingParenStart.MatchString(after.chunk) {
+				continue
+			}
+		}
+		return fmt.Errorf("cannot compare %s with %q, the operator needs a single value: use \"IN ($%s)\" to match any of the values",
+			ie.input.Desc(), m[1], ie.input.Identifier())
+	}
+	return nil
+}
+
+// upsertUpdateStart matches the end of SQL that is followed by the SET clause
+// of the update in an upsert, e.g. "ON CONFLICT (id) DO UPDATE ".
+var upsertUpdateStart = regexp.MustCompile(`(?i)(?:^|[^A-Za-z0-9_])DO\s+UPDATE\s+$`)
+
+// markUpsertAssignments finds update assignments in the style of an insert
+// expression that follow "DO UPDATE", e.g.
+// "ON CONFLICT (id) DO UPDATE SET (*) = ($Person.*)", and marks them to assign
+// each column the value of the row that could not be inserted.
+func markUpsertAssignments(typedExprs []typedExpr) {
+	for i := 1; i < len(typedExprs); i++ {
+		se, ok := typedExprs[i].(*typedUpdateSetExpr)
+		if !ok {
+			continue
+		}
+		if before, ok := typedExprs[i-1].(*bypass); ok && upsertUpdateStart.MatchString(before.chunk) {
+			se.excluded = true
+		}
+	}
+}
+
+// integerClauseStart matches the end of SQL that is followed by the value of
+// a LIMIT or OFFSET clause, capturing the keyword.
+var integerClauseStart = regexp.MustCompile(`(?i)(?:^|[^A-Za-z0-9_])(LIMIT|OFFSET)\s*$`)
+
+// markIntegerClauses finds member inputs that are the value of a LIMIT or
+// OFFSET clause, e.g. "LIMIT $Page.size", and marks them to be passed to the
+// driver as an int64. An error is returned if the type of the member is not
+// an integer type.
+func markIntegerClauses(typedExprs []typedExpr) error {
+	for i := 1; i < len(typedExprs); i++ {
+		ie, ok := typedExprs[i].(*typedInputExpr)
+		if !ok || ie.input.ArgType().Kind() == reflect.Slice {
+			continue
+		}
+		before, ok := typedExprs[i-1].(*bypass)
+		if !ok {
+			continue
+		}
+		m := integerClauseStart.FindStringSubmatch(before.chunk)
+		if m == nil {
+			continue
+		}
+		clause := strings.ToUpper(m[1])
+		if t := ie.input.Member().Type; t != nil && !typeinfo.IsIntegerType(t) {
+			return fmt.Errorf("cannot use %s as %s value: type %s is not an integer type", ie.input.Desc(), clause, t)
+		}
+		ie.integerClause = clause
+	}
+	return nil
+}
+
+// checkPositionalOutputs returns an error if an output expression that reads
+// columns by position is not the only output expression. Positions count from
+// the first result column, so no other columns can be read by name. It also
+// returns an error if more than one map reads the unmatched columns, since a
+// column could go to either.
+func checkPositionalOutputs(typedExprs []typedExpr) error {
+	numOutputs := 0
+	numCatchAll := 0
+	positional := false
+	for _, te := range typedExprs {
+		if oe, ok := te.(*typedOutputExpr); ok {
+			numOutputs++
+			positional = positional || oe.positionalColumns != ""
+			if oe.catchAll != nil {
+				numCatchAll++
+			}
+		}
+	}
+	if positional && numOutputs > 1 {
+		return fmt.Errorf("output expression with positional db tags must be the only output expression in the query")
+	}
+	if numCatchAll > 1 {
+		return fmt.Errorf("only one map can read all columns with an asterisk")
+	}
+	return nil
 }
 
 // checkAllArgsUsed goes through all the arguments contained in typeToValue and
@@ -179,7 +434,7 @@ func (teb *typedExprBuilder) Build() (*TypeBoundExpr, error) {
 func (teb *typedExprBuilder) checkAllArgsUsed() error {
 	for _, argInfo := range teb.argInfos {
 		if !teb.argUsed[argInfo] {
-			return fmt.Errorf("type %q not found in statement", argInfo.Typ().Name())
+			return fmt.Errorf("type %q not found in statement", typeinfo.TypeName(argInfo.Typ()))
 		}
 	}
 	return nil
@@ -207,6 +462,15 @@ func (teb *typedExprBuilder) nameNotFoundError(missingTypeName string) error {
 	return typeinfo.TypeMissingError(missingTypeName, argNames)
 }
 
+// outputKey returns a string that uniquely identifies the output in the query
+// when scanned into the output argument with the given label.
+func outputKey(output typeinfo.Output, label string) string {
+	if label == "" {
+		return output.Identifier()
+	}
+	return label + ":" + output.Identifier()
+}
+
 func usedInMultipleOutputsError(desc string) error {
 	// This error message is appended the expression the second usage was
 	// found in.
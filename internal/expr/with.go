@@ -0,0 +1,322 @@
+// Copyright 2024 Canonical Ltd.
+// Licensed under Apache 2.0, see LICENCE file for details.
+
+package expr
+
+import (
+	"fmt"
+	"strings"
+)
+
+// checkNestedOutputs returns an error if an output expression is in a part
+// of the query whose rows are not returned as the query results, such as the
+// subquery in
+//
+//	SELECT name FROM person WHERE id IN (SELECT &Person.id FROM person)
+//
+// or the body of the common table expression in
+//
+//	WITH avg_id(id) AS (SELECT &Person.id FROM person) SELECT id FROM avg_id
+//
+// The rows of a subquery are returned if it is a table in the FROM clause of
+// a query that is returned and that query selects all of its columns with an
+// asterisk, as in "SELECT * FROM (SELECT &Person.* FROM person)". The same
+// goes for a common table expression, which may also be selected from by
+// another common table expression whose rows are returned. Subqueries joined
+// with UNION, INTERSECT or EXCEPT are returned if the compound query is.
+func checkNestedOutputs(exprs []expression) error {
+	nl := newNestedLevels(exprs)
+	for i, l := range nl.levels {
+		if len(l.outputs) == 0 || nl.returned(i, map[int]bool{}) {
+			continue
+		}
+		where := "a subquery"
+		for j := i; j > 0; j = nl.levels[j].parent {
+			if _, ok := nl.cteNames[j]; ok {
+				where = "a WITH clause"
+				break
+			}
+		}
+		return fmt.Errorf("output expression %s is in %s and its columns are not in the query results", l.outputs[0].raw, where)
+	}
+	return nil
+}
+
+// nestedLevel is the top level of a query or a part of it nested in
+// parentheses.
+type nestedLevel struct {
+	// parent is the index of the enclosing level.
+	parent int
+	// pos is the index of the level in the tokens of its parent.
+	pos int
+	// tokens holds the tokens of the SQL at this level. A nested level is
+	// recorded as a "()" token and an expression other than a bypass part as
+	// a "?" token.
+	tokens []string
+	// outputs holds the output expressions at this level.
+	outputs []*outputExpr
+}
+
+// nestedLevels holds the levels of a query, the first of which is the top
+// level.
+type nestedLevels struct {
+	levels []*nestedLevel
+	// stmtStart is the index in the top level tokens of the statement that
+	// follows a WITH clause.
+	stmtStart int
+	// cteNames holds the names of the common table expressions, keyed by the
+	// level holding their body.
+	cteNames map[int]string
+}
+
+func newNestedLevels(exprs []expression) *nestedLevels {
+	nl := &nestedLevels{
+		levels:   []*nestedLevel{{parent: -1}},
+		cteNames: map[int]string{},
+	}
+	children := map[[2]int]int{}
+	cur := 0
+	for _, e := range exprs {
+		l := nl.levels[cur]
+		switch e := e.(type) {
+		case *bypass:
+			for _, tok := range sqlTokens(e.chunk) {
+				l = nl.levels[cur]
+				switch tok {
+				case "(":
+					children[[2]int{cur, len(l.tokens)}] = len(nl.levels)
+					nl.levels = append(nl.levels, &nestedLevel{parent: cur, pos: len(l.tokens)})
+					l.tokens = append(l.tokens, "()")
+					cur = len(nl.levels) - 1
+				case ")":
+					if cur != 0 {
+						cur = l.parent
+					}
+				default:
+					l.tokens = append(l.tokens, tok)
+				}
+			}
+		case *outputExpr:
+			l.outputs = append(l.outputs, e)
+			l.tokens = append(l.tokens, "?")
+		default:
+			l.tokens = append(l.tokens, "?")
+		}
+	}
+
+	// Find the bodies of the common table expressions in a WITH clause,
+	// e.g. "name AS (...)" or "name(col) AS NOT MATERIALIZED (...)". The
+	// WITH clause ends at the keyword starting the statement.
+	top := nl.levels[0].tokens
+	if len(top) == 0 || !strings.EqualFold(top[0], "WITH") {
+		return nl
+	}
+	for nl.stmtStart < len(top) && !isStatementKeyword(top[nl.stmtStart]) {
+		nl.stmtStart++
+	}
+	for i := 1; i < nl.stmtStart; i++ {
+		if top[i] != "()" {
+			continue
+		}
+		k := i - 1
+		for k > 0 && (strings.EqualFold(top[k], "MATERIALIZED") || strings.EqualFold(top[k], "NOT")) {
+			k--
+		}
+		if !strings.EqualFold(top[k], "AS") {
+			continue
+		}
+		n := k - 1
+		if n > 0 && top[n] == "()" {
+			n--
+		}
+		if n > 0 {
+			nl.cteNames[children[[2]int{0, i}]] = strings.ToLower(top[n])
+		}
+	}
+	return nl
+}
+
+// tokens returns the tokens of the query at the level. At the top level,
+// these are the tokens of the statement following a WITH clause.
+func (nl *nestedLevels) tokens(i int) []string {
+	if i == 0 {
+		return nl.levels[0].tokens[nl.stmtStart:]
+	}
+	return nl.levels[i].tokens
+}
+
+// returned reports whether the rows of the level are returned as the query
+// results. Levels in visiting are being checked further up the call stack.
+func (nl *nestedLevels) returned(i int, visiting map[int]bool) bool {
+	if i == 0 {
+		return true
+	}
+	if visiting[i] {
+		return false
+	}
+	visiting[i] = true
+	defer delete(visiting, i)
+
+	l := nl.levels[i]
+	if cte, ok := nl.cteNames[i]; ok {
+		for j := range nl.levels {
+			if j != i && nl.selectsAllFrom(j, cte) && nl.returned(j, visiting) {
+				return true
+			}
+		}
+		return false
+	}
+	if !isQuery(l.tokens) {
+		// Parentheses that do not hold a query, e.g. around a list of
+		// columns, do not change whether their contents are returned.
+		return nl.returned(l.parent, visiting)
+	}
+	pos := l.pos
+	if l.parent == 0 {
+		// Parts of the WITH clause other than the common table expression
+		// bodies are never returned.
+		if pos < nl.stmtStart {
+			return false
+		}
+		pos -= nl.stmtStart
+	}
+	tokens := nl.tokens(l.parent)
+	if pos == 0 {
+		return nl.returned(l.parent, visiting)
+	}
+	switch strings.ToUpper(tokens[pos-1]) {
+	case "UNION", "INTERSECT", "EXCEPT", "ALL":
+		return nl.returned(l.parent, visiting)
+	}
+	for _, ref := range tableRefs(tokens) {
+		if ref.pos == pos {
+			return selectsAll(tokens, ref) && nl.returned(l.parent, visiting)
+		}
+	}
+	return false
+}
+
+// selectsAllFrom reports whether the query at the level selects all columns
+// of the named table.
+func (nl *nestedLevels) selectsAllFrom(i int, table string) bool {
+	tokens := nl.tokens(i)
+	for _, ref := range tableRefs(tokens) {
+		if strings.EqualFold(tokens[ref.pos], table) && selectsAll(tokens, ref) {
+			return true
+		}
+	}
+	return false
+}
+
+// tableRef is a table in the FROM clause of a query.
+type tableRef struct {
+	// pos is the index of the table name or subquery in the tokens.
+	pos int
+	// alias is the alias of the table, if it has one.
+	alias string
+}
+
+// tableRefs returns the tables in the FROM clauses of the tokens of a query,
+// e.g. "person" and "()" in "SELECT * FROM person, (...) AS p".
+func tableRefs(tokens []string) []tableRef {
+	var refs []tableRef
+	inFrom := false
+	for i, tok := range tokens {
+		switch strings.ToUpper(tok) {
+		case "FROM", "JOIN":
+			inFrom = true
+		case ",":
+			if !inFrom {
+				continue
+			}
+		case "SELECT", "WHERE", "GROUP", "HAVING", "ORDER", "LIMIT", "UNION", "INTERSECT", "EXCEPT", "RETURNING":
+			inFrom = false
+			continue
+		default:
+			continue
+		}
+		if i+1 >= len(tokens) {
+			break
+		}
+		ref := tableRef{pos: i + 1}
+		if j := i + 2; j < len(tokens) {
+			if strings.EqualFold(tokens[j], "AS") && j+1 < len(tokens) {
+				ref.alias = tokens[j+1]
+			} else if isAlias(tokens[j]) {
+				ref.alias = tokens[j]
+			}
+		}
+		refs = append(refs, ref)
+	}
+	return refs
+}
+
+// selectsAll reports whether the tokens of a query select all columns of the
+// table with an asterisk, as in "SELECT * FROM table" or "SELECT t.* FROM
+// table AS t, other".
+func selectsAll(tokens []string, ref tableRef) bool {
+	name := ref.alias
+	if name == "" {
+		name = tokens[ref.pos]
+	}
+	for i, tok := range tokens {
+		if tok != "*" {
+			continue
+		}
+		// A qualified asterisk, e.g. "t.*", must be qualified by the table.
+		j := i - 1
+		if j >= 1 && tokens[j] == "." {
+			if !strings.EqualFold(tokens[j-1], name) {
+				continue
+			}
+			j -= 2
+		}
+		if j < 0 {
+			continue
+		}
+		switch strings.ToUpper(tokens[j]) {
+		case "SELECT", "DISTINCT", "ALL", ",":
+		default:
+			continue
+		}
+		if i+1 < len(tokens) && (tokens[i+1] == "," || strings.EqualFold(tokens[i+1], "FROM")) {
+			return true
+		}
+	}
+	return false
+}
+
+// isQuery reports whether the tokens at a nested level are those of a query,
+// or of a nested level that may hold one.
+func isQuery(tokens []string) bool {
+	if len(tokens) == 0 {
+		return false
+	}
+	switch strings.ToUpper(tokens[0]) {
+	case "SELECT", "WITH", "VALUES", "()":
+		return true
+	}
+	return false
+}
+
+// isAlias reports whether a token following a table in a FROM clause is an
+// alias for the table rather than a keyword or punctuation.
+func isAlias(tok string) bool {
+	switch strings.ToUpper(tok) {
+	case "WHERE", "JOIN", "INNER", "LEFT", "RIGHT", "FULL", "OUTER", "CROSS", "NATURAL", "ON", "USING",
+		"GROUP", "HAVING", "ORDER", "LIMIT", "OFFSET", "UNION", "INTERSECT", "EXCEPT", "WINDOW",
+		"RETURNING", "SET", "VALUES", "FOR":
+		return false
+	}
+	return tok == `"` || tok == "`" || isNameChar(rune(tok[0]))
+}
+
+// isStatementKeyword reports whether the token is a keyword that starts the
+// statement following a WITH clause.
+func isStatementKeyword(tok string) bool {
+	switch strings.ToUpper(tok) {
+	case "SELECT", "INSERT", "UPDATE", "DELETE", "REPLACE", "VALUES", "MERGE":
+		return true
+	}
+	return false
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"reflect"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"unicode"
@@ -26,34 +27,65 @@ type ArgInfo interface {
 	GetSlice() (ValueLocator, error)
 }
 
+// DefaultTagName is the key of the struct tags that map struct fields to
+// columns, unless another key is given.
+const DefaultTagName = "db"
+
 // GenerateArgInfo takes sample instantiations of argument types and uses
 // reflection to generate an ArgInfo for each. These ArgInfo objects are
 // returned in a map keyed by the type names.
 func GenerateArgInfo(typeSamples []any) (map[string]ArgInfo, error) {
+	return GenerateArgInfoWithTag(typeSamples, DefaultTagName)
+}
+
+// GenerateArgInfoWithTag is like GenerateArgInfo but reads the struct tags
+// with the given key, e.g. "col" in `col:"id"`, instead of "db".
+func GenerateArgInfoWithTag(typeSamples []any, tagName string) (map[string]ArgInfo, error) {
 	argInfo := map[string]ArgInfo{}
 	for _, typeSample := range typeSamples {
+		if ns, ok := typeSample.(NamedSample); ok {
+			if ns.Sample == nil {
+				return nil, fmt.Errorf("need supported value, got nil")
+			}
+			if err := registerTypeName(SampleType(ns.Sample), ns.Name); err != nil {
+				return nil, err
+			}
+			typeSample = ns.Sample
+		}
 		if typeSample == nil {
 			return nil, fmt.Errorf("need supported value, got nil")
 		}
-		t := reflect.TypeOf(typeSample)
+		t := SampleType(typeSample)
 		switch t.Kind() {
 		case reflect.Struct, reflect.Map, reflect.Slice:
-			if t.Name() == "" {
+			name := TypeName(t)
+			if name == "" {
 				return nil, fmt.Errorf("cannot use anonymous %s", t.Kind())
 			}
-			info, err := getArgInfo(t)
+			info, err := getArgInfo(t, tagName)
 			if err != nil {
 				return nil, err
 			}
+			if dupeArg, ok := argInfo[name]; ok {
+				if dupeArg.Typ() == t {
+					return nil, fmt.Errorf("found multiple instances of type %q", name)
+				}
+				return nil, sameNameError(dupeArg.Typ(), t)
+			}
+			argInfo[name] = info
+		case reflect.String:
+			if t != IdentType {
+				return nil, fmt.Errorf("need supported type, got %s", t.Kind())
+			}
 			if dupeArg, ok := argInfo[t.Name()]; ok {
 				if dupeArg.Typ() == t {
 					return nil, fmt.Errorf("found multiple instances of type %q", t.Name())
 				}
-				return nil, fmt.Errorf("two types found with name %q: %q and %q", t.Name(), dupeArg.Typ().String(), t.String())
+				return nil, sameNameError(dupeArg.Typ(), t)
 			}
-			argInfo[t.Name()] = info
+			argInfo[t.Name()] = &identInfo{}
 		case reflect.Pointer:
-			return nil, fmt.Errorf("need non-pointer type, got pointer to %s", t.Elem().Kind())
+			return nil, fmt.Errorf("need value or pointer to value, got pointer to pointer")
 		default:
 			return nil, fmt.Errorf("need supported type, got %s", t.Kind())
 		}
@@ -61,6 +93,63 @@ func GenerateArgInfo(typeSamples []any) (map[string]ArgInfo, error) {
 	return argInfo, nil
 }
 
+// SampleType returns the type of a type sample. A pointer to a value is
+// dereferenced, so &Person{} is the same type sample as Person{}. Only one
+// level of pointer is removed.
+func SampleType(typeSample any) reflect.Type {
+	t := reflect.TypeOf(typeSample)
+	if t != nil && t.Kind() == reflect.Pointer && t.Elem().Kind() != reflect.Pointer {
+		t = t.Elem()
+	}
+	return t
+}
+
+// sameNameError returns the error for two different types with the same name.
+// SQLair refers to types by name alone so they cannot be used together.
+func sameNameError(t1, t2 reflect.Type) error {
+	return fmt.Errorf("two types found with name %q: %q and %q", TypeName(t1), FullTypeName(t1), FullTypeName(t2))
+}
+
+// FullTypeName returns the name of the type qualified by the full path of its
+// package, e.g. "github.com/canonical/sqlair.M". Types declared inside
+// functions cannot be told apart from other types with the same name in the
+// same package.
+func FullTypeName(t reflect.Type) string {
+	if t.Name() == "" || t.PkgPath() == "" {
+		return t.String()
+	}
+	return t.PkgPath() + "." + t.Name()
+}
+
+// CaseInsensitive returns the ArgInfos wrapped so that GetMember matches the
+// member name against struct db tags ignoring case, and map keys of inputs are
+// looked up ignoring case when the map does not contain the exact key. An exact
+// match is always preferred.
+func CaseInsensitive(argInfos map[string]ArgInfo) map[string]ArgInfo {
+	wrapped := map[string]ArgInfo{}
+	for name, info := range argInfos {
+		wrapped[name] = &caseInsensitiveInfo{ArgInfo: info}
+	}
+	return wrapped
+}
+
+// caseInsensitiveInfo wraps an ArgInfo to resolve members ignoring case.
+type caseInsensitiveInfo struct {
+	ArgInfo
+}
+
+// GetMember returns a value locator for the member, ignoring the case of the
+// member name.
+func (ci *caseInsensitiveInfo) GetMember(memberName string) (ValueLocator, error) {
+	switch info := ci.ArgInfo.(type) {
+	case *structInfo:
+		return info.getMemberFold(memberName)
+	case *mapInfo:
+		return &mapKey{name: memberName, mapType: info.mapType, foldCase: true}, nil
+	}
+	return ci.ArgInfo.GetMember(memberName)
+}
+
 // structInfo stores information useful for SQLair about struct types.
 type structInfo struct {
 	structType reflect.Type
@@ -69,6 +158,13 @@ type structInfo struct {
 	tags []string
 
 	tagToField map[string]*structField
+
+	// positional is true if the fields have positional db tags of the form
+	// "#0". The tags are ordered by position.
+	positional bool
+
+	// tagName is the key of the struct tags the fields were read from.
+	tagName string
 }
 
 func (si *structInfo) Typ() reflect.Type {
@@ -79,16 +175,44 @@ func (si *structInfo) Typ() reflect.Type {
 func (si *structInfo) GetMember(memberName string) (ValueLocator, error) {
 	structField, ok := si.tagToField[memberName]
 	if !ok {
-		return nil, fmt.Errorf(`type %q has no %q db tag`, si.structType.Name(), memberName)
+		if path := splitMemberPath(memberName); len(path) > 1 {
+			return si.nestedMember(path, false)
+		}
+		return nil, fmt.Errorf(`type %q has no %q db tag`, TypeName(si.structType), memberName)
 	}
 	return structField, nil
 }
 
+// getMemberFold returns a value locator for the field of the struct with a db
+// tag equal to the member name ignoring case. An error is returned if more
+// than one tag matches and none matches exactly.
+func (si *structInfo) getMemberFold(memberName string) (ValueLocator, error) {
+	if structField, ok := si.tagToField[memberName]; ok {
+		return structField, nil
+	}
+	if path := splitMemberPath(memberName); len(path) > 1 {
+		return si.nestedMember(path, true)
+	}
+	var found []string
+	for _, tag := range si.tags {
+		if strings.EqualFold(tag, memberName) {
+			found = append(found, tag)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return nil, fmt.Errorf(`type %q has no %q db tag`, TypeName(si.structType), memberName)
+	case 1:
+		return si.tagToField[found[0]], nil
+	}
+	return nil, fmt.Errorf(`type %q has db tags %q and %q matching %q ignoring case`, TypeName(si.structType), found[0], found[1], memberName)
+}
+
 // GetAllStructMembers returns information about every member of the struct type
 // along with their names.
 func (si *structInfo) GetAllStructMembers() ([]ValueLocator, []string, error) {
 	if len(si.tags) == 0 {
-		return nil, nil, fmt.Errorf(`no "db" tags found in struct %q`, si.structType.Name())
+		return nil, nil, fmt.Errorf(`no "db" tags found in struct %q`, TypeName(si.structType))
 	}
 
 	var vls []ValueLocator
@@ -114,6 +238,9 @@ func (mi *mapInfo) Typ() reflect.Type {
 
 // GetMember returns a value locator for the specified key of the map
 func (mi *mapInfo) GetMember(memberName string) (ValueLocator, error) {
+	if len(splitMemberPath(memberName)) > 1 {
+		return nil, fmt.Errorf("cannot get nested member %q of map", memberName)
+	}
 	return &mapKey{name: memberName, mapType: mi.mapType}, nil
 }
 
@@ -146,21 +273,35 @@ func (si *sliceInfo) GetAllStructMembers() ([]ValueLocator, []string, error) {
 	return nil, nil, fmt.Errorf("cannot use slice with asterisk")
 }
 
-// GetSlice returns a locator for a slice.
+// GetSlice returns a locator for a slice. An error is returned if the
+// elements of the slice have a type that cannot be passed to the database.
 func (si *sliceInfo) GetSlice() (ValueLocator, error) {
+	if t, ok := concreteElemType(si.sliceType); ok {
+		if err := checkSliceElemType(t); err != nil {
+			return nil, fmt.Errorf("cannot use slice %q: %s", PrettyTypeName(si.sliceType), err)
+		}
+	}
 	return &slice{sliceType: si.sliceType}, nil
 }
 
+// argInfoKey identifies the reflection information of a type read with a
+// struct tag key.
+type argInfoKey struct {
+	t       reflect.Type
+	tagName string
+}
+
 // argInfoCache caches type reflection information across queries.
 var argInfoCacheMutex sync.RWMutex
-var argInfoCache = make(map[reflect.Type]ArgInfo)
+var argInfoCache = make(map[argInfoKey]ArgInfo)
 
 // getArgInfo returns type information useful for SQLair from a sample
-// instantiation of an argument type.
-func getArgInfo(t reflect.Type) (ArgInfo, error) {
+// instantiation of an argument type. The fields of structs are read from the
+// struct tags with the key tagName.
+func getArgInfo(t reflect.Type, tagName string) (ArgInfo, error) {
 	// Check cache for type
 	argInfoCacheMutex.RLock()
-	typeInfo, found := argInfoCache[t]
+	typeInfo, found := argInfoCache[argInfoKey{t: t, tagName: tagName}]
 	argInfoCacheMutex.RUnlock()
 	if found {
 		return typeInfo, nil
@@ -176,10 +317,11 @@ func getArgInfo(t reflect.Type) (ArgInfo, error) {
 		info := structInfo{
 			tagToField: make(map[string]*structField),
 			structType: t,
+			tagName:    tagName,
 		}
 		var tags []string
 
-		fields, err := getStructFields(t)
+		fields, err := getStructFields(t, tagName)
 		if err != nil {
 			return nil, err
 		}
@@ -189,13 +331,25 @@ func getArgInfo(t reflect.Type) (ArgInfo, error) {
 			tags = append(tags, field.tag)
 			if dup, ok := info.tagToField[field.tag]; ok {
 				return nil, fmt.Errorf("db tag %q appears in both field %q and field %q of struct %q",
-					field.tag, field.name, dup.name, t.Name())
+					field.tag, fieldPath(t, field.index), fieldPath(t, dup.index), TypeName(t))
 			}
 			info.tagToField[field.tag] = field
 		}
 
-		sort.Strings(tags)
+		positional, err := checkPositionalTags(t, tags)
+		if err != nil {
+			return nil, err
+		}
+		if positional {
+			// Order the fields by position, "#0", "#1", ..., "#10".
+			sort.Slice(tags, func(i, j int) bool {
+				return positionOf(tags[i]) < positionOf(tags[j])
+			})
+		} else {
+			sort.Strings(tags)
+		}
 		info.tags = tags
+		info.positional = positional
 
 		typeInfo = &info
 	case reflect.Slice:
@@ -206,41 +360,76 @@ func getArgInfo(t reflect.Type) (ArgInfo, error) {
 
 	// Put type in cache.
 	argInfoCacheMutex.Lock()
-	argInfoCache[t] = typeInfo
+	argInfoCache[argInfoKey{t: t, tagName: tagName}] = typeInfo
 	argInfoCacheMutex.Unlock()
 
 	return typeInfo, nil
 }
 
-// parseTag parses the input tag string and returns its
-// name and whether it contains the "omitempty" option.
-func parseTag(tag string) (string, bool, error) {
+// tagOptions are the options that can follow the name in a db tag.
+type tagOptions struct {
+	omitEmpty  bool
+	primaryKey bool
+	text       bool
+	json       bool
+	notNull    bool
+	secret     bool
+	// null is the value of the "null=" option and hasNull is true if the
+	// option is present.
+	null    string
+	hasNull bool
+}
+
+// parseTag parses the input tag string and returns its name and options.
+func parseTag(tag string) (name string, opts tagOptions, err error) {
 	options := strings.Split(tag, ",")
 
-	var omitEmpty bool
 	if len(options) > 1 {
 		for _, flag := range options[1:] {
-			if strings.TrimSpace(flag) == "omitempty" {
-				omitEmpty = true
-			} else {
-				return "", omitEmpty, fmt.Errorf("unsupported flag %q in tag %q", flag, tag)
+			if f := strings.TrimSpace(flag); strings.HasPrefix(f, "null=") {
+				opts.null = strings.TrimPrefix(f, "null=")
+				opts.hasNull = true
+				continue
+			}
+			switch strings.TrimSpace(flag) {
+			case "omitempty":
+				opts.omitEmpty = true
+			case "pk":
+				opts.primaryKey = true
+			case "text":
+				opts.text = true
+			case "json":
+				opts.json = true
+			case "notnull":
+				opts.notNull = true
+			case "secret":
+				opts.secret = true
+			default:
+				return "", tagOptions{}, fmt.Errorf("unsupported flag %q in tag %q", flag, tag)
 			}
 		}
 	}
 
-	name := options[0]
+	name = options[0]
 	if len(name) == 0 {
-		return "", false, fmt.Errorf("empty db tag")
+		return "", tagOptions{}, fmt.Errorf("empty db tag")
+	}
+
+	if name[0] == positionalTagPrefix {
+		if n, err := strconv.Atoi(name[1:]); err != nil || n < 0 || strconv.Itoa(n) != name[1:] {
+			return "", tagOptions{}, fmt.Errorf("invalid position in 'db' tag: %q", name)
+		}
+		return name, opts, nil
 	}
 
 	// Check the tag is a valid column name.
 
 	if name[0] == '"' || name[0] == '\'' {
 		if name[len(name)-1] != name[0] {
-			return "", false, fmt.Errorf("missing quotes at end of 'db' tag: %q", name)
+			return "", tagOptions{}, fmt.Errorf("missing quotes at end of 'db' tag: %q", name)
 		}
 		// No need to validate chars in quotes.
-		return name, omitEmpty, nil
+		return name, opts, nil
 	}
 
 	char, size := utf8.DecodeRuneInString(name)
@@ -256,27 +445,79 @@ func parseTag(tag string) (string, bool, error) {
 			return unicode.IsLetter(char) || unicode.IsDigit(char) || char == '_'
 		}
 	default:
-		return "", false, fmt.Errorf("invalid column name in 'db' tag: %q", name)
+		return "", tagOptions{}, fmt.Errorf("invalid column name in 'db' tag: %q", name)
 	}
 	for nextPos < len(name) {
 		char, size = utf8.DecodeRuneInString(name[nextPos:])
 		nextPos += size
 		if !(checker(char)) {
-			return "", false, fmt.Errorf("invalid column name in 'db' tag: %q", name)
+			return "", tagOptions{}, fmt.Errorf("invalid column name in 'db' tag: %q", name)
 		}
 	}
 
-	return name, omitEmpty, nil
+	return name, opts, nil
+}
+
+// positionalTagPrefix starts a positional db tag, e.g. "#0". A positional tag
+// maps the field to the result column at that position rather than by name.
+const positionalTagPrefix = '#'
+
+// isPositionalTag returns true if the db tag is of the form "#0".
+func isPositionalTag(tag string) bool {
+	return strings.HasPrefix(tag, string(positionalTagPrefix))
+}
+
+// positionOf returns the position in a positional db tag. The tag must have
+// been validated by parseTag.
+func positionOf(tag string) int {
+	n, _ := strconv.Atoi(tag[1:])
+	return n
+}
+
+// checkPositionalTags returns true if the db tags of the struct are
+// positional. A struct cannot mix positional and named tags, and its
+// positional tags must be "#0" to "#n-1" for n tagged fields.
+func checkPositionalTags(t reflect.Type, tags []string) (bool, error) {
+	var positional, named []string
+	for _, tag := range tags {
+		if isPositionalTag(tag) {
+			positional = append(positional, tag)
+		} else {
+			named = append(named, tag)
+		}
+	}
+	if len(positional) == 0 {
+		return false, nil
+	}
+	if len(named) > 0 {
+		return false, fmt.Errorf("struct %q mixes positional db tag %q with named db tag %q", TypeName(t), positional[0], named[0])
+	}
+	for _, tag := range positional {
+		if positionOf(tag) >= len(positional) {
+			return false, fmt.Errorf("positional db tags of struct %q must be #0 to #%d, got %q", TypeName(t), len(positional)-1, tag)
+		}
+	}
+	return true, nil
+}
+
+// Positional returns true if the argument is a struct whose fields are read
+// from the result columns by position, with db tags of the form "#0".
+func Positional(argInfo ArgInfo) bool {
+	if ci, ok := argInfo.(*caseInsensitiveInfo); ok {
+		argInfo = ci.ArgInfo
+	}
+	si, ok := argInfo.(*structInfo)
+	return ok && si.positional
 }
 
 // getStructFields returns relevant reflection information about all struct
 // fields included embedded fields. The caller must check that structType is a
 // struct.
-func getStructFields(structType reflect.Type) ([]*structField, error) {
+func getStructFields(structType reflect.Type, tagName string) ([]*structField, error) {
 	var fields []*structField
 	for i := 0; i < structType.NumField(); i++ {
 		field := structType.Field(i)
-		tag := field.Tag.Get("db")
+		tag := field.Tag.Get(tagName)
 
 		// If Anonymous is true, the field is embedded.
 		if field.Anonymous && tag == "" {
@@ -298,7 +539,7 @@ func getStructFields(structType reflect.Type) ([]*structField, error) {
 			// Promote the embedded struct fields into the current parent struct
 			// scope, making sure to update the Index list for navigation back
 			// to the original nested location.
-			nestedFields, err := getStructFields(fieldType)
+			nestedFields, err := getStructFields(fieldType, tagName)
 			if err != nil {
 				return nil, err
 			}
@@ -313,16 +554,39 @@ func getStructFields(structType reflect.Type) ([]*structField, error) {
 				continue
 			}
 			if !field.IsExported() {
-				return nil, fmt.Errorf("field %q of struct %s not exported", field.Name, structType.Name())
+				return nil, &UnexportedFieldError{Struct: TypeName(structType), Field: field.Name, TagName: tagName, Tag: tag}
 			}
-			tag, omitEmpty, err := parseTag(tag)
+			tag, opts, err := parseTag(tag)
 			if err != nil {
-				return nil, fmt.Errorf("cannot parse tag for field %s.%s: %s", structType.Name(), field.Name, err)
+				return nil, fmt.Errorf("cannot parse tag for field %s.%s: %s", TypeName(structType), field.Name, err)
+			}
+			if opts.text && opts.json {
+				return nil, fmt.Errorf("field %s.%s cannot have both the text and json options", TypeName(structType), field.Name)
+			}
+			if opts.text && !isTextType(field.Type) {
+				return nil, fmt.Errorf("field %s.%s has the text option but its type %s does not implement encoding.TextMarshaler and encoding.TextUnmarshaler", TypeName(structType), field.Name, field.Type)
+			}
+			var nullValue reflect.Value
+			if opts.hasNull {
+				if opts.notNull || opts.text || opts.json {
+					return nil, fmt.Errorf("field %s.%s cannot have the null option with the notnull, text or json options", TypeName(structType), field.Name)
+				}
+				if nullValue, err = parseNullValue(field.Type, opts.null); err != nil {
+					return nil, fmt.Errorf("field %s.%s has invalid null option %q: %s", TypeName(structType), field.Name, opts.null, err)
+				}
 			}
+			// A zero primary key is omitted from inserts so that the database
+			// can generate it.
 			fields = append(fields, &structField{
 				name:       field.Name,
 				index:      field.Index,
-				omitEmpty:  omitEmpty,
+				omitEmpty:  opts.omitEmpty,
+				primaryKey: opts.primaryKey,
+				text:       opts.text,
+				json:       opts.json,
+				notNull:    opts.notNull,
+				secret:     opts.secret,
+				nullValue:  nullValue,
 				tag:        tag,
 				structType: structType,
 			})
@@ -331,6 +595,119 @@ func getStructFields(structType reflect.Type) ([]*structField, error) {
 	return fields, nil
 }
 
+// parseNullValue parses the value of the "null" option of a db tag into a
+// value of the field type t. Only fields of basic types can have the option,
+// since pointers and sql.Scanner implementations handle NULL themselves.
+func parseNullValue(t reflect.Type, s string) (reflect.Value, error) {
+	if reflect.PointerTo(t).Implements(scannerInterface) {
+		return reflect.Value{}, fmt.Errorf("type %s implements sql.Scanner", t)
+	}
+	v := reflect.New(t).Elem()
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, t.Bits())
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("cannot parse as %s", t)
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, t.Bits())
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("cannot parse as %s", t)
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, t.Bits())
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("cannot parse as %s", t)
+		}
+		v.SetFloat(f)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("cannot parse as %s", t)
+		}
+		v.SetBool(b)
+	case reflect.String:
+		v.SetString(s)
+	default:
+		return reflect.Value{}, fmt.Errorf("need field of integer, float, bool or string type, got %s", t)
+	}
+	return v, nil
+}
+
+// UnexportedFieldError is returned when a struct field that is not exported
+// has a db tag. SQLair cannot read or set the values of unexported fields.
+type UnexportedFieldError struct {
+	// Struct is the name of the struct type.
+	Struct string
+	// Field is the name of the unexported field.
+	Field string
+	// TagName is the key of the struct tag, usually "db".
+	TagName string
+	// Tag is the value of the struct tag on the field.
+	Tag string
+}
+
+func (e *UnexportedFieldError) Error() string {
+	return fmt.Sprintf("field %q of struct %q is unexported but tagged %s:%q", e.Field, e.Struct, e.TagName, e.Tag)
+}
+
+// fieldPath returns the name of the struct field at the index path, qualified
+// with the names of the embedded structs it is promoted from, e.g.
+// "Timestamps.Created".
+func fieldPath(t reflect.Type, index []int) string {
+	var names []string
+	for _, i := range index {
+		if t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+		field := t.Field(i)
+		names = append(names, field.Name)
+		t = field.Type
+	}
+	return strings.Join(names, ".")
+}
+
+// PrimaryKey returns the db tag of the primary key field of a struct type. The
+// primary key field is marked with the "pk" option in its db tag. An error is
+// returned if the struct does not have exactly one primary key field.
+func PrimaryKey(t reflect.Type) (string, error) {
+	if t.Kind() != reflect.Struct {
+		return "", fmt.Errorf("need struct, got %s", t.Kind())
+	}
+	info, err := getArgInfo(t, DefaultTagName)
+	if err != nil {
+		return "", err
+	}
+	var pk string
+	for _, field := range info.(*structInfo).tagToField {
+		if !field.primaryKey {
+			continue
+		}
+		if pk != "" {
+			return "", fmt.Errorf("struct %q has more than one primary key field", TypeName(t))
+		}
+		pk = field.tag
+	}
+	if pk == "" {
+		return "", fmt.Errorf(`struct %q has no field with the "pk" option in its db tag`, TypeName(t))
+	}
+	return pk, nil
+}
+
+// OmitZeroPK returns an input that is omitted when it is zero if input locates
+// the primary key field of a struct. Other inputs are returned unchanged.
+func OmitZeroPK(input Input) Input {
+	f, ok := input.(*structField)
+	if !ok || !f.primaryKey || f.omitEmpty {
+		return input
+	}
+	pk := *f
+	pk.omitEmpty = true
+	return &pk
+}
+
 // TypeMissingError returns an error specifying the missing type and types
 // that are present.
 func TypeMissingError(missingType string, existingTypes []string) error {
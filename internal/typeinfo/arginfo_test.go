@@ -6,12 +6,14 @@ package typeinfo
 import (
 	"reflect"
 	"testing"
+	"time"
 
 	. "gopkg.in/check.v1"
 )
 
 var _ ArgInfo = &structInfo{}
 var _ ArgInfo = &mapInfo{}
+var _ ArgInfo = &identInfo{}
 
 func TestTypeInfo(t *testing.T) { TestingT(t) }
 
@@ -102,6 +104,58 @@ func (s *typeInfoSuite) TestArgInfoStruct(c *C) {
 	}
 }
 
+func (s *typeInfoSuite) TestArgInfoPositional(c *C) {
+	type pragma struct {
+		A int    `db:"#2"`
+		B string `db:"#0"`
+		C int    `db:"#10"`
+		D int    `db:"#1"`
+		E int    `db:"#3"`
+		F int    `db:"#4"`
+		G int    `db:"#5"`
+		H int    `db:"#6"`
+		I int    `db:"#7"`
+		J int    `db:"#8"`
+		K int    `db:"#9"`
+	}
+	type named struct {
+		A int `db:"a"`
+	}
+	argInfo, err := GenerateArgInfo([]any{pragma{}, named{}})
+	c.Assert(err, IsNil)
+
+	c.Check(Positional(argInfo["pragma"]), Equals, true)
+	c.Check(Positional(argInfo["named"]), Equals, false)
+
+	_, memberNames, err := argInfo["pragma"].GetAllStructMembers()
+	c.Assert(err, IsNil)
+	c.Check(memberNames, DeepEquals, []string{"#0", "#1", "#2", "#3", "#4", "#5", "#6", "#7", "#8", "#9", "#10"})
+}
+
+func (s *typeInfoSuite) TestArgInfoWithTag(c *C) {
+	type tagged struct {
+		ID   int    `db:"id" col:"person_id,omitempty"`
+		Name string `col:"name"`
+		Age  int    `db:"age"`
+	}
+
+	argInfo, err := GenerateArgInfoWithTag([]any{tagged{}}, "col")
+	c.Assert(err, IsNil)
+	_, memberNames, err := argInfo["tagged"].GetAllStructMembers()
+	c.Assert(err, IsNil)
+	c.Check(memberNames, DeepEquals, []string{"name", "person_id"})
+	member, err := argInfo["tagged"].GetMember("person_id")
+	c.Assert(err, IsNil)
+	c.Check(member.(*structField).omitEmpty, Equals, true)
+
+	// The information read with db tags is cached separately.
+	argInfo, err = GenerateArgInfo([]any{tagged{}})
+	c.Assert(err, IsNil)
+	_, memberNames, err = argInfo["tagged"].GetAllStructMembers()
+	c.Assert(err, IsNil)
+	c.Check(memberNames, DeepEquals, []string{"age", "id"})
+}
+
 func (s *typeInfoSuite) TestArgInfoMap(c *C) {
 	type myMap map[string]any
 
@@ -115,6 +169,27 @@ func (s *typeInfoSuite) TestArgInfoMap(c *C) {
 	c.Check(input, DeepEquals, expectedMapKey)
 }
 
+func (s *typeInfoSuite) TestArgInfoIdent(c *C) {
+	argInfo, err := GenerateArgInfo([]any{Ident("")})
+	c.Assert(err, IsNil)
+
+	input, err := argInfo["Ident"].GetMember("name")
+	c.Assert(err, IsNil)
+	c.Check(input, DeepEquals, &identName{})
+
+	_, err = argInfo["Ident"].GetMember("other")
+	c.Assert(err, ErrorMatches, `type "Ident" has no member "other", expected Ident.name`)
+}
+
+func (s *typeInfoSuite) TestValidateIdent(c *C) {
+	for _, ident := range []string{"person", "_person", "Person_2", `"person"`, `"persön table"`} {
+		c.Check(ValidateIdent(ident), IsNil, Commentf("identifier: %q", ident))
+	}
+	for _, ident := range []string{"", "2person", "person table", "persön", "person;", `"`, `""`, `"person`, `"a"; DROP TABLE t; --"`} {
+		c.Check(ValidateIdent(ident), ErrorMatches, `invalid identifier .*`, Commentf("identifier: %q", ident))
+	}
+}
+
 func (s *typeInfoSuite) TestArgInfoEmbeddedStruct(c *C) {
 	type EmbeddedString string
 	type TaggedStruct struct {
@@ -197,6 +272,7 @@ var t = T{}
 func (s *typeInfoSuite) TestGenerateArgInfoInvalidTypeErrors(c *C) {
 	type T struct{ foo int }
 	type M map[string]any
+	type Time struct{ foo int }
 
 	tests := []struct {
 		args []any
@@ -217,13 +293,16 @@ func (s *typeInfoSuite) TestGenerateArgInfoInvalidTypeErrors(c *C) {
 		args: []any{T{}, T{}},
 		err:  `found multiple instances of type "T"`,
 	}, {
+		args: []any{T{}, &T{}},
+		err:  `found multiple instances of type "T"`,
+	}, {
 
-		args: []any{(*T)(nil)},
-		err:  "need non-pointer type, got pointer to struct",
+		args: []any{(**T)(nil)},
+		err:  "need value or pointer to value, got pointer to pointer",
 	}, {
 
-		args: []any{(*M)(nil)},
-		err:  "need non-pointer type, got pointer to map",
+		args: []any{new(*M)},
+		err:  "need value or pointer to value, got pointer to pointer",
 	}, {
 
 		args: []any{""},
@@ -237,7 +316,10 @@ func (s *typeInfoSuite) TestGenerateArgInfoInvalidTypeErrors(c *C) {
 		err:  "need supported type, got array",
 	}, {
 		args: []any{t, T{}},
-		err:  `two types found with name "T": "typeinfo.T" and "typeinfo.T"`,
+		err:  `two types found with name "T": "github.com/canonical/sqlair/internal/typeinfo.T" and "github.com/canonical/sqlair/internal/typeinfo.T"`,
+	}, {
+		args: []any{Time{}, time.Time{}},
+		err:  `two types found with name "Time": "github.com/canonical/sqlair/internal/typeinfo.Time" and "time.Time"`,
 	}}
 
 	for _, t := range tests {
@@ -251,7 +333,10 @@ func (s *typeInfoSuite) TestGenerateArgInfoStructError(c *C) {
 		unexp int `db:"unexp"`
 	}
 	_, err := GenerateArgInfo([]any{S1{}})
-	c.Assert(err, ErrorMatches, `field "unexp" of struct S1 not exported`)
+	c.Assert(err, ErrorMatches, `field "unexp" of struct "S1" is unexported but tagged db:"unexp"`)
+	fieldErr, ok := err.(*UnexportedFieldError)
+	c.Assert(ok, Equals, true)
+	c.Check(*fieldErr, Equals, UnexportedFieldError{Struct: "S1", Field: "unexp", TagName: "db", Tag: "unexp"})
 
 	type S2 struct {
 		Foo int `db:"id,bad-juju"`
@@ -259,6 +344,30 @@ func (s *typeInfoSuite) TestGenerateArgInfoStructError(c *C) {
 	_, err = GenerateArgInfo([]any{S2{}})
 	c.Assert(err, ErrorMatches, `cannot parse tag for field S2.Foo: unsupported flag "bad-juju" in tag "id,bad-juju"`)
 
+	type NullOption struct {
+		Foo int `db:"id,null=x"`
+	}
+	_, err = GenerateArgInfo([]any{NullOption{}})
+	c.Assert(err, ErrorMatches, `field NullOption.Foo has invalid null option "x": cannot parse as int`)
+
+	type NullOptionPointer struct {
+		Foo *int `db:"id,null=1"`
+	}
+	_, err = GenerateArgInfo([]any{NullOptionPointer{}})
+	c.Assert(err, ErrorMatches, `field NullOptionPointer.Foo has invalid null option "1": need field of integer, float, bool or string type, got \*int`)
+
+	type NullOptionNotNull struct {
+		Foo int `db:"id,notnull,null=1"`
+	}
+	_, err = GenerateArgInfo([]any{NullOptionNotNull{}})
+	c.Assert(err, ErrorMatches, `field NullOptionNotNull.Foo cannot have the null option with the notnull, text or json options`)
+
+	type TextAndJSON struct {
+		Foo map[string]int `db:"foo,text,json"`
+	}
+	_, err = GenerateArgInfo([]any{TextAndJSON{}})
+	c.Assert(err, ErrorMatches, `field TextAndJSON.Foo cannot have both the text and json options`)
+
 	type S3 struct {
 		Foo int `db:",omitempty"`
 	}
@@ -295,6 +404,36 @@ func (s *typeInfoSuite) TestGenerateArgInfoStructError(c *C) {
 	_, err = GenerateArgInfo([]any{S8{}})
 	c.Assert(err.Error(), Equals, `cannot parse tag for field S8.Foo: missing quotes at end of 'db' tag: "'!)*)£*("`)
 
+	type S9 struct {
+		Foo int `db:"#01"`
+	}
+	_, err = GenerateArgInfo([]any{S9{}})
+	c.Assert(err.Error(), Equals, `cannot parse tag for field S9.Foo: invalid position in 'db' tag: "#01"`)
+
+	type S10 struct {
+		Foo int `db:"#0"`
+		Bar int `db:"bar"`
+	}
+	_, err = GenerateArgInfo([]any{S10{}})
+	c.Assert(err.Error(), Equals, `struct "S10" mixes positional db tag "#0" with named db tag "bar"`)
+
+	type S11 struct {
+		Foo int `db:"#0"`
+		Bar int `db:"#2"`
+	}
+	_, err = GenerateArgInfo([]any{S11{}})
+	c.Assert(err.Error(), Equals, `positional db tags of struct "S11" must be #0 to #1, got "#2"`)
+
+	type Timestamps struct {
+		Created int `db:"created_at"`
+	}
+	type S12 struct {
+		*Timestamps
+		Made int `db:"created_at"`
+	}
+	_, err = GenerateArgInfo([]any{S12{}})
+	c.Assert(err.Error(), Equals, `db tag "created_at" appears in both field "Made" and field "Timestamps.Created" of struct "S12"`)
+
 	type badMap map[int]any
 	_, err = GenerateArgInfo([]any{badMap{}})
 	c.Assert(err, ErrorMatches, "map type badMap must have key type string, found type int")
@@ -362,7 +501,10 @@ func (*typeInfoSuite) TestSliceInputError(c *C) {
 	type myStruct struct {
 		Foo int `db:"foo"`
 	}
-	argInfo, err := GenerateArgInfo([]any{myMap{}, myStruct{}})
+	type pairs [][]int
+	type structs []*myStruct
+	type maps []myMap
+	argInfo, err := GenerateArgInfo([]any{myMap{}, myStruct{}, pairs{}, structs{}, maps{}})
 	c.Assert(err, IsNil)
 
 	tests := []struct {
@@ -374,6 +516,15 @@ func (*typeInfoSuite) TestSliceInputError(c *C) {
 	}, {
 		typeName: "myMap",
 		err:      "cannot use slice syntax with a map",
+	}, {
+		typeName: "pairs",
+		err:      `cannot use slice "pairs": slice elements in an IN expression must be scalar values, got slice`,
+	}, {
+		typeName: "structs",
+		err:      `cannot use slice "structs": slice elements in an IN expression must be scalar values, got struct`,
+	}, {
+		typeName: "maps",
+		err:      `cannot use slice "maps": slice elements in an IN expression must be scalar values, got map`,
 	}}
 
 	for i, test := range tests {
@@ -382,3 +533,47 @@ func (*typeInfoSuite) TestSliceInputError(c *C) {
 		c.Check(err.Error(), Equals, test.err)
 	}
 }
+
+func (*typeInfoSuite) TestSliceInputElemTypes(c *C) {
+	type ints []*int
+	type blobs [][]byte
+	type times []time.Time
+	type valuers []intValuer
+	type valuerPtrs []*intValuer
+	samples := []any{S{}, ints{}, blobs{}, times{}, valuers{}, valuerPtrs{}}
+	argInfo, err := GenerateArgInfo(samples)
+	c.Assert(err, IsNil)
+	for _, sample := range samples {
+		name := reflect.TypeOf(sample).Name()
+		_, err := argInfo[name].GetSlice()
+		c.Check(err, IsNil, Commentf("slice %q", name))
+	}
+}
+
+func (s *typeInfoSuite) TestGenerateArgInfoNamedSample(c *C) {
+	type anon = struct {
+		Foo int `db:"foo"`
+	}
+	argInfo, err := GenerateArgInfo([]any{NamedSample{Name: "Anon", Sample: anon{}}})
+	c.Assert(err, IsNil)
+	info, ok := argInfo["Anon"]
+	c.Assert(ok, Equals, true)
+	c.Assert(info.Typ(), Equals, reflect.TypeOf(anon{}))
+	c.Assert(TypeName(info.Typ()), Equals, "Anon")
+	member, err := info.GetMember("foo")
+	c.Assert(err, IsNil)
+	c.Assert(member.Identifier(), Equals, "Anon.foo")
+
+	// Once named, the struct can be used as a plain type sample.
+	_, err = GenerateArgInfo([]any{anon{}})
+	c.Assert(err, IsNil)
+
+	_, err = GenerateArgInfo([]any{NamedSample{Name: "Other", Sample: anon{}}})
+	c.Assert(err, ErrorMatches, `cannot name anonymous struct "Other", it is already named "Anon"`)
+	_, err = GenerateArgInfo([]any{NamedSample{Name: "", Sample: struct{}{}}})
+	c.Assert(err, ErrorMatches, `cannot name anonymous struct with empty name`)
+	_, err = GenerateArgInfo([]any{NamedSample{Name: "Anon", Sample: map[string]any{}}})
+	c.Assert(err, ErrorMatches, `cannot name type map\[string\]interface \{\}, need anonymous struct`)
+	_, err = GenerateArgInfo([]any{NamedSample{Name: "Anon", Sample: nil}})
+	c.Assert(err, ErrorMatches, `need supported value, got nil`)
+}
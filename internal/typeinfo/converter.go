@@ -0,0 +1,180 @@
+// Copyright 2024 Canonical Ltd.
+// Licensed under Apache 2.0, see LICENCE file for details.
+
+package typeinfo
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sync"
+	"sync/atomic"
+)
+
+// ScanConverter converts a value returned by a driver, such as a []byte or
+// string, into a value of the type it is registered for.
+type ScanConverter func(src any) (any, error)
+
+// scanConverters holds a map[reflect.Type]ScanConverter. It is replaced
+// rather than modified so that it can be read without locking while rows are
+// scanned.
+var scanConverters atomic.Value
+
+// scanConvertersMutex serialises calls to RegisterScanConverter.
+var scanConvertersMutex sync.Mutex
+
+// RegisterScanConverter registers convert as the scan converter for struct
+// fields of type t or pointers to t. A converter registered for a type that
+// already has one replaces it.
+func RegisterScanConverter(t reflect.Type, convert ScanConverter) {
+	scanConvertersMutex.Lock()
+	defer scanConvertersMutex.Unlock()
+	old, _ := scanConverters.Load().(map[reflect.Type]ScanConverter)
+	converters := make(map[reflect.Type]ScanConverter, len(old)+1)
+	for k, v := range old {
+		converters[k] = v
+	}
+	converters[t] = convert
+	scanConverters.Store(converters)
+}
+
+// scanConverterFor returns the scan converter registered for the type or the
+// type it points to, if there is one.
+func scanConverterFor(t reflect.Type) (ScanConverter, bool) {
+	converters, _ := scanConverters.Load().(map[reflect.Type]ScanConverter)
+	if len(converters) == 0 {
+		return nil, false
+	}
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	convert, ok := converters[t]
+	return convert, ok
+}
+
+// converterScanTarget returns a scan target that accepts any value, along with
+// a ScanProxy that converts it into the struct field with the scan converter.
+func converterScanTarget(field reflect.Value, convert ScanConverter) (any, *ScanProxy) {
+	var x any
+	scanVal := reflect.ValueOf(&x).Elem()
+	return &x, &ScanProxy{original: field, scan: scanVal, convert: convert}
+}
+
+// convertScanned passes the scanned value to the scan converter and sets the
+// field to the result. A NULL sets the field to its zero value without calling
+// the converter.
+func (sp ScanProxy) convertScanned() error {
+	src := sp.scan.Interface()
+	if src == nil {
+		sp.original.Set(sp.nullValue())
+		return nil
+	}
+	t := sp.original.Type()
+	isPointer := t.Kind() == reflect.Pointer
+	if isPointer {
+		t = t.Elem()
+	}
+	converted, err := sp.convert(src)
+	if err != nil {
+		return fmt.Errorf("cannot convert %T value to %s: %s", src, t, err)
+	}
+	dst := reflect.New(t).Elem()
+	cv := reflect.ValueOf(converted)
+	if !cv.IsValid() || !cv.Type().AssignableTo(t) {
+		return fmt.Errorf("cannot convert %T value to %s: converter returned %T", src, t, converted)
+	}
+	dst.Set(cv)
+	if isPointer {
+		sp.original.Set(dst.Addr())
+	} else {
+		sp.original.Set(dst)
+	}
+	return nil
+}
+
+// InputConverter converts the value of a query input into the value passed
+// to the driver.
+type InputConverter func(v any) (any, error)
+
+// inputConverters holds a map[reflect.Type]InputConverter. Like
+// scanConverters, it is replaced rather than modified.
+var inputConverters atomic.Value
+
+// inputConvertersMutex serialises calls to RegisterInputConverter.
+var inputConvertersMutex sync.Mutex
+
+// RegisterInputConverter registers convert as the input converter for values
+// of type t. A converter registered for a type that already has one replaces
+// it.
+func RegisterInputConverter(t reflect.Type, convert InputConverter) {
+	inputConvertersMutex.Lock()
+	defer inputConvertersMutex.Unlock()
+	old, _ := inputConverters.Load().(map[reflect.Type]InputConverter)
+	converters := make(map[reflect.Type]InputConverter, len(old)+1)
+	for k, v := range old {
+		converters[k] = v
+	}
+	converters[t] = convert
+	inputConverters.Store(converters)
+}
+
+// ConvertInput returns the result of the input converter registered for the
+// type of val, or val itself if there is none.
+func ConvertInput(val any) (any, error) {
+	converters, _ := inputConverters.Load().(map[reflect.Type]InputConverter)
+	if len(converters) == 0 || val == nil {
+		return val, nil
+	}
+	convert, ok := converters[reflect.TypeOf(val)]
+	if !ok {
+		return val, nil
+	}
+	converted, err := convert(val)
+	if err != nil {
+		return nil, fmt.Errorf("cannot convert input value of type %T: %s", val, err)
+	}
+	return converted, nil
+}
+
+// IsIntegerType returns true if values of type t can be passed to ToInt64.
+// This is the case for integer types, pointers to them and interface types,
+// whose values are only known when the query is run.
+func IsIntegerType(t reflect.Type) bool {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Interface:
+		return true
+	}
+	return false
+}
+
+// ToInt64 converts a value of any integer type, or a pointer to one, into an
+// int64. A nil value or nil pointer is returned as nil. An error is returned
+// if val is not an integer or is an unsigned integer too large for an int64.
+func ToInt64(val any) (any, error) {
+	if val == nil {
+		return nil, nil
+	}
+	v := reflect.ValueOf(val)
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil, nil
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u := v.Uint()
+		if u > math.MaxInt64 {
+			return nil, fmt.Errorf("value %d overflows int64", u)
+		}
+		return int64(u), nil
+	}
+	return nil, fmt.Errorf("value of type %T is not an integer", val)
+}
@@ -0,0 +1,114 @@
+// Copyright 2024 Canonical Ltd.
+// Licensed under Apache 2.0, see LICENCE file for details.
+
+package typeinfo
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// Ident is a SQL identifier, such as a table or column name. It is substituted
+// directly into the SQL rather than being passed as a query parameter.
+type Ident string
+
+// IdentType is the reflected type of Ident.
+var IdentType = reflect.TypeOf(Ident(""))
+
+// identMember is the name of the only member of Ident.
+const identMember = "name"
+
+// validIdent matches unquoted identifiers.
+var validIdent = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// ValidateIdent checks that the identifier can be safely substituted into a
+// SQL query. The identifier must either consist of letters, digits and
+// underscores and not start with a digit, or be enclosed in double quotes.
+// Quoted identifiers may contain any characters other than double quotes.
+func ValidateIdent(ident string) error {
+	if len(ident) >= 2 && ident[0] == '"' && ident[len(ident)-1] == '"' {
+		if inner := ident[1 : len(ident)-1]; inner != "" && !strings.ContainsAny(inner, "\"\x00") {
+			return nil
+		}
+	} else if validIdent.MatchString(ident) {
+		return nil
+	}
+	return fmt.Errorf("invalid identifier %q", ident)
+}
+
+// SortDirection is the direction of an ORDER BY clause. It is substituted
+// directly into the SQL rather than being passed as a query parameter.
+type SortDirection string
+
+// ValidateSortDirection checks that the direction is either "ASC" or "DESC",
+// ignoring case, and returns it in upper case.
+func ValidateSortDirection(direction string) (string, error) {
+	switch d := strings.ToUpper(direction); d {
+	case "ASC", "DESC":
+		return d, nil
+	}
+	return "", fmt.Errorf("invalid sort direction %q, expected ASC or DESC", direction)
+}
+
+// identInfo stores information about the Ident type.
+type identInfo struct{}
+
+func (ii *identInfo) Typ() reflect.Type {
+	return IdentType
+}
+
+// GetMember returns a locator for the name of the identifier.
+func (ii *identInfo) GetMember(memberName string) (ValueLocator, error) {
+	if memberName != identMember {
+		return nil, fmt.Errorf("type %q has no member %q, expected %s.%s", IdentType.Name(), memberName, IdentType.Name(), identMember)
+	}
+	return &identName{}, nil
+}
+
+// GetAllStructMembers returns an error since identifiers do not have struct
+// members.
+func (ii *identInfo) GetAllStructMembers() ([]ValueLocator, []string, error) {
+	return nil, nil, fmt.Errorf("cannot use identifier with asterisk")
+}
+
+// GetSlice returns an error.
+func (ii *identInfo) GetSlice() (ValueLocator, error) {
+	return nil, fmt.Errorf("cannot use slice syntax with an identifier")
+}
+
+// identName locates the name of an identifier.
+type identName struct{}
+
+// ArgType returns the Ident type.
+func (in *identName) ArgType() reflect.Type {
+	return IdentType
+}
+
+// LocateParams locates the Ident in typeToValue and returns its name as the
+// single value of the params.
+func (in *identName) LocateParams(typeToValue TypeToValue) (*Params, error) {
+	v, ok := typeToValue[IdentType]
+	if !ok {
+		return nil, valueNotFoundError(typeToValue, IdentType)
+	}
+	return newParams([]any{v.String()}, false, false, IdentType), nil
+}
+
+// Desc returns a natural language description of the identifier for use in
+// error messages.
+func (in *identName) Desc() string {
+	return fmt.Sprintf("name of identifier %q", IdentType.Name())
+}
+
+// Member returns the name member of the identifier, which holds a string.
+func (in *identName) Member() Member {
+	return Member{Name: identMember, Type: reflect.TypeOf("")}
+}
+
+// Identifier returns a string that uniquely identifies the identifier in the
+// context of the query.
+func (in *identName) Identifier() string {
+	return IdentType.Name() + "." + identMember
+}
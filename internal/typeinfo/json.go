@@ -0,0 +1,144 @@
+// Copyright 2024 Canonical Ltd.
+// Licensed under Apache 2.0, see LICENCE file for details.
+
+package typeinfo
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// jsonValue locates a whole struct that is passed to the database, and read
+// from it, as a single JSON encoded value.
+type jsonValue struct {
+	structType reflect.Type
+}
+
+// JSONValue returns a value locator for the whole of a struct encoded as JSON.
+// It is used for input and output expressions that name a type without a
+// member, such as "$Doc" and "data AS &Doc".
+func JSONValue(argInfo ArgInfo) (ValueLocator, error) {
+	t := argInfo.Typ()
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("cannot encode %s %q as JSON, only structs can be used whole", t.Kind(), t.Name())
+	}
+	return &jsonValue{structType: t}, nil
+}
+
+// ArgType returns the type of the struct.
+func (jv *jsonValue) ArgType() reflect.Type {
+	return jv.structType
+}
+
+// Desc returns a natural language description of the JSON value for use in
+// error messages.
+func (jv *jsonValue) Desc() string {
+	return fmt.Sprintf("JSON of struct %q", TypeName(jv.structType))
+}
+
+// Identifier returns a string that uniquely identifies the JSON value in the
+// context of the query.
+func (jv *jsonValue) Identifier() string {
+	return TypeName(jv.structType)
+}
+
+// Member returns the type of the struct. The value has no member name.
+func (jv *jsonValue) Member() Member {
+	return Member{Type: jv.structType}
+}
+
+// LocateParams locates the struct (or slice of structs for a bulk insert) in
+// typeToValue and returns Params containing its JSON encoding as a string.
+func (jv *jsonValue) LocateParams(typeToValue TypeToValue) (*Params, error) {
+	if s, ok := typeToValue[jv.structType]; ok {
+		param, err := jv.param(s)
+		if err != nil {
+			return nil, err
+		}
+		return newParams([]any{param}, false, false, s.Type()), nil
+	}
+	if ss, ok := locateBulkType(typeToValue, jv.structType); ok {
+		if ss.Len() == 0 {
+			return nil, fmt.Errorf("got slice of %q with length 0", TypeName(jv.structType))
+		}
+		var vals []any
+		for i := 0; i < ss.Len(); i++ {
+			s := ss.Index(i)
+			if s.Kind() == reflect.Pointer {
+				if s.IsNil() {
+					return nil, fmt.Errorf("got nil pointer in slice of %q at index %d", TypeName(jv.structType), i)
+				}
+				s = s.Elem()
+			}
+			param, err := jv.param(s)
+			if err != nil {
+				return nil, err
+			}
+			vals = append(vals, param)
+		}
+		return newParams(vals, false, true, ss.Type()), nil
+	}
+	return nil, valueNotFoundError(typeToValue, jv.structType)
+}
+
+// param returns the JSON encoding of the struct as a string.
+func (jv *jsonValue) param(s reflect.Value) (any, error) {
+	data, err := json.Marshal(s.Interface())
+	if err != nil {
+		return nil, fmt.Errorf("cannot marshal %s: %s", jv.Desc(), err)
+	}
+	return string(data), nil
+}
+
+// LocateScanTarget locates the struct in typeToValue and returns a scan
+// target that reads the column as text, along with a ScanProxy that decodes
+// the JSON into the struct.
+func (jv *jsonValue) LocateScanTarget(typeToValue TypeToValue) (any, *ScanProxy, error) {
+	s, ok := typeToValue[jv.structType]
+	if !ok {
+		return nil, nil, valueNotFoundError(typeToValue, jv.structType)
+	}
+	if !s.CanSet() {
+		return nil, nil, fmt.Errorf("internal error: cannot set struct %s", TypeName(jv.structType))
+	}
+	scanVal := reflect.New(nullStringType).Elem()
+	return scanVal.Addr().Interface(), &ScanProxy{original: s, scan: scanVal, json: true}, nil
+}
+
+// marshalJSON returns the JSON encoding of the value of a field with the
+// "json" tag option as a string. A nil pointer, map or slice is returned as
+// nil so that it is passed to the database as NULL.
+func marshalJSON(val reflect.Value) (any, error) {
+	switch val.Kind() {
+	case reflect.Pointer, reflect.Map, reflect.Slice, reflect.Interface:
+		if val.IsNil() {
+			return nil, nil
+		}
+	}
+	data, err := json.Marshal(val.Interface())
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// unmarshalJSON decodes the scanned JSON into the struct or field. It is set
+// to its zero value first, so members missing from the JSON are zeroed. A
+// NULL leaves it set to its zero value.
+func (sp ScanProxy) unmarshalJSON() error {
+	sp.original.Set(reflect.Zero(sp.original.Type()))
+	ns := sp.scan.Interface().(sql.NullString)
+	if !ns.Valid {
+		return nil
+	}
+	if err := json.Unmarshal([]byte(ns.String), sp.original.Addr().Interface()); err != nil {
+		into := sp.desc
+		if into == "" {
+			into = TypeName(sp.original.Type())
+		}
+		return fmt.Errorf("cannot unmarshal JSON into %s: %s", into, err)
+	}
+	return nil
+}
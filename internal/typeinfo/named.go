@@ -0,0 +1,65 @@
+// Copyright 2024 Canonical Ltd.
+// Licensed under Apache 2.0, see LICENCE file for details.
+
+package typeinfo
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"sync/atomic"
+)
+
+// NamedSample is a type sample of an anonymous struct along with the name
+// used to refer to the struct in queries.
+type NamedSample struct {
+	Name   string
+	Sample any
+}
+
+// typeNames holds a map[reflect.Type]string of the names given to anonymous
+// structs. It is replaced rather than modified so that it can be read without
+// locking.
+var typeNames atomic.Value
+
+// typeNamesMutex serialises calls to registerTypeName.
+var typeNamesMutex sync.Mutex
+
+// registerTypeName gives the anonymous struct type t the name used to refer to
+// it in queries. Since identical anonymous structs are the same type, a type
+// can only be given one name.
+func registerTypeName(t reflect.Type, name string) error {
+	if t.Kind() != reflect.Struct || t.Name() != "" {
+		return fmt.Errorf("cannot name type %s, need anonymous struct", t)
+	}
+	if name == "" {
+		return fmt.Errorf("cannot name anonymous struct with empty name")
+	}
+	typeNamesMutex.Lock()
+	defer typeNamesMutex.Unlock()
+	old, _ := typeNames.Load().(map[reflect.Type]string)
+	if oldName, ok := old[t]; ok {
+		if oldName != name {
+			return fmt.Errorf("cannot name anonymous struct %q, it is already named %q", name, oldName)
+		}
+		return nil
+	}
+	names := make(map[reflect.Type]string, len(old)+1)
+	for k, v := range old {
+		names[k] = v
+	}
+	names[t] = name
+	typeNames.Store(names)
+	return nil
+}
+
+// TypeName returns the name used to refer to the type in queries. This is
+// the name of the type or, for an anonymous struct, the name given to it with
+// a NamedSample. It is empty for other unnamed types.
+func TypeName(t reflect.Type) string {
+	if name := t.Name(); name != "" {
+		return name
+	}
+	names, _ := typeNames.Load().(map[reflect.Type]string)
+	return names[t]
+}
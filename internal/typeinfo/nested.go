@@ -0,0 +1,128 @@
+// Copyright 2024 Canonical Ltd.
+// Licensed under Apache 2.0, see LICENCE file for details.
+
+package typeinfo
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// splitMemberPath splits a member name into the segments of a path to a
+// nested struct field, e.g. "Addr.street" into "Addr" and "street". Dots in
+// quoted names do not split the path.
+func splitMemberPath(memberName string) []string {
+	var path []string
+	start := 0
+	var quote byte
+	for i := 0; i < len(memberName); i++ {
+		switch c := memberName[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '.':
+			path = append(path, memberName[start:i])
+			start = i + 1
+		}
+	}
+	return append(path, memberName[start:])
+}
+
+// MemberColumn returns the column name of a member. This is the member name
+// itself, or the db tag at the end of the path to a nested struct field, e.g.
+// "street" for "Addr.street".
+func MemberColumn(memberName string) string {
+	path := splitMemberPath(memberName)
+	return path[len(path)-1]
+}
+
+// nestedMember returns the struct field at the end of a path of the form
+// "Field.Field.tag". The segments before the last are the Go names of fields
+// that hold a struct, or a pointer to a struct, and the last is a db tag of
+// the innermost struct. The field is located from the outer struct. If fold
+// is true the db tag is matched ignoring case.
+func (si *structInfo) nestedMember(path []string, fold bool) (*structField, error) {
+	t := si.structType
+	var index []int
+	var names []string
+	for _, name := range path[:len(path)-1] {
+		field, ok := t.FieldByName(name)
+		if !ok || !field.IsExported() {
+			return nil, fmt.Errorf("type %q has no exported field %q", t.Name(), name)
+		}
+		ft := field.Type
+		if ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if ft.Kind() != reflect.Struct {
+			return nil, fmt.Errorf("field %q of type %q is not a struct", name, t.Name())
+		}
+		index = append(index, field.Index...)
+		names = append(names, name)
+		t = ft
+	}
+
+	info, err := getArgInfo(t, si.tagName)
+	if err != nil {
+		return nil, err
+	}
+	inner := info.(*structInfo)
+	var vl ValueLocator
+	if fold {
+		vl, err = inner.getMemberFold(path[len(path)-1])
+	} else {
+		vl, err = inner.GetMember(path[len(path)-1])
+	}
+	if err != nil {
+		return nil, err
+	}
+	leaf := *vl.(*structField)
+	leaf.index = append(append([]int{}, index...), leaf.index...)
+	leaf.name = fieldPath(si.structType, leaf.index)
+	leaf.structType = si.structType
+	leaf.nested = true
+	leaf.tag = strings.Join(append(names, leaf.tag), ".")
+	return &leaf, nil
+}
+
+// inputField returns the field of the struct s at the index path. An error is
+// returned if a pointer to a nested struct on the path is nil.
+func (f *structField) inputField(s reflect.Value) (reflect.Value, error) {
+	if !f.nested {
+		return s.FieldByIndex(f.index), nil
+	}
+	v := s
+	for i, x := range f.index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				return reflect.Value{}, fmt.Errorf("cannot get %s: nil pointer at field %s", f.Desc(), fieldPath(f.structType, f.index[:i]))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, nil
+}
+
+// outputField returns the field of the struct s at the index path. Nil
+// pointers to nested structs on the path are set to newly allocated structs.
+func (f *structField) outputField(s reflect.Value) reflect.Value {
+	if !f.nested {
+		return s.FieldByIndex(f.index)
+	}
+	v := s
+	for i, x := range f.index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
@@ -3,7 +3,13 @@
 
 package typeinfo
 
-import "reflect"
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
 
 // ScanProxy is a shim for scanning query results
 // into struct fields or map keys.
@@ -18,13 +24,63 @@ type ScanProxy struct {
 	// key when valid indicates that this proxy is
 	// for a key in the map indicated by original.
 	key reflect.Value
+
+	// coerce indicates that scan holds an any value that is converted to
+	// the numeric type of original.
+	coerce bool
+
+	// text indicates that scan holds a sql.NullString that is unmarshalled
+	// into original.
+	text bool
+
+	// textBool indicates that scan holds an any value that is converted to
+	// the bool type of original.
+	textBool bool
+
+	// json indicates that scan holds a sql.NullString that is decoded as
+	// JSON into original, a struct or a field with the "json" tag option.
+	json bool
+
+	// desc, if set, describes original in error messages.
+	desc string
+
+	// convert, if set, is the scan converter registered for the type of
+	// original. The scanned any value is passed to it.
+	convert ScanConverter
+
+	// null, if valid, is the value original is set to when the column is
+	// NULL, from the "null" option of the field's db tag.
+	null reflect.Value
+}
+
+// nullValue returns the value to set original to when the column is NULL.
+func (sp ScanProxy) nullValue() reflect.Value {
+	if sp.null.IsValid() {
+		return sp.null
+	}
+	return reflect.Zero(sp.original.Type())
 }
 
 // OnSuccess is run after using rows.Scan to read a single query column
 // return into the variable referenced by the scan member.
 // When the ScanProxy is for a map key, we set the map's value for the key.
 // When the proxy is for a struct field, we set that field.
-func (sp ScanProxy) OnSuccess() {
+func (sp ScanProxy) OnSuccess() error {
+	if sp.coerce {
+		return sp.coerceNumeric()
+	}
+	if sp.text {
+		return sp.unmarshalText()
+	}
+	if sp.json {
+		return sp.unmarshalJSON()
+	}
+	if sp.convert != nil {
+		return sp.convertScanned()
+	}
+	if sp.textBool {
+		return sp.convertTextBool()
+	}
 	if sp.key.IsValid() {
 		sp.original.SetMapIndex(sp.key, sp.scan)
 	} else {
@@ -32,8 +88,309 @@ func (sp ScanProxy) OnSuccess() {
 		if !sp.scan.IsNil() {
 			val = sp.scan.Elem()
 		} else {
-			val = reflect.Zero(sp.original.Type())
+			val = sp.nullValue()
 		}
 		sp.original.Set(val)
 	}
+	return nil
+}
+
+// CoerceNumeric replaces the scan target of a numeric struct field, or a
+// pointer to a numeric struct field, with a target that accepts any value.
+// When the returned ScanProxy is run, the scanned value is converted to the
+// type of the field if it can be represented exactly. Other scan targets are
+// returned unchanged.
+func CoerceNumeric(ptr any, proxy *ScanProxy) (any, *ScanProxy) {
+	var field reflect.Value
+	switch {
+	case proxy == nil:
+		field = reflect.ValueOf(ptr).Elem()
+	case proxy.text || proxy.json || proxy.convert != nil:
+		return ptr, proxy
+	case !proxy.key.IsValid():
+		field = proxy.original
+	default:
+		return ptr, proxy
+	}
+	t := field.Type()
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if !isNumericKind(t.Kind()) || reflect.PointerTo(t).Implements(scannerInterface) {
+		return ptr, proxy
+	}
+	var x any
+	scanVal := reflect.ValueOf(&x).Elem()
+	return &x, &ScanProxy{original: field, scan: scanVal, coerce: true, null: proxyNull(proxy)}
+}
+
+// proxyNull returns the NULL value of proxy, which may be nil.
+func proxyNull(proxy *ScanProxy) reflect.Value {
+	if proxy == nil {
+		return reflect.Value{}
+	}
+	return proxy.null
+}
+
+// TextBool replaces the scan target of a bool struct field, or a pointer to
+// bool struct field, with one that accepts textual booleans. Other scan
+// targets are returned unchanged.
+func TextBool(ptr any, proxy *ScanProxy) (any, *ScanProxy) {
+	var field reflect.Value
+	switch {
+	case proxy == nil:
+		field = reflect.ValueOf(ptr).Elem()
+	case proxy.text || proxy.json || proxy.coerce || proxy.convert != nil || proxy.key.IsValid():
+		return ptr, proxy
+	default:
+		field = proxy.original
+	}
+	t := field.Type()
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Bool || reflect.PointerTo(t).Implements(scannerInterface) {
+		return ptr, proxy
+	}
+	var x any
+	scanVal := reflect.ValueOf(&x).Elem()
+	return &x, &ScanProxy{original: field, scan: scanVal, textBool: true, null: proxyNull(proxy)}
+}
+
+// convertTextBool converts the scanned value to a bool and sets the field.
+func (sp ScanProxy) convertTextBool() error {
+	src := sp.scan.Interface()
+	if src == nil {
+		sp.original.Set(sp.nullValue())
+		return nil
+	}
+	t := sp.original.Type()
+	isPointer := t.Kind() == reflect.Pointer
+	if isPointer {
+		t = t.Elem()
+	}
+	b, err := parseTextBool(src)
+	if err != nil {
+		return err
+	}
+	dst := reflect.New(t).Elem()
+	dst.SetBool(b)
+	if isPointer {
+		sp.original.Set(dst.Addr())
+	} else {
+		sp.original.Set(dst)
+	}
+	return nil
+}
+
+// parseTextBool returns the boolean represented by src, a value returned by a
+// driver.
+func parseTextBool(src any) (bool, error) {
+	var s string
+	switch v := src.(type) {
+	case bool:
+		return v, nil
+	case int64:
+		if v == 0 || v == 1 {
+			return v == 1, nil
+		}
+		return false, fmt.Errorf("cannot convert int64 value %d to bool", v)
+	case []byte:
+		s = string(v)
+	case string:
+		s = v
+	default:
+		return false, fmt.Errorf("cannot convert %T value to bool", src)
+	}
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "true", "t", "yes", "y", "on", "1":
+		return true, nil
+	case "false", "f", "no", "n", "off", "0":
+		return false, nil
+	}
+	return false, fmt.Errorf("cannot convert %T value %q to bool: not a boolean", src, s)
+}
+
+// isNumericKind returns true for integer and floating point kinds.
+func isNumericKind(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// coerceNumeric converts the scanned value to the numeric type of the field
+// and sets the field. An error is returned if the value cannot be represented
+// exactly by the type of the field.
+func (sp ScanProxy) coerceNumeric() error {
+	src := sp.scan.Interface()
+	if src == nil {
+		sp.original.Set(sp.nullValue())
+		return nil
+	}
+	t := sp.original.Type()
+	isPointer := t.Kind() == reflect.Pointer
+	if isPointer {
+		t = t.Elem()
+	}
+	dst := reflect.New(t).Elem()
+	if err := convertNumeric(src, dst); err != nil {
+		return err
+	}
+	if isPointer {
+		sp.original.Set(dst.Addr())
+	} else {
+		sp.original.Set(dst)
+	}
+	return nil
+}
+
+// convertNumeric converts src, a value returned by a driver, to the numeric
+// kind of dst and sets dst.
+func convertNumeric(src any, dst reflect.Value) error {
+	var i int64
+	var u uint64
+	var f float64
+	var kind reflect.Kind
+	switch v := src.(type) {
+	case int64:
+		i, kind = v, reflect.Int64
+	case int:
+		i, kind = int64(v), reflect.Int64
+	case int32:
+		i, kind = int64(v), reflect.Int64
+	case uint64:
+		u, kind = v, reflect.Uint64
+	case float64:
+		f, kind = v, reflect.Float64
+	case float32:
+		f, kind = float64(v), reflect.Float64
+	case []byte, string:
+		s := fmt.Sprintf("%s", v)
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+			i, kind = n, reflect.Int64
+		} else if n, err := strconv.ParseUint(s, 10, 64); err == nil {
+			u, kind = n, reflect.Uint64
+		} else if n, err := strconv.ParseFloat(s, 64); err == nil {
+			f, kind = n, reflect.Float64
+		} else {
+			return fmt.Errorf("cannot coerce %T value %q to %s: not a number", src, s, dst.Type())
+		}
+	default:
+		return fmt.Errorf("cannot coerce %T value to %s", src, dst.Type())
+	}
+
+	outOfRange := fmt.Errorf("cannot coerce %T value %v to %s: out of range", src, src, dst.Type())
+	switch dst.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		switch kind {
+		case reflect.Uint64:
+			if u > math.MaxInt64 {
+				return outOfRange
+			}
+			i = int64(u)
+		case reflect.Float64:
+			if f != math.Trunc(f) {
+				return fmt.Errorf("cannot coerce %T value %v to %s: not an integer", src, src, dst.Type())
+			}
+			if f < math.MinInt64 || f >= math.MaxInt64 {
+				return outOfRange
+			}
+			i = int64(f)
+		}
+		if dst.OverflowInt(i) {
+			return outOfRange
+		}
+		dst.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		switch kind {
+		case reflect.Int64:
+			if i < 0 {
+				return outOfRange
+			}
+			u = uint64(i)
+		case reflect.Float64:
+			if f != math.Trunc(f) {
+				return fmt.Errorf("cannot coerce %T value %v to %s: not an integer", src, src, dst.Type())
+			}
+			if f < 0 || f >= math.MaxUint64 {
+				return outOfRange
+			}
+			u = uint64(f)
+		}
+		if dst.OverflowUint(u) {
+			return outOfRange
+		}
+		dst.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		inexact := fmt.Errorf("cannot coerce %T value %v to %s: cannot be represented exactly", src, src, dst.Type())
+		// Integers above 2^53 are rounded when converted to a float. The
+		// float is converted back to check that no rounding happened.
+		switch kind {
+		case reflect.Int64:
+			f = float64(i)
+			if f >= math.MaxInt64 || int64(f) != i {
+				return inexact
+			}
+		case reflect.Uint64:
+			f = float64(u)
+			if f >= math.MaxUint64 || uint64(f) != u {
+				return inexact
+			}
+		}
+		if dst.OverflowFloat(f) {
+			return outOfRange
+		}
+		if dst.Kind() == reflect.Float32 && !math.IsNaN(f) && float64(float32(f)) != f {
+			return inexact
+		}
+		dst.SetFloat(f)
+	default:
+		return fmt.Errorf("internal error: cannot coerce to %s", dst.Type())
+	}
+	return nil
+}
+
+// FieldScanner scans a result column into the same field of successive values
+// of a struct type. The kind of scan target is worked out once, and the
+// pointer that a nullable column is scanned into is allocated once and reused
+// for every value.
+type FieldScanner struct {
+	field *structField
+	// scan, if valid, is the reused pointer that the column is scanned into
+	// before it is copied into the field by a ScanProxy.
+	scan reflect.Value
+}
+
+// NewFieldScanner returns a FieldScanner for o. It returns false if o is not
+// a struct field or is scanned through text unmarshalling or a scan
+// converter, since those need a new scan target for every value.
+func NewFieldScanner(o Output) (*FieldScanner, bool) {
+	f, ok := o.(*structField)
+	if !ok {
+		return nil, false
+	}
+	fs := &FieldScanner{field: f}
+	switch f.scanKind() {
+	case scanDirect:
+	case scanNullable:
+		fs.scan = reflect.New(reflect.PointerTo(f.Member().Type)).Elem()
+	default:
+		return nil, false
+	}
+	return fs, true
+}
+
+// ScanTarget returns a pointer for rows.Scan to the field in s, a value of the
+// struct type of the field. If the value must be copied into the field after
+// the row is scanned it also returns a ScanProxy to do so, and true.
+func (fs *FieldScanner) ScanTarget(s reflect.Value) (any, ScanProxy, bool) {
+	val := fs.field.outputField(s)
+	if !fs.scan.IsValid() {
+		return val.Addr().Interface(), ScanProxy{}, false
+	}
+	return fs.scan.Addr().Interface(), ScanProxy{original: val, scan: fs.scan, null: fs.field.nullValue}, true
 }
@@ -0,0 +1,78 @@
+// Copyright 2024 Canonical Ltd.
+// Licensed under Apache 2.0, see LICENCE file for details.
+
+package typeinfo
+
+import (
+	"database/sql"
+	"encoding"
+	"fmt"
+	"reflect"
+)
+
+var textMarshalerInterface = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+var textUnmarshalerInterface = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+var nullStringType = reflect.TypeOf(sql.NullString{})
+
+// isTextType returns true if values of type t, or of the type t points to,
+// can be marshalled to and unmarshalled from text.
+func isTextType(t reflect.Type) bool {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	pt := reflect.PointerTo(t)
+	return pt.Implements(textMarshalerInterface) && pt.Implements(textUnmarshalerInterface)
+}
+
+// marshalText returns the text form of a value of a type checked with
+// isTextType as a string. A nil pointer is returned as nil so that it is
+// passed to the database as NULL.
+func marshalText(val reflect.Value) (any, error) {
+	if val.Kind() == reflect.Pointer {
+		if val.IsNil() {
+			return nil, nil
+		}
+		val = val.Elem()
+	}
+	// The value may not be addressable, so copy it in case MarshalText has a
+	// pointer receiver.
+	ptr := reflect.New(val.Type())
+	ptr.Elem().Set(val)
+	text, err := ptr.Interface().(encoding.TextMarshaler).MarshalText()
+	if err != nil {
+		return nil, err
+	}
+	return string(text), nil
+}
+
+// textScanTarget returns a scan target and ScanProxy that read a column as
+// text and unmarshal it into the field.
+func textScanTarget(field reflect.Value) (any, *ScanProxy) {
+	scanVal := reflect.New(nullStringType).Elem()
+	return scanVal.Addr().Interface(), &ScanProxy{original: field, scan: scanVal, text: true}
+}
+
+// unmarshalText unmarshals the scanned text into the field. A NULL sets the
+// field to its zero value.
+func (sp ScanProxy) unmarshalText() error {
+	ns := sp.scan.Interface().(sql.NullString)
+	if !ns.Valid {
+		sp.original.Set(reflect.Zero(sp.original.Type()))
+		return nil
+	}
+	t := sp.original.Type()
+	isPointer := t.Kind() == reflect.Pointer
+	if isPointer {
+		t = t.Elem()
+	}
+	dst := reflect.New(t)
+	if err := dst.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(ns.String)); err != nil {
+		return fmt.Errorf("cannot unmarshal %q into %s: %s", ns.String, t, err)
+	}
+	if isPointer {
+		sp.original.Set(dst)
+	} else {
+		sp.original.Set(dst.Elem())
+	}
+	return nil
+}
@@ -16,15 +16,14 @@ type TypeToValue = map[reflect.Type]reflect.Value
 func ValidateInputs(args []any) (TypeToValue, error) {
 	typeToValue := TypeToValue{}
 	for _, arg := range args {
-		v := reflect.ValueOf(arg)
-		if err := validateValue(v); err != nil {
+		v, err := indirectInput(reflect.ValueOf(arg))
+		if err != nil {
 			return nil, err
 		}
-		v = reflect.Indirect(v)
 		t := v.Type()
 		switch k := v.Kind(); k {
 		case reflect.Map, reflect.Struct:
-			if t.Name() == "" {
+			if TypeName(t) == "" {
 				return nil, fmt.Errorf("cannot use anonymous %s", k)
 			}
 			if _, ok := typeToValue[reflect.SliceOf(t)]; ok {
@@ -43,6 +42,9 @@ func ValidateInputs(args []any) (TypeToValue, error) {
 					return nil, typeAndSliceProvidedError(t, t.Elem())
 				}
 			case reflect.Pointer:
+				if ek := t.Elem().Elem().Kind(); t.Name() == "" && ek != reflect.Struct && ek != reflect.Map {
+					return nil, fmt.Errorf("need slice of structs or maps, or pointers to them, for bulk insert, got slice of %s", t.Elem())
+				}
 				if _, ok := typeToValue[t.Elem().Elem()]; t.Name() == "" && ok {
 					return nil, typeAndSliceProvidedError(t, t.Elem().Elem())
 				}
@@ -53,11 +55,15 @@ func ValidateInputs(args []any) (TypeToValue, error) {
 					return nil, fmt.Errorf("cannot use anonymous slice outside bulk insert")
 				}
 			}
+		case reflect.String:
+			if t != IdentType {
+				return nil, fmt.Errorf("need supported value, got %s", k)
+			}
 		default:
 			return nil, fmt.Errorf("need supported value, got %s", k)
 		}
 		if _, ok := typeToValue[t]; ok {
-			return nil, fmt.Errorf("type %q provided more than once", t.Name())
+			return nil, fmt.Errorf("type %q provided more than once", TypeName(t))
 		}
 		typeToValue[t] = v
 	}
@@ -87,20 +93,41 @@ func ValidateOutputs(args []any) (TypeToValue, error) {
 		}
 		t := v.Type()
 		if _, ok := typeToValue[t]; ok {
-			return nil, fmt.Errorf("type %q provided more than once", t.Name())
+			return nil, fmt.Errorf("type %q provided more than once", TypeName(t))
 		}
 		typeToValue[t] = v
 	}
 	return typeToValue, nil
 }
 
+// indirectInput follows the pointers and interfaces wrapping an input argument
+// and returns the value inside. An error is returned if any of them are nil.
+func indirectInput(v reflect.Value) (reflect.Value, error) {
+	for {
+		if err := validateValue(v); err != nil {
+			return reflect.Value{}, err
+		}
+		switch v.Kind() {
+		case reflect.Pointer:
+			v = v.Elem()
+		case reflect.Interface:
+			if v.IsNil() {
+				return reflect.Value{}, fmt.Errorf("got nil argument")
+			}
+			v = v.Elem()
+		default:
+			return v, nil
+		}
+	}
+}
+
 func validateValue(v reflect.Value) error {
 	switch v.Kind() {
 	case reflect.Invalid:
 		return fmt.Errorf("got nil argument")
 	case reflect.Pointer:
 		if v.IsNil() {
-			return fmt.Errorf("got nil pointer to %s", v.Type().Elem().Name())
+			return fmt.Errorf("got nil pointer to %s", TypeName(v.Type().Elem()))
 		}
 	case reflect.Map:
 		if v.IsNil() {
@@ -5,12 +5,19 @@ package typeinfo
 
 import (
 	"database/sql"
+	"database/sql/driver"
 	"fmt"
 	"reflect"
 	"sort"
+	"strings"
+	"time"
 )
 
 var scannerInterface = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+var valuerInterface = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+var bytesType = reflect.TypeOf([]byte(nil))
+var timeType = reflect.TypeOf(time.Time{})
+var rawBytesType = reflect.TypeOf(sql.RawBytes{})
 
 // ValueLocator specifies how to locate a value in a SQLair argument type.
 type ValueLocator interface {
@@ -22,6 +29,21 @@ type ValueLocator interface {
 	// Identifier returns a string that uniquely identifies the ValueLocator in
 	// the query.
 	Identifier() string
+	// Member returns a description of the located value within the argument.
+	Member() Member
+}
+
+// Member describes the value located by a ValueLocator within its argument.
+type Member struct {
+	// Name is the name of the member in SQLair expressions. It is the db tag
+	// of a struct field or the key of a map. It is empty for slices.
+	Name string
+	// Field is the name of the struct field. It is empty if the argument is
+	// not a struct.
+	Field string
+	// Type is the type of the located value. For a slice it is the type of
+	// the slice elements.
+	Type reflect.Type
 }
 
 // Input is a locator for a Go value from SQLair input arguments to be used in
@@ -48,6 +70,8 @@ type Params struct {
 	// ArgTypeUsed is the type of the argument that was used to generate the
 	// params.
 	ArgTypeUsed reflect.Type
+	// Secret is true if the values must not appear in logging output.
+	Secret bool
 }
 
 // newParams generates a new Params struct.
@@ -81,6 +105,9 @@ type Output interface {
 type mapKey struct {
 	name    string
 	mapType reflect.Type
+	// foldCase is true if input values are looked up ignoring the case of
+	// the key when the map does not contain the exact key.
+	foldCase bool
 }
 
 // ArgType returns the type of the map the key is located in.
@@ -95,9 +122,9 @@ func (mk *mapKey) LocateParams(typeToValue TypeToValue) (*Params, error) {
 	var argType reflect.Type
 	var vals []any
 	if m, ok := typeToValue[mk.mapType]; ok {
-		v := m.MapIndex(reflect.ValueOf(mk.name))
-		if v.Kind() == reflect.Invalid {
-			return nil, fmt.Errorf("map %q does not contain key %q", mk.mapType.Name(), mk.name)
+		v, err := mk.index(m)
+		if err != nil {
+			return nil, err
 		}
 		argType = m.Type()
 		vals = append(vals, v.Interface())
@@ -120,9 +147,9 @@ func (mk *mapKey) LocateParams(typeToValue TypeToValue) (*Params, error) {
 			if m.IsNil() {
 				return nil, fmt.Errorf("got nil map in slice of %q at index %d", m.Type().Name(), i)
 			}
-			v := m.MapIndex(reflect.ValueOf(mk.name))
-			if v.Kind() == reflect.Invalid {
-				return nil, fmt.Errorf("map %q does not contain key %q", mk.mapType.Name(), mk.name)
+			v, err := mk.index(m)
+			if err != nil {
+				return nil, err
 			}
 			vals = append(vals, v.Interface())
 		}
@@ -132,6 +159,33 @@ func (mk *mapKey) LocateParams(typeToValue TypeToValue) (*Params, error) {
 	return nil, valueNotFoundError(typeToValue, mk.mapType)
 }
 
+// index returns the value at the key in the map. If foldCase is set and the
+// map does not contain the exact key, a key that differs only in case is used.
+// An error is returned if there is no such key or more than one.
+func (mk *mapKey) index(m reflect.Value) (reflect.Value, error) {
+	v := m.MapIndex(reflect.ValueOf(mk.name))
+	if v.Kind() != reflect.Invalid {
+		return v, nil
+	}
+	if mk.foldCase {
+		var found []string
+		for _, k := range m.MapKeys() {
+			if strings.EqualFold(k.String(), mk.name) {
+				found = append(found, k.String())
+				v = m.MapIndex(k)
+			}
+		}
+		if len(found) > 1 {
+			sort.Strings(found)
+			return reflect.Value{}, fmt.Errorf("map %q contains keys %q and %q matching key %q ignoring case", mk.mapType.Name(), found[0], found[1], mk.name)
+		}
+		if len(found) == 1 {
+			return v, nil
+		}
+	}
+	return reflect.Value{}, fmt.Errorf("map %q does not contain key %q", mk.mapType.Name(), mk.name)
+}
+
 // Desc returns a natural language description of the mapKey for use in error
 // messages.
 func (mk *mapKey) Desc() string {
@@ -144,6 +198,11 @@ func (mk *mapKey) Identifier() string {
 	return mk.mapType.Name() + "." + mk.name
 }
 
+// Member returns the key and the value type of the map.
+func (mk *mapKey) Member() Member {
+	return Member{Name: mk.name, Type: mk.mapType.Elem()}
+}
+
 // LocateScanTarget locates the map specified in mapKey from the provided
 // typeToValue map. It returns a pointer to pass to rows.Scan, and a ScanProxy
 // reference for setting the key value in the map once the pointer has been
@@ -157,6 +216,60 @@ func (mk *mapKey) LocateScanTarget(typeToValue TypeToValue) (any, *ScanProxy, er
 	return scanVal.Addr().Interface(), &ScanProxy{original: m, scan: scanVal, key: reflect.ValueOf(mk.name)}, nil
 }
 
+// MapKeys locates the map of type mapType in typeToValue, or the slice of maps
+// for a bulk insert, and returns its keys in sorted order along with the type
+// of the argument used. For a bulk insert, every map in the slice must contain
+// the same keys.
+func MapKeys(typeToValue TypeToValue, mapType reflect.Type) ([]string, reflect.Type, error) {
+	if m, ok := typeToValue[mapType]; ok {
+		return sortedMapKeys(m), m.Type(), nil
+	}
+	ms, ok := locateBulkType(typeToValue, mapType)
+	if !ok {
+		return nil, nil, valueNotFoundError(typeToValue, mapType)
+	}
+	if ms.Len() == 0 {
+		return nil, nil, fmt.Errorf("got slice of %q with length 0", mapType.Name())
+	}
+	var keys []string
+	for i := 0; i < ms.Len(); i++ {
+		m := ms.Index(i)
+		if m.Kind() == reflect.Pointer {
+			if m.IsNil() {
+				return nil, nil, fmt.Errorf("got nil pointer in slice of %q at index %d", mapType.Name(), i)
+			}
+			m = m.Elem()
+		}
+		if m.IsNil() {
+			return nil, nil, fmt.Errorf("got nil map in slice of %q at index %d", mapType.Name(), i)
+		}
+		mapKeys := sortedMapKeys(m)
+		if i == 0 {
+			keys = mapKeys
+			continue
+		}
+		if len(mapKeys) != len(keys) {
+			return nil, nil, fmt.Errorf("map at index %d in slice of %q has different keys to map at index 0", i, mapType.Name())
+		}
+		for j := range keys {
+			if mapKeys[j] != keys[j] {
+				return nil, nil, fmt.Errorf("map at index %d in slice of %q has different keys to map at index 0", i, mapType.Name())
+			}
+		}
+	}
+	return keys, ms.Type(), nil
+}
+
+// sortedMapKeys returns the keys of a map with string keys in sorted order.
+func sortedMapKeys(m reflect.Value) []string {
+	var keys []string
+	for _, k := range m.MapKeys() {
+		keys = append(keys, k.String())
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 // structField represents reflection information about a field of a particular
 // struct type.
 type structField struct {
@@ -175,6 +288,36 @@ type structField struct {
 	// omitEmpty is true when "omitempty" is
 	// a property of the field's "db" tag.
 	omitEmpty bool
+
+	// primaryKey is true when "pk" is a property of the field's "db" tag.
+	primaryKey bool
+
+	// text is true when "text" is a property of the field's "db" tag. The
+	// field is marshalled to text for inputs and unmarshalled from text for
+	// outputs.
+	text bool
+
+	// json is true when "json" is a property of the field's "db" tag. The
+	// field is encoded as JSON for inputs and decoded from JSON for outputs.
+	json bool
+
+	// notNull is true when "notnull" is a property of the field's "db" tag.
+	// The column is never NULL so results are scanned directly into the
+	// field.
+	notNull bool
+
+	// secret is true when "secret" is a property of the field's "db" tag.
+	// The value of the field is redacted from logging output.
+	secret bool
+
+	// nullValue, if valid, is the value from the "null=" option of the
+	// field's "db" tag. The field is set to it when the column is NULL.
+	nullValue reflect.Value
+
+	// nested is true if the field is in a struct held by a field of
+	// structType, and is accessed with a path such as "Addr.street". The tag
+	// is then the whole path.
+	nested bool
 }
 
 // ArgType returns the type of the struct this field is located in.
@@ -182,6 +325,17 @@ func (f *structField) ArgType() reflect.Type {
 	return f.structType
 }
 
+// FieldIndex returns the index sequence of the struct field located by vl, for
+// use with reflect.Value.FieldByIndex. It returns false if vl does not locate a
+// struct field.
+func FieldIndex(vl ValueLocator) ([]int, bool) {
+	f, ok := vl.(*structField)
+	if !ok {
+		return nil, false
+	}
+	return f.index, true
+}
+
 // LocateParams locates the struct (or slice of structs for a bulk insert) that
 // contains the field in the TypeToValue map. It returns Params containing the
 // value of this field.
@@ -190,30 +344,42 @@ func (f *structField) LocateParams(typeToValue TypeToValue) (*Params, error) {
 	var argType reflect.Type
 	var vals []any
 	if s, ok := typeToValue[f.structType]; ok {
-		val := s.FieldByIndex(f.index)
+		val, err := f.inputField(s)
+		if err != nil {
+			return nil, err
+		}
 		if val.IsZero() && f.omitEmpty {
 			omit = true
 		}
 		argType = s.Type()
-		vals = append(vals, val.Interface())
-		return newParams(vals, omit, false, argType), nil
+		param, err := f.param(val)
+		if err != nil {
+			return nil, err
+		}
+		vals = append(vals, param)
+		params := newParams(vals, omit, false, argType)
+		params.Secret = f.secret
+		return params, nil
 	}
 	if ss, ok := locateBulkType(typeToValue, f.structType); ok {
 		if ss.Len() == 0 {
-			return nil, fmt.Errorf("got slice of %q with length 0", f.structType.Name())
+			return nil, fmt.Errorf("got slice of %q with length 0", TypeName(f.structType))
 		}
 
 		for i := 0; i < ss.Len(); i++ {
 			s := ss.Index(i)
 			if s.Kind() == reflect.Pointer {
 				if s.IsNil() {
-					return nil, fmt.Errorf("got nil pointer in slice of %q at index %d", f.structType.Name(), i)
+					return nil, fmt.Errorf("got nil pointer in slice of %q at index %d", TypeName(f.structType), i)
 				}
 				s = s.Elem()
 			}
 			// The slice has the correct type so there is no need to check the
 			// type of each element.
-			val := s.FieldByIndex(f.index)
+			val, err := f.inputField(s)
+			if err != nil {
+				return nil, err
+			}
 			if f.omitEmpty {
 				// If the omitemtpy flag is present, we expect either all rows to
 				// have a zero value, or all have a none zero value. If we have a
@@ -225,23 +391,53 @@ func (f *structField) LocateParams(typeToValue TypeToValue) (*Params, error) {
 				}
 			}
 			argType = ss.Type()
-			vals = append(vals, val.Interface())
+			param, err := f.param(val)
+			if err != nil {
+				return nil, err
+			}
+			vals = append(vals, param)
 		}
-		return newParams(vals, omit, true, argType), nil
+		params := newParams(vals, omit, true, argType)
+		params.Secret = f.secret
+		return params, nil
 	}
 	return nil, valueNotFoundError(typeToValue, f.structType)
 }
 
+// param returns the query parameter for the value of the field.
+func (f *structField) param(val reflect.Value) (any, error) {
+	if f.json {
+		data, err := marshalJSON(val)
+		if err != nil {
+			return nil, fmt.Errorf("cannot marshal %s to JSON: %s", f.Desc(), err)
+		}
+		return data, nil
+	}
+	if !f.text {
+		return val.Interface(), nil
+	}
+	text, err := marshalText(val)
+	if err != nil {
+		return nil, fmt.Errorf("cannot marshal %s to text: %s", f.Desc(), err)
+	}
+	return text, nil
+}
+
 // Desc returns a natural language description of the struct field for use in
 // error messages.
 func (f *structField) Desc() string {
-	return fmt.Sprintf("tag %q of struct %q", f.tag, f.structType.Name())
+	return fmt.Sprintf("tag %q of struct %q", f.tag, TypeName(f.structType))
 }
 
 // Identifier returns a string that uniquely identifies the struct field in the
 // context of the query.
 func (f *structField) Identifier() string {
-	return f.structType.Name() + "." + f.tag
+	return TypeName(f.structType) + "." + f.tag
+}
+
+// Member returns the tag, name and type of the struct field.
+func (f *structField) Member() Member {
+	return Member{Name: f.tag, Field: f.name, Type: f.structType.FieldByIndex(f.index).Type}
 }
 
 // LocateScanTarget locates the struct specified in structField from the
@@ -253,19 +449,83 @@ func (f *structField) LocateScanTarget(typeToValue TypeToValue) (any, *ScanProxy
 	if !ok {
 		return nil, nil, valueNotFoundError(typeToValue, f.structType)
 	}
-	val := s.FieldByIndex(f.index)
+	val := f.outputField(s)
 	if !val.CanSet() {
-		return nil, nil, fmt.Errorf("internal error: cannot set field %s of struct %s", f.name, f.structType.Name())
+		return nil, nil, fmt.Errorf("internal error: cannot set field %s of struct %s", f.name, TypeName(f.structType))
 	}
 
-	pt := reflect.PointerTo(val.Type())
-	if val.Type().Kind() != reflect.Pointer && !pt.Implements(scannerInterface) {
-		scanVal := reflect.New(pt).Elem()
-		return scanVal.Addr().Interface(), &ScanProxy{original: val, scan: scanVal}, nil
+	switch f.scanKind() {
+	case scanText:
+		ptr, proxy := textScanTarget(val)
+		return ptr, proxy, nil
+	case scanJSON:
+		scanVal := reflect.New(nullStringType).Elem()
+		return scanVal.Addr().Interface(), &ScanProxy{original: val, scan: scanVal, json: true, desc: f.Desc()}, nil
+	case scanConverted:
+		convert, _ := scanConverterFor(val.Type())
+		ptr, proxy := converterScanTarget(val, convert)
+		proxy.null = f.nullValue
+		return ptr, proxy, nil
+	case scanNullable:
+		scanVal := reflect.New(reflect.PointerTo(val.Type())).Elem()
+		return scanVal.Addr().Interface(), &ScanProxy{original: val, scan: scanVal, null: f.nullValue}, nil
 	}
 	return val.Addr().Interface(), nil, nil
 }
 
+// scanKind is the way a result column is scanned into a struct field.
+type scanKind int
+
+const (
+	// scanDirect scans the column into the field itself.
+	scanDirect scanKind = iota
+	// scanNullable scans the column into a pointer to the type of the field,
+	// so that NULL sets the field to its zero value.
+	scanNullable
+	// scanText scans the column through the text marshalling methods of the
+	// field.
+	scanText
+	// scanJSON scans the column as JSON text.
+	scanJSON
+	// scanConverted scans the column through a registered scan converter.
+	scanConverted
+)
+
+// scanKind returns the way a result column is scanned into the field. It is
+// used both by LocateScanTarget and by the FieldScanner, which works it out
+// once for the rows of a query.
+func (f *structField) scanKind() scanKind {
+	t := f.Member().Type
+	switch {
+	case f.text:
+		return scanText
+	case f.json:
+		return scanJSON
+	}
+	if _, ok := scanConverterFor(t); ok {
+		return scanConverted
+	}
+	// sql.RawBytes must be scanned into directly so that database/sql can
+	// point it at the driver's memory rather than copying. A NULL is scanned
+	// as a nil slice so no proxy is needed.
+	if t == rawBytesType {
+		return scanDirect
+	}
+	// A NOT NULL column is scanned directly into the field, avoiding the
+	// allocation of a proxy. database/sql returns an error if it is NULL.
+	if f.notNull {
+		return scanDirect
+	}
+	// Types implementing sql.Scanner, including slices such as the array
+	// types of Postgres drivers, are scanned into directly and handle NULL
+	// themselves. Other values are scanned through a pointer so that NULL
+	// sets them to their zero value.
+	if t.Kind() == reflect.Pointer || reflect.PointerTo(t).Implements(scannerInterface) {
+		return scanDirect
+	}
+	return scanNullable
+}
+
 // slice represents a slice input.
 type slice struct {
 	sliceType reflect.Type
@@ -283,11 +543,27 @@ func (s *slice) Identifier() string {
 	return s.sliceType.Name() + "[:]"
 }
 
+// Member returns the element type of the slice.
+func (s *slice) Member() Member {
+	return Member{Type: s.sliceType.Elem()}
+}
+
 // ArgType is the type of the slice input to extract query parameters from.
 func (s *slice) ArgType() reflect.Type {
 	return s.sliceType
 }
 
+// ProvidedAsSlice returns true if t is not in typeToValue but a slice of t,
+// or of pointers to t, is. This is how the arguments of a bulk insert are
+// provided.
+func ProvidedAsSlice(typeToValue TypeToValue, t reflect.Type) bool {
+	if _, ok := typeToValue[t]; ok {
+		return false
+	}
+	_, ok := locateBulkType(typeToValue, t)
+	return ok
+}
+
 // locateBulkType type looks for a slice of t in typeToValue.
 func locateBulkType(typeToValue TypeToValue, t reflect.Type) (reflect.Value, bool) {
 	if bt, ok := typeToValue[reflect.SliceOf(t)]; ok {
@@ -306,16 +582,118 @@ func (s *slice) LocateParams(typeToValue TypeToValue) (*Params, error) {
 		return nil, valueNotFoundError(typeToValue, s.sliceType)
 	}
 
+	// The element type of a slice with concrete elements is checked when the
+	// slice is located at prepare time, only interface elements are checked
+	// one by one.
+	_, concrete := concreteElemType(s.sliceType)
 	var vals []any
 	for i := 0; i < sv.Len(); i++ {
-		vals = append(vals, sv.Index(i).Interface())
+		v := sv.Index(i)
+		if !concrete {
+			if err := checkSliceElem(v); err != nil {
+				return nil, fmt.Errorf("invalid element at index %d of slice %q: %s", i, PrettyTypeName(s.sliceType), err)
+			}
+		}
+		vals = append(vals, sliceElemParam(v))
 	}
 	return newParams(vals, false, false, s.sliceType), nil
 }
 
+// sliceElemParam returns the query parameter for an element of a slice input.
+// Pointers are dereferenced so that the database is passed the value pointed
+// to, and nil pointers are passed as NULL. Pointers to types that implement
+// driver.Valuer are passed unchanged.
+func sliceElemParam(v reflect.Value) any {
+	for v.Kind() == reflect.Interface || v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil
+		}
+		if v.Kind() == reflect.Pointer && v.Type().Implements(valuerInterface) {
+			break
+		}
+		v = v.Elem()
+	}
+	return v.Interface()
+}
+
+// ResolveValuers replaces the elements of the parameters of a slice input
+// that implement driver.Valuer with the result of calling their Value method.
+// An error identifying the element is returned if Value fails or returns a
+// value that is not a valid driver.Value.
+func ResolveValuers(vals []any, sliceType reflect.Type) ([]any, error) {
+	var resolved []any
+	for i, val := range vals {
+		valuer, ok := val.(driver.Valuer)
+		if !ok {
+			resolved = append(resolved, val)
+			continue
+		}
+		dv, err := valuer.Value()
+		if err != nil {
+			return nil, fmt.Errorf("invalid element at index %d of slice %q: cannot get value of %T: %s", i, PrettyTypeName(sliceType), val, err)
+		}
+		if !driver.IsValue(dv) {
+			return nil, fmt.Errorf("invalid element at index %d of slice %q: Value method of %T returned %T, which is not a valid driver value", i, PrettyTypeName(sliceType), val, dv)
+		}
+		resolved = append(resolved, dv)
+	}
+	return resolved, nil
+}
+
+// checkSliceElem checks that an element of a slice input is a scalar value
+// that can be passed to the database as a query parameter. Slices, arrays,
+// maps and structs are rejected unless they implement driver.Valuer, or are a
+// []byte or time.Time.
+func checkSliceElem(v reflect.Value) error {
+	for v.Kind() == reflect.Interface || v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil
+		}
+		if v.Type().Implements(valuerInterface) {
+			return nil
+		}
+		v = v.Elem()
+	}
+	return checkSliceElemType(v.Type())
+}
+
+// checkSliceElemType checks that values of type t, which is not an interface,
+// are scalar values that can be passed to the database as query parameters.
+func checkSliceElemType(t reflect.Type) error {
+	if t.Implements(valuerInterface) || reflect.PointerTo(t).Implements(valuerInterface) {
+		return nil
+	}
+	switch k := t.Kind(); k {
+	case reflect.Slice, reflect.Array, reflect.Map, reflect.Struct:
+		if t == bytesType || t == timeType {
+			return nil
+		}
+		return fmt.Errorf("slice elements in an IN expression must be scalar values, got %s", k)
+	}
+	return nil
+}
+
+// concreteElemType returns the type of the values of the elements of the
+// slice type, with pointers dereferenced unless they implement driver.Valuer.
+// It returns false if the values can have any type that implements an
+// interface, as with the elements of S.
+func concreteElemType(sliceType reflect.Type) (reflect.Type, bool) {
+	t := sliceType.Elem()
+	for t.Kind() == reflect.Pointer {
+		if t.Implements(valuerInterface) {
+			return t, true
+		}
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.Interface {
+		return nil, false
+	}
+	return t, true
+}
+
 // PrettyTypeName returns a human readable name for slices and pointers.
 func PrettyTypeName(t reflect.Type) string {
-	if t.Name() == "" {
+	if TypeName(t) == "" {
 		switch t.Kind() {
 		case reflect.Slice:
 			return "[]" + PrettyTypeName(t.Elem())
@@ -323,7 +701,7 @@ func PrettyTypeName(t reflect.Type) string {
 			return "*" + PrettyTypeName(t.Elem())
 		}
 	}
-	return t.Name()
+	return TypeName(t)
 }
 
 // valueNotFoundError generates the arguments present and returns a TypeMissingError
@@ -331,8 +709,8 @@ func valueNotFoundError(typeToValue TypeToValue, missingType reflect.Type) error
 	// Get the argument names from typeToValue map.
 	argNames := []string{}
 	for argType := range typeToValue {
-		if argType.Name() == missingType.Name() {
-			return fmt.Errorf("parameter with type %q missing, have type with same name: %q", missingType.String(), argType.String())
+		if TypeName(argType) == TypeName(missingType) {
+			return fmt.Errorf("parameter with type %q missing, have type with same name: %q", FullTypeName(missingType), FullTypeName(argType))
 		}
 		argNames = append(argNames, PrettyTypeName(argType))
 	}
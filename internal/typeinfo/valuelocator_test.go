@@ -4,7 +4,13 @@
 package typeinfo
 
 import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/hex"
+	"fmt"
+	"math"
 	"reflect"
+	"time"
 
 	. "gopkg.in/check.v1"
 )
@@ -93,6 +99,67 @@ func (s *typeInfoSuite) TestLocateScanTargetStruct(c *C) {
 	c.Assert(ptr, FitsTypeOf, (**string)(nil))
 }
 
+func (s *typeInfoSuite) TestLocateScanTargetRawBytes(c *C) {
+	type T struct {
+		Raw sql.RawBytes `db:"raw"`
+	}
+
+	argInfo, err := GenerateArgInfo([]any{T{}})
+	c.Assert(err, IsNil)
+
+	t := T{}
+	typeToValue := map[reflect.Type]reflect.Value{
+		reflect.TypeOf(t): reflect.ValueOf(&t).Elem(),
+	}
+
+	// sql.RawBytes is scanned into directly without a proxy.
+	member, err := argInfo["T"].GetMember("raw")
+	c.Assert(err, IsNil)
+	ptr, scanProxy, err := member.(Output).LocateScanTarget(typeToValue)
+	c.Assert(err, IsNil)
+	c.Assert(scanProxy, IsNil)
+	c.Assert(ptr, Equals, &t.Raw)
+}
+
+// scannerSlice is a slice type that implements sql.Scanner, like the array
+// types of Postgres drivers.
+type scannerSlice []string
+
+func (ss *scannerSlice) Scan(src any) error {
+	return nil
+}
+
+func (s *typeInfoSuite) TestLocateScanTargetSlice(c *C) {
+	type T struct {
+		Array scannerSlice `db:"array"`
+		Tags  []string     `db:"tags"`
+	}
+
+	argInfo, err := GenerateArgInfo([]any{T{}})
+	c.Assert(err, IsNil)
+
+	t := T{}
+	typeToValue := TypeToValue{reflect.TypeOf(t): reflect.ValueOf(&t).Elem()}
+
+	// A slice that implements sql.Scanner is scanned into directly, so the
+	// scanner also handles NULL.
+	member, err := argInfo["T"].GetMember("array")
+	c.Assert(err, IsNil)
+	ptr, proxy, err := member.(Output).LocateScanTarget(typeToValue)
+	c.Assert(err, IsNil)
+	c.Check(proxy, IsNil)
+	c.Check(ptr, Equals, &t.Array)
+
+	// Other slices are scanned through a proxy, so that NULL sets them to
+	// nil.
+	member, err = argInfo["T"].GetMember("tags")
+	c.Assert(err, IsNil)
+	ptr, proxy, err = member.(Output).LocateScanTarget(typeToValue)
+	c.Assert(err, IsNil)
+	c.Check(proxy, NotNil)
+	c.Check(ptr, FitsTypeOf, (**[]string)(nil))
+}
+
 func (s *typeInfoSuite) TestLocateScanTargetError(c *C) {
 	type T struct {
 		Foo string `db:"foo"`
@@ -128,7 +195,7 @@ func (s *typeInfoSuite) TestLocateScanTargetError(c *C) {
 		type M map[string]any
 		typeToValue := map[reflect.Type]reflect.Value{reflect.TypeOf(M{}): reflect.ValueOf(M{})}
 		_, _, err = output.LocateScanTarget(typeToValue)
-		c.Assert(err, ErrorMatches, `parameter with type "typeinfo.M" missing, have type with same name: "typeinfo.M"`)
+		c.Assert(err, ErrorMatches, `parameter with type "github.com/canonical/sqlair/internal/typeinfo.M" missing, have type with same name: "github.com/canonical/sqlair/internal/typeinfo.M"`)
 	}
 }
 
@@ -142,7 +209,10 @@ type TT struct{}
 type S []any
 type Sint []int
 
+type SintPtr []*int
+
 func (s *typeInfoSuite) TestLocateParams(c *C) {
+	one, two := 1, 2
 	tests := []struct {
 		summary      string
 		typeSample   any
@@ -191,6 +261,16 @@ func (s *typeInfoSuite) TestLocateParams(c *C) {
 		expectedOmit: false,
 		expectedBulk: false,
 		expectedVals: []any{1, 2},
+	}, {
+		summary:    "int pointer slice",
+		typeSample: SintPtr{},
+		arg:        SintPtr{&one, nil, &two},
+		input: func(ai map[string]ArgInfo) (ValueLocator, error) {
+			return ai["SintPtr"].GetSlice()
+		},
+		expectedOmit: false,
+		expectedBulk: false,
+		expectedVals: []any{1, nil, 2},
 	}, {
 		summary:    "any slice",
 		typeSample: S{},
@@ -201,6 +281,16 @@ func (s *typeInfoSuite) TestLocateParams(c *C) {
 		expectedOmit: false,
 		expectedBulk: false,
 		expectedVals: []any{1, "two", 3.0},
+	}, {
+		summary:    "any slice with time and nil",
+		typeSample: S{},
+		arg:        S{"one", time.Time{}, nil},
+		input: func(ai map[string]ArgInfo) (ValueLocator, error) {
+			return ai["S"].GetSlice()
+		},
+		expectedOmit: false,
+		expectedBulk: false,
+		expectedVals: []any{"one", time.Time{}, nil},
 	}, {
 		summary:    "empty slice",
 		typeSample: S{},
@@ -314,6 +404,30 @@ func (s *typeInfoSuite) TestLocateParamsError(c *C) {
 			return ai["Sint"].GetSlice()
 		},
 		err: `parameter with type "Sint" missing (have "S")`,
+	}, {
+		summary:    "nested slice in slice",
+		typeSample: S{},
+		arg:        S{1, []int{2, 3}},
+		vl: func(ai map[string]ArgInfo) (ValueLocator, error) {
+			return ai["S"].GetSlice()
+		},
+		err: `invalid element at index 1 of slice "S": slice elements in an IN expression must be scalar values, got slice`,
+	}, {
+		summary:    "struct in slice",
+		typeSample: S{},
+		arg:        S{&TS{}},
+		vl: func(ai map[string]ArgInfo) (ValueLocator, error) {
+			return ai["S"].GetSlice()
+		},
+		err: `invalid element at index 0 of slice "S": slice elements in an IN expression must be scalar values, got struct`,
+	}, {
+		summary:    "map in slice",
+		typeSample: S{},
+		arg:        S{"a", "b", M{}},
+		vl: func(ai map[string]ArgInfo) (ValueLocator, error) {
+			return ai["S"].GetSlice()
+		},
+		err: `invalid element at index 2 of slice "S": slice elements in an IN expression must be scalar values, got map`,
 	}, {
 		summary:    "map bulk insert invalid key",
 		typeSample: M{},
@@ -397,3 +511,441 @@ func (s *typeInfoSuite) TestLocateParamsError(c *C) {
 		c.Check(err.Error(), Equals, t.err)
 	}
 }
+
+func (s *typeInfoSuite) TestCoerceNumeric(c *C) {
+	type T struct {
+		I8  int8    `db:"i8"`
+		I32 int32   `db:"i32"`
+		U16 uint16  `db:"u16"`
+		F32 float32 `db:"f32"`
+		F64 float64 `db:"f64"`
+		P   *int16  `db:"p"`
+		S   string  `db:"s"`
+	}
+	argInfo, err := GenerateArgInfo([]any{T{}})
+	c.Assert(err, IsNil)
+
+	tests := []struct {
+		member   string
+		src      any
+		expected any
+		err      string
+	}{
+		{member: "i32", src: int64(70000), expected: int32(70000)},
+		{member: "i32", src: float64(12), expected: int32(12)},
+		{member: "i32", src: []byte("-5"), expected: int32(-5)},
+		{member: "i32", src: nil, expected: int32(0)},
+		{member: "i8", src: int64(300), err: `cannot coerce int64 value 300 to int8: out of range`},
+		{member: "i8", src: float64(1.5), err: `cannot coerce float64 value 1.5 to int8: not an integer`},
+		{member: "i8", src: "abc", err: `cannot coerce string value "abc" to int8: not a number`},
+		{member: "u16", src: int64(65535), expected: uint16(65535)},
+		{member: "u16", src: int64(-1), err: `cannot coerce int64 value -1 to uint16: out of range`},
+		{member: "f32", src: float64(1.5), expected: float32(1.5)},
+		{member: "f32", src: float64(1e300), err: `cannot coerce float64 value 1e\+300 to float32: out of range`},
+		{member: "f32", src: int64(3), expected: float32(3)},
+		{member: "f32", src: float64(0.1), err: `cannot coerce float64 value 0.1 to float32: cannot be represented exactly`},
+		{member: "f32", src: int64(1<<24 + 1), err: `cannot coerce int64 value 16777217 to float32: cannot be represented exactly`},
+		{member: "f64", src: int64(1 << 53), expected: float64(1 << 53)},
+		{member: "f64", src: int64(1<<53 + 1), err: `cannot coerce int64 value 9007199254740993 to float64: cannot be represented exactly`},
+		{member: "f64", src: int64(math.MaxInt64), err: `cannot coerce int64 value 9223372036854775807 to float64: cannot be represented exactly`},
+		{member: "f64", src: uint64(math.MaxUint64), err: `cannot coerce uint64 value 18446744073709551615 to float64: cannot be represented exactly`},
+		{member: "f64", src: float64(0.1), expected: float64(0.1)},
+		{member: "i32", src: true, err: `cannot coerce bool value to int32`},
+		{member: "p", src: int64(7), expected: int16(7)},
+		{member: "p", src: nil, expected: (*int16)(nil)},
+	}
+	for i, t := range tests {
+		v := T{}
+		typeToValue := TypeToValue{reflect.TypeOf(v): reflect.ValueOf(&v).Elem()}
+		member, err := argInfo["T"].GetMember(t.member)
+		c.Assert(err, IsNil)
+		ptr, proxy, err := member.(Output).LocateScanTarget(typeToValue)
+		c.Assert(err, IsNil)
+		ptr, proxy = CoerceNumeric(ptr, proxy)
+		c.Assert(proxy, NotNil)
+
+		// Simulate rows.Scan.
+		*(ptr.(*any)) = t.src
+		err = proxy.OnSuccess()
+		if t.err != "" {
+			c.Check(err, ErrorMatches, t.err, Commentf("test %d", i))
+			continue
+		}
+		c.Assert(err, IsNil, Commentf("test %d", i))
+		field := typeToValue[reflect.TypeOf(v)].FieldByIndex(member.(*structField).index)
+		if field.Kind() == reflect.Pointer && !field.IsNil() {
+			field = field.Elem()
+		}
+		c.Check(field.Interface(), Equals, t.expected, Commentf("test %d", i))
+	}
+
+	// Non-numeric fields are not changed.
+	v := T{}
+	typeToValue := TypeToValue{reflect.TypeOf(v): reflect.ValueOf(&v).Elem()}
+	member, err := argInfo["T"].GetMember("s")
+	c.Assert(err, IsNil)
+	ptr, proxy, err := member.(Output).LocateScanTarget(typeToValue)
+	c.Assert(err, IsNil)
+	coercedPtr, coercedProxy := CoerceNumeric(ptr, proxy)
+	c.Check(coercedPtr, Equals, ptr)
+	c.Check(coercedProxy, Equals, proxy)
+}
+
+func (s *typeInfoSuite) TestTextBool(c *C) {
+	type T struct {
+		B bool   `db:"b"`
+		P *bool  `db:"p"`
+		S string `db:"s"`
+	}
+	argInfo, err := GenerateArgInfo([]any{T{}})
+	c.Assert(err, IsNil)
+
+	tests := []struct {
+		member   string
+		src      any
+		expected any
+		err      string
+	}{
+		{member: "b", src: "true", expected: true},
+		{member: "b", src: "t", expected: true},
+		{member: "b", src: []byte("YES"), expected: true},
+		{member: "b", src: " on ", expected: true},
+		{member: "b", src: "1", expected: true},
+		{member: "b", src: "false", expected: false},
+		{member: "b", src: "F", expected: false},
+		{member: "b", src: "no", expected: false},
+		{member: "b", src: "off", expected: false},
+		{member: "b", src: "0", expected: false},
+		{member: "b", src: int64(1), expected: true},
+		{member: "b", src: true, expected: true},
+		{member: "b", src: nil, expected: false},
+		{member: "b", src: "maybe", err: `cannot convert string value "maybe" to bool: not a boolean`},
+		{member: "b", src: int64(2), err: `cannot convert int64 value 2 to bool`},
+		{member: "b", src: 1.5, err: `cannot convert float64 value to bool`},
+		{member: "p", src: "t", expected: true},
+		{member: "p", src: nil, expected: (*bool)(nil)},
+	}
+	for i, t := range tests {
+		v := T{}
+		typeToValue := TypeToValue{reflect.TypeOf(v): reflect.ValueOf(&v).Elem()}
+		member, err := argInfo["T"].GetMember(t.member)
+		c.Assert(err, IsNil)
+		ptr, proxy, err := member.(Output).LocateScanTarget(typeToValue)
+		c.Assert(err, IsNil)
+		ptr, proxy = TextBool(ptr, proxy)
+		c.Assert(proxy, NotNil)
+
+		// Simulate rows.Scan.
+		*(ptr.(*any)) = t.src
+		err = proxy.OnSuccess()
+		if t.err != "" {
+			c.Check(err, ErrorMatches, t.err, Commentf("test %d", i))
+			continue
+		}
+		c.Assert(err, IsNil, Commentf("test %d", i))
+		field := typeToValue[reflect.TypeOf(v)].FieldByIndex(member.(*structField).index)
+		if field.Kind() == reflect.Pointer && !field.IsNil() {
+			field = field.Elem()
+		}
+		c.Check(field.Interface(), Equals, t.expected, Commentf("test %d", i))
+	}
+
+	// Non-bool fields are not changed.
+	v := T{}
+	typeToValue := TypeToValue{reflect.TypeOf(v): reflect.ValueOf(&v).Elem()}
+	member, err := argInfo["T"].GetMember("s")
+	c.Assert(err, IsNil)
+	ptr, proxy, err := member.(Output).LocateScanTarget(typeToValue)
+	c.Assert(err, IsNil)
+	textPtr, textProxy := TextBool(ptr, proxy)
+	c.Check(textPtr, Equals, ptr)
+	c.Check(textProxy, Equals, proxy)
+}
+
+// textID is a value type that implements encoding.TextMarshaler and
+// encoding.TextUnmarshaler but not driver.Valuer or sql.Scanner.
+type textID [2]byte
+
+func (id textID) MarshalText() ([]byte, error) {
+	return []byte(hex.EncodeToString(id[:])), nil
+}
+
+func (id *textID) UnmarshalText(text []byte) error {
+	b, err := hex.DecodeString(string(text))
+	if err != nil {
+		return err
+	}
+	if len(b) != len(id) {
+		return fmt.Errorf("need %d bytes, got %d", len(id), len(b))
+	}
+	copy(id[:], b)
+	return nil
+}
+
+func (s *typeInfoSuite) TestTextField(c *C) {
+	type T struct {
+		ID  textID  `db:"id,text"`
+		Ptr *textID `db:"ptr,text"`
+	}
+	argInfo, err := GenerateArgInfo([]any{T{}})
+	c.Assert(err, IsNil)
+
+	// Inputs are marshalled to text.
+	id := textID{0xab, 0x01}
+	typeToValue := TypeToValue{reflect.TypeOf(T{}): reflect.ValueOf(T{ID: id})}
+	member, err := argInfo["T"].GetMember("id")
+	c.Assert(err, IsNil)
+	params, err := member.(Input).LocateParams(typeToValue)
+	c.Assert(err, IsNil)
+	c.Check(params.Vals, DeepEquals, []any{"ab01"})
+
+	ptrMember, err := argInfo["T"].GetMember("ptr")
+	c.Assert(err, IsNil)
+	params, err = ptrMember.(Input).LocateParams(typeToValue)
+	c.Assert(err, IsNil)
+	c.Check(params.Vals, DeepEquals, []any{nil})
+
+	// Outputs are unmarshalled from text.
+	t := T{}
+	typeToValue = TypeToValue{reflect.TypeOf(t): reflect.ValueOf(&t).Elem()}
+	for _, m := range []ValueLocator{member, ptrMember} {
+		ptr, proxy, err := m.(Output).LocateScanTarget(typeToValue)
+		c.Assert(err, IsNil)
+		c.Assert(proxy, NotNil)
+		*ptr.(*sql.NullString) = sql.NullString{String: "ab01", Valid: true}
+		c.Assert(proxy.OnSuccess(), IsNil)
+	}
+	c.Check(t.ID, Equals, id)
+	c.Assert(t.Ptr, NotNil)
+	c.Check(*t.Ptr, Equals, id)
+
+	// NULL sets the zero value.
+	ptr, proxy, err := ptrMember.(Output).LocateScanTarget(typeToValue)
+	c.Assert(err, IsNil)
+	*ptr.(*sql.NullString) = sql.NullString{}
+	c.Assert(proxy.OnSuccess(), IsNil)
+	c.Check(t.Ptr, IsNil)
+
+	// Invalid text returns an error.
+	ptr, proxy, err = member.(Output).LocateScanTarget(typeToValue)
+	c.Assert(err, IsNil)
+	*ptr.(*sql.NullString) = sql.NullString{String: "zz", Valid: true}
+	c.Check(proxy.OnSuccess(), ErrorMatches, `cannot unmarshal "zz" into typeinfo.textID: encoding/hex: invalid byte: U\+007A 'z'`)
+
+	// The type must implement both interfaces.
+	type Bad struct {
+		Name string `db:"name,text"`
+	}
+	_, err = GenerateArgInfo([]any{Bad{}})
+	c.Check(err, ErrorMatches, `field Bad.Name has the text option but its type string does not implement encoding.TextMarshaler and encoding.TextUnmarshaler`)
+}
+
+func (s *typeInfoSuite) TestJSONField(c *C) {
+	type Meta struct {
+		Tags []string `json:"tags"`
+	}
+	type T struct {
+		Meta  Meta           `db:"meta, json"`
+		Attrs map[string]int `db:"attrs,json"`
+		Bad   chan int       `db:"bad,json"`
+	}
+	argInfo, err := GenerateArgInfo([]any{T{}})
+	c.Assert(err, IsNil)
+
+	// Inputs are marshalled to JSON, a nil map is passed as NULL.
+	typeToValue := TypeToValue{reflect.TypeOf(T{}): reflect.ValueOf(T{Meta: Meta{Tags: []string{"a", "b"}}})}
+	member, err := argInfo["T"].GetMember("meta")
+	c.Assert(err, IsNil)
+	params, err := member.(Input).LocateParams(typeToValue)
+	c.Assert(err, IsNil)
+	c.Check(params.Vals, DeepEquals, []any{`{"tags":["a","b"]}`})
+
+	mapMember, err := argInfo["T"].GetMember("attrs")
+	c.Assert(err, IsNil)
+	params, err = mapMember.(Input).LocateParams(typeToValue)
+	c.Assert(err, IsNil)
+	c.Check(params.Vals, DeepEquals, []any{nil})
+
+	// Marshalling errors identify the field.
+	typeToValue = TypeToValue{reflect.TypeOf(T{}): reflect.ValueOf(T{Bad: make(chan int)})}
+	badMember, err := argInfo["T"].GetMember("bad")
+	c.Assert(err, IsNil)
+	_, err = badMember.(Input).LocateParams(typeToValue)
+	c.Check(err, ErrorMatches, `cannot marshal tag "bad" of struct "T" to JSON: json: unsupported type: chan int`)
+
+	// Outputs are unmarshalled from JSON, replacing the old value.
+	t := T{Attrs: map[string]int{"old": 1}}
+	typeToValue = TypeToValue{reflect.TypeOf(t): reflect.ValueOf(&t).Elem()}
+	ptr, proxy, err := member.(Output).LocateScanTarget(typeToValue)
+	c.Assert(err, IsNil)
+	*ptr.(*sql.NullString) = sql.NullString{String: `{"tags":["c"]}`, Valid: true}
+	c.Assert(proxy.OnSuccess(), IsNil)
+	c.Check(t.Meta, DeepEquals, Meta{Tags: []string{"c"}})
+
+	ptr, proxy, err = mapMember.(Output).LocateScanTarget(typeToValue)
+	c.Assert(err, IsNil)
+	*ptr.(*sql.NullString) = sql.NullString{String: `{"new":2}`, Valid: true}
+	c.Assert(proxy.OnSuccess(), IsNil)
+	c.Check(t.Attrs, DeepEquals, map[string]int{"new": 2})
+
+	// NULL sets the zero value.
+	ptr, proxy, err = mapMember.(Output).LocateScanTarget(typeToValue)
+	c.Assert(err, IsNil)
+	*ptr.(*sql.NullString) = sql.NullString{}
+	c.Assert(proxy.OnSuccess(), IsNil)
+	c.Check(t.Attrs, IsNil)
+
+	// Invalid JSON returns an error identifying the field.
+	ptr, proxy, err = member.(Output).LocateScanTarget(typeToValue)
+	c.Assert(err, IsNil)
+	*ptr.(*sql.NullString) = sql.NullString{String: "{", Valid: true}
+	c.Check(proxy.OnSuccess(), ErrorMatches, `cannot unmarshal JSON into tag "meta" of struct "T": unexpected end of JSON input`)
+
+	// A field with the json option is not scanned into directly.
+	_, ok := NewFieldScanner(member.(Output))
+	c.Check(ok, Equals, false)
+}
+
+func (s *typeInfoSuite) TestLocateScanTargetNotNull(c *C) {
+	type T struct {
+		ID   int    `db:"id,notnull"`
+		Name string `db:"name"`
+	}
+	argInfo, err := GenerateArgInfo([]any{T{}})
+	c.Assert(err, IsNil)
+
+	t := T{}
+	typeToValue := TypeToValue{reflect.TypeOf(t): reflect.ValueOf(&t).Elem()}
+
+	// A notnull field is scanned into directly without a proxy.
+	member, err := argInfo["T"].GetMember("id")
+	c.Assert(err, IsNil)
+	ptr, proxy, err := member.(Output).LocateScanTarget(typeToValue)
+	c.Assert(err, IsNil)
+	c.Check(proxy, IsNil)
+	c.Check(ptr, Equals, &t.ID)
+
+	member, err = argInfo["T"].GetMember("name")
+	c.Assert(err, IsNil)
+	_, proxy, err = member.(Output).LocateScanTarget(typeToValue)
+	c.Assert(err, IsNil)
+	c.Check(proxy, NotNil)
+}
+
+func (s *typeInfoSuite) TestLocateParamsSecret(c *C) {
+	type T struct {
+		SSN  string `db:"ssn,secret"`
+		Name string `db:"name"`
+	}
+	argInfo, err := GenerateArgInfo([]any{T{}})
+	c.Assert(err, IsNil)
+
+	t := T{SSN: "123-45-6789", Name: "Fred"}
+	typeToValue := TypeToValue{reflect.TypeOf(t): reflect.ValueOf(t)}
+
+	// The params of a secret field hold the real value.
+	member, err := argInfo["T"].GetMember("ssn")
+	c.Assert(err, IsNil)
+	params, err := member.(Input).LocateParams(typeToValue)
+	c.Assert(err, IsNil)
+	c.Check(params.Vals, DeepEquals, []any{"123-45-6789"})
+	c.Check(params.Secret, Equals, true)
+
+	member, err = argInfo["T"].GetMember("name")
+	c.Assert(err, IsNil)
+	params, err = member.(Input).LocateParams(typeToValue)
+	c.Assert(err, IsNil)
+	c.Check(params.Secret, Equals, false)
+}
+
+// cents is an amount of money in hundredths, converted from the decimal text
+// returned by drivers for NUMERIC columns.
+type cents int64
+
+func (s *typeInfoSuite) TestScanConverter(c *C) {
+	RegisterScanConverter(reflect.TypeOf(cents(0)), func(src any) (any, error) {
+		var text string
+		switch v := src.(type) {
+		case []byte:
+			text = string(v)
+		case string:
+			text = v
+		default:
+			return nil, fmt.Errorf("unexpected type %T", src)
+		}
+		var whole, frac int64
+		if _, err := fmt.Sscanf(text, "%d.%02d", &whole, &frac); err != nil {
+			return nil, err
+		}
+		return cents(whole*100 + frac), nil
+	})
+
+	type T struct {
+		Price cents  `db:"price"`
+		Ptr   *cents `db:"ptr"`
+	}
+	argInfo, err := GenerateArgInfo([]any{T{}})
+	c.Assert(err, IsNil)
+	t := T{}
+	typeToValue := TypeToValue{reflect.TypeOf(t): reflect.ValueOf(&t).Elem()}
+	member, err := argInfo["T"].GetMember("price")
+	c.Assert(err, IsNil)
+	ptrMember, err := argInfo["T"].GetMember("ptr")
+	c.Assert(err, IsNil)
+
+	// The converter receives the raw value scanned from the driver.
+	for _, m := range []ValueLocator{member, ptrMember} {
+		ptr, proxy, err := m.(Output).LocateScanTarget(typeToValue)
+		c.Assert(err, IsNil)
+		c.Assert(proxy, NotNil)
+		*ptr.(*any) = []byte("12.34")
+		c.Assert(proxy.OnSuccess(), IsNil)
+	}
+	c.Check(t.Price, Equals, cents(1234))
+	c.Assert(t.Ptr, NotNil)
+	c.Check(*t.Ptr, Equals, cents(1234))
+
+	// NULL sets the zero value without calling the converter.
+	ptr, proxy, err := ptrMember.(Output).LocateScanTarget(typeToValue)
+	c.Assert(err, IsNil)
+	*ptr.(*any) = nil
+	c.Assert(proxy.OnSuccess(), IsNil)
+	c.Check(t.Ptr, IsNil)
+
+	// Errors from the converter are returned.
+	ptr, proxy, err = member.(Output).LocateScanTarget(typeToValue)
+	c.Assert(err, IsNil)
+	*ptr.(*any) = int64(12)
+	c.Check(proxy.OnSuccess(), ErrorMatches, `cannot convert int64 value to typeinfo.cents: unexpected type int64`)
+
+	// Numeric coercion does not replace the converter.
+	coercedPtr, coercedProxy := CoerceNumeric(ptr, proxy)
+	c.Check(coercedPtr, Equals, ptr)
+	c.Check(coercedProxy, Equals, proxy)
+}
+
+// intValuer is a driver.Valuer that returns its value as a driver value, or
+// as an invalid one if bad is set.
+type intValuer struct {
+	i   int
+	bad bool
+}
+
+func (v intValuer) Value() (driver.Value, error) {
+	if v.bad {
+		return struct{}{}, nil
+	}
+	return int64(v.i), nil
+}
+
+func (s *typeInfoSuite) TestResolveValuers(c *C) {
+	type S []any
+	sliceType := reflect.TypeOf(S{})
+
+	vals, err := ResolveValuers([]any{intValuer{i: 1}, "two", nil, intValuer{i: 3}}, sliceType)
+	c.Assert(err, IsNil)
+	c.Check(vals, DeepEquals, []any{int64(1), "two", nil, int64(3)})
+
+	_, err = ResolveValuers([]any{1, intValuer{bad: true}}, sliceType)
+	c.Check(err, ErrorMatches, `invalid element at index 1 of slice "S": Value method of typeinfo.intValuer returned struct {}, which is not a valid driver value`)
+}
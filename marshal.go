@@ -0,0 +1,125 @@
+// Copyright 2024 Canonical Ltd.
+// Licensed under Apache 2.0, see LICENCE file for details.
+
+package sqlair
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/canonical/sqlair/internal/expr"
+)
+
+// marshalVersion is the version of the format written by Statement.Marshal.
+// It is incremented whenever the format changes in a way that older versions
+// of SQLair cannot read.
+const marshalVersion = 1
+
+// marshalledStatement is the format of a marshalled Statement.
+type marshalledStatement struct {
+	Version             int               `json:"version"`
+	Dialect             *expr.Dialect     `json:"dialect,omitempty"`
+	NullSafeIn          bool              `json:"nullSafeIn,omitempty"`
+	CoerceNumeric       bool              `json:"coerceNumeric,omitempty"`
+	TextBool            bool              `json:"textBool,omitempty"`
+	CaseInsensitiveTags bool              `json:"caseInsensitiveTags,omitempty"`
+	IgnoreUnusedOutputs bool              `json:"ignoreUnusedOutputs,omitempty"`
+	ColumnPrefix        expr.ColumnPrefix `json:"columnPrefix,omitempty"`
+	JSONTypes           []string          `json:"jsonTypes,omitempty"`
+	SecretKeys          []string          `json:"secretKeys,omitempty"`
+	Strict              bool              `json:"strict,omitempty"`
+	TagName             string            `json:"tagName,omitempty"`
+	ResolveValuers      bool              `json:"resolveValuers,omitempty"`
+	IntegerLimits       bool              `json:"integerLimits,omitempty"`
+	OmitZeroPK          bool              `json:"omitZeroPK,omitempty"`
+	Expr                *expr.ParsedExpr  `json:"expr"`
+}
+
+// Marshal encodes the Statement so that it can be stored, for example by a
+// code generator, and turned back into a Statement with [UnmarshalStatement]
+// without parsing the query again.
+//
+// The encoding is a JSON object containing a format version, the
+// [PrepareOption] values passed to [Prepare] and the parsed SQLair
+// expressions of the query. Types are recorded by name only. The type samples
+// must be passed again to UnmarshalStatement.
+func (s *Statement) Marshal() ([]byte, error) {
+	if s.pe == nil {
+		return nil, fmt.Errorf("cannot marshal statement: statement not created with Prepare")
+	}
+	var jsonTypes []string
+	for name := range s.bindOpts.JSONTypes {
+		jsonTypes = append(jsonTypes, name)
+	}
+	sort.Strings(jsonTypes)
+	var secretKeys []string
+	for key := range s.bindOpts.SecretKeys {
+		secretKeys = append(secretKeys, key)
+	}
+	sort.Strings(secretKeys)
+	ms := marshalledStatement{
+		Version:             marshalVersion,
+		Dialect:             s.dialect,
+		NullSafeIn:          s.bindOpts.NullSafeIn,
+		CoerceNumeric:       s.bindOpts.CoerceNumeric,
+		TextBool:            s.bindOpts.TextBool,
+		CaseInsensitiveTags: s.bindOpts.CaseInsensitiveTags,
+		IgnoreUnusedOutputs: s.ignoreUnusedOutputs,
+		ColumnPrefix:        s.bindOpts.ColumnPrefix,
+		JSONTypes:           jsonTypes,
+		SecretKeys:          secretKeys,
+		Strict:              s.bindOpts.Strict,
+		TagName:             s.bindOpts.TagName,
+		ResolveValuers:      s.bindOpts.ResolveValuers,
+		IntegerLimits:       s.bindOpts.IntegerLimits,
+		OmitZeroPK:          s.bindOpts.OmitZeroPK,
+		Expr:                s.pe,
+	}
+	data, err := json.Marshal(ms)
+	if err != nil {
+		return nil, fmt.Errorf("cannot marshal statement: %s", err)
+	}
+	return data, nil
+}
+
+// UnmarshalStatement creates a [Statement] from data encoded with
+// [Statement.Marshal]. The query is not parsed again but the type samples are
+// bound to it as in [Prepare]. The types are matched to the SQLair expressions
+// by name, so a sample of every type in the original query must be passed. The
+// types are checked again, so an error is returned if a type has changed in a
+// way that makes the statement invalid.
+//
+// The options recorded in the data are applied first. Any [PrepareOption]
+// passed along with the type samples is applied after them.
+func UnmarshalStatement(data []byte, typeSamples ...any) (*Statement, error) {
+	var ms marshalledStatement
+	if err := json.Unmarshal(data, &ms); err != nil {
+		return nil, fmt.Errorf("cannot unmarshal statement: %s", err)
+	}
+	if ms.Version != marshalVersion {
+		return nil, fmt.Errorf("cannot unmarshal statement: unsupported version %d, expected %d", ms.Version, marshalVersion)
+	}
+	if ms.Expr == nil {
+		return nil, fmt.Errorf("cannot unmarshal statement: missing expression")
+	}
+
+	opts := prepareOptions{
+		dialect:             ms.Dialect,
+		nullSafeIn:          ms.NullSafeIn,
+		coerceNumeric:       ms.CoerceNumeric,
+		textBool:            ms.TextBool,
+		caseInsensitiveTags: ms.CaseInsensitiveTags,
+		ignoreUnusedOutputs: ms.IgnoreUnusedOutputs,
+		columnPrefix:        ms.ColumnPrefix,
+		jsonTypes:           ms.JSONTypes,
+		secretKeys:          ms.SecretKeys,
+		strict:              ms.Strict,
+		tagName:             ms.TagName,
+		resolveValuers:      ms.ResolveValuers,
+		integerLimits:       ms.IntegerLimits,
+		omitZeroPK:          ms.OmitZeroPK,
+	}
+	samples := applyPrepareOptions(&opts, typeSamples)
+	return bindStatement(ms.Expr, opts, samples)
+}
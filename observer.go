@@ -0,0 +1,97 @@
+// Copyright 2024 Canonical Ltd.
+// Licensed under Apache 2.0, see LICENCE file for details.
+
+package sqlair
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// Observer is notified before and after each query run on a [DB], including
+// queries run on a [Conn] or [TX] of the DB. It can be used to log queries or
+// to record them in a tracing system.
+type Observer interface {
+	// BeforeQuery is called before the query is sent to the database with
+	// the SQL generated by SQLair and the query arguments. The values of
+	// secret struct fields and map keys are redacted from args as in
+	// [Query.DebugParams]. The returned context is used to run the query and
+	// is passed to AfterQuery, so it can carry a tracing span.
+	BeforeQuery(ctx context.Context, sql string, args []any) context.Context
+	// AfterQuery is called once the query is finished with the error it
+	// returned, or nil. For a query without output expressions rowsAffected
+	// is the number of rows affected, or -1 if the driver does not report
+	// it. For a query that returns rows it is the number of rows read, and
+	// AfterQuery is called when the rows are closed.
+	AfterQuery(ctx context.Context, err error, rowsAffected int64)
+}
+
+// observer is a DBOption that sets the Observer of the database.
+type observer struct {
+	obs Observer
+}
+
+// applyToDB sets the observer of the database.
+func (o observer) applyToDB(db *DB) {
+	db.observer = o.obs
+}
+
+// WithObserver returns a [DBOption] that calls the methods of obs before and
+// after each query run on the database. A panic in obs is recovered and
+// returned as an error from the query. If BeforeQuery panics the query is not
+// run. If AfterQuery panics the error is returned unless the query itself
+// failed, in which case the error of the query is returned.
+func WithObserver(obs Observer) DBOption {
+	return observer{obs: obs}
+}
+
+// observe calls BeforeQuery on the observer of the query, if it has one, and
+// returns the context to run the query with and a function to call when the
+// query is finished. The function is nil if there is no observer. It returns
+// an error if BeforeQuery panics, and the function returns an error if
+// AfterQuery panics.
+func (q *Query) observe(ctx context.Context) (context.Context, func(err error, rowsAffected int64) error, error) {
+	if q.observer == nil {
+		return ctx, nil, nil
+	}
+	ctx, err := beforeQuery(q.observer, ctx, q.pq.SQL(), q.DebugParams())
+	if err != nil {
+		return nil, nil, err
+	}
+	return ctx, func(err error, rowsAffected int64) (panicErr error) {
+		defer func() {
+			if r := recover(); r != nil {
+				panicErr = fmt.Errorf("observer panicked in AfterQuery: %v", r)
+			}
+		}()
+		q.observer.AfterQuery(ctx, err, rowsAffected)
+		return nil
+	}, nil
+}
+
+// beforeQuery calls BeforeQuery on obs and returns the context it returns. If
+// it returns nil, ctx is returned. If it panics, an error is returned.
+func beforeQuery(obs Observer, ctx context.Context, sql string, args []any) (observedCtx context.Context, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			observedCtx, err = nil, fmt.Errorf("observer panicked in BeforeQuery: %v", r)
+		} else if observedCtx == nil {
+			observedCtx = ctx
+		}
+	}()
+	return obs.BeforeQuery(ctx, sql, args), nil
+}
+
+// rowsAffected returns the number of rows affected by a query, or -1 if it is
+// not known.
+func rowsAffected(result sql.Result) int64 {
+	if result == nil {
+		return -1
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return -1
+	}
+	return n
+}
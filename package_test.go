@@ -9,7 +9,11 @@ import (
 	"database/sql/driver"
 	"errors"
 	"fmt"
+	"reflect"
+	"sort"
 	"strconv"
+	"strings"
+	"time"
 
 	_ "github.com/mattn/go-sqlite3"
 	. "gopkg.in/check.v1"
@@ -409,14 +413,6 @@ func (s *PackageSuite) TestIterGetErrors(c *C) {
 		inputs:  []any{},
 		outputs: []any{&Person{}, &Address{}},
 		err:     `cannot get result: "Address" not referenced in query`,
-	}, {
-		summary: "output expr in a with clause",
-		query: `WITH averageID(avgid) AS (SELECT &Person.id FROM person)
-		        SELECT id FROM person, averageID WHERE id > averageID.avgid LIMIT 1`,
-		types:   []any{Person{}},
-		inputs:  []any{},
-		outputs: []any{&Person{}},
-		err:     `cannot get result: column\(s\) for output "&Person" not found in query results`,
 	}}
 
 	db, tables := s.personAndAddressDB(c)
@@ -923,6 +919,14 @@ func (s *PackageSuite) TestErrNoRows(c *C) {
 	c.Check(errors.Is(err, sql.ErrNoRows), Equals, true)
 }
 
+// SentinelPerson reads NULL columns as the values of the null tag option.
+type SentinelPerson struct {
+	ID       int    `db:"id,null=-1"`
+	Name     string `db:"name,null=none"`
+	Postcode int64  `db:"address_id,null=-1"`
+	Email    string `db:"email,null=none"`
+}
+
 func (s *PackageSuite) TestNulls(c *C) {
 	type I int
 	type J = int
@@ -978,6 +982,13 @@ func (s *PackageSuite) TestNulls(c *C) {
 		inputs:   []any{},
 		outputs:  []any{&ScannerDude{}},
 		expected: []any{&ScannerDude{Name: ScannerValuerString{S: "ScannerString scanned well!"}, ID: ScannerValuerInt{F: 666}, Postcode: ScannerValuerInt{F: 666}}},
+	}, {
+		summary:  "nulls with null option",
+		query:    `SELECT &SentinelPerson.* FROM person WHERE name = "Nully"`,
+		types:    []any{SentinelPerson{}},
+		inputs:   []any{},
+		outputs:  []any{&SentinelPerson{}},
+		expected: []any{&SentinelPerson{Name: "Nully", ID: -1, Postcode: -1, Email: "none"}},
 	}}
 
 	db, tables := s.personAndAddressDB(c)
@@ -1213,7 +1224,9 @@ func (s *PackageSuite) TestIterMethodOrder(c *C) {
 		c.Fatal("expected true, got false")
 	}
 	err = iter.Get(&p)
-	c.Assert(err, ErrorMatches, `cannot get result: sql: Scan error on column index 0, name "_sqlair_0": converting driver.Value type string \("Fred"\) to a int: invalid syntax`)
+	c.Assert(err, ErrorMatches, `cannot get result: sql: Scan error on column index 0, name "_sqlair_0": converting driver.Value type string \("Fred"\) to a int: invalid syntax
+expected columns: _sqlair_0 \(&Person.id\)
+result columns: _sqlair_0`)
 	err = iter.Close()
 	c.Assert(err, IsNil)
 }
@@ -1363,3 +1376,2772 @@ func (s *PackageSuite) TestScannerValuerInterfaces(c *C) {
 	c.Assert(err, IsNil)
 	c.Check(svs, DeepEquals, ScannerValuerStruct{ScannerValuerInt: &ScannerValuerInt{F: 1000}})
 }
+
+func (s *PackageSuite) TestEach(c *C) {
+	db, tables := s.personAndAddressDB(c)
+	defer dropTables(c, db, tables...)
+
+	stmt := sqlair.MustPrepare("SELECT &Person.* FROM person", Person{})
+
+	// Iterate over every row.
+	var people []Person
+	err := db.Query(nil, stmt).Each(nil, func(iter *sqlair.Iterator) error {
+		var p Person
+		if err := iter.Get(&p); err != nil {
+			return err
+		}
+		people = append(people, p)
+		return nil
+	})
+	c.Assert(err, IsNil)
+	c.Check(people, DeepEquals, allPeople)
+
+	// Stop early when the callback returns an error.
+	stopErr := errors.New("stop")
+	rows := 0
+	err = db.Query(nil, stmt).Each(context.Background(), func(iter *sqlair.Iterator) error {
+		rows++
+		if rows == 2 {
+			return stopErr
+		}
+		return nil
+	})
+	c.Assert(err, Equals, stopErr)
+	c.Check(rows, Equals, 2)
+
+	// Errors from Get are returned from Each.
+	err = db.Query(nil, stmt).Each(nil, func(iter *sqlair.Iterator) error {
+		return iter.Get(&Address{})
+	})
+	c.Assert(err, ErrorMatches, `cannot get result: parameter with type "Person" missing \(have "Address"\)`)
+
+	// Errors from the query are returned from Each.
+	selectStmt := sqlair.MustPrepare("SELECT &Person.* FROM person WHERE id = $Person.id", Person{})
+	err = db.Query(nil, selectStmt).Each(nil, func(iter *sqlair.Iterator) error {
+		c.Fatal("callback should not be called")
+		return nil
+	})
+	c.Assert(err, ErrorMatches, `invalid input parameter: parameter with type "Person" missing`)
+}
+
+func (s *PackageSuite) TestUnmappedColumns(c *C) {
+	db, tables := s.personAndAddressDB(c)
+	defer dropTables(c, db, tables...)
+
+	// Columns that are not read into an output expression can appear anywhere
+	// in the results, can be NULL and can have any type. They are discarded.
+	stmt := sqlair.MustPrepare(`
+		SELECT email, *, &Person.*, 1.5, x'ff', NULL, name
+		FROM   person
+		WHERE  id = $Person.id`,
+		Person{},
+	)
+	var p Person
+	err := db.Query(nil, stmt, fred).Get(&p)
+	c.Assert(err, IsNil)
+	c.Check(p, Equals, fred)
+
+	var people []Person
+	err = db.Query(nil, sqlair.MustPrepare("SELECT *, &Person.*, * FROM person", Person{})).GetAll(&people)
+	c.Assert(err, IsNil)
+	c.Check(people, DeepEquals, allPeople)
+}
+
+func (s *PackageSuite) TestUpdateSetMap(c *C) {
+	db, tables := s.personAndAddressDB(c)
+	defer dropTables(c, db, tables...)
+
+	stmt := sqlair.MustPrepare("UPDATE person SET (* EXCEPT (id)) = ($M.*) WHERE id = $M.id", sqlair.M{})
+	selectStmt := sqlair.MustPrepare("SELECT &Person.* FROM person WHERE id = $Person.id", Person{})
+
+	// Only the columns for the keys in the map are updated.
+	err := db.Query(nil, stmt, sqlair.M{"id": fred.ID, "name": "Frederick"}).Run()
+	c.Assert(err, IsNil)
+	var p Person
+	err = db.Query(nil, selectStmt, fred).Get(&p)
+	c.Assert(err, IsNil)
+	c.Check(p, Equals, Person{ID: fred.ID, Name: "Frederick", Postcode: fred.Postcode})
+
+	// A different set of keys generates different SQL from the same statement.
+	err = db.Query(nil, stmt, sqlair.M{"id": fred.ID, "address_id": 9999}).Run()
+	c.Assert(err, IsNil)
+	err = db.Query(nil, selectStmt, fred).Get(&p)
+	c.Assert(err, IsNil)
+	c.Check(p, Equals, Person{ID: fred.ID, Name: "Frederick", Postcode: 9999})
+}
+
+func (s *PackageSuite) TestDynamicOrderBy(c *C) {
+	db, tables := s.personAndAddressDB(c)
+	defer dropTables(c, db, tables...)
+
+	type Sort struct {
+		Column    sqlair.Ident         `db:"column"`
+		Direction sqlair.SortDirection `db:"direction"`
+	}
+	stmt := sqlair.MustPrepare("SELECT &Person.* FROM person ORDER BY $Sort.column $Sort.direction", Person{}, Sort{})
+
+	var people []Person
+	err := db.Query(nil, stmt, Sort{Column: "name", Direction: sqlair.Descending}).GetAll(&people)
+	c.Assert(err, IsNil)
+	c.Check(people, DeepEquals, []Person{mary, mark, fred, dave})
+
+	people = nil
+	err = db.Query(nil, stmt, Sort{Column: "id", Direction: "asc"}).GetAll(&people)
+	c.Assert(err, IsNil)
+	c.Check(people, DeepEquals, []Person{mark, fred, dave, mary})
+
+	err = db.Query(nil, stmt, Sort{Column: "id", Direction: "sideways"}).GetAll(&people)
+	c.Assert(err, ErrorMatches, `invalid input parameter: tag "direction" of struct "Sort": invalid sort direction "sideways", expected ASC or DESC`)
+}
+
+func (s *PackageSuite) TestDo(c *C) {
+	db, tables := s.personAndAddressDB(c)
+	defer dropTables(c, db, tables...)
+
+	stmt := sqlair.MustPrepare("SELECT &Person.* FROM person", Person{})
+
+	// Rows are scanned into structs.
+	var people []Person
+	err := sqlair.Do(nil, db.Query(nil, stmt), func(p Person) error {
+		people = append(people, p)
+		return nil
+	})
+	c.Assert(err, IsNil)
+	c.Check(people, DeepEquals, allPeople)
+
+	// A new value is allocated for each row for pointer types.
+	var ptrs []*Person
+	err = sqlair.Do(context.Background(), db.Query(nil, stmt), func(p *Person) error {
+		ptrs = append(ptrs, p)
+		return nil
+	})
+	c.Assert(err, IsNil)
+	c.Assert(ptrs, HasLen, len(allPeople))
+	for i, p := range ptrs {
+		c.Check(*p, DeepEquals, allPeople[i])
+	}
+
+	// Maps are made for each row.
+	mapStmt := sqlair.MustPrepare("SELECT (name, id) AS (&M.*) FROM person", sqlair.M{})
+	var names []any
+	err = sqlair.Do(nil, db.Query(nil, mapStmt), func(m sqlair.M) error {
+		names = append(names, m["name"])
+		return nil
+	})
+	c.Assert(err, IsNil)
+	c.Check(names, DeepEquals, []any{"Fred", "Mark", "Mary", "Dave"})
+
+	// Stop early when the callback returns an error.
+	stopErr := errors.New("stop")
+	rows := 0
+	err = sqlair.Do(nil, db.Query(nil, stmt), func(p Person) error {
+		rows++
+		if rows == 2 {
+			return stopErr
+		}
+		return nil
+	})
+	c.Assert(err, Equals, stopErr)
+	c.Check(rows, Equals, 2)
+
+	// The type must match the output type of the query.
+	err = sqlair.Do(nil, db.Query(nil, stmt), func(a Address) error {
+		c.Fatal("callback should not be called")
+		return nil
+	})
+	c.Assert(err, ErrorMatches, `cannot get result: parameter with type "Person" missing \(have "Address"\)`)
+}
+
+func (s *PackageSuite) TestLabelledOutputs(c *C) {
+	db, tables := s.personAndAddressDB(c)
+	defer dropTables(c, db, tables...)
+
+	stmt := sqlair.MustPrepare(`
+		SELECT a.* AS &Person:first.*, b.* AS &Person:second.*
+		FROM person AS a JOIN person AS b ON a.id < b.id
+		WHERE a.id = $Person.id
+		ORDER BY b.id`,
+		Person{},
+	)
+
+	// Get the first row.
+	var first, second Person
+	err := db.Query(nil, stmt, fred).Get(sqlair.Labelled("first", &first), sqlair.Labelled("second", &second))
+	c.Assert(err, IsNil)
+	c.Check(first, DeepEquals, fred)
+	c.Check(second, DeepEquals, dave)
+
+	// Iterate over the rows.
+	var seconds []Person
+	iter := db.Query(nil, stmt, fred).Iter()
+	for iter.Next() {
+		var p1, p2 Person
+		err := iter.Get(sqlair.Labelled("second", &p2), sqlair.Labelled("first", &p1))
+		c.Assert(err, IsNil)
+		c.Check(p1, DeepEquals, fred)
+		seconds = append(seconds, p2)
+	}
+	c.Assert(iter.Close(), IsNil)
+	c.Check(seconds, DeepEquals, []Person{dave, mary})
+
+	// Get all the rows.
+	var firsts []Person
+	var secondPtrs []*Person
+	err = db.Query(nil, stmt, fred).GetAll(sqlair.Labelled("first", &firsts), sqlair.Labelled("second", &secondPtrs))
+	c.Assert(err, IsNil)
+	c.Check(firsts, DeepEquals, []Person{fred, fred})
+	c.Check(secondPtrs, DeepEquals, []*Person{&dave, &mary})
+
+	// Unlabelled arguments do not match labelled outputs.
+	err = db.Query(nil, stmt, fred).Get(&first)
+	c.Assert(err, ErrorMatches, `cannot get result: no output argument labelled "first"`)
+}
+
+func (s *PackageSuite) TestIdent(c *C) {
+	db, tables := s.personAndAddressDB(c)
+	defer dropTables(c, db, tables...)
+
+	stmt := sqlair.MustPrepare("SELECT &Person.* FROM $Ident.name WHERE id = $Person.id", sqlair.Ident(""), Person{})
+
+	var p Person
+	err := db.Query(nil, stmt, sqlair.Ident("person"), fred).Get(&p)
+	c.Assert(err, IsNil)
+	c.Check(p, DeepEquals, fred)
+
+	err = db.Query(nil, stmt, sqlair.Ident(`"person"`), mark).Get(&p)
+	c.Assert(err, IsNil)
+	c.Check(p, DeepEquals, mark)
+
+	err = db.Query(nil, stmt, sqlair.Ident("person; DROP TABLE person; --"), fred).Get(&p)
+	c.Assert(err, ErrorMatches, `invalid input parameter: invalid identifier "person; DROP TABLE person; --"`)
+}
+
+func (s *PackageSuite) TestRunAffected(c *C) {
+	db, tables := s.personAndAddressDB(c)
+	defer dropTables(c, db, tables...)
+
+	stmt := sqlair.MustPrepare("UPDATE person SET name = 'Joe' WHERE address_id > $Person.address_id", Person{})
+
+	affected, err := db.Query(nil, stmt, Person{Postcode: 1000}).RunAffected(nil)
+	c.Assert(err, IsNil)
+	c.Check(affected, Equals, int64(3))
+
+	affected, err = db.Query(context.Background(), stmt, Person{Postcode: 9999}).RunAffected(nil)
+	c.Assert(err, IsNil)
+	c.Check(affected, Equals, int64(0))
+
+	// Queries with outputs are rejected.
+	selectStmt := sqlair.MustPrepare("SELECT &Person.* FROM person", Person{})
+	_, err = db.Query(nil, selectStmt).RunAffected(nil)
+	c.Assert(err, ErrorMatches, "cannot get rows affected: query contains output expressions")
+
+	// Errors from binding inputs are returned.
+	_, err = db.Query(nil, stmt).RunAffected(nil)
+	c.Assert(err, ErrorMatches, `invalid input parameter: parameter with type "Person" missing`)
+}
+
+func (s *PackageSuite) TestRawBytes(c *C) {
+	db, tables := s.personAndAddressDB(c)
+	defer dropTables(c, db, tables...)
+
+	type RawPerson struct {
+		ID   int          `db:"id"`
+		Name sql.RawBytes `db:"name"`
+	}
+	stmt := sqlair.MustPrepare("SELECT &RawPerson.* FROM person ORDER BY id", RawPerson{})
+
+	// The contents of sql.RawBytes is only valid until the next call to Next,
+	// so it must be copied.
+	var names []string
+	iter := db.Query(nil, stmt).Iter()
+	for iter.Next() {
+		var p RawPerson
+		c.Assert(iter.Get(&p), IsNil)
+		names = append(names, string(p.Name))
+	}
+	c.Assert(iter.Close(), IsNil)
+	c.Check(names, DeepEquals, []string{"Mark", "Fred", "Dave", "Mary"})
+
+	// Helpers that close the rows copy the contents.
+	var people []RawPerson
+	c.Assert(db.Query(nil, stmt).GetAll(&people), IsNil)
+	names = nil
+	for _, p := range people {
+		names = append(names, string(p.Name))
+	}
+	c.Check(names, DeepEquals, []string{"Mark", "Fred", "Dave", "Mary"})
+
+	var p RawPerson
+	c.Assert(db.Query(nil, stmt).Get(&p), IsNil)
+	c.Check(string(p.Name), Equals, "Mark")
+
+	var kept []RawPerson
+	err := sqlair.Do(nil, db.Query(nil, stmt), func(p RawPerson) error {
+		kept = append(kept, p)
+		return nil
+	})
+	c.Assert(err, IsNil)
+	names = nil
+	for _, p := range kept {
+		names = append(names, string(p.Name))
+	}
+	c.Check(names, DeepEquals, []string{"Mark", "Fred", "Dave", "Mary"})
+}
+
+func (s *PackageSuite) TestInsertReturningPK(c *C) {
+	type Pet struct {
+		ID   int    `db:"id,pk"`
+		Name string `db:"name"`
+	}
+	db := sqlair.NewDB(s.db)
+	createPet := sqlair.MustPrepare("CREATE TABLE pet (id integer PRIMARY KEY AUTOINCREMENT, name text)")
+	c.Assert(db.Query(nil, createPet).Run(), IsNil)
+	defer dropTables(c, db, "pet")
+
+	stmt, err := sqlair.InsertReturningPK("pet", Pet{})
+	c.Assert(err, IsNil)
+
+	// The primary key is generated by the database.
+	rex := Pet{Name: "Rex"}
+	c.Assert(db.Query(nil, stmt, &rex).Get(&rex), IsNil)
+	c.Check(rex, Equals, Pet{ID: 1, Name: "Rex"})
+
+	tom := Pet{Name: "Tom"}
+	c.Assert(db.Query(nil, stmt, &tom).Get(&tom), IsNil)
+	c.Check(tom, Equals, Pet{ID: 2, Name: "Tom"})
+
+	// A non-zero primary key is inserted.
+	fido := Pet{ID: 10, Name: "Fido"}
+	c.Assert(db.Query(nil, stmt, &fido).Get(&fido), IsNil)
+	c.Check(fido, Equals, Pet{ID: 10, Name: "Fido"})
+
+	// The zero primary key is still omitted after marshalling the statement.
+	data, err := stmt.Marshal()
+	c.Assert(err, IsNil)
+	unmarshalled, err := sqlair.UnmarshalStatement(data, Pet{})
+	c.Assert(err, IsNil)
+	rover := Pet{Name: "Rover"}
+	c.Assert(db.Query(nil, unmarshalled, &rover).Get(&rover), IsNil)
+	c.Check(rover, Equals, Pet{ID: 11, Name: "Rover"})
+
+	// A zero primary key is only omitted by InsertReturningPK. Other
+	// statements use it like any other value.
+	bulkStmt := sqlair.MustPrepare("INSERT INTO pet (*) VALUES ($Pet.*)", Pet{})
+	c.Assert(db.Query(nil, bulkStmt, []Pet{{ID: 0, Name: "Zero"}}).Run(), IsNil)
+	updateStmt := sqlair.MustPrepare("UPDATE pet SET name = $Pet.name WHERE id = $Pet.id", Pet{})
+	c.Assert(db.Query(nil, updateStmt, Pet{ID: 0, Name: "Nil"}).Run(), IsNil)
+	selectStmt := sqlair.MustPrepare("SELECT &Pet.* FROM pet WHERE id = $Pet.id", Pet{})
+	var zero Pet
+	c.Assert(db.Query(nil, selectStmt, Pet{}).Get(&zero), IsNil)
+	c.Check(zero, Equals, Pet{ID: 0, Name: "Nil"})
+
+	// An anonymous struct is referred to by the name given with Named.
+	type petRow = struct {
+		ID   int    `db:"id,pk"`
+		Name string `db:"name"`
+	}
+	namedStmt, err := sqlair.InsertReturningPK("pet", sqlair.Named("PetRow", petRow{}))
+	c.Assert(err, IsNil)
+	spot := petRow{Name: "Spot"}
+	c.Assert(db.Query(nil, namedStmt, &spot).Get(&spot), IsNil)
+	c.Check(spot, Equals, petRow{ID: 12, Name: "Spot"})
+
+	type NoPK struct {
+		ID int `db:"id"`
+	}
+	_, err = sqlair.InsertReturningPK("pet", NoPK{})
+	c.Assert(err, ErrorMatches, `cannot prepare insert statement: struct "NoPK" has no field with the "pk" option in its db tag`)
+
+	type TwoPKs struct {
+		ID1 int `db:"id1,pk"`
+		ID2 int `db:"id2,pk"`
+	}
+	_, err = sqlair.InsertReturningPK("pet", TwoPKs{})
+	c.Assert(err, ErrorMatches, `cannot prepare insert statement: struct "TwoPKs" has more than one primary key field`)
+
+	_, err = sqlair.InsertReturningPK("pet; DROP TABLE pet", Pet{})
+	c.Assert(err, ErrorMatches, `cannot prepare insert statement: table name: invalid identifier "pet; DROP TABLE pet"`)
+
+	_, err = sqlair.InsertReturningPK("pet", sqlair.M{})
+	c.Assert(err, ErrorMatches, `cannot prepare insert statement: need struct, got map`)
+}
+
+func (s *PackageSuite) TestCheckSyntax(c *C) {
+	c.Check(sqlair.CheckSyntax("SELECT &Person.* FROM person WHERE id = $Person.id"), IsNil)
+
+	errs := sqlair.CheckSyntax("SELECT &Person.* FROM person WHERE id = $Person.* OR name = $Person.*")
+	c.Assert(errs, HasLen, 2)
+	c.Check(errs[0], ErrorMatches, `column 41: invalid asterisk placement in input "\$Person.\*"`)
+	c.Check(errs[1], ErrorMatches, `column 61: invalid asterisk placement in input "\$Person.\*"`)
+	parseErr, ok := errs[1].(*sqlair.ParseError)
+	c.Assert(ok, Equals, true)
+	c.Check(parseErr.Column, Equals, 61)
+
+	// A type marked as JSON can be used without a member.
+	type Doc struct {
+		Title string `json:"title"`
+	}
+	query := "INSERT INTO t (data) VALUES ($Doc)"
+	errs = sqlair.CheckSyntax(query)
+	c.Assert(errs, HasLen, 1)
+	c.Check(errs[0], ErrorMatches, `column 30: unqualified type, expected Doc.* or Doc.<db tag> or Doc\[:\], or a type marked as JSON`)
+	c.Check(sqlair.CheckSyntax(query, sqlair.JSON(Doc{})), IsNil)
+}
+
+func (s *PackageSuite) TestNullSafeIn(c *C) {
+	db, tables := s.personAndAddressDB(c)
+	defer dropTables(c, db, tables...)
+
+	err := db.Query(nil, sqlair.MustPrepare("INSERT INTO person (id) VALUES (99)")).Run()
+	c.Assert(err, IsNil)
+
+	type Names []any
+	type ID struct {
+		ID int `db:"id"`
+	}
+	stmt := sqlair.MustPrepare("SELECT &ID.id FROM person WHERE name IN ($Names[:]) ORDER BY id", ID{}, Names{}, sqlair.NullSafeIn())
+
+	var ids []ID
+	err = db.Query(nil, stmt, Names{"Fred", nil}).GetAll(&ids)
+	c.Assert(err, IsNil)
+	c.Check(ids, DeepEquals, []ID{{fred.ID}, {99}})
+
+	ids = nil
+	err = db.Query(nil, stmt, Names{nil}).GetAll(&ids)
+	c.Assert(err, IsNil)
+	c.Check(ids, DeepEquals, []ID{{99}})
+
+	ids = nil
+	err = db.Query(nil, stmt, Names{"Fred"}).GetAll(&ids)
+	c.Assert(err, IsNil)
+	c.Check(ids, DeepEquals, []ID{{fred.ID}})
+
+	// With NOT IN a nil element excludes NULL.
+	notInStmt := sqlair.MustPrepare("SELECT &ID.id FROM person WHERE name NOT IN ($Names[:]) ORDER BY id", ID{}, Names{}, sqlair.NullSafeIn())
+	ids = nil
+	err = db.Query(nil, notInStmt, Names{"Fred", nil}).GetAll(&ids)
+	c.Assert(err, IsNil)
+	c.Check(ids, DeepEquals, []ID{{mark.ID}, {dave.ID}, {mary.ID}})
+
+	// Without the option NULL is not matched.
+	stmt = sqlair.MustPrepare("SELECT &ID.id FROM person WHERE name IN ($Names[:]) ORDER BY id", ID{}, Names{})
+	ids = nil
+	err = db.Query(nil, stmt, Names{"Fred", nil}).GetAll(&ids)
+	c.Assert(err, IsNil)
+	c.Check(ids, DeepEquals, []ID{{fred.ID}})
+}
+
+func (s *PackageSuite) TestSliceOfPointersIn(c *C) {
+	db, tables := s.personAndAddressDB(c)
+	defer dropTables(c, db, tables...)
+
+	err := db.Query(nil, sqlair.MustPrepare("INSERT INTO person (id) VALUES (99)")).Run()
+	c.Assert(err, IsNil)
+
+	type IDs []*int
+	type ID struct {
+		ID int `db:"id"`
+	}
+	a, b := fred.ID, mary.ID
+
+	// Pointers are dereferenced and nil pointers are passed as NULL, which
+	// does not match anything in an IN list.
+	stmt := sqlair.MustPrepare("SELECT &ID.id FROM person WHERE id IN ($IDs[:]) ORDER BY id", ID{}, IDs{})
+	var ids []ID
+	err = db.Query(nil, stmt, IDs{&a, nil, &b}).GetAll(&ids)
+	c.Assert(err, IsNil)
+	c.Check(ids, DeepEquals, []ID{{fred.ID}, {mary.ID}})
+
+	// With NullSafeIn a nil pointer matches NULL.
+	type Addresses []*int
+	stmt = sqlair.MustPrepare("SELECT &ID.id FROM person WHERE address_id IN ($Addresses[:]) ORDER BY id", ID{}, Addresses{}, sqlair.NullSafeIn())
+	postcode := fred.Postcode
+	ids = nil
+	err = db.Query(nil, stmt, Addresses{&postcode, nil}).GetAll(&ids)
+	c.Assert(err, IsNil)
+	c.Check(ids, DeepEquals, []ID{{fred.ID}, {99}})
+}
+
+func (s *PackageSuite) TestUpdateSetAsterisk(c *C) {
+	db, tables := s.personAndAddressDB(c)
+	defer dropTables(c, db, tables...)
+
+	stmt := sqlair.MustPrepare("UPDATE person SET (* EXCEPT (id)) = ($Person.*) WHERE id = $Person.id", Person{})
+	updated := Person{ID: fred.ID, Name: "Frederick", Postcode: 9999}
+	err := db.Query(nil, stmt, updated).Run()
+	c.Assert(err, IsNil)
+
+	var p Person
+	err = db.Query(nil, sqlair.MustPrepare("SELECT &Person.* FROM person WHERE id = $Person.id", Person{}), fred).Get(&p)
+	c.Assert(err, IsNil)
+	c.Check(p, DeepEquals, updated)
+}
+
+func (s *PackageSuite) TestPragma(c *C) {
+	db, tables := s.personAndAddressDB(c)
+	defer dropTables(c, db, tables...)
+
+	// Pragmas without results are run like any other statement.
+	err := db.Query(nil, sqlair.MustPrepare("PRAGMA foreign_keys = ON")).Run()
+	c.Assert(err, IsNil)
+
+	// Pragma results are read from the table-valued pragma functions since
+	// PRAGMA statements do not allow column aliases.
+	type ColumnInfo struct {
+		CID     int    `db:"cid"`
+		Name    string `db:"name"`
+		Type    string `db:"type"`
+		NotNull bool   `db:"\"notnull\""`
+	}
+	stmt := sqlair.MustPrepare("SELECT &ColumnInfo.* FROM pragma_table_info($M.table) ORDER BY cid", ColumnInfo{}, sqlair.M{})
+	var cols []ColumnInfo
+	err = db.Query(nil, stmt, sqlair.M{"table": "person"}).GetAll(&cols)
+	c.Assert(err, IsNil)
+	c.Check(cols, DeepEquals, []ColumnInfo{
+		{CID: 0, Name: "name", Type: "TEXT"},
+		{CID: 1, Name: "id", Type: "INTEGER"},
+		{CID: 2, Name: "address_id", Type: "INTEGER"},
+		{CID: 3, Name: "email", Type: "TEXT"},
+	})
+
+	// Output expressions cannot be used in a PRAGMA statement.
+	stmt = sqlair.MustPrepare("PRAGMA table_info(person) &ColumnInfo.*", ColumnInfo{})
+	err = db.Query(nil, stmt).GetAll(&cols)
+	c.Assert(err, NotNil)
+}
+
+func (s *PackageSuite) TestGetAllLimit(c *C) {
+	db, tables := s.personAndAddressDB(c)
+	defer dropTables(c, db, tables...)
+
+	stmt := sqlair.MustPrepare("SELECT &Person.* FROM person ORDER BY id", Person{})
+
+	// A limit at or above the number of rows gets all the rows.
+	var people []Person
+	err := db.Query(nil, stmt).GetAllLimit(4, &people)
+	c.Assert(err, IsNil)
+	c.Check(people, DeepEquals, []Person{mark, fred, dave, mary})
+
+	// Extra rows return ErrTooManyRows along with the first rows.
+	people = nil
+	err = db.Query(nil, stmt).GetAllLimit(2, &people)
+	c.Assert(err, Equals, sqlair.ErrTooManyRows)
+	c.Check(people, DeepEquals, []Person{mark, fred})
+
+	people = nil
+	err = db.Query(nil, stmt).GetAllLimit(0, &people)
+	c.Assert(err, Equals, sqlair.ErrTooManyRows)
+	c.Check(people, HasLen, 0)
+
+	emptyStmt := sqlair.MustPrepare("SELECT &Person.* FROM person WHERE id = 99", Person{})
+	err = db.Query(nil, emptyStmt).GetAllLimit(2, &people)
+	c.Assert(err, Equals, sqlair.ErrNoRows)
+
+	err = db.Query(nil, stmt).GetAllLimit(-1, &people)
+	c.Assert(err, ErrorMatches, "cannot get results: limit must not be negative, got -1")
+}
+
+func (s *PackageSuite) TestIgnoreUnusedOutputs(c *C) {
+	db, tables := s.personAndAddressDB(c)
+	defer dropTables(c, db, tables...)
+
+	stmt := sqlair.MustPrepare("UPDATE person SET name = 'Frederick' WHERE id = $Person.id", Person{}, sqlair.IgnoreUnusedOutputs())
+
+	// The output arguments are ignored and left unchanged.
+	p := Person{ID: 99, Name: "Unchanged"}
+	err := db.Query(nil, stmt, fred).Get(&p)
+	c.Assert(err, IsNil)
+	c.Check(p, Equals, Person{ID: 99, Name: "Unchanged"})
+
+	var people []Person
+	err = db.Query(nil, stmt, fred).GetAll(&people)
+	c.Assert(err, IsNil)
+	c.Check(people, IsNil)
+
+	// The statement has been run.
+	var got Person
+	selectStmt := sqlair.MustPrepare("SELECT &Person.* FROM person WHERE id = $Person.id", Person{})
+	err = db.Query(nil, selectStmt, fred).Get(&got)
+	c.Assert(err, IsNil)
+	c.Check(got.Name, Equals, "Frederick")
+
+	// The option survives marshalling.
+	data, err := stmt.Marshal()
+	c.Assert(err, IsNil)
+	unmarshalled, err := sqlair.UnmarshalStatement(data, Person{})
+	c.Assert(err, IsNil)
+	err = db.Query(nil, unmarshalled, fred).Get(&p)
+	c.Assert(err, IsNil)
+
+	// Without the option the output arguments are an error.
+	stmt = sqlair.MustPrepare("UPDATE person SET name = 'Frederick' WHERE id = $Person.id", Person{})
+	err = db.Query(nil, stmt, fred).Get(&p)
+	c.Assert(err, ErrorMatches, "cannot get results: output variables provided but not referenced in query")
+	err = db.Query(nil, stmt, fred).GetAll(&people)
+	c.Assert(err, ErrorMatches, "output variables provided but not referenced in query")
+}
+
+func (s *PackageSuite) TestGetAllChunked(c *C) {
+	db, tables := s.personAndAddressDB(c)
+	defer dropTables(c, db, tables...)
+
+	type IDs []int
+	stmt := sqlair.MustPrepare("SELECT &Person.* FROM person WHERE id IN ($IDs[:]) ORDER BY id", Person{}, IDs{})
+
+	// The results of each chunk are appended in chunk order.
+	var people []Person
+	err := db.Query(nil, stmt, IDs{40, 20, 30, 35, 99}).GetAllChunked(2, &people)
+	c.Assert(err, IsNil)
+	c.Check(people, DeepEquals, []Person{mark, mary, fred, dave})
+
+	// A slice smaller than the chunk size is run in a single query.
+	people = nil
+	err = db.Query(nil, stmt, IDs{40, 20}).GetAllChunked(10, &people)
+	c.Assert(err, IsNil)
+	c.Check(people, DeepEquals, []Person{mark, mary})
+
+	// Chunks with no rows are skipped.
+	people = nil
+	err = db.Query(nil, stmt, &IDs{1, 2, 30}).GetAllChunked(1, &people)
+	c.Assert(err, IsNil)
+	c.Check(people, DeepEquals, []Person{fred})
+
+	people = nil
+	err = db.Query(nil, stmt, IDs{1, 2, 3}).GetAllChunked(1, &people)
+	c.Assert(err, Equals, sqlair.ErrNoRows)
+	c.Check(people, IsNil)
+
+	// The output slices are not changed on error.
+	people = []Person{dave}
+	err = db.Query(nil, stmt, IDs{30, 40, 20}).GetAllChunked(2, &people, &[]Address{})
+	c.Assert(err, NotNil)
+	c.Check(people, DeepEquals, []Person{dave})
+
+	// Rows appended by earlier chunks are removed if a later chunk fails.
+	type Out struct {
+		V int `db:"v"`
+	}
+	failLate := sqlair.MustPrepare("SELECT CASE WHEN id < 35 THEN id ELSE 'x' END AS &Out.v FROM person WHERE id IN ($IDs[:]) ORDER BY id", Out{}, IDs{})
+	outs := []Out{{V: 1}}
+	err = db.Query(nil, failLate, IDs{20, 30, 35, 40}).GetAllChunked(2, &outs)
+	c.Assert(err, NotNil)
+	c.Check(outs, DeepEquals, []Out{{V: 1}})
+
+	err = db.Query(nil, stmt, IDs{30}).GetAllChunked(0, &people)
+	c.Assert(err, ErrorMatches, "cannot run chunked query: chunk size must be positive, got 0")
+
+	type Names []string
+	twoSlices := sqlair.MustPrepare("SELECT &Person.* FROM person WHERE id IN ($IDs[:]) AND name IN ($Names[:])", Person{}, IDs{}, Names{})
+	err = db.Query(nil, twoSlices, IDs{30}, Names{"Fred"}).GetAllChunked(1, &people)
+	c.Assert(err, ErrorMatches, "cannot run chunked query: query has 2 slice inputs, expected at most one")
+}
+
+func (s *PackageSuite) TestStatementShape(c *C) {
+	tests := []struct {
+		stmt    *sqlair.Statement
+		inputs  bool
+		outputs bool
+	}{{
+		stmt: sqlair.MustPrepare("CREATE TABLE t (id integer)"),
+	}, {
+		stmt:    sqlair.MustPrepare("SELECT &Person.* FROM person", Person{}),
+		outputs: true,
+	}, {
+		stmt:   sqlair.MustPrepare("DELETE FROM person WHERE id = $Person.id", Person{}),
+		inputs: true,
+	}, {
+		stmt:   sqlair.MustPrepare("INSERT INTO person (*) VALUES ($Person.*)", Person{}),
+		inputs: true,
+	}, {
+		stmt:    sqlair.MustPrepare("SELECT &Person.* FROM person WHERE id IN ($S[:])", Person{}, sqlair.S{}),
+		inputs:  true,
+		outputs: true,
+	}}
+	for i, t := range tests {
+		c.Check(t.stmt.HasInputs(), Equals, t.inputs, Commentf("test %d", i))
+		c.Check(t.stmt.HasOutputs(), Equals, t.outputs, Commentf("test %d", i))
+	}
+}
+
+func (s *PackageSuite) TestGetUnclaimed(c *C) {
+	db, tables := s.personAndAddressDB(c)
+	defer dropTables(c, db, tables...)
+
+	stmt := sqlair.MustPrepare("SELECT &Person.*, email, 1 AS one, 'x' || name AS x_name FROM person WHERE id = $Person.id", Person{})
+
+	var outcome sqlair.Outcome
+	var p Person
+	m := sqlair.M{}
+	err := db.Query(nil, stmt, fred).Get(&outcome, &p, sqlair.Unclaimed(m))
+	c.Assert(err, IsNil)
+	c.Check(p, DeepEquals, fred)
+	c.Check(m, DeepEquals, sqlair.M{"email": nil, "one": int64(1), "x_name": "xFred"})
+
+	// A pointer to a map can also be used.
+	dynamic := map[string]any{}
+	err = db.Query(nil, stmt, mark).Get(&p, sqlair.Unclaimed(&dynamic))
+	c.Assert(err, IsNil)
+	c.Check(p, DeepEquals, mark)
+	c.Check(dynamic["x_name"], Equals, "xMark")
+
+	err = db.Query(nil, stmt, fred).Get(&p, sqlair.Unclaimed(map[string]int{}))
+	c.Assert(err, ErrorMatches, "cannot get result: need map with string keys and interface values for unclaimed columns, got map")
+
+	err = db.Query(nil, stmt, fred).Get(&p, sqlair.Unclaimed(sqlair.M(nil)))
+	c.Assert(err, ErrorMatches, "cannot get result: got nil map for unclaimed columns")
+
+	err = db.Query(nil, stmt, fred).Get(&p, sqlair.Unclaimed(m), sqlair.Unclaimed(dynamic))
+	c.Assert(err, ErrorMatches, "cannot get result: more than one output argument for unclaimed columns")
+}
+
+func (s *PackageSuite) TestCoerceNumeric(c *C) {
+	db := sqlair.NewDB(s.db)
+
+	type Numbers struct {
+		Small int8    `db:"small"`
+		Int   int     `db:"i"`
+		Float float32 `db:"f"`
+		Count *uint16 `db:"count"`
+	}
+	stmt := sqlair.MustPrepare("SELECT 100 AS &Numbers.small, 2.0 AS &Numbers.i, 1.5 AS &Numbers.f, 7 AS &Numbers.count", Numbers{}, sqlair.CoerceNumeric())
+	var n Numbers
+	err := db.Query(nil, stmt).Get(&n)
+	c.Assert(err, IsNil)
+	count := uint16(7)
+	c.Check(n, DeepEquals, Numbers{Small: 100, Int: 2, Float: 1.5, Count: &count})
+
+	stmt = sqlair.MustPrepare("SELECT 300 AS &Numbers.small", Numbers{}, sqlair.CoerceNumeric())
+	err = db.Query(nil, stmt).Get(&n)
+	c.Assert(err, ErrorMatches, "cannot get result: cannot coerce int64 value 300 to int8: out of range")
+
+	stmt = sqlair.MustPrepare("SELECT 2.5 AS &Numbers.i", Numbers{}, sqlair.CoerceNumeric())
+	err = db.Query(nil, stmt).Get(&n)
+	c.Assert(err, ErrorMatches, "cannot get result: cannot coerce float64 value 2.5 to int: not an integer")
+
+	stmt = sqlair.MustPrepare("SELECT 0.1 AS &Numbers.f", Numbers{}, sqlair.CoerceNumeric())
+	err = db.Query(nil, stmt).Get(&n)
+	c.Assert(err, ErrorMatches, "cannot get result: cannot coerce float64 value 0.1 to float32: cannot be represented exactly")
+}
+
+func (s *PackageSuite) TestTextBool(c *C) {
+	db := sqlair.NewDB(s.db)
+
+	type Flags struct {
+		Active  bool  `db:"active"`
+		Deleted bool  `db:"deleted"`
+		Admin   *bool `db:"admin"`
+	}
+	stmt := sqlair.MustPrepare("SELECT 'true' AS &Flags.active, 'f' AS &Flags.deleted, 'Y' AS &Flags.admin", Flags{}, sqlair.TextBool())
+	var f Flags
+	err := db.Query(nil, stmt).Get(&f)
+	c.Assert(err, IsNil)
+	admin := true
+	c.Check(f, DeepEquals, Flags{Active: true, Deleted: false, Admin: &admin})
+
+	stmt = sqlair.MustPrepare("SELECT 'maybe' AS &Flags.active", Flags{}, sqlair.TextBool())
+	err = db.Query(nil, stmt).Get(&f)
+	c.Assert(err, ErrorMatches, `cannot get result: cannot convert string value "maybe" to bool: not a boolean`)
+
+	// Without the option the conversion is left to database/sql.
+	stmt = sqlair.MustPrepare("SELECT 'yes' AS &Flags.active", Flags{})
+	err = db.Query(nil, stmt).Get(&f)
+	c.Assert(err, NotNil)
+}
+
+func (s *PackageSuite) TestConn(c *C) {
+	db := sqlair.NewDB(s.db)
+
+	conn, err := db.Conn(nil)
+	c.Assert(err, IsNil)
+
+	// Temporary tables are only visible on the connection that created them.
+	err = conn.Query(nil, sqlair.MustPrepare("CREATE TEMP TABLE conn_temp (id integer, name text)")).Run()
+	c.Assert(err, IsNil)
+
+	insert := sqlair.MustPrepare("INSERT INTO conn_temp (*) VALUES ($Person.id, $Person.name)", Person{})
+	q := conn.Query(nil, insert, fred)
+	c.Check(q.CacheState(), Equals, sqlair.CacheMiss)
+	c.Assert(q.Run(), IsNil)
+	q = conn.Query(nil, insert, mark)
+	c.Check(q.CacheState(), Equals, sqlair.CacheHit)
+	c.Assert(q.Run(), IsNil)
+
+	var people []Person
+	sel := sqlair.MustPrepare("SELECT &Person.id, &Person.name FROM conn_temp ORDER BY id", Person{})
+	err = conn.Query(nil, sel).GetAll(&people)
+	c.Assert(err, IsNil)
+	c.Check(people, DeepEquals, []Person{{ID: mark.ID, Name: mark.Name}, {ID: fred.ID, Name: fred.Name}})
+
+	c.Assert(conn.Close(), IsNil)
+	err = conn.Query(nil, sel).GetAll(&people)
+	c.Assert(err, Equals, sql.ErrConnDone)
+}
+
+func (s *PackageSuite) TestExistsBool(c *C) {
+	db, tables := s.personAndAddressDB(c)
+	defer dropTables(c, db, tables...)
+
+	type Result struct {
+		Exists bool `db:"exists"`
+	}
+	stmt := sqlair.MustPrepare("SELECT EXISTS(SELECT 1 FROM person WHERE id=$M.id) AS &Result.exists", Result{}, sqlair.M{})
+
+	var r Result
+	err := db.Query(nil, stmt, sqlair.M{"id": fred.ID}).Get(&r)
+	c.Assert(err, IsNil)
+	c.Check(r.Exists, Equals, true)
+
+	err = db.Query(nil, stmt, sqlair.M{"id": 999}).Get(&r)
+	c.Assert(err, IsNil)
+	c.Check(r.Exists, Equals, false)
+
+	type BoolMap map[string]bool
+	stmt = sqlair.MustPrepare("SELECT EXISTS(SELECT 1 FROM person WHERE id=$M.id) AS &BoolMap.exists", BoolMap{}, sqlair.M{})
+	m := BoolMap{}
+	err = db.Query(nil, stmt, sqlair.M{"id": fred.ID}).Get(&m)
+	c.Assert(err, IsNil)
+	c.Check(m, DeepEquals, BoolMap{"exists": true})
+
+	// Values of maps with interface values are stored as returned by the
+	// driver.
+	stmt = sqlair.MustPrepare("SELECT EXISTS(SELECT 1 FROM person WHERE id=$M.id) AS &M.exists", sqlair.M{})
+	result := sqlair.M{}
+	err = db.Query(nil, stmt, sqlair.M{"id": fred.ID}).Get(&result)
+	c.Assert(err, IsNil)
+	c.Check(result, DeepEquals, sqlair.M{"exists": int64(1)})
+}
+
+func (s *PackageSuite) TestMarshalStatement(c *C) {
+	db, tables := s.personAndAddressDB(c)
+	defer dropTables(c, db, tables...)
+
+	stmt := sqlair.MustPrepare("SELECT &Person.* FROM person WHERE id IN ($S[:])", Person{}, sqlair.S{}, sqlair.SQLite.WithMaxParams(100))
+	data, err := stmt.Marshal()
+	c.Assert(err, IsNil)
+
+	unmarshalled, err := sqlair.UnmarshalStatement(data, Person{}, sqlair.S{})
+	c.Assert(err, IsNil)
+
+	var people []Person
+	err = db.Query(nil, unmarshalled, sqlair.S{fred.ID, mark.ID}).GetAll(&people)
+	c.Assert(err, IsNil)
+	c.Check(people, DeepEquals, []Person{fred, mark})
+
+	// The type samples must be passed again.
+	_, err = sqlair.UnmarshalStatement(data, Person{})
+	c.Check(err, ErrorMatches, `cannot prepare statement: input expression: parameter with type "S" missing \(have "Person"\): \$S\[:\]`)
+
+	_, err = sqlair.UnmarshalStatement([]byte(`{"version":2}`), Person{})
+	c.Check(err, ErrorMatches, `cannot unmarshal statement: unsupported version 2, expected 1`)
+
+	_, err = sqlair.UnmarshalStatement([]byte(`{"version":1,"expr":[{"kind":"foo"}]}`), Person{})
+	c.Check(err, ErrorMatches, `cannot unmarshal statement: unknown expression kind "foo"`)
+}
+
+func (s *PackageSuite) TestCaseInsensitiveTags(c *C) {
+	db, tables := s.personAndAddressDB(c)
+	defer dropTables(c, db, tables...)
+
+	stmt := sqlair.MustPrepare(
+		"SELECT (Name, ID, Address_ID) AS (&Person.*) FROM person WHERE id = $M.ID",
+		Person{}, sqlair.M{}, sqlair.WithCaseInsensitiveTags(),
+	)
+	var p Person
+	err := db.Query(nil, stmt, sqlair.M{"id": fred.ID}).Get(&p)
+	c.Assert(err, IsNil)
+	c.Check(p, DeepEquals, fred)
+
+	_, err = sqlair.Prepare("SELECT &Person.NAME FROM person", Person{})
+	c.Check(err, ErrorMatches, `cannot prepare statement: output expression: type "Person" has no "NAME" db tag: &Person.NAME`)
+}
+
+func (s *PackageSuite) TestDeleteSliceReturning(c *C) {
+	db, tables := s.personAndAddressDB(c)
+	defer dropTables(c, db, tables...)
+
+	stmt := sqlair.MustPrepare("DELETE FROM person WHERE id IN ($S[:]) AND name <> $M.name RETURNING &Person.*", Person{}, sqlair.S{}, sqlair.M{})
+
+	var deleted []Person
+	err := db.Query(nil, stmt, sqlair.S{fred.ID, mark.ID, dave.ID}, sqlair.M{"name": "Dave"}).GetAll(&deleted)
+	c.Assert(err, IsNil)
+	sort.Slice(deleted, func(i, j int) bool { return deleted[i].ID < deleted[j].ID })
+	c.Check(deleted, DeepEquals, []Person{mark, fred})
+
+	var remaining []Person
+	err = db.Query(nil, sqlair.MustPrepare("SELECT &Person.* FROM person ORDER BY id", Person{})).GetAll(&remaining)
+	c.Assert(err, IsNil)
+	c.Check(remaining, DeepEquals, []Person{dave, mary})
+
+	// ErrNoRows is returned if nothing is deleted.
+	deleted = nil
+	err = db.Query(nil, stmt, sqlair.S{fred.ID}, sqlair.M{"name": "Dave"}).GetAll(&deleted)
+	c.Assert(err, Equals, sqlair.ErrNoRows)
+	c.Check(deleted, HasLen, 0)
+
+	// Inputs after the slice are numbered after the expanded slice.
+	pq := db.Query(nil, stmt, sqlair.S{1, 2}, sqlair.M{"name": "Dave"})
+	c.Check(pq.DebugSQL(), Equals, "DELETE FROM person WHERE id IN (@sqlair_0, @sqlair_1) AND name <> @sqlair_2 RETURNING address_id AS _sqlair_0, id AS _sqlair_1, name AS _sqlair_2")
+}
+
+// UUID is a UUID-like value type that implements encoding.TextMarshaler and
+// encoding.TextUnmarshaler but not driver.Valuer or sql.Scanner.
+type UUID [4]byte
+
+func (u UUID) MarshalText() ([]byte, error) {
+	return []byte(fmt.Sprintf("%x-%x", u[:2], u[2:])), nil
+}
+
+func (u *UUID) UnmarshalText(text []byte) error {
+	_, err := fmt.Sscanf(string(text), "%02x%02x-%02x%02x", &u[0], &u[1], &u[2], &u[3])
+	return err
+}
+
+func (s *PackageSuite) TestTextTag(c *C) {
+	db := sqlair.NewDB(s.db)
+
+	type Device struct {
+		ID     UUID  `db:"id,text"`
+		Parent *UUID `db:"parent,text"`
+	}
+	err := db.Query(nil, sqlair.MustPrepare("CREATE TABLE device (id text, parent text)")).Run()
+	c.Assert(err, IsNil)
+	defer dropTables(c, db, "device")
+
+	parent := UUID{0xde, 0xad, 0xbe, 0xef}
+	devices := []Device{{ID: parent}, {ID: UUID{1, 2, 3, 4}, Parent: &parent}}
+	err = db.Query(nil, sqlair.MustPrepare("INSERT INTO device (*) VALUES ($Device.*)", Device{}), devices).Run()
+	c.Assert(err, IsNil)
+
+	// The values are stored as text.
+	var texts []sqlair.M
+	err = db.Query(nil, sqlair.MustPrepare("SELECT (id, parent) AS (&M.*) FROM device ORDER BY id", sqlair.M{})).GetAll(&texts)
+	c.Assert(err, IsNil)
+	c.Check(texts, DeepEquals, []sqlair.M{{"id": "0102-0304", "parent": "dead-beef"}, {"id": "dead-beef", "parent": nil}})
+
+	var got []Device
+	err = db.Query(nil, sqlair.MustPrepare("SELECT &Device.* FROM device WHERE parent = $Device.id OR parent IS NULL ORDER BY id", Device{}), Device{ID: parent}).GetAll(&got)
+	c.Assert(err, IsNil)
+	c.Check(got, DeepEquals, []Device{{ID: UUID{1, 2, 3, 4}, Parent: &parent}, {ID: parent}})
+}
+
+type OrderItem struct{}
+
+type HTTPRequest struct{}
+
+type userID int
+
+type renamed struct{}
+
+func (renamed) TableName() string { return "people" }
+
+type renamedPtr struct{}
+
+func (*renamedPtr) TableName() string { return "pointers" }
+
+func (s *PackageSuite) TestTableName(c *C) {
+	tests := []struct {
+		sample any
+		name   string
+	}{
+		{Person{}, "person"},
+		{&Person{}, "person"},
+		{OrderItem{}, "order_item"},
+		{HTTPRequest{}, "http_request"},
+		{userID(0), "user_id"},
+		{renamed{}, "people"},
+		{&renamed{}, "people"},
+		{renamedPtr{}, "pointers"},
+		{nil, ""},
+		{struct{}{}, ""},
+	}
+	for i, t := range tests {
+		c.Check(sqlair.TableName(t.sample), Equals, t.name, Commentf("test %d", i))
+	}
+}
+
+func (s *PackageSuite) TestStatementDescribe(c *C) {
+	stmt := sqlair.MustPrepare("SELECT &Person.name FROM person WHERE id IN ($S[:])", Person{}, sqlair.S{})
+
+	desc := stmt.Describe()
+	c.Check(desc.Inputs, DeepEquals, []sqlair.MemberDescription{
+		{Expr: "$S[:]", Type: reflect.TypeOf(sqlair.S{}), Kind: reflect.Interface},
+	})
+	c.Check(desc.Outputs, DeepEquals, []sqlair.MemberDescription{
+		{Expr: "&Person.name", Column: "name", Type: reflect.TypeOf(Person{}), Member: "name", Field: "Name", Kind: reflect.String},
+	})
+
+	// The description is a copy.
+	desc.Outputs[0].Field = "Other"
+	c.Check(stmt.Describe().Outputs[0].Field, Equals, "Name")
+}
+
+func (s *PackageSuite) TestUnderscorePrefix(c *C) {
+	db, tables := s.personAndAddressDB(c)
+	defer dropTables(c, db, tables...)
+
+	stmt := sqlair.MustPrepare(`
+SELECT p.* AS &Person.*
+FROM   (SELECT id AS p_id, name AS p_name, address_id AS p_address_id FROM person)
+WHERE  p_id = $Person.id`, Person{}, sqlair.UnderscorePrefix)
+
+	var got Person
+	err := db.Query(nil, stmt, fred).Get(&got)
+	c.Assert(err, IsNil)
+	c.Check(got, Equals, fred)
+
+	// The column prefix survives marshalling.
+	data, err := stmt.Marshal()
+	c.Assert(err, IsNil)
+	stmt, err = sqlair.UnmarshalStatement(data, Person{})
+	c.Assert(err, IsNil)
+	got = Person{}
+	err = db.Query(nil, stmt, mark).Get(&got)
+	c.Assert(err, IsNil)
+	c.Check(got, Equals, mark)
+
+	// The default prefix qualifies the columns with the table name.
+	stmt = sqlair.MustPrepare("SELECT p.* AS &Person.* FROM person AS p WHERE p.id = $Person.id", Person{}, sqlair.TableQualifier)
+	got = Person{}
+	err = db.Query(nil, stmt, fred).Get(&got)
+	c.Assert(err, IsNil)
+	c.Check(got, Equals, fred)
+}
+
+func (s *PackageSuite) TestStatementBind(c *C) {
+	stmt := sqlair.MustPrepare("SELECT &Person.* FROM person WHERE id IN ($S[:]) AND name = $Person.name", Person{}, sqlair.S{})
+
+	query, params, err := stmt.Bind(sqlair.S{1, 2}, fred)
+	c.Assert(err, IsNil)
+	c.Check(query, Equals, "SELECT address_id AS _sqlair_0, id AS _sqlair_1, name AS _sqlair_2 FROM person WHERE id IN (@sqlair_0, @sqlair_1) AND name = @sqlair_2")
+	c.Check(params, DeepEquals, []any{sql.Named("sqlair_0", 1), sql.Named("sqlair_1", 2), sql.Named("sqlair_2", "Fred")})
+
+	_, _, err = stmt.Bind(fred)
+	c.Check(err, ErrorMatches, `invalid input parameter: parameter with type "S" missing \(have "Person"\)`)
+
+	// The dialect passed to Prepare is used.
+	stmt = sqlair.MustPrepare("SELECT name FROM person WHERE id = $Person.id", Person{}, sqlair.Postgres)
+	query, params, err = stmt.Bind(fred)
+	c.Assert(err, IsNil)
+	c.Check(query, Equals, "SELECT name FROM person WHERE id = $1")
+	c.Check(params, DeepEquals, []any{30})
+}
+
+func (s *PackageSuite) TestNotNullTag(c *C) {
+	db, tables := s.personAndAddressDB(c)
+	defer dropTables(c, db, tables...)
+
+	type Contact struct {
+		ID    int    `db:"id,notnull"`
+		Name  string `db:"name,notnull"`
+		Email string `db:"email,notnull"`
+	}
+	var contacts []Contact
+	err := db.Query(nil, sqlair.MustPrepare("SELECT (id, name) AS (&Contact.id, &Contact.name) FROM person ORDER BY id", Contact{})).GetAll(&contacts)
+	c.Assert(err, IsNil)
+	c.Check(contacts, DeepEquals, []Contact{{ID: 20, Name: "Mark"}, {ID: 30, Name: "Fred"}, {ID: 35, Name: "Dave"}, {ID: 40, Name: "Mary"}})
+
+	// Scanning NULL into a notnull field is an error.
+	var contact Contact
+	err = db.Query(nil, sqlair.MustPrepare("SELECT &Contact.email FROM person WHERE id = $Person.id", Contact{}, Person{}), fred).Get(&contact)
+	c.Check(err, ErrorMatches, `cannot get result: sql: Scan error on column index 0, name "_sqlair_0": converting NULL to string is unsupported
+expected columns: _sqlair_0 \(&Contact.email\)
+result columns: _sqlair_0`)
+}
+
+// Point is a composite value stored in a single column as "(x,y)". It has db
+// tags of its own but implements sql.Scanner and driver.Valuer, so it is read
+// and written as a whole.
+type Point struct {
+	X int `db:"x"`
+	Y int `db:"y"`
+}
+
+func (p *Point) Scan(src any) error {
+	var s string
+	switch v := src.(type) {
+	case string:
+		s = v
+	case []byte:
+		s = string(v)
+	default:
+		return fmt.Errorf("cannot scan %T into Point", src)
+	}
+	_, err := fmt.Sscanf(s, "(%d,%d)", &p.X, &p.Y)
+	return err
+}
+
+func (p Point) Value() (driver.Value, error) {
+	return fmt.Sprintf("(%d,%d)", p.X, p.Y), nil
+}
+
+func (s *PackageSuite) TestScannerStructField(c *C) {
+	db := sqlair.NewDB(s.db)
+
+	type Shape struct {
+		Name  string `db:"name"`
+		Pos   Point  `db:"pos"`
+		Point `db:"origin"`
+	}
+	err := db.Query(nil, sqlair.MustPrepare("CREATE TABLE shape (name text, pos text, origin text)")).Run()
+	c.Assert(err, IsNil)
+	defer dropTables(c, db, "shape")
+
+	// The Point fields are columns of their own rather than the x and y
+	// columns of the Point struct.
+	insert := sqlair.MustPrepare("INSERT INTO shape (*) VALUES ($Shape.*)", Shape{})
+	square := Shape{Name: "square", Pos: Point{X: 1, Y: 2}, Point: Point{X: 3, Y: 4}}
+	query, params, err := insert.Bind(square)
+	c.Assert(err, IsNil)
+	c.Check(query, Equals, "INSERT INTO shape (name, origin, pos) VALUES (@sqlair_0, @sqlair_1, @sqlair_2)")
+	c.Check(params, DeepEquals, []any{sql.Named("sqlair_0", "square"), sql.Named("sqlair_1", Point{X: 3, Y: 4}), sql.Named("sqlair_2", Point{X: 1, Y: 2})})
+	err = db.Query(nil, insert, square).Run()
+	c.Assert(err, IsNil)
+
+	var got Shape
+	err = db.Query(nil, sqlair.MustPrepare("SELECT &Shape.* FROM shape", Shape{})).Get(&got)
+	c.Assert(err, IsNil)
+	c.Check(got, DeepEquals, square)
+
+	// A single member is scanned as a whole.
+	var pos Shape
+	err = db.Query(nil, sqlair.MustPrepare("SELECT '(5,6)' AS &Shape.pos", Shape{})).Get(&pos)
+	c.Assert(err, IsNil)
+	c.Check(pos.Pos, Equals, Point{X: 5, Y: 6})
+
+	// The members of Point are not members of Shape.
+	_, err = sqlair.Prepare("SELECT &Shape.x FROM shape", Shape{})
+	c.Check(err, ErrorMatches, `cannot prepare statement: output expression: type "Shape" has no "x" db tag: &Shape.x`)
+}
+
+func (s *PackageSuite) TestInsertDefaultValuesReturning(c *C) {
+	db := sqlair.NewDB(s.db)
+
+	type Counter struct {
+		ID    int    `db:"id"`
+		Label string `db:"label"`
+	}
+	err := db.Query(nil, sqlair.MustPrepare("CREATE TABLE counter (id integer PRIMARY KEY AUTOINCREMENT, label text DEFAULT 'new')")).Run()
+	c.Assert(err, IsNil)
+	defer dropTables(c, db, "counter")
+
+	stmt := sqlair.MustPrepare("INSERT INTO counter DEFAULT VALUES RETURNING &Counter.*", Counter{})
+	for i := 1; i <= 2; i++ {
+		var counter Counter
+		err = db.Query(nil, stmt).Get(&counter)
+		c.Assert(err, IsNil)
+		c.Check(counter, Equals, Counter{ID: i, Label: "new"})
+	}
+}
+
+func (s *PackageSuite) TestOutputSeveralTablesIntoStruct(c *C) {
+	db, tables := s.personAndAddressDB(c)
+	defer dropTables(c, db, tables...)
+
+	type PersonAddress struct {
+		PersonID  int    `db:"p_id"`
+		Name      string `db:"p_name"`
+		AddressID int    `db:"p_address_id"`
+		ID        int    `db:"a_id"`
+		District  string `db:"a_district"`
+		Street    string `db:"a_street"`
+	}
+	stmt, err := sqlair.Prepare(`
+		SELECT     (p.*, a.*) AS (&PersonAddress.*)
+		FROM       person AS p
+		INNER JOIN address AS a ON p.address_id = a.id
+		ORDER BY   p.id`,
+		PersonAddress{},
+	)
+	c.Assert(err, IsNil)
+
+	var got []PersonAddress
+	err = db.Query(nil, stmt).GetAll(&got)
+	c.Assert(err, IsNil)
+	c.Check(got, DeepEquals, []PersonAddress{{
+		PersonID: 20, Name: "Mark", AddressID: 1500,
+		ID: 1500, District: "Sad World", Street: "Church Road",
+	}, {
+		PersonID: 30, Name: "Fred", AddressID: 1000,
+		ID: 1000, District: "Happy Land", Street: "Main Street",
+	}, {
+		PersonID: 40, Name: "Mary", AddressID: 3500,
+		ID: 3500, District: "Ambivalent Commons", Street: "Station Lane",
+	}})
+}
+
+func (s *PackageSuite) TestJSON(c *C) {
+	db, tables := s.personAndAddressDB(c)
+	defer dropTables(c, db, tables...)
+
+	type Doc struct {
+		Title string   `json:"title" db:"title"`
+		Tags  []string `json:"tags,omitempty"`
+	}
+	err := db.Query(nil, sqlair.MustPrepare("CREATE TABLE doc (id integer, data text)")).Run()
+	c.Assert(err, IsNil)
+	defer dropTables(c, db, "doc")
+
+	insertStmt := sqlair.MustPrepare("INSERT INTO doc (id, data) VALUES ($Person.id, $Doc)", Person{}, sqlair.JSON(Doc{}))
+	doc := Doc{Title: "Report", Tags: []string{"a", "b"}}
+	err = db.Query(nil, insertStmt, fred, doc).Run()
+	c.Assert(err, IsNil)
+	err = db.Query(nil, sqlair.MustPrepare("INSERT INTO doc (id, data) VALUES ($Person.id, NULL)", Person{}), mark).Run()
+	c.Assert(err, IsNil)
+
+	// The column holds the JSON encoding of the whole struct.
+	type Raw struct {
+		Data string `db:"data"`
+	}
+	var raw Raw
+	rawStmt := sqlair.MustPrepare("SELECT &Raw.data FROM doc WHERE id = $Person.id", Raw{}, Person{})
+	err = db.Query(nil, rawStmt, fred).Get(&raw)
+	c.Assert(err, IsNil)
+	c.Check(raw.Data, Equals, `{"title":"Report","tags":["a","b"]}`)
+
+	selectStmt := sqlair.MustPrepare("SELECT data AS &Doc FROM doc WHERE id = $Person.id", Person{}, sqlair.JSON(Doc{}))
+	var got Doc
+	err = db.Query(nil, selectStmt, fred).Get(&got)
+	c.Assert(err, IsNil)
+	c.Check(got, DeepEquals, doc)
+
+	// A NULL column sets the struct to its zero value.
+	err = db.Query(nil, selectStmt, mark).Get(&got)
+	c.Assert(err, IsNil)
+	c.Check(got, DeepEquals, Doc{})
+
+	// The members of a JSON type can still be used on their own.
+	memberStmt := sqlair.MustPrepare("SELECT name AS &Doc.title FROM person WHERE id = $Person.id", Person{}, sqlair.JSON(Doc{}))
+	err = db.Query(nil, memberStmt, fred).Get(&got)
+	c.Assert(err, IsNil)
+	c.Check(got, DeepEquals, Doc{Title: "Fred"})
+
+	// The JSON types survive marshalling.
+	data, err := selectStmt.Marshal()
+	c.Assert(err, IsNil)
+	selectStmt, err = sqlair.UnmarshalStatement(data, Person{}, Doc{})
+	c.Assert(err, IsNil)
+	got = Doc{}
+	err = db.Query(nil, selectStmt, fred).Get(&got)
+	c.Assert(err, IsNil)
+	c.Check(got, DeepEquals, doc)
+
+	// Invalid JSON in the column is an error.
+	err = db.Query(nil, sqlair.MustPrepare("UPDATE doc SET data = 'not json' WHERE id = $Person.id", Person{}), fred).Run()
+	c.Assert(err, IsNil)
+	err = db.Query(nil, selectStmt, fred).Get(&got)
+	c.Check(err, ErrorMatches, `cannot get result: cannot unmarshal JSON into Doc: .*`)
+
+	// A type must be marked with JSON to be used whole.
+	_, err = sqlair.Prepare("INSERT INTO doc (id, data) VALUES ($Person.id, $Doc)", Person{}, Doc{})
+	c.Check(err, ErrorMatches, `cannot parse expression: column 48: unqualified type, expected Doc.\* or Doc.<db tag> or Doc\[:\], or a type marked as JSON`)
+}
+
+func (s *PackageSuite) TestDebugParamsSecret(c *C) {
+	db, tables := s.personAndAddressDB(c)
+	defer dropTables(c, db, tables...)
+
+	type Contact struct {
+		ID    int    `db:"id"`
+		Email string `db:"email,secret"`
+	}
+	stmt := sqlair.MustPrepare("UPDATE person SET email = $Contact.email WHERE id = $Contact.id", Contact{})
+	q := db.Query(nil, stmt, Contact{ID: fred.ID, Email: "fred@example.com"})
+	c.Check(q.DebugParams(), DeepEquals, []any{sql.Named("sqlair_0", "***"), sql.Named("sqlair_1", 30)})
+	c.Assert(q.Run(), IsNil)
+
+	// The database receives the real value.
+	var got Contact
+	err := db.Query(nil, sqlair.MustPrepare("SELECT &Contact.* FROM person WHERE id = $Person.id", Contact{}, Person{}), fred).Get(&got)
+	c.Assert(err, IsNil)
+	c.Check(got, Equals, Contact{ID: fred.ID, Email: "fred@example.com"})
+
+	// Map keys are marked as secret with SecretKeys, which survives
+	// marshalling.
+	stmt = sqlair.MustPrepare("SELECT &Person.* FROM person WHERE email = $M.email", Person{}, sqlair.M{}, sqlair.SecretKeys("email"))
+	data, err := stmt.Marshal()
+	c.Assert(err, IsNil)
+	stmt, err = sqlair.UnmarshalStatement(data, Person{}, sqlair.M{})
+	c.Assert(err, IsNil)
+	q = db.Query(nil, stmt, sqlair.M{"email": "fred@example.com"})
+	c.Check(q.DebugParams(), DeepEquals, []any{sql.Named("sqlair_0", "***")})
+	var person Person
+	c.Assert(q.Get(&person), IsNil)
+	c.Check(person, Equals, fred)
+
+	// No parameters are returned if the query could not be built.
+	c.Check(db.Query(nil, stmt).DebugParams(), IsNil)
+}
+
+// Decimal is a fixed point decimal number that does not implement
+// sql.Scanner, like the decimal types of other packages.
+type Decimal struct {
+	Unscaled int64
+	Scale    int
+}
+
+// parseDecimal parses a decimal number such as "12.50".
+func parseDecimal(s string) (Decimal, error) {
+	whole, frac, _ := strings.Cut(s, ".")
+	n, err := strconv.ParseInt(whole+frac, 10, 64)
+	if err != nil {
+		return Decimal{}, err
+	}
+	return Decimal{Unscaled: n, Scale: len(frac)}, nil
+}
+
+func init() {
+	sqlair.RegisterScanConverter(func(src any) (Decimal, error) {
+		switch v := src.(type) {
+		case []byte:
+			return parseDecimal(string(v))
+		case string:
+			return parseDecimal(v)
+		case int64:
+			return Decimal{Unscaled: v}, nil
+		}
+		return Decimal{}, fmt.Errorf("unexpected type %T", src)
+	})
+}
+
+func (s *PackageSuite) TestScanConverter(c *C) {
+	db := sqlair.NewDB(s.db)
+	err := db.Query(nil, sqlair.MustPrepare("CREATE TABLE price (id integer, amount text)")).Run()
+	c.Assert(err, IsNil)
+	defer dropTables(c, db, "price")
+	err = db.Query(nil, sqlair.MustPrepare("INSERT INTO price (id, amount) VALUES (1, '12.50'), (2, '0.125'), (3, NULL), (4, 'abc')")).Run()
+	c.Assert(err, IsNil)
+
+	type Price struct {
+		ID     int      `db:"id"`
+		Amount Decimal  `db:"amount"`
+		Ptr    *Decimal `db:"amount_ptr"`
+	}
+	stmt := sqlair.MustPrepare("SELECT (id, amount, amount) AS (&Price.id, &Price.amount, &Price.amount_ptr) FROM price WHERE id < 4 ORDER BY id", Price{})
+	var prices []Price
+	err = db.Query(nil, stmt).GetAll(&prices)
+	c.Assert(err, IsNil)
+	c.Check(prices, DeepEquals, []Price{
+		{ID: 1, Amount: Decimal{Unscaled: 1250, Scale: 2}, Ptr: &Decimal{Unscaled: 1250, Scale: 2}},
+		{ID: 2, Amount: Decimal{Unscaled: 125, Scale: 3}, Ptr: &Decimal{Unscaled: 125, Scale: 3}},
+		{ID: 3},
+	})
+
+	// Errors from the converter are returned.
+	var price Price
+	err = db.Query(nil, sqlair.MustPrepare("SELECT &Price.amount FROM price WHERE id = 4", Price{})).Get(&price)
+	c.Check(err, ErrorMatches, `cannot get result: cannot convert string value to sqlair_test.Decimal: strconv.ParseInt: parsing "abc": invalid syntax`)
+}
+
+func (s *PackageSuite) TestStrict(c *C) {
+	db, tables := s.personAndAddressDB(c)
+	defer dropTables(c, db, tables...)
+
+	_, err := sqlair.Prepare("SELECT *, &Person.id FROM person", Person{}, sqlair.Strict())
+	c.Check(err, ErrorMatches, `cannot prepare statement: cannot mix asterisk with output expressions in strict mode: SELECT \*,`)
+
+	// Without strict mode the extra columns are discarded.
+	stmt := sqlair.MustPrepare("SELECT *, &Person.id FROM person WHERE id = $Person.id", Person{})
+	var got Person
+	err = db.Query(nil, stmt, fred).Get(&got)
+	c.Assert(err, IsNil)
+	c.Check(got, Equals, Person{ID: fred.ID})
+
+	// Strict mode survives marshalling.
+	stmt = sqlair.MustPrepare("SELECT * FROM person WHERE id = $Person.id", Person{}, sqlair.Strict())
+	data, err := stmt.Marshal()
+	c.Assert(err, IsNil)
+	c.Check(string(data), Matches, `.*"strict":true.*`)
+	_, err = sqlair.UnmarshalStatement(data, Person{})
+	c.Assert(err, IsNil)
+}
+
+func (s *PackageSuite) TestPositionalTags(c *C) {
+	db, tables := s.personAndAddressDB(c)
+	defer dropTables(c, db, tables...)
+
+	type ColumnInfo struct {
+		CID          int     `db:"#0"`
+		Name         string  `db:"#1"`
+		Type         string  `db:"#2"`
+		NotNull      bool    `db:"#3"`
+		DefaultValue *string `db:"#4"`
+		PK           int     `db:"#5"`
+	}
+	stmt := sqlair.MustPrepare("SELECT &ColumnInfo.* FROM pragma_table_info('person')", ColumnInfo{})
+	var got []ColumnInfo
+	err := db.Query(nil, stmt).GetAll(&got)
+	c.Assert(err, IsNil)
+	c.Check(got, DeepEquals, []ColumnInfo{
+		{CID: 0, Name: "name", Type: "TEXT"},
+		{CID: 1, Name: "id", Type: "INTEGER"},
+		{CID: 2, Name: "address_id", Type: "INTEGER"},
+		{CID: 3, Name: "email", Type: "TEXT"},
+	})
+
+	// The number of columns must match the number of fields.
+	type ColumnName struct {
+		CID  int    `db:"#0"`
+		Name string `db:"#1"`
+	}
+	stmt = sqlair.MustPrepare("SELECT t.* AS &ColumnName.* FROM pragma_table_info('person') AS t", ColumnName{})
+	var name ColumnName
+	err = db.Query(nil, stmt).Get(&name)
+	c.Check(err, ErrorMatches, `cannot get result: expected 2 column\(s\) in the query results for positional output, got 6\nresult columns: cid, name, type, notnull, dflt_value, pk`)
+
+	// Positional tags cannot be mixed with named tags.
+	type Mixed struct {
+		CID  int    `db:"#0"`
+		Name string `db:"name"`
+	}
+	_, err = sqlair.Prepare("SELECT &Mixed.* FROM pragma_table_info('person')", Mixed{})
+	c.Check(err, ErrorMatches, `cannot prepare statement: struct "Mixed" mixes positional db tag "#0" with named db tag "name"`)
+}
+
+func (s *PackageSuite) TestPrepareCached(c *C) {
+	db, tables := s.personAndAddressDB(c)
+	defer dropTables(c, db, tables...)
+
+	stmt1, err := sqlair.PrepareCached("SELECT &Person.* FROM person WHERE id = $Person.id", Person{})
+	c.Assert(err, IsNil)
+	stmt2, err := sqlair.PrepareCached(`
+		SELECT &Person.*   -- all columns
+		FROM   person /* people */
+		WHERE  id = $Person.id
+	`, Person{})
+	c.Assert(err, IsNil)
+	c.Check(stmt2, Equals, stmt1)
+
+	var got Person
+	err = db.Query(nil, stmt1, fred).Get(&got)
+	c.Assert(err, IsNil)
+	c.Check(got, Equals, fred)
+
+	// The second query uses the driver statement prepared for the first.
+	q := db.Query(nil, stmt2, mark)
+	c.Check(q.CacheState(), Equals, sqlair.CacheHit)
+	err = q.Get(&got)
+	c.Assert(err, IsNil)
+	c.Check(got, Equals, mark)
+
+	// Different string literals and options give different statements.
+	stmt3, err := sqlair.PrepareCached("SELECT &Person.* FROM person WHERE id = $Person.id AND name <> 'a  b'", Person{})
+	c.Assert(err, IsNil)
+	stmt4, err := sqlair.PrepareCached("SELECT &Person.* FROM person WHERE id = $Person.id AND name <> 'a b'", Person{})
+	c.Assert(err, IsNil)
+	c.Check(stmt3 == stmt4, Equals, false)
+	stmt5, err := sqlair.PrepareCached("SELECT &Person.* FROM person WHERE id = $Person.id", Person{}, sqlair.NullSafeIn())
+	c.Assert(err, IsNil)
+	c.Check(stmt5 == stmt1, Equals, false)
+
+	// Errors are not cached.
+	_, err = sqlair.PrepareCached("SELECT &Address.* FROM address", Person{})
+	c.Check(err, NotNil)
+	_, err = sqlair.PrepareCached("SELECT &Address.* FROM address", Address{})
+	c.Check(err, IsNil)
+
+	// Structs given the same name with Named are told apart by their type.
+	type rowX = struct {
+		X int `db:"x"`
+	}
+	type rowY = struct {
+		Y int `db:"y"`
+	}
+	namedX, err := sqlair.PrepareCached("SELECT &Row.* FROM t", sqlair.Named("Row", rowX{}))
+	c.Assert(err, IsNil)
+	namedY, err := sqlair.PrepareCached("SELECT &Row.* FROM t", sqlair.Named("Row", rowY{}))
+	c.Assert(err, IsNil)
+	c.Check(namedX == namedY, Equals, false)
+	c.Check(db.Query(nil, namedX).DebugSQL(), Equals, "SELECT x AS _sqlair_0 FROM t")
+	c.Check(db.Query(nil, namedY).DebugSQL(), Equals, "SELECT y AS _sqlair_0 FROM t")
+	namedX2, err := sqlair.PrepareCached("SELECT &Row.* FROM t", sqlair.Named("Row", rowX{}))
+	c.Assert(err, IsNil)
+	c.Check(namedX2 == namedX, Equals, true)
+}
+
+func (s *PackageSuite) TestWithTag(c *C) {
+	db, tables := s.personAndAddressDB(c)
+	defer dropTables(c, db, tables...)
+
+	type ColPerson struct {
+		ID    int    `col:"id" db:"person_id"`
+		Name  string `col:"name"`
+		Email string `col:"email,omitempty"`
+	}
+	insertStmt, err := sqlair.Prepare("INSERT INTO person (*) VALUES ($ColPerson.*)", ColPerson{}, sqlair.WithTag("col"))
+	c.Assert(err, IsNil)
+	err = db.Query(nil, insertStmt, ColPerson{ID: 50, Name: "jim"}).Run()
+	c.Assert(err, IsNil)
+
+	selectStmt, err := sqlair.Prepare("SELECT &ColPerson.* FROM person WHERE id = $ColPerson.id", ColPerson{}, sqlair.WithTag("col"))
+	c.Assert(err, IsNil)
+	var got ColPerson
+	err = db.Query(nil, selectStmt, ColPerson{ID: 50}).Get(&got)
+	c.Assert(err, IsNil)
+	c.Check(got, Equals, ColPerson{ID: 50, Name: "jim"})
+
+	// Without the option the db tags are used.
+	_, err = sqlair.Prepare("SELECT &ColPerson.* FROM person WHERE id = $ColPerson.id", ColPerson{})
+	c.Check(err, ErrorMatches, `cannot prepare statement: input expression: type "ColPerson" has no "id" db tag: \$ColPerson.id`)
+
+	// The tag key survives marshalling.
+	data, err := selectStmt.Marshal()
+	c.Assert(err, IsNil)
+	c.Check(string(data), Matches, `.*"tagName":"col".*`)
+	stmt, err := sqlair.UnmarshalStatement(data, ColPerson{})
+	c.Assert(err, IsNil)
+	err = db.Query(nil, stmt, ColPerson{ID: 50}).Get(&got)
+	c.Assert(err, IsNil)
+	c.Check(got, Equals, ColPerson{ID: 50, Name: "jim"})
+
+	// Statements prepared with different tag keys are not shared.
+	query := "SELECT &ColPerson.* FROM person"
+	stmt1, err := sqlair.PrepareCached(query, ColPerson{}, sqlair.WithTag("col"))
+	c.Assert(err, IsNil)
+	stmt2, err := sqlair.PrepareCached(query, ColPerson{})
+	c.Assert(err, IsNil)
+	c.Check(stmt1 == stmt2, Equals, false)
+}
+
+func (s *PackageSuite) TestGetScalar(c *C) {
+	db, tables := s.personAndAddressDB(c)
+	defer dropTables(c, db, tables...)
+
+	var count int
+	stmt := sqlair.MustPrepare("SELECT COUNT(*) FROM person")
+	err := db.Query(nil, stmt).GetScalar(&count)
+	c.Assert(err, IsNil)
+	c.Check(count, Equals, len(allPeople))
+
+	var name string
+	stmt = sqlair.MustPrepare("SELECT name FROM person WHERE id = $Person.id", Person{})
+	err = db.Query(nil, stmt, mark).GetScalar(&name)
+	c.Assert(err, IsNil)
+	c.Check(name, Equals, mark.Name)
+
+	// Scalars can be read in transactions.
+	tx, err := db.Begin(nil, nil)
+	c.Assert(err, IsNil)
+	err = tx.Query(nil, stmt, fred).GetScalar(&name)
+	c.Assert(err, IsNil)
+	c.Check(name, Equals, fred.Name)
+	c.Assert(tx.Commit(), IsNil)
+
+	err = db.Query(nil, stmt, Person{ID: 1000}).GetScalar(&name)
+	c.Check(errors.Is(err, sqlair.ErrNoRows), Equals, true)
+
+	stmt = sqlair.MustPrepare("SELECT id, name FROM person")
+	err = db.Query(nil, stmt).GetScalar(&name)
+	c.Check(err, ErrorMatches, "cannot get result: expected 1 column in the query results for scalar output, got 2\nresult columns: id, name")
+
+	err = db.Query(nil, stmt).GetScalar(name)
+	c.Check(err, ErrorMatches, "cannot get result: need non-nil pointer for scalar output, got string")
+
+	stmt = sqlair.MustPrepare("SELECT &Person.id FROM person", Person{})
+	err = db.Query(nil, stmt).GetScalar(&count)
+	c.Check(err, ErrorMatches, "cannot get scalar: query has output expressions")
+
+	// Reading a scalar does not change the Query or the copies made of it
+	// with WithContext.
+	stmt = sqlair.MustPrepare("SELECT name FROM person WHERE id = $Person.id", Person{})
+	q := db.Query(nil, stmt, mark)
+	qCtx := q.WithContext(context.Background())
+	err = qCtx.GetScalar(&name)
+	c.Assert(err, IsNil)
+	c.Check(name, Equals, mark.Name)
+	err = q.Get(&name)
+	c.Check(err, ErrorMatches, "cannot get results: output variables provided but not referenced in query")
+	err = qCtx.Get(&name)
+	c.Check(err, ErrorMatches, "cannot get results: output variables provided but not referenced in query")
+}
+
+func (s *PackageSuite) TestPreparePointerTypeSamples(c *C) {
+	db, tables := s.personAndAddressDB(c)
+	defer dropTables(c, db, tables...)
+
+	stmt, err := sqlair.Prepare("SELECT &Person.* FROM person WHERE id = $Person.id", &Person{})
+	c.Assert(err, IsNil)
+	var got Person
+	err = db.Query(nil, stmt, fred).Get(&got)
+	c.Assert(err, IsNil)
+	c.Check(got, Equals, fred)
+
+	stmt, err = sqlair.Prepare("SELECT &Person.* FROM person WHERE id = $M.id", (*Person)(nil), &sqlair.M{})
+	c.Assert(err, IsNil)
+	err = db.Query(nil, stmt, sqlair.M{"id": mark.ID}).Get(&got)
+	c.Assert(err, IsNil)
+	c.Check(got, Equals, mark)
+
+	_, err = sqlair.Prepare("SELECT &Person.* FROM person", new(*Person))
+	c.Check(err, ErrorMatches, "cannot prepare statement: need value or pointer to value, got pointer to pointer")
+}
+
+func (s *PackageSuite) TestBatchSize(c *C) {
+	db := sqlair.NewDB(s.db)
+
+	type Item struct {
+		ID   int    `db:"id"`
+		Name string `db:"name"`
+	}
+	err := db.Query(nil, sqlair.MustPrepare("CREATE TABLE item (id integer PRIMARY KEY, name text)")).Run()
+	c.Assert(err, IsNil)
+	defer dropTables(c, db, "item")
+
+	var items []Item
+	for i := 1; i <= 7; i++ {
+		items = append(items, Item{ID: i, Name: fmt.Sprintf("item %d", i)})
+	}
+	insertStmt := sqlair.MustPrepare("INSERT INTO item (*) VALUES ($Item.*)", Item{})
+	selectStmt := sqlair.MustPrepare("SELECT &Item.* FROM item ORDER BY rowid", Item{})
+	deleteStmt := sqlair.MustPrepare("DELETE FROM item")
+
+	// The rows affected by each batch are summed in the outcome.
+	var outcome sqlair.Outcome
+	err = db.Query(nil, insertStmt, items).BatchSize(3).Get(&outcome)
+	c.Assert(err, IsNil)
+	affected, err := outcome.Result().RowsAffected()
+	c.Assert(err, IsNil)
+	c.Check(affected, Equals, int64(7))
+	lastID, err := outcome.Result().LastInsertId()
+	c.Assert(err, IsNil)
+	c.Check(lastID, Equals, int64(7))
+
+	var got []Item
+	err = db.Query(nil, selectStmt).GetAll(&got)
+	c.Assert(err, IsNil)
+	c.Check(got, DeepEquals, items)
+	c.Assert(db.Query(nil, deleteStmt).Run(), IsNil)
+	got = nil
+
+	// The batches can be run in a transaction.
+	tx, err := db.Begin(nil, nil)
+	c.Assert(err, IsNil)
+	affected, err = tx.Query(nil, insertStmt, items).BatchSize(2).RunAffected(nil)
+	c.Assert(err, IsNil)
+	c.Check(affected, Equals, int64(7))
+	c.Assert(tx.Commit(), IsNil)
+	err = db.Query(nil, selectStmt).GetAll(&got)
+	c.Assert(err, IsNil)
+	c.Check(got, DeepEquals, items)
+	c.Assert(db.Query(nil, deleteStmt).Run(), IsNil)
+	got = nil
+
+	// If a batch fails, none of the rows are inserted.
+	failing := append(items, Item{ID: 1, Name: "duplicate"})
+	err = db.Query(nil, insertStmt, failing).BatchSize(3).Run()
+	c.Check(err, ErrorMatches, "cannot run batch of rows 6 to 7: .*UNIQUE constraint failed.*")
+	err = db.Query(nil, selectStmt).GetAll(&got)
+	c.Check(errors.Is(err, sqlair.ErrNoRows), Equals, true)
+
+	// If a batch fails in a transaction, the earlier batches are rolled back
+	// and the transaction can still be used.
+	tx, err = db.Begin(nil, nil)
+	c.Assert(err, IsNil)
+	err = tx.Query(nil, insertStmt, items).BatchSize(3).Run()
+	c.Assert(err, IsNil)
+	extra := []Item{{ID: 8, Name: "item 8"}, {ID: 9, Name: "item 9"}, {ID: 1, Name: "duplicate"}}
+	err = tx.Query(nil, insertStmt, extra).BatchSize(2).Run()
+	c.Check(err, ErrorMatches, "cannot run batch of rows 2 to 2: .*UNIQUE constraint failed.*")
+	err = tx.Query(nil, selectStmt).GetAll(&got)
+	c.Assert(err, IsNil)
+	c.Check(got, DeepEquals, items)
+	c.Assert(tx.Commit(), IsNil)
+	c.Assert(db.Query(nil, deleteStmt).Run(), IsNil)
+	got = nil
+
+	err = db.Query(nil, insertStmt, items).BatchSize(0).Run()
+	c.Check(err, ErrorMatches, "cannot run batched query: batch size must be positive, got 0")
+
+	err = db.Query(nil, selectStmt).BatchSize(3).GetAll(&got)
+	c.Check(err, ErrorMatches, "cannot run batched query: query contains output expressions")
+}
+
+// StringArray is a slice read from and written to a column in the text form
+// of a Postgres array, e.g. "{a,b}", like the array types of Postgres drivers.
+type StringArray []string
+
+func (a *StringArray) Scan(src any) error {
+	var s string
+	switch v := src.(type) {
+	case nil:
+		*a = nil
+		return nil
+	case string:
+		s = v
+	case []byte:
+		s = string(v)
+	default:
+		return fmt.Errorf("cannot scan %T into StringArray", src)
+	}
+	s = strings.TrimSuffix(strings.TrimPrefix(s, "{"), "}")
+	if s == "" {
+		*a = StringArray{}
+		return nil
+	}
+	*a = strings.Split(s, ",")
+	return nil
+}
+
+func (a StringArray) Value() (driver.Value, error) {
+	if a == nil {
+		return nil, nil
+	}
+	return "{" + strings.Join(a, ",") + "}", nil
+}
+
+func (s *PackageSuite) TestScanSliceField(c *C) {
+	db := sqlair.NewDB(s.db)
+
+	type Post struct {
+		ID   int         `db:"id"`
+		Tags StringArray `db:"tags"`
+	}
+	type IDs []int
+	err := db.Query(nil, sqlair.MustPrepare("CREATE TABLE post (id integer, tags text)")).Run()
+	c.Assert(err, IsNil)
+	defer dropTables(c, db, "post")
+
+	posts := []Post{
+		{ID: 1, Tags: StringArray{"go", "sql"}},
+		{ID: 2, Tags: StringArray{}},
+		{ID: 3, Tags: nil},
+	}
+	insertStmt := sqlair.MustPrepare("INSERT INTO post (*) VALUES ($Post.*)", Post{})
+	err = db.Query(nil, insertStmt, posts).Run()
+	c.Assert(err, IsNil)
+
+	// A slice field is read as a whole and is not confused with slice
+	// inputs.
+	selectStmt := sqlair.MustPrepare("SELECT &Post.* FROM post WHERE id IN ($IDs[:]) ORDER BY id", Post{}, IDs{})
+	var got []Post
+	err = db.Query(nil, selectStmt, IDs{1, 2, 3}).GetAll(&got)
+	c.Assert(err, IsNil)
+	c.Check(got, DeepEquals, posts)
+
+	var post Post
+	tagsStmt := sqlair.MustPrepare("SELECT &Post.tags FROM post WHERE id = $Post.id", Post{})
+	err = db.Query(nil, tagsStmt, Post{ID: 1}).Get(&post)
+	c.Assert(err, IsNil)
+	c.Check(post.Tags, DeepEquals, StringArray{"go", "sql"})
+
+	// A slice input is used alongside a slice field in the input.
+	updateStmt := sqlair.MustPrepare("UPDATE post SET tags = $Post.tags WHERE id IN ($IDs[:])", Post{}, IDs{})
+	err = db.Query(nil, updateStmt, Post{Tags: StringArray{"new"}}, IDs{2, 3}).Run()
+	c.Assert(err, IsNil)
+	got = nil
+	err = db.Query(nil, selectStmt, IDs{2, 3}).GetAll(&got)
+	c.Assert(err, IsNil)
+	c.Check(got, DeepEquals, []Post{{ID: 2, Tags: StringArray{"new"}}, {ID: 3, Tags: StringArray{"new"}}})
+}
+
+func (s *PackageSuite) TestPrepareTimeoutWithOneConn(c *C) {
+	db, tables := s.personAndAddressDB(c)
+	defer dropTables(c, db, tables...)
+	db.PlainDB().SetMaxOpenConns(1)
+
+	// The transaction holds the only connection, so preparing a statement on
+	// the DB waits for a connection until the deadline of the context.
+	tx, err := db.Begin(nil, nil)
+	c.Assert(err, IsNil)
+
+	stmt := sqlair.MustPrepare("SELECT &Person.* FROM person", Person{})
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err = db.Query(ctx, stmt).Run()
+	c.Check(err, ErrorMatches, "cannot prepare statement: timed out, the connection pool may be exhausted: context deadline exceeded")
+	c.Check(errors.Is(err, context.DeadlineExceeded), Equals, true)
+
+	// The timeout option applies when the context has no deadline.
+	timeoutDB := sqlair.NewDB(db.PlainDB(), sqlair.WithPrepareTimeout(20*time.Millisecond))
+	err = timeoutDB.Query(nil, stmt).Run()
+	c.Check(err, ErrorMatches, "cannot prepare statement: timed out, the connection pool may be exhausted: context deadline exceeded")
+
+	// The transaction itself runs the statement on its connection.
+	var people []Person
+	err = tx.Query(nil, stmt).GetAll(&people)
+	c.Assert(err, IsNil)
+	c.Check(people, HasLen, len(allPeople))
+	c.Assert(tx.Commit(), IsNil)
+
+	// Once the connection is returned the statement can be prepared.
+	people = nil
+	err = timeoutDB.Query(nil, stmt).GetAll(&people)
+	c.Assert(err, IsNil)
+	c.Check(people, HasLen, len(allPeople))
+}
+
+func (s *PackageSuite) TestWithClauseOutputs(c *C) {
+	db, tables := s.personAndAddressDB(c)
+	defer dropTables(c, db, tables...)
+
+	// Output expressions in the statement after the WITH clause are read.
+	stmt := sqlair.MustPrepare(`
+		WITH averageID(avgid) AS (SELECT AVG(id) FROM person)
+		SELECT &Person.* FROM person, averageID WHERE id > averageID.avgid ORDER BY id`,
+		Person{},
+	)
+	var got []Person
+	err := db.Query(nil, stmt).GetAll(&got)
+	c.Assert(err, IsNil)
+	c.Check(got, DeepEquals, []Person{dave, mary})
+
+	// Output expressions in a WITH clause are read if the statement selects
+	// all their columns.
+	stmt = sqlair.MustPrepare(`
+		WITH p AS (SELECT &Person.* FROM person WHERE id = $Person.id)
+		SELECT * FROM p`,
+		Person{},
+	)
+	var p Person
+	err = db.Query(nil, stmt, mark).Get(&p)
+	c.Assert(err, IsNil)
+	c.Check(p, Equals, mark)
+
+	_, err = sqlair.Prepare(`
+		WITH averageID(avgid) AS (SELECT &Person.id FROM person)
+		SELECT id FROM person, averageID WHERE id > averageID.avgid LIMIT 1`,
+		Person{},
+	)
+	c.Check(err, ErrorMatches, "cannot prepare statement: output expression &Person.id is in a WITH clause and its columns are not in the query results")
+
+	// The statement must select all columns from the common table
+	// expression holding the output expression.
+	_, err = sqlair.Prepare(`
+		WITH p AS (SELECT &Person.* FROM person), q AS (SELECT 1 AS n)
+		SELECT * FROM q`,
+		Person{},
+	)
+	c.Check(err, ErrorMatches, `cannot prepare statement: output expression &Person.\* is in a WITH clause and its columns are not in the query results`)
+
+	// The common table expression may be given an alias.
+	stmt = sqlair.MustPrepare(`
+		WITH p AS (SELECT &Person.* FROM person WHERE id = $Person.id)
+		SELECT x.* FROM p AS x`,
+		Person{},
+	)
+	err = db.Query(nil, stmt, mary).Get(&p)
+	c.Assert(err, IsNil)
+	c.Check(p, Equals, mary)
+
+	// Output expressions in other subqueries do not reach the results.
+	_, err = sqlair.Prepare(`
+		WITH p AS (SELECT id FROM person)
+		SELECT name FROM person WHERE id IN (SELECT &Person.id FROM p)`,
+		Person{},
+	)
+	c.Check(err, ErrorMatches, "cannot prepare statement: output expression &Person.id is in a subquery and its columns are not in the query results")
+}
+
+func (s *PackageSuite) TestUpdateAssign(c *C) {
+	db, tables := s.personAndAddressDB(c)
+	defer dropTables(c, db, tables...)
+
+	selectStmt := sqlair.MustPrepare("SELECT &Person.* FROM person WHERE id = $Person.id", Person{})
+
+	stmt := sqlair.MustPrepare("UPDATE person SET (*) = ($Person.*) WHERE id = $Person.id", Person{})
+	updated := Person{ID: fred.ID, Name: "Frederick", Postcode: 9999}
+	err := db.Query(nil, stmt, updated).Run()
+	c.Assert(err, IsNil)
+	var p Person
+	err = db.Query(nil, selectStmt, fred).Get(&p)
+	c.Assert(err, IsNil)
+	c.Check(p, DeepEquals, updated)
+
+	stmt = sqlair.MustPrepare("UPDATE person SET (name) = ($Person.*) WHERE id = $Person.id", Person{})
+	err = db.Query(nil, stmt, Person{ID: mark.ID, Name: "Marcus", Postcode: 1}).Run()
+	c.Assert(err, IsNil)
+	err = db.Query(nil, selectStmt, mark).Get(&p)
+	c.Assert(err, IsNil)
+	c.Check(p, DeepEquals, Person{ID: mark.ID, Name: "Marcus", Postcode: mark.Postcode})
+}
+
+type ScannerValuerInts []*ScannerValuerInt
+
+// failingValuer is a driver.Valuer that always fails.
+type failingValuer struct{}
+
+func (failingValuer) Value() (driver.Value, error) {
+	return nil, fmt.Errorf("no value")
+}
+
+func (s *PackageSuite) TestResolveValuers(c *C) {
+	db, tables := s.personAndAddressDB(c)
+	defer dropTables(c, db, tables...)
+
+	ids := ScannerValuerInts{{F: fred.ID}, nil, {F: mark.ID}}
+	for _, opts := range [][]any{nil, {sqlair.ResolveValuers()}, {sqlair.ResolveValuers(), sqlair.NullSafeIn()}} {
+		stmt := sqlair.MustPrepare("SELECT &Person.* FROM person WHERE id IN ($ScannerValuerInts[:]) ORDER BY id", append(opts, Person{}, ScannerValuerInts{})...)
+		var got []Person
+		err := db.Query(nil, stmt, ids).GetAll(&got)
+		c.Assert(err, IsNil)
+		c.Check(got, DeepEquals, []Person{mark, fred})
+	}
+
+	type Values []any
+	stmt := sqlair.MustPrepare("SELECT &Person.* FROM person WHERE id IN ($Values[:])", Person{}, Values{}, sqlair.ResolveValuers())
+	var got []Person
+	err := db.Query(nil, stmt, Values{fred.ID, &ScannerValuerInt{F: mark.ID}, failingValuer{}}).GetAll(&got)
+	c.Check(err, ErrorMatches, `invalid input parameter: invalid element at index 2 of slice "Values": cannot get value of sqlair_test.failingValuer: no value`)
+
+	// The option survives marshalling.
+	data, err := stmt.Marshal()
+	c.Assert(err, IsNil)
+	c.Check(string(data), Matches, `.*"resolveValuers":true.*`)
+	stmt, err = sqlair.UnmarshalStatement(data, Person{}, Values{})
+	c.Assert(err, IsNil)
+	err = db.Query(nil, stmt, Values{failingValuer{}}).GetAll(&got)
+	c.Check(err, ErrorMatches, `invalid input parameter: invalid element at index 0 of slice "Values": cannot get value of sqlair_test.failingValuer: no value`)
+}
+
+func (s *PackageSuite) TestStream(c *C) {
+	db, tables := s.personAndAddressDB(c)
+	defer dropTables(c, db, tables...)
+
+	stmt := sqlair.MustPrepare("SELECT &Person.* FROM person", Person{})
+
+	// All the rows are sent before the error channel receives nil.
+	rows, errs := sqlair.Stream[Person](nil, db.Query(nil, stmt))
+	var people []Person
+	for p := range rows {
+		people = append(people, p)
+	}
+	c.Assert(<-errs, IsNil)
+	c.Check(people, DeepEquals, allPeople)
+	_, ok := <-errs
+	c.Check(ok, Equals, false)
+
+	// Cancelling the context stops the stream early and closes the rows.
+	ctx, cancel := context.WithCancel(context.Background())
+	ptrs, errs := sqlair.Stream[*Person](ctx, db.Query(nil, stmt))
+	p := <-ptrs
+	c.Check(*p, DeepEquals, allPeople[0])
+	cancel()
+	for range ptrs {
+	}
+	c.Assert(<-errs, Equals, context.Canceled)
+	c.Check(db.PlainDB().Stats().InUse, Equals, 0)
+
+	// Errors from the query are sent on the error channel.
+	addresses, errs := sqlair.Stream[Address](nil, db.Query(nil, stmt))
+	for range addresses {
+		c.Fatal("no rows should be sent")
+	}
+	c.Assert(<-errs, ErrorMatches, `cannot get result: parameter with type "Person" missing \(have "Address"\)`)
+}
+
+func (s *PackageSuite) TestRange(c *C) {
+	db, tables := s.personAndAddressDB(c)
+	defer dropTables(c, db, tables...)
+
+	stmt := sqlair.MustPrepare("SELECT &Person.* FROM person", Person{})
+
+	// The module is built with Go versions that do not support range over
+	// functions, so the functions are called directly.
+	var people []Person
+	sqlair.Range[Person](db.Query(nil, stmt))(func(p Person, err error) bool {
+		c.Assert(err, IsNil)
+		people = append(people, p)
+		return true
+	})
+	c.Check(people, DeepEquals, allPeople)
+
+	// Stopping early closes the rows.
+	var ptrs []*Person
+	sqlair.Range[*Person](db.Query(nil, stmt))(func(p *Person, err error) bool {
+		c.Assert(err, IsNil)
+		ptrs = append(ptrs, p)
+		return len(ptrs) < 2
+	})
+	c.Assert(ptrs, HasLen, 2)
+	c.Check(*ptrs[1], DeepEquals, allPeople[1])
+	c.Check(db.PlainDB().Stats().InUse, Equals, 0)
+
+	// Errors are yielded with the zero value.
+	calls := 0
+	sqlair.Range[Address](db.Query(nil, stmt))(func(a Address, err error) bool {
+		calls++
+		c.Check(a, Equals, Address{})
+		c.Check(err, ErrorMatches, `cannot get result: parameter with type "Person" missing \(have "Address"\)`)
+		return true
+	})
+	c.Check(calls, Equals, 1)
+}
+
+func (s *PackageSuite) TestSavepoints(c *C) {
+	db, tables := s.personAndAddressDB(c)
+	defer dropTables(c, db, tables...)
+
+	insertStmt := sqlair.MustPrepare("INSERT INTO person (*) VALUES ($Person.*)", Person{})
+	selectStmt := sqlair.MustPrepare("SELECT &Person.* FROM person WHERE id > 40 ORDER BY id", Person{})
+	p1 := Person{Name: "Ann", ID: 50, Postcode: 100}
+	p2 := Person{Name: "Bob", ID: 60, Postcode: 200}
+	p3 := Person{Name: "Cat", ID: 70, Postcode: 300}
+
+	tx, err := db.Begin(nil, nil)
+	c.Assert(err, IsNil)
+	c.Assert(tx.Query(nil, insertStmt, p1).Run(), IsNil)
+
+	// Rolling back to the savepoint undoes only the work done after it.
+	sp, err := tx.Savepoint(nil, "sp1")
+	c.Assert(err, IsNil)
+	c.Assert(tx.Query(nil, insertStmt, p2).Run(), IsNil)
+	c.Assert(sp.RollbackTo(nil), IsNil)
+
+	// The savepoint can be used again after rolling back to it.
+	c.Assert(tx.Query(nil, insertStmt, p3).Run(), IsNil)
+	c.Assert(sp.Release(nil), IsNil)
+	c.Check(sp.Release(nil), ErrorMatches, "savepoint sp1 already released")
+	c.Check(sp.RollbackTo(nil), ErrorMatches, "savepoint sp1 already released")
+
+	c.Assert(tx.Commit(), IsNil)
+	var got []Person
+	c.Assert(db.Query(nil, selectStmt).GetAll(&got), IsNil)
+	c.Check(got, DeepEquals, []Person{p1, p3})
+
+	// Names that are not identifiers are rejected.
+	tx, err = db.Begin(nil, nil)
+	c.Assert(err, IsNil)
+	_, err = tx.Savepoint(nil, "sp; DROP TABLE person")
+	c.Check(err, ErrorMatches, `cannot create savepoint: invalid identifier "sp; DROP TABLE person"`)
+	sp, err = tx.Savepoint(nil, `"quoted name"`)
+	c.Assert(err, IsNil)
+	c.Assert(tx.Rollback(), IsNil)
+
+	// Savepoints cannot be used after the transaction ends.
+	c.Check(sp.RollbackTo(nil), Equals, sqlair.ErrTXDone)
+	_, err = tx.Savepoint(nil, "sp2")
+	c.Check(err, Equals, sqlair.ErrTXDone)
+}
+
+func (s *PackageSuite) TestNestedStructMembers(c *C) {
+	db, tables := s.personAndAddressDB(c)
+	defer dropTables(c, db, tables...)
+
+	type Household struct {
+		Owner  Person
+		Tenant *Person
+	}
+	stmt := sqlair.MustPrepare(`
+		SELECT p.name AS &Household.Owner.name, q.name AS &Household.Tenant.name
+		FROM person AS p, person AS q
+		WHERE p.id = $Household.Owner.id AND q.id = $Household.Owner.address_id`,
+		Household{},
+	)
+
+	// A nil pointer to a nested struct is allocated for outputs.
+	var got Household
+	err := db.Query(nil, stmt, Household{Owner: Person{ID: fred.ID, Postcode: mark.ID}}).Get(&got)
+	c.Assert(err, IsNil)
+	c.Check(got.Owner.Name, Equals, fred.Name)
+	c.Assert(got.Tenant, NotNil)
+	c.Check(got.Tenant.Name, Equals, mark.Name)
+
+	// A nil pointer to a nested struct is an error for inputs.
+	stmt = sqlair.MustPrepare("SELECT &Person.* FROM person WHERE id = $Household.Tenant.id", Person{}, Household{})
+	var p Person
+	err = db.Query(nil, stmt, Household{}).Get(&p)
+	c.Assert(err, ErrorMatches, `invalid input parameter: cannot get tag "Tenant.id" of struct "Household": nil pointer at field Tenant`)
+
+	// Nested struct members can be inserted.
+	stmt = sqlair.MustPrepare("INSERT INTO person (*) VALUES ($Household.Owner.name, $Household.Owner.id)", Household{})
+	err = db.Query(nil, stmt, Household{Owner: Person{Name: "Nina", ID: 70}}).Run()
+	c.Assert(err, IsNil)
+	err = db.Query(nil, sqlair.MustPrepare("SELECT &Person.* FROM person WHERE id = 70", Person{})).Get(&p)
+	c.Assert(err, IsNil)
+	c.Check(p, Equals, Person{Name: "Nina", ID: 70})
+}
+
+type observedQuery struct {
+	sql          string
+	args         []any
+	err          error
+	rowsAffected int64
+}
+
+type observedKey struct{}
+
+type recordingObserver struct {
+	queries []observedQuery
+	panics  bool
+}
+
+func (o *recordingObserver) BeforeQuery(ctx context.Context, sql string, args []any) context.Context {
+	o.queries = append(o.queries, observedQuery{sql: sql, args: args})
+	if o.panics {
+		panic("before")
+	}
+	return context.WithValue(ctx, observedKey{}, len(o.queries)-1)
+}
+
+func (o *recordingObserver) AfterQuery(ctx context.Context, err error, rowsAffected int64) {
+	if o.panics {
+		panic("after")
+	}
+	i := ctx.Value(observedKey{}).(int)
+	o.queries[i].err = err
+	o.queries[i].rowsAffected = rowsAffected
+}
+
+// afterPanicObserver is an Observer that panics in AfterQuery.
+type afterPanicObserver struct {
+	calls int
+}
+
+func (o *afterPanicObserver) BeforeQuery(ctx context.Context, sql string, args []any) context.Context {
+	return ctx
+}
+
+func (o *afterPanicObserver) AfterQuery(ctx context.Context, err error, rowsAffected int64) {
+	o.calls++
+	panic("after")
+}
+
+func (s *PackageSuite) TestObserver(c *C) {
+	db, tables := s.personAndAddressDB(c)
+	defer dropTables(c, db, tables...)
+
+	obs := &recordingObserver{}
+	observedDB := sqlair.NewDB(db.PlainDB(), sqlair.WithObserver(obs))
+
+	selectStmt := sqlair.MustPrepare("SELECT &Person.* FROM person WHERE id > $Person.id", Person{})
+	var people []Person
+	err := observedDB.Query(nil, selectStmt, Person{ID: 25}).GetAll(&people)
+	c.Assert(err, IsNil)
+	c.Assert(obs.queries, HasLen, 1)
+	c.Check(obs.queries[0].sql, Equals, "SELECT address_id AS _sqlair_0, id AS _sqlair_1, name AS _sqlair_2 FROM person WHERE id > @sqlair_0")
+	c.Check(obs.queries[0].args, DeepEquals, []any{sql.Named("sqlair_0", 25)})
+	c.Check(obs.queries[0].err, IsNil)
+	c.Check(obs.queries[0].rowsAffected, Equals, int64(3))
+
+	// Queries in a transaction are observed.
+	updateStmt := sqlair.MustPrepare("UPDATE person SET address_id = 1 WHERE id > $Person.id", Person{})
+	tx, err := observedDB.Begin(nil, nil)
+	c.Assert(err, IsNil)
+	err = tx.Query(nil, updateStmt, Person{ID: 25}).Run()
+	c.Assert(err, IsNil)
+	c.Assert(tx.Rollback(), IsNil)
+	c.Assert(obs.queries, HasLen, 2)
+	c.Check(obs.queries[1].err, IsNil)
+	c.Check(obs.queries[1].rowsAffected, Equals, int64(3))
+
+	// Errors are passed to AfterQuery.
+	badStmt := sqlair.MustPrepare("SELECT &Person.* FROM no_table", Person{})
+	err = observedDB.Query(nil, badStmt).GetAll(&people)
+	c.Assert(err, NotNil)
+	c.Assert(obs.queries, HasLen, 3)
+	c.Check(obs.queries[2].err, ErrorMatches, ".*no such table: no_table")
+
+	// A panic in BeforeQuery is returned and the query is not run.
+	obs.panics = true
+	people = nil
+	err = observedDB.Query(nil, selectStmt, Person{ID: 25}).GetAll(&people)
+	c.Assert(err, ErrorMatches, "observer panicked in BeforeQuery: before")
+	c.Check(people, HasLen, 0)
+	c.Check(obs.queries, HasLen, 4)
+
+	// A panic in AfterQuery is returned once the query has run.
+	afterObs := &afterPanicObserver{}
+	afterDB := sqlair.NewDB(db.PlainDB(), sqlair.WithObserver(afterObs))
+	err = afterDB.Query(nil, selectStmt, Person{ID: 25}).GetAll(&people)
+	c.Assert(err, ErrorMatches, "observer panicked in AfterQuery: after")
+	err = afterDB.Query(nil, updateStmt, Person{ID: 25}).Run()
+	c.Assert(err, ErrorMatches, "observer panicked in AfterQuery: after")
+
+	// The error of a failed query is returned rather than the panic.
+	err = afterDB.Query(nil, badStmt).GetAll(&people)
+	c.Assert(err, ErrorMatches, ".*no such table: no_table")
+	c.Check(afterObs.calls, Equals, 3)
+
+	// The DB the observer is not set on is not observed.
+	err = db.Query(nil, selectStmt, Person{ID: 25}).GetAll(&people)
+	c.Assert(err, IsNil)
+	c.Check(obs.queries, HasLen, 4)
+}
+
+func (s *PackageSuite) TestPrepareUnexportedField(c *C) {
+	type Account struct {
+		ID       int    `db:"id"`
+		password string `db:"password"`
+	}
+	_, err := sqlair.Prepare("SELECT &Account.* FROM account", Account{})
+	c.Assert(err, ErrorMatches, `cannot prepare statement: field "password" of struct "Account" is unexported but tagged db:"password"`)
+	var fieldErr *sqlair.UnexportedFieldError
+	c.Assert(errors.As(err, &fieldErr), Equals, true)
+	c.Check(fieldErr.Field, Equals, "password")
+	c.Check(fieldErr.Struct, Equals, "Account")
+
+	// Unexported fields without a db tag are ignored.
+	type Note struct {
+		ID   int `db:"id"`
+		note string
+	}
+	_, err = sqlair.Prepare("SELECT &Note.* FROM note", Note{})
+	c.Assert(err, IsNil)
+}
+
+func (s *PackageSuite) TestIntegerLimits(c *C) {
+	db, tables := s.personAndAddressDB(c)
+	defer dropTables(c, db, tables...)
+
+	type Page struct {
+		Size   uint16 `db:"size"`
+		Offset uint   `db:"offset"`
+	}
+	stmt, err := sqlair.Prepare("SELECT &Person.* FROM person ORDER BY id LIMIT $Page.size OFFSET $Page.offset", Person{}, Page{}, sqlair.IntegerLimits())
+	c.Assert(err, IsNil)
+	var people []Person
+	err = db.Query(nil, stmt, Page{Size: 2, Offset: 1}).GetAll(&people)
+	c.Assert(err, IsNil)
+	c.Check(people, DeepEquals, []Person{fred, dave})
+	q := db.Query(nil, stmt, Page{Size: 2, Offset: 1})
+	c.Check(q.DebugParams(), DeepEquals, []any{sql.Named("sqlair_0", int64(2)), sql.Named("sqlair_1", int64(1))})
+
+	_, err = sqlair.Prepare("SELECT &Person.* FROM person LIMIT $Person.name", Person{}, sqlair.IntegerLimits())
+	c.Assert(err, ErrorMatches, `cannot prepare statement: cannot use tag "name" of struct "Person" as LIMIT value: type string is not an integer type`)
+}
+
+func (s *PackageSuite) TestInputConverter(c *C) {
+	sqlair.RegisterInputConverter(func(d Decimal) (any, error) {
+		if d.Scale < 0 {
+			return nil, fmt.Errorf("negative scale")
+		}
+		return fmt.Sprintf("%d/%d", d.Unscaled, d.Scale), nil
+	})
+
+	db := sqlair.NewDB(s.db)
+	err := db.Query(nil, sqlair.MustPrepare("CREATE TABLE price (id integer, amount text)")).Run()
+	c.Assert(err, IsNil)
+	defer dropTables(c, db, "price")
+
+	type Price struct {
+		ID     int     `db:"id"`
+		Amount Decimal `db:"amount"`
+	}
+	insertStmt := sqlair.MustPrepare("INSERT INTO price (*) VALUES ($Price.*)", Price{})
+	err = db.Query(nil, insertStmt, Price{ID: 1, Amount: Decimal{Unscaled: 1250, Scale: 2}}).Run()
+	c.Assert(err, IsNil)
+
+	var amount string
+	err = db.PlainDB().QueryRow("SELECT amount FROM price WHERE id = 1").Scan(&amount)
+	c.Assert(err, IsNil)
+	c.Check(amount, Equals, "1250/2")
+
+	err = db.Query(nil, insertStmt, Price{ID: 2, Amount: Decimal{Scale: -1}}).Run()
+	c.Assert(err, ErrorMatches, `invalid input parameter: cannot convert input value of type sqlair_test.Decimal: negative scale`)
+}
+
+func (s *PackageSuite) TestQueryWithContext(c *C) {
+	db, tables := s.personAndAddressDB(c)
+	defer dropTables(c, db, tables...)
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	stmt := sqlair.MustPrepare("SELECT &Person.* FROM person WHERE id = $Person.id", Person{})
+	q := db.Query(cancelled, stmt, Person{ID: fred.ID})
+	var p Person
+	err := q.Get(&p)
+	c.Assert(err, ErrorMatches, "context canceled")
+
+	// The query can be run again with another context.
+	err = q.WithContext(context.Background()).Get(&p)
+	c.Assert(err, IsNil)
+	c.Check(p, Equals, fred)
+
+	// The original query is not changed.
+	q = db.Query(nil, stmt, Person{ID: fred.ID})
+	err = q.WithContext(cancelled).Get(&p)
+	c.Assert(err, ErrorMatches, "context canceled")
+	err = q.Get(&p)
+	c.Assert(err, IsNil)
+
+	// The queries run for each chunk use the new context.
+	type IDs []int
+	chunkStmt := sqlair.MustPrepare("SELECT &Person.* FROM person WHERE id IN ($IDs[:])", Person{}, IDs{})
+	var people []Person
+	err = db.Query(nil, chunkStmt, IDs{fred.ID, mark.ID}).WithContext(cancelled).GetAllChunked(1, &people)
+	c.Assert(err, ErrorMatches, "context canceled")
+	err = db.Query(cancelled, chunkStmt, IDs{fred.ID, mark.ID}).WithContext(context.Background()).GetAllChunked(1, &people)
+	c.Assert(err, IsNil)
+	c.Check(people, HasLen, 2)
+
+	// Transactions work the same way.
+	tx, err := db.Begin(nil, nil)
+	c.Assert(err, IsNil)
+	defer tx.Rollback()
+	err = tx.Query(cancelled, stmt, Person{ID: mark.ID}).WithContext(context.Background()).Get(&p)
+	c.Assert(err, IsNil)
+	c.Check(p, Equals, mark)
+}
+
+func (s *PackageSuite) TestInsertReturningMultipleTypes(c *C) {
+	db := sqlair.NewDB(s.db)
+	err := db.Query(nil, sqlair.MustPrepare("CREATE TABLE audited_person (name text, id integer, address_id integer, created text DEFAULT 'today')")).Run()
+	c.Assert(err, IsNil)
+	defer dropTables(c, db, "audited_person")
+
+	type Audit struct {
+		Created string `db:"created"`
+	}
+	stmt, err := sqlair.Prepare(
+		"INSERT INTO audited_person (*) VALUES ($Person.*) RETURNING id AS &Person.id, created AS &Audit.created",
+		Person{}, Audit{},
+	)
+	c.Assert(err, IsNil)
+
+	var p Person
+	var a Audit
+	err = db.Query(nil, stmt, fred).Get(&p, &a)
+	c.Assert(err, IsNil)
+	c.Check(p, Equals, Person{ID: fred.ID})
+	c.Check(a, Equals, Audit{Created: "today"})
+
+	// Each row of a bulk insert is returned into both types.
+	stmt, err = sqlair.Prepare(
+		"INSERT INTO audited_person (*) VALUES ($Person.*) RETURNING &Person.*, &Audit.*",
+		Person{}, Audit{},
+	)
+	c.Assert(err, IsNil)
+	var people []Person
+	var audits []Audit
+	err = db.Query(nil, stmt, []Person{mark, mary}).GetAll(&people, &audits)
+	c.Assert(err, IsNil)
+	c.Check(people, DeepEquals, []Person{mark, mary})
+	c.Check(audits, DeepEquals, []Audit{{Created: "today"}, {Created: "today"}})
+}
+
+func (s *PackageSuite) TestUpsertUpdateAssign(c *C) {
+	db := sqlair.NewDB(s.db)
+	err := db.Query(nil, sqlair.MustPrepare("CREATE TABLE upsert_person (name text, id integer PRIMARY KEY, address_id integer)")).Run()
+	c.Assert(err, IsNil)
+	defer dropTables(c, db, "upsert_person")
+
+	stmt := sqlair.MustPrepare(`
+		INSERT INTO upsert_person (*) VALUES ($Person.*)
+		ON CONFLICT (id) DO UPDATE SET (name, address_id) = ($Person.*)`,
+		Person{},
+	)
+	err = db.Query(nil, stmt, []Person{fred, mark}).Run()
+	c.Assert(err, IsNil)
+
+	// Rows that conflict are updated with the values that were not inserted.
+	newFred := Person{ID: fred.ID, Name: "Frederick", Postcode: 2000}
+	err = db.Query(nil, stmt, []Person{newFred, mary}).Run()
+	c.Assert(err, IsNil)
+	err = db.Query(nil, stmt, Person{ID: mark.ID, Name: "Marcus", Postcode: 3000}).Run()
+	c.Assert(err, IsNil)
+
+	var people []Person
+	err = db.Query(nil, sqlair.MustPrepare("SELECT &Person.* FROM upsert_person ORDER BY id", Person{})).GetAll(&people)
+	c.Assert(err, IsNil)
+	c.Check(people, DeepEquals, []Person{{ID: mark.ID, Name: "Marcus", Postcode: 3000}, newFred, mary})
+}
+
+func (s *PackageSuite) TestCatchAllMapOutput(c *C) {
+	db, tables := s.personAndAddressDB(c)
+	defer dropTables(c, db, tables...)
+
+	stmt := sqlair.MustPrepare("SELECT * AS &M.* FROM person WHERE name = $Person.name", sqlair.M{}, Person{})
+	m := sqlair.M{}
+	err := db.Query(nil, stmt, fred).Get(&m)
+	c.Assert(err, IsNil)
+	c.Check(m, DeepEquals, sqlair.M{"name": fred.Name, "id": int64(fred.ID), "address_id": int64(fred.Postcode), "email": nil})
+
+	// Other outputs read their own columns alongside the map.
+	stmt = sqlair.MustPrepare("SELECT &Person.name, p.* AS &M.* FROM person AS p WHERE p.id < 35 ORDER BY p.id", sqlair.M{}, Person{})
+	var people []Person
+	var ms []sqlair.M
+	err = db.Query(nil, stmt).GetAll(&people, &ms)
+	c.Assert(err, IsNil)
+	c.Check(people, DeepEquals, []Person{{Name: mark.Name}, {Name: fred.Name}})
+	c.Check(ms, DeepEquals, []sqlair.M{
+		{"name": mark.Name, "id": int64(mark.ID), "address_id": int64(mark.Postcode), "email": nil},
+		{"name": fred.Name, "id": int64(fred.ID), "address_id": int64(fred.Postcode), "email": nil},
+	})
+}
+
+func (s *PackageSuite) TestIterReusesScanTargets(c *C) {
+	type NullableRow struct {
+		ID int    `db:"id"`
+		N  int    `db:"n"`
+		S  string `db:"s"`
+	}
+	db := sqlair.NewDB(s.db)
+	err := db.Query(nil, sqlair.MustPrepare("CREATE TABLE nullable_row (id integer, n integer, s text)")).Run()
+	c.Assert(err, IsNil)
+	defer dropTables(c, db, "nullable_row")
+	err = db.Query(nil, sqlair.MustPrepare("INSERT INTO nullable_row VALUES (1, 5, 'a'), (2, NULL, NULL), (3, 7, NULL)")).Run()
+	c.Assert(err, IsNil)
+
+	// A NULL is read as the zero value even though the struct and scan
+	// targets are reused for every row.
+	stmt := sqlair.MustPrepare("SELECT &NullableRow.* FROM nullable_row ORDER BY id", NullableRow{})
+	var rows []NullableRow
+	var row NullableRow
+	iter := db.Query(nil, stmt).Iter()
+	for iter.Next() {
+		c.Assert(iter.Get(&row), IsNil)
+		rows = append(rows, row)
+	}
+	c.Assert(iter.Close(), IsNil)
+	expected := []NullableRow{{ID: 1, N: 5, S: "a"}, {ID: 2}, {ID: 3, N: 7}}
+	c.Check(rows, DeepEquals, expected)
+
+	var all []NullableRow
+	err = db.Query(nil, stmt).GetAll(&all)
+	c.Assert(err, IsNil)
+	c.Check(all, DeepEquals, expected)
+
+	// Changing the output arguments between rows is still checked.
+	iter = db.Query(nil, stmt).Iter()
+	c.Assert(iter.Next(), Equals, true)
+	c.Assert(iter.Get(&row), IsNil)
+	c.Assert(iter.Next(), Equals, true)
+	err = iter.Get(&row, &Person{})
+	c.Check(err, ErrorMatches, `cannot get result: "Person" not referenced in query`)
+	c.Assert(iter.Get(&row), IsNil)
+	c.Check(row, DeepEquals, NullableRow{ID: 2})
+	c.Assert(iter.Close(), IsNil)
+}
+
+func (s *PackageSuite) TestMustPrepareWithOptions(c *C) {
+	type ColPerson struct {
+		ID   int    `col:"id"`
+		Name string `col:"name"`
+	}
+	// Options and type samples can be passed in any order.
+	stmt := sqlair.MustPrepare("SELECT &ColPerson.name FROM person WHERE id = $ColPerson.id AND name IN ($S[:])",
+		sqlair.Postgres, ColPerson{}, sqlair.WithTag("col"), sqlair.S{}, sqlair.NullSafeIn())
+	query, params, err := stmt.Bind(ColPerson{ID: 30}, sqlair.S{"Fred"})
+	c.Assert(err, IsNil)
+	c.Check(query, Equals, "SELECT name AS _sqlair_0 FROM person WHERE id = $1 AND name IN ($2)")
+	c.Check(params, DeepEquals, []any{30, "Fred"})
+
+	c.Check(func() {
+		sqlair.MustPrepare("SELECT &ColPerson.name FROM person", sqlair.Postgres, ColPerson{})
+	}, PanicMatches, `cannot prepare statement: output expression: type "ColPerson" has no "name" db tag: &ColPerson.name`)
+}
+
+// UnixNanoTime is a time stored as an integer number of nanoseconds by a
+// converter.
+type UnixNanoTime time.Time
+
+// ValuerTime implements sql.Scanner and driver.Valuer, which take precedence
+// over converters.
+type ValuerTime struct {
+	T time.Time
+}
+
+func (vt *ValuerTime) Scan(src any) error {
+	n, ok := src.(int64)
+	if !ok {
+		return fmt.Errorf("unexpected type %T", src)
+	}
+	vt.T = time.Unix(n, 0).UTC()
+	return nil
+}
+
+func (vt ValuerTime) Value() (driver.Value, error) {
+	return vt.T.Unix(), nil
+}
+
+func (s *PackageSuite) TestRegisterConverter(c *C) {
+	sqlair.RegisterConverter(
+		func(t UnixNanoTime) (any, error) {
+			return time.Time(t).UnixNano(), nil
+		},
+		func(src any) (UnixNanoTime, error) {
+			n, ok := src.(int64)
+			if !ok {
+				return UnixNanoTime{}, fmt.Errorf("unexpected type %T", src)
+			}
+			return UnixNanoTime(time.Unix(0, n).UTC()), nil
+		},
+	)
+	sqlair.RegisterConverter(
+		func(ValuerTime) (any, error) {
+			return nil, fmt.Errorf("converter used")
+		},
+		func(any) (ValuerTime, error) {
+			return ValuerTime{}, fmt.Errorf("converter used")
+		},
+	)
+
+	db := sqlair.NewDB(s.db)
+	err := db.Query(nil, sqlair.MustPrepare("CREATE TABLE event (id integer, at integer, valuer_at integer)")).Run()
+	c.Assert(err, IsNil)
+	defer dropTables(c, db, "event")
+
+	type Event struct {
+		ID       int          `db:"id"`
+		At       UnixNanoTime `db:"at"`
+		ValuerAt ValuerTime   `db:"valuer_at"`
+	}
+	at := time.Date(2024, 3, 1, 12, 30, 0, 123456789, time.UTC)
+	err = db.Query(nil, sqlair.MustPrepare("INSERT INTO event (*) VALUES ($Event.*)", Event{}), Event{
+		ID: 1, At: UnixNanoTime(at), ValuerAt: ValuerTime{T: at},
+	}).Run()
+	c.Assert(err, IsNil)
+
+	var stored, valuerStored int64
+	err = db.PlainDB().QueryRow("SELECT at, valuer_at FROM event WHERE id = 1").Scan(&stored, &valuerStored)
+	c.Assert(err, IsNil)
+	c.Check(stored, Equals, at.UnixNano())
+	c.Check(valuerStored, Equals, at.Unix())
+
+	// The time round-trips without losing precision.
+	var e Event
+	err = db.Query(nil, sqlair.MustPrepare("SELECT &Event.* FROM event", Event{})).Get(&e)
+	c.Assert(err, IsNil)
+	c.Check(time.Time(e.At).Equal(at), Equals, true)
+	c.Check(e.ValuerAt.T.Equal(at.Truncate(time.Second)), Equals, true)
+}
+
+func (s *PackageSuite) TestBulkInsertSliceKinds(c *C) {
+	db, tables := s.personAndAddressDB(c)
+	defer dropTables(c, db, tables...)
+
+	insertPerson := sqlair.MustPrepare("INSERT INTO person (*) VALUES ($Person.*)", Person{})
+	insertM := sqlair.MustPrepare("INSERT INTO person (name, id, address_id) VALUES ($M.*)", sqlair.M{})
+	selectPerson := sqlair.MustPrepare("SELECT &Person.* FROM person WHERE id = $Person.id", Person{})
+	selectM := sqlair.MustPrepare("SELECT &Person.* FROM person WHERE id = $M.id", Person{}, sqlair.M{})
+
+	// Slices of structs, of pointers to structs and of pointers to maps can
+	// all be bulk inserted.
+	err := db.Query(nil, insertPerson, []Person{{ID: 100, Name: "a"}, {ID: 101, Name: "b"}}).Run()
+	c.Assert(err, IsNil)
+	err = db.Query(nil, insertPerson, []*Person{{ID: 102, Name: "c"}, {ID: 103, Name: "d"}}).Run()
+	c.Assert(err, IsNil)
+	err = db.Query(nil, insertM, []*sqlair.M{{"name": "e", "id": 104, "address_id": 0}, {"name": "f", "id": 105, "address_id": 0}}).Run()
+	c.Assert(err, IsNil)
+	var people []Person
+	err = db.Query(nil, sqlair.MustPrepare("SELECT &Person.* FROM person WHERE id >= 100 ORDER BY id", Person{})).GetAll(&people)
+	c.Assert(err, IsNil)
+	c.Check(people, DeepEquals, []Person{
+		{ID: 100, Name: "a"}, {ID: 101, Name: "b"}, {ID: 102, Name: "c"},
+		{ID: 103, Name: "d"}, {ID: 104, Name: "e"}, {ID: 105, Name: "f"},
+	})
+
+	// Outside a bulk insert the same slices are rejected.
+	var p Person
+	err = db.Query(nil, selectPerson, []Person{fred}).Get(&p)
+	c.Check(err, ErrorMatches, `invalid input parameter: type "Person" provided as slice but query uses it as a single value`)
+	err = db.Query(nil, selectPerson, []*Person{&fred}).Get(&p)
+	c.Check(err, ErrorMatches, `invalid input parameter: type "Person" provided as slice but query uses it as a single value`)
+	err = db.Query(nil, selectM, []*sqlair.M{{"id": fred.ID}}).Get(&p)
+	c.Check(err, ErrorMatches, `invalid input parameter: type "M" provided as slice but query uses it as a single value`)
+
+	// Slices of other pointers are never valid.
+	fredPtr := &fred
+	err = db.Query(nil, insertPerson, []**Person{&fredPtr}).Run()
+	c.Check(err, ErrorMatches, `invalid input parameter: need slice of structs or maps, or pointers to them, for bulk insert, got slice of \*\*sqlair_test.Person`)
+	err = db.Query(nil, insertPerson, []*int{}).Run()
+	c.Check(err, ErrorMatches, `invalid input parameter: need slice of structs or maps, or pointers to them, for bulk insert, got slice of \*int`)
+}
+
+func (s *PackageSuite) TestNullOption(c *C) {
+	db, tables := s.personAndAddressDB(c)
+	defer dropTables(c, db, tables...)
+	err := db.Query(nil, sqlair.MustPrepare("INSERT INTO person VALUES ('Nully', NULL, NULL, NULL)")).Run()
+	c.Assert(err, IsNil)
+	err = db.Query(nil, sqlair.MustPrepare("INSERT INTO person VALUES ('Zero', 0, 0, '')")).Run()
+	c.Assert(err, IsNil)
+
+	// A NULL is told apart from a zero value, including after rows that were
+	// not NULL.
+	var people []SentinelPerson
+	stmt := sqlair.MustPrepare("SELECT &SentinelPerson.* FROM person WHERE name IN ('Fred', 'Nully', 'Zero') ORDER BY name", SentinelPerson{})
+	err = db.Query(nil, stmt).GetAll(&people)
+	c.Assert(err, IsNil)
+	c.Check(people, DeepEquals, []SentinelPerson{
+		{ID: fred.ID, Name: fred.Name, Postcode: int64(fred.Postcode), Email: "none"},
+		{ID: -1, Name: "Nully", Postcode: -1, Email: "none"},
+		{ID: 0, Name: "Zero", Postcode: 0, Email: ""},
+	})
+
+	// The null option also applies to numeric fields read with CoerceNumeric.
+	var p SentinelPerson
+	stmt = sqlair.MustPrepare(`SELECT &SentinelPerson.* FROM person WHERE name = "Nully"`, SentinelPerson{}, sqlair.CoerceNumeric())
+	err = db.Query(nil, stmt).Get(&p)
+	c.Assert(err, IsNil)
+	c.Check(p, Equals, SentinelPerson{ID: -1, Name: "Nully", Postcode: -1, Email: "none"})
+}
+
+func (s *PackageSuite) TestInsertMultipleRows(c *C) {
+	db, tables := s.personAndAddressDB(c)
+	defer dropTables(c, db, tables...)
+
+	stmt := sqlair.MustPrepare(`
+		INSERT INTO person (name, id, address_id)
+		VALUES ($Person.name, $Person.id, $Person.address_id),
+		       ($Manager.name, $Manager.id, $Manager.address_id),
+		       ($M.name, $M.id, 0)`,
+		Person{}, Manager{}, sqlair.M{},
+	)
+	err := db.Query(nil, stmt, Person{ID: 100, Name: "a", Postcode: 1}, Manager{ID: 101, Name: "b", Postcode: 2}, sqlair.M{"name": "c", "id": 102}).Run()
+	c.Assert(err, IsNil)
+
+	var people []Person
+	err = db.Query(nil, sqlair.MustPrepare("SELECT &Person.* FROM person WHERE id >= 100 ORDER BY id", Person{})).GetAll(&people)
+	c.Assert(err, IsNil)
+	c.Check(people, DeepEquals, []Person{{ID: 100, Name: "a", Postcode: 1}, {ID: 101, Name: "b", Postcode: 2}, {ID: 102, Name: "c"}})
+
+	_, err = sqlair.Prepare("INSERT INTO person (name, id) VALUES ($Person.name, $Person.id), ($Manager.name)", Person{}, Manager{})
+	c.Check(err, ErrorMatches, `cannot prepare statement: input expression: mismatched number of columns and values in row 2: 2 != 1: .*`)
+}
+
+func (s *PackageSuite) TestIteratorColumns(c *C) {
+	db, tables := s.personAndAddressDB(c)
+	defer dropTables(c, db, tables...)
+
+	stmt := sqlair.MustPrepare("SELECT &Person.*, email FROM person", Person{})
+	iter := db.Query(nil, stmt).Iter()
+	c.Check(iter.Columns(), DeepEquals, []string{"_sqlair_0", "_sqlair_1", "_sqlair_2", "email"})
+	c.Assert(iter.Next(), Equals, true)
+	cols := iter.Columns()
+	cols[3] = "changed"
+	c.Check(iter.Columns()[3], Equals, "email")
+	c.Assert(iter.Close(), IsNil)
+
+	// A query without results has no columns.
+	stmt = sqlair.MustPrepare("UPDATE person SET name = 'Fred' WHERE id = 30")
+	iter = db.Query(nil, stmt).Iter()
+	c.Check(iter.Columns(), IsNil)
+	c.Assert(iter.Close(), IsNil)
+
+	// Neither does a query that failed.
+	iter = db.Query(nil, sqlair.MustPrepare("SELECT &Person.* FROM nonexistent", Person{})).Iter()
+	c.Check(iter.Columns(), IsNil)
+	c.Check(iter.Close(), ErrorMatches, ".*no such table: nonexistent")
+}
+
+func (s *PackageSuite) TestNamedAnonymousStruct(c *C) {
+	db, tables := s.personAndAddressDB(c)
+	defer dropTables(c, db, tables...)
+
+	type row = struct {
+		Name string `db:"name"`
+		ID   int    `db:"id"`
+	}
+	stmt, err := sqlair.Prepare("SELECT &Row.* FROM person WHERE id = $Row.id", sqlair.Named("Row", row{}))
+	c.Assert(err, IsNil)
+	var r row
+	err = db.Query(nil, stmt, row{ID: fred.ID}).Get(&r)
+	c.Assert(err, IsNil)
+	c.Check(r, Equals, row{Name: fred.Name, ID: fred.ID})
+
+	var rows []row
+	stmt = sqlair.MustPrepare("SELECT &Row.* FROM person ORDER BY id", sqlair.Named("Row", &row{}))
+	err = db.Query(nil, stmt).GetAll(&rows)
+	c.Assert(err, IsNil)
+	c.Check(rows, DeepEquals, []row{{mark.Name, mark.ID}, {fred.Name, fred.ID}, {dave.Name, dave.ID}, {mary.Name, mary.ID}})
+
+	// Errors name the struct.
+	err = db.Query(nil, stmt).Get(&Person{})
+	c.Check(err, ErrorMatches, `cannot get result: parameter with type "Row" missing \(have "Person"\)`)
+
+	// A struct can only be given one name.
+	_, err = sqlair.Prepare("SELECT &Other.* FROM person", sqlair.Named("Other", row{}))
+	c.Check(err, ErrorMatches, `cannot prepare statement: cannot name anonymous struct "Other", it is already named "Row"`)
+	_, err = sqlair.Prepare("SELECT &Person.* FROM person", sqlair.Named("Other", Person{}))
+	c.Check(err, ErrorMatches, `cannot prepare statement: cannot name type sqlair_test.Person, need anonymous struct`)
+}
+
+func (s *PackageSuite) TestGetAllMap(c *C) {
+	db, tables := s.personAndAddressDB(c)
+	defer dropTables(c, db, tables...)
+
+	stmt := sqlair.MustPrepare("SELECT &Person.* FROM person", Person{})
+	var byID map[int]Person
+	err := db.Query(nil, stmt).GetAllMap(&byID, "id")
+	c.Assert(err, IsNil)
+	c.Check(byID, DeepEquals, map[int]Person{fred.ID: fred, mark.ID: mark, mary.ID: mary, dave.ID: dave})
+
+	// Rows are added to an existing map of pointers, with a key type that
+	// the member converts to.
+	type Key int64
+	byKey := map[Key]*Person{1: {Name: "Existing"}}
+	err = db.Query(nil, stmt).GetAllMap(&byKey, "address_id")
+	c.Assert(err, IsNil)
+	c.Check(byKey, HasLen, 5)
+	c.Check(byKey[1], DeepEquals, &Person{Name: "Existing"})
+	c.Check(byKey[Key(fred.Postcode)], DeepEquals, &fred)
+
+	// Duplicate keys are an error unless overwriting is allowed.
+	err = db.Query(nil, sqlair.MustPrepare("UPDATE person SET name = 'Fred'")).Run()
+	c.Assert(err, IsNil)
+	ordered := sqlair.MustPrepare("SELECT &Person.* FROM person ORDER BY id", Person{})
+	var byName map[string]Person
+	err = db.Query(nil, ordered).GetAllMap(&byName, "name")
+	c.Check(err, ErrorMatches, `key "name" has value Fred in more than one row`)
+	c.Check(byName, IsNil)
+	err = db.Query(nil, ordered).GetAllMap(&byName, "name", sqlair.AllowOverwrite())
+	c.Assert(err, IsNil)
+	c.Check(byName, DeepEquals, map[string]Person{"Fred": {ID: mary.ID, Name: "Fred", Postcode: mary.Postcode}})
+
+	// No rows.
+	var none map[int]Person
+	err = db.Query(nil, sqlair.MustPrepare("SELECT &Person.* FROM person WHERE id = 0", Person{})).GetAllMap(&none, "id")
+	c.Check(err, Equals, sqlair.ErrNoRows)
+
+	// Invalid arguments.
+	err = db.Query(nil, stmt).GetAllMap(byID, "id")
+	c.Check(err, ErrorMatches, `need pointer to map, got map`)
+	err = db.Query(nil, stmt).GetAllMap(&[]Person{}, "id")
+	c.Check(err, ErrorMatches, `need pointer to map, got pointer to slice`)
+	err = db.Query(nil, stmt).GetAllMap(&map[int]int{}, "id")
+	c.Check(err, ErrorMatches, `need map of structs, got map of int`)
+	err = db.Query(nil, stmt).GetAllMap(&map[int]Person{}, "email")
+	c.Check(err, ErrorMatches, `key "email" is not a member of "Person" read by query`)
+	err = db.Query(nil, stmt).GetAllMap(&map[int]Person{}, "name")
+	c.Check(err, ErrorMatches, `cannot use key "name" of type string as key of map\[int\]sqlair_test.Person`)
+}
+
+func (s *PackageSuite) TestJSONTag(c *C) {
+	db, tables := s.personAndAddressDB(c)
+	defer dropTables(c, db, tables...)
+
+	type Meta struct {
+		Nickname string   `json:"nickname"`
+		Tags     []string `json:"tags,omitempty"`
+	}
+	type Item struct {
+		ID    int               `db:"id"`
+		Meta  Meta              `db:"meta, json"`
+		Attrs map[string]string `db:"attrs,json"`
+	}
+	err := db.Query(nil, sqlair.MustPrepare("CREATE TABLE item (id integer, meta text, attrs text)")).Run()
+	c.Assert(err, IsNil)
+	defer dropTables(c, db, "item")
+
+	insertStmt := sqlair.MustPrepare("INSERT INTO item (*) VALUES ($Item.*)", Item{})
+	items := []Item{
+		{ID: 1, Meta: Meta{Nickname: "one", Tags: []string{"a"}}, Attrs: map[string]string{"colour": "red"}},
+		{ID: 2, Meta: Meta{Nickname: "two"}},
+	}
+	err = db.Query(nil, insertStmt, items).Run()
+	c.Assert(err, IsNil)
+
+	// The columns hold the JSON encoding of the fields and a nil map is NULL.
+	type Raw struct {
+		Meta  string  `db:"meta"`
+		Attrs *string `db:"attrs"`
+	}
+	var raw Raw
+	rawStmt := sqlair.MustPrepare("SELECT &Raw.* FROM item WHERE id = 2", Raw{})
+	err = db.Query(nil, rawStmt).Get(&raw)
+	c.Assert(err, IsNil)
+	c.Check(raw, DeepEquals, Raw{Meta: `{"nickname":"two"}`})
+
+	selectStmt := sqlair.MustPrepare("SELECT &Item.* FROM item ORDER BY id", Item{})
+	var got []Item
+	err = db.Query(nil, selectStmt).GetAll(&got)
+	c.Assert(err, IsNil)
+	c.Check(got, DeepEquals, items)
+
+	// Invalid JSON in the column is an error identifying the field.
+	err = db.Query(nil, sqlair.MustPrepare("UPDATE item SET meta = 'not json' WHERE id = 1")).Run()
+	c.Assert(err, IsNil)
+	var item Item
+	err = db.Query(nil, selectStmt).Get(&item)
+	c.Check(err, ErrorMatches, `cannot get result: cannot unmarshal JSON into tag "meta" of struct "Item": .*`)
+}
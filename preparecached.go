@@ -0,0 +1,89 @@
+// Copyright 2024 Canonical Ltd.
+// Licensed under Apache 2.0, see LICENCE file for details.
+
+package sqlair
+
+import (
+	"reflect"
+	"sync"
+
+	"github.com/canonical/sqlair/internal/expr"
+	"github.com/canonical/sqlair/internal/typeinfo"
+)
+
+// preparedStatement is a Statement in the prepared statement cache along with
+// the type samples and options it was prepared with.
+type preparedStatement struct {
+	samples []sampleKey
+	opts    prepareOptions
+	stmt    *Statement
+}
+
+// sampleKey identifies a type sample in the prepared statement cache. The
+// name is set for samples given a name with Named, which are identified by
+// the name along with the type of the struct.
+type sampleKey struct {
+	name string
+	typ  reflect.Type
+}
+
+// newSampleKey returns the key of the type sample.
+func newSampleKey(sample any) sampleKey {
+	if ns, ok := sample.(typeinfo.NamedSample); ok {
+		return sampleKey{name: ns.Name, typ: typeinfo.SampleType(ns.Sample)}
+	}
+	return sampleKey{typ: typeinfo.SampleType(sample)}
+}
+
+// preparedCache holds the Statements returned by PrepareCached. They are
+// indexed by the normalized form of the query. The mutex must be held when
+// accessing the statements.
+var preparedCache = struct {
+	mutex      sync.Mutex
+	statements map[string][]preparedStatement
+}{statements: map[string][]preparedStatement{}}
+
+// PrepareCached is like [Prepare] but returns the same [Statement] each time
+// it is called with an equivalent query, type samples of the same types and
+// the same options. Queries are equivalent if they differ only in whitespace
+// and comments, so they share the driver prepared statements of the
+// Statement. The SQL run on the database is generated from the query passed to
+// the first call.
+//
+// Statements returned by PrepareCached are never released. It is intended for
+// queries known when the program is written, rather than queries built at run
+// time. Since comments are ignored, queries that differ only in comments such
+// as optimizer hints should be prepared with Prepare.
+func PrepareCached(query string, typeSamples ...any) (*Statement, error) {
+	var opts prepareOptions
+	samples := applyPrepareOptions(&opts, typeSamples)
+	var sampleKeys []sampleKey
+	for _, sample := range samples {
+		sampleKeys = append(sampleKeys, newSampleKey(sample))
+	}
+	key := expr.NormalizeSQL(query)
+
+	preparedCache.mutex.Lock()
+	defer preparedCache.mutex.Unlock()
+	for _, ps := range preparedCache.statements[key] {
+		if reflect.DeepEqual(ps.samples, sampleKeys) && reflect.DeepEqual(ps.opts, opts) {
+			return ps.stmt, nil
+		}
+	}
+
+	parser := expr.NewParser().WithJSONTypes(opts.jsonTypes)
+	parsedExpr, err := parser.Parse(query)
+	if err != nil {
+		return nil, err
+	}
+	stmt, err := bindStatement(parsedExpr, opts, samples)
+	if err != nil {
+		return nil, err
+	}
+	preparedCache.statements[key] = append(preparedCache.statements[key], preparedStatement{
+		samples: sampleKeys,
+		opts:    opts,
+		stmt:    stmt,
+	})
+	return stmt, nil
+}
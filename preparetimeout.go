@@ -0,0 +1,66 @@
+// Copyright 2024 Canonical Ltd.
+// Licensed under Apache 2.0, see LICENCE file for details.
+
+package sqlair
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// prepareTimeout is a DBOption that limits the time spent preparing driver
+// statements.
+type prepareTimeout time.Duration
+
+// applyToDB sets the prepare timeout of the database.
+func (pt prepareTimeout) applyToDB(db *DB) {
+	db.prepareTimeout = time.Duration(pt)
+}
+
+// WithPrepareTimeout returns a [DBOption] that limits the time SQLair waits
+// while preparing a driver statement for a query run with [DB.Query] or
+// [Conn.Query]. Preparing a statement on a [DB] needs a connection from the
+// connection pool. If every connection is held, for example by a transaction
+// when the pool has a single connection, the prepare waits until one is
+// returned, which may be never. With a timeout the query returns an error
+// instead. The deadline of the context of the query applies whether or not
+// this option is used, and a timeout of zero or less means no limit other than
+// the context.
+func WithPrepareTimeout(timeout time.Duration) DBOption {
+	return prepareTimeout(timeout)
+}
+
+// prepareTimeoutError is returned when preparing a driver statement does not
+// finish before the deadline.
+type prepareTimeoutError struct {
+	err error
+}
+
+func (e *prepareTimeoutError) Error() string {
+	return "cannot prepare statement: timed out, the connection pool may be exhausted: " + e.err.Error()
+}
+
+// Unwrap returns the error returned by the driver, so that the error matches
+// context.DeadlineExceeded.
+func (e *prepareTimeoutError) Unwrap() error {
+	return e.err
+}
+
+// prepareContext returns the context used to prepare a driver statement,
+// which is ctx with the prepare timeout of the database applied.
+func (db *DB) prepareContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if db.prepareTimeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, db.prepareTimeout)
+}
+
+// prepareError returns a prepareTimeoutError if err was returned because the
+// deadline of the context used to prepare a statement passed.
+func prepareError(ctx context.Context, err error) error {
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return &prepareTimeoutError{err: err}
+	}
+	return err
+}
@@ -0,0 +1,77 @@
+// Copyright 2024 Canonical Ltd.
+// Licensed under Apache 2.0, see LICENCE file for details.
+
+package sqlair
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/canonical/sqlair/internal/typeinfo"
+)
+
+// Savepoint is a savepoint in a transaction created with [TX.Savepoint]. The
+// work done in the transaction since the savepoint was created can be undone
+// with [Savepoint.RollbackTo] without rolling back the whole transaction.
+type Savepoint struct {
+	tx       *TX
+	name     string
+	released int32
+}
+
+// Savepoint creates a savepoint with the given name in the transaction. The
+// name is written into the SQL, so it must be a valid identifier: a letter or
+// underscore followed by letters, digits and underscores, or a double quoted
+// identifier. Otherwise an error is returned.
+//
+// Savepoints can be nested. Rolling back to or releasing a savepoint also
+// ends the savepoints created after it.
+func (tx *TX) Savepoint(ctx context.Context, name string) (*Savepoint, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if err := typeinfo.ValidateIdent(name); err != nil {
+		return nil, fmt.Errorf("cannot create savepoint: %s", err)
+	}
+	if tx.isDone() {
+		return nil, ErrTXDone
+	}
+	if _, err := tx.sqltx.ExecContext(ctx, "SAVEPOINT "+name); err != nil {
+		return nil, err
+	}
+	return &Savepoint{tx: tx, name: name}, nil
+}
+
+// RollbackTo undoes the work done in the transaction since the savepoint was
+// created. The transaction, and the savepoint, remain active, so more work can
+// be done and rolled back to the savepoint again.
+func (sp *Savepoint) RollbackTo(ctx context.Context) error {
+	return sp.exec(ctx, "ROLLBACK TO SAVEPOINT "+sp.name)
+}
+
+// Release removes the savepoint. The work done since it was created is kept
+// as part of the transaction. The savepoint cannot be used after it is
+// released.
+func (sp *Savepoint) Release(ctx context.Context) error {
+	if err := sp.exec(ctx, "RELEASE SAVEPOINT "+sp.name); err != nil {
+		return err
+	}
+	atomic.StoreInt32(&sp.released, 1)
+	return nil
+}
+
+// exec runs a savepoint statement on the transaction.
+func (sp *Savepoint) exec(ctx context.Context, sql string) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if atomic.LoadInt32(&sp.released) == 1 {
+		return fmt.Errorf("savepoint %s already released", sp.name)
+	}
+	if sp.tx.isDone() {
+		return ErrTXDone
+	}
+	_, err := sp.tx.sqltx.ExecContext(ctx, sql)
+	return err
+}
@@ -6,11 +6,15 @@ package sqlair
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"reflect"
+	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/canonical/sqlair/internal/expr"
+	"github.com/canonical/sqlair/internal/typeinfo"
 )
 
 // M is a convenience type that can be used in input and output expressions to
@@ -32,9 +36,47 @@ type M map[string]any
 // SQLair to pass a slice of input values.
 type S []any
 
+// Ident is a SQL identifier, such as a table or column name, that can be passed
+// as an input to an expression of the form "$Ident.name". Identifiers cannot be
+// query parameters so the identifier is validated and written directly into
+// the SQL. It must either consist of letters, digits and underscores, and not
+// start with a digit, or be enclosed in double quotes.
+//
+// Example:
+//
+//	stmt := sqlair.MustPrepare("SELECT &Person.* FROM $Ident.name", sqlair.Ident(""), Person{})
+//	err := db.Query(ctx, stmt, sqlair.Ident("people")).GetAll(&people)
+type Ident = typeinfo.Ident
+
+// SortDirection is the direction of an ORDER BY clause. A struct field or map
+// value of type SortDirection passed to an input expression is validated and
+// written directly into the SQL, since the direction cannot be a query
+// parameter. It must be "ASC" or "DESC", ignoring case. Likewise, a struct
+// field or map value of type [Ident] is written into the SQL as an identifier.
+//
+// Example:
+//
+//	type Sort struct {
+//		Column    sqlair.Ident         `db:"column"`
+//		Direction sqlair.SortDirection `db:"direction"`
+//	}
+//	stmt := sqlair.MustPrepare("SELECT &Person.* FROM person ORDER BY $Sort.column $Sort.direction", Sort{}, Person{})
+//	err := db.Query(ctx, stmt, Sort{Column: "name", Direction: sqlair.Descending}).GetAll(&people)
+type SortDirection = typeinfo.SortDirection
+
+// The sort directions of an ORDER BY clause.
+const (
+	Ascending  SortDirection = "ASC"
+	Descending SortDirection = "DESC"
+)
+
 var ErrNoRows = sql.ErrNoRows
 var ErrTXDone = sql.ErrTxDone
 
+// ErrTooManyRows is returned by [Query.GetAllLimit] if the query returns more
+// rows than the limit.
+var ErrTooManyRows = errors.New("sql: more rows than limit in result set")
+
 // stmtCache stores the driver prepared statements associated to the SQLair
 // Statement objects.
 var stmtCache = newStatementCache()
@@ -49,6 +91,16 @@ type Statement struct {
 	// generate query values from the input arguments when the Statement is run
 	// on a database.
 	te *expr.TypeBoundExpr
+	// dialect overrides the dialect of the database the Statement is run on.
+	// If it is nil, the dialect of the database is used.
+	dialect *expr.Dialect
+	// pe is the parsed query and bindOpts the options it was bound with. They
+	// are kept so that the Statement can be marshalled.
+	pe       *expr.ParsedExpr
+	bindOpts expr.BindOptions
+	// ignoreUnusedOutputs is true if output arguments passed to Get and GetAll
+	// are ignored when the statement has no output expressions.
+	ignoreUnusedOutputs bool
 }
 
 // Prepare takes a query containing SQLair expressions along with samples of all
@@ -57,22 +109,463 @@ type Statement struct {
 //
 // The type samples passed after the query must contain an instance of every
 // type mentioned in the SQLair expressions in the query. These are used only
-// for type information and can be the zero value of the type.
+// for type information and can be the zero value of the type, or a pointer to
+// it.
+//
+// A [PrepareOption], such as a [Dialect] or [NullSafeIn], may be passed along
+// with the type samples, in any order. An argument is an option if it
+// implements PrepareOption, and a type sample otherwise. Since PrepareOption
+// has an unexported method only values returned by this package are options,
+// so a type sample is never mistaken for one. A Dialect passed to Prepare
+// overrides the dialect of the database whenever the Statement is run.
 func Prepare(query string, typeSamples ...any) (*Statement, error) {
-	parser := expr.NewParser()
+	var opts prepareOptions
+	samples := applyPrepareOptions(&opts, typeSamples)
+
+	parser := expr.NewParser().WithJSONTypes(opts.jsonTypes)
 	parsedExpr, err := parser.Parse(query)
 	if err != nil {
 		return nil, err
 	}
-	typedExpr, err := parsedExpr.BindTypes(typeSamples...)
+	return bindStatement(parsedExpr, opts, samples)
+}
+
+// applyPrepareOptions applies the PrepareOptions found among the type samples
+// to opts and returns the remaining type samples.
+func applyPrepareOptions(opts *prepareOptions, typeSamples []any) []any {
+	var samples []any
+	for _, ts := range typeSamples {
+		if o, ok := ts.(PrepareOption); ok {
+			o.applyToPrepare(opts)
+			// A type marked with JSON is also a type sample.
+			if j, ok := o.(jsonType); ok {
+				samples = append(samples, j.typeSample)
+			}
+			continue
+		}
+		samples = append(samples, ts)
+	}
+	return samples
+}
+
+// bindStatement binds the type samples to the parsed query and returns a new
+// Statement.
+func bindStatement(pe *expr.ParsedExpr, opts prepareOptions, samples []any) (*Statement, error) {
+	bindOpts := expr.BindOptions{
+		NullSafeIn:          opts.nullSafeIn,
+		CoerceNumeric:       opts.coerceNumeric,
+		TextBool:            opts.textBool,
+		CaseInsensitiveTags: opts.caseInsensitiveTags,
+		ColumnPrefix:        opts.columnPrefix,
+		Strict:              opts.strict,
+		TagName:             opts.tagName,
+		ResolveValuers:      opts.resolveValuers,
+		IntegerLimits:       opts.integerLimits,
+		OmitZeroPK:          opts.omitZeroPK,
+	}
+	if len(opts.jsonTypes) > 0 {
+		bindOpts.JSONTypes = map[string]bool{}
+		for _, name := range opts.jsonTypes {
+			bindOpts.JSONTypes[name] = true
+		}
+	}
+	if len(opts.secretKeys) > 0 {
+		bindOpts.SecretKeys = map[string]bool{}
+		for _, key := range opts.secretKeys {
+			bindOpts.SecretKeys[key] = true
+		}
+	}
+	typedExpr, err := pe.BindTypesWithOptions(bindOpts, samples...)
 	if err != nil {
 		return nil, err
 	}
 
-	return stmtCache.newStatement(typedExpr), nil
+	s := stmtCache.newStatement(typedExpr)
+	s.dialect = opts.dialect
+	s.pe = pe
+	s.bindOpts = bindOpts
+	s.ignoreUnusedOutputs = opts.ignoreUnusedOutputs
+	return s, nil
+}
+
+// PrepareOption configures a [Statement] created with [Prepare]. Options are
+// passed to Prepare alongside the type samples.
+type PrepareOption interface {
+	applyToPrepare(*prepareOptions)
+}
+
+// prepareOptions holds the options passed to Prepare.
+type prepareOptions struct {
+	dialect             *expr.Dialect
+	nullSafeIn          bool
+	coerceNumeric       bool
+	textBool            bool
+	caseInsensitiveTags bool
+	ignoreUnusedOutputs bool
+	columnPrefix        expr.ColumnPrefix
+	// jsonTypes are the names of the types marked with JSON.
+	jsonTypes []string
+	// secretKeys are the map keys passed to SecretKeys.
+	secretKeys []string
+	strict     bool
+	// tagName is the struct tag key passed to WithTag.
+	tagName        string
+	resolveValuers bool
+	integerLimits  bool
+	// omitZeroPK is set by InsertReturningPK.
+	omitZeroPK bool
+}
+
+type nullSafeIn struct{}
+
+// applyToPrepare enables NULL-safe IN expressions.
+func (nullSafeIn) applyToPrepare(opts *prepareOptions) {
+	opts.nullSafeIn = true
+}
+
+// NullSafeIn returns a [PrepareOption] that makes IN expressions containing
+// only a slice input also match NULL when the slice contains nil. For example,
+// with the option set,
+//
+//	SELECT &Person.* FROM person WHERE name IN ($S[:])
+//
+// is run with "(name IN (...) OR name IS NULL)" if S contains a nil element. The
+// nil elements are removed from the IN list. If S contains only nil elements
+// the expression becomes "name IS NULL". For NOT IN, a nil element excludes
+// NULL instead, with "(name NOT IN (...) AND name IS NOT NULL)".
+func NullSafeIn() PrepareOption {
+	return nullSafeIn{}
+}
+
+type coerceNumeric struct{}
+
+// applyToPrepare enables coercion of numeric results.
+func (coerceNumeric) applyToPrepare(opts *prepareOptions) {
+	opts.coerceNumeric = true
+}
+
+// CoerceNumeric returns a [PrepareOption] that makes numeric struct fields
+// accept any numeric value returned by the driver, as long as the value can be
+// represented exactly by the type of the field. For example, an int64 can be
+// read into an int32 field if it is in range, and a float64 with no
+// fractional part can be read into an int field. Reading a value that does not
+// fit, or that would be rounded, such as 0.1 into a float32 field or an int64
+// above 2^53 into a float64 field, returns an error. Bool values are not
+// converted. Without this option the conversion is left to database/sql.
+func CoerceNumeric() PrepareOption {
+	return coerceNumeric{}
+}
+
+type textBool struct{}
+
+// applyToPrepare enables reading textual booleans into bool fields.
+func (textBool) applyToPrepare(opts *prepareOptions) {
+	opts.textBool = true
+}
+
+// TextBool returns a [PrepareOption] that makes bool struct fields accept
+// booleans stored as text, as some databases and schemas do. The values
+// "true", "t", "yes", "y", "on" and "1" are read as true and "false", "f",
+// "no", "n", "off" and "0" as false, ignoring case and surrounding spaces.
+// Integers 1 and 0 and native booleans are also accepted. Any other value
+// returns an error. Without this option the conversion is left to
+// database/sql.
+func TextBool() PrepareOption {
+	return textBool{}
+}
+
+type ignoreUnusedOutputs struct{}
+
+// applyToPrepare makes Get and GetAll ignore unused output arguments.
+func (ignoreUnusedOutputs) applyToPrepare(opts *prepareOptions) {
+	opts.ignoreUnusedOutputs = true
+}
+
+// IgnoreUnusedOutputs returns a [PrepareOption] that makes [Query.Get],
+// [Query.GetAll] and the methods built on them ignore the output arguments
+// passed to them if the statement has no output expressions, instead of
+// returning an error. This allows generic code to pass the same output
+// arguments to statements that may or may not read results.
+//
+// Use with care: a statement that was meant to contain output expressions but
+// does not will silently leave the output arguments unchanged. Output
+// arguments of types that are not used in a statement that does have output
+// expressions are still an error.
+func IgnoreUnusedOutputs() PrepareOption {
+	return ignoreUnusedOutputs{}
+}
+
+type caseInsensitiveTags struct{}
+
+// applyToPrepare enables case-insensitive matching of db tags.
+func (caseInsensitiveTags) applyToPrepare(opts *prepareOptions) {
+	opts.caseInsensitiveTags = true
+}
+
+// WithCaseInsensitiveTags returns a [PrepareOption] that matches names against
+// db tags and map keys ignoring case. An exact match is always preferred. It
+// applies where SQLair resolves a name to a member of a type:
+//   - the member names of input and output expressions, e.g. "$Person.ID" and
+//     "&Person.Name", are matched against the db tags of structs;
+//   - the columns of "(col1, col2) AS &Person.*", of
+//     "(col1, col2) VALUES ($Person.*)" and of EXCEPT lists are matched
+//     against the db tags of structs;
+//   - the member names of input expressions are matched against the keys of
+//     map arguments when the statement is run.
+//
+// It does not change the keys set in output maps, which are the member names
+// as written in the query, or the column names collected with [Unclaimed].
+// Output columns are always read by position using their generated aliases,
+// so the case of the column names returned by the database does not matter.
+//
+// If a name matches more than one db tag or map key ignoring case, and none
+// exactly, an error is returned.
+func WithCaseInsensitiveTags() PrepareOption {
+	return caseInsensitiveTags{}
+}
+
+type tagName string
+
+// applyToPrepare sets the key of the struct tags read by SQLair.
+func (tn tagName) applyToPrepare(opts *prepareOptions) {
+	opts.tagName = string(tn)
+}
+
+// WithTag returns a [PrepareOption] that maps struct fields to columns with
+// struct tags of the given key instead of "db". For example, with
+// WithTag("col") the field
+//
+//	ID int `col:"id,omitempty"`
+//
+// is the column "id" and is omitted from inserts when it is zero. The options
+// and the syntax of the tags are the same as for db tags. Fields without a tag
+// of the given key are ignored, even if they have a db tag. The key applies to
+// all the structs used in the statement, including embedded structs. An empty
+// key selects db tags.
+func WithTag(name string) PrepareOption {
+	return tagName(name)
+}
+
+type strict struct{}
+
+// applyToPrepare enables strict checking of the query.
+func (strict) applyToPrepare(opts *prepareOptions) {
+	opts.strict = true
+}
+
+// Strict returns a [PrepareOption] that rejects queries that select columns
+// with an asterisk outside of an output expression in the same query as an
+// output expression, such as:
+//
+//	SELECT *, &Person.id FROM person
+//
+// The columns selected by the asterisk are not read into any output argument,
+// so the query is usually a mistake for "SELECT &Person.* FROM person".
+// Asterisks in subqueries are only rejected if the subquery contains an output
+// expression.
+//
+// Without Strict such queries are accepted. Columns in the results that are
+// not generated by an output expression are discarded, or read into a map
+// wrapped with [Unclaimed], so a query may deliberately select extra columns
+// alongside its output expressions.
+func Strict() PrepareOption {
+	return strict{}
+}
+
+type resolveValuers struct{}
+
+// applyToPrepare enables resolving driver.Valuer elements of slice inputs.
+func (resolveValuers) applyToPrepare(opts *prepareOptions) {
+	opts.resolveValuers = true
+}
+
+// ResolveValuers returns a [PrepareOption] that calls the Value method of the
+// elements of slice inputs, such as "$S[:]", that implement driver.Valuer
+// when the query is built, and passes the results to the database instead of
+// the elements. If Value fails, or returns a value that is not a valid
+// driver.Value, the query fails with an error giving the index of the
+// element. Without the option the elements are passed to the driver as they
+// are and errors are reported by the driver, which does not say which element
+// is at fault.
+//
+// Nil pointer elements are passed as NULL without calling Value.
+func ResolveValuers() PrepareOption {
+	return resolveValuers{}
+}
+
+type integerLimits struct{}
+
+// applyToPrepare enables passing LIMIT and OFFSET values as int64.
+func (integerLimits) applyToPrepare(opts *prepareOptions) {
+	opts.integerLimits = true
+}
+
+// IntegerLimits returns a [PrepareOption] that passes the values of input
+// expressions used in a LIMIT or OFFSET clause, as in
+// "LIMIT $Page.size OFFSET $Page.offset", to the driver as an int64 whatever
+// their integer type. Drivers differ in the integer types they accept, so this
+// keeps paging queries portable. Prepare returns an error if such a struct
+// field does not have an integer type, and the query returns an error if a
+// map value is not an integer or an unsigned value overflows an int64.
+func IntegerLimits() PrepareOption {
+	return integerLimits{}
+}
+
+type secretKeys []string
+
+// applyToPrepare marks the map keys as secret.
+func (sk secretKeys) applyToPrepare(opts *prepareOptions) {
+	opts.secretKeys = append(opts.secretKeys, sk...)
+}
+
+// SecretKeys returns a [PrepareOption] that marks the values of the given map
+// keys as secret, like the "secret" option of a db tag marks the value of a
+// struct field. The values are passed to the database as usual but are
+// replaced with "***" in the parameters returned by [Query.DebugParams]. The
+// keys are matched against the member names in the query, e.g. "ssn" in
+// "$M.ssn", and apply to every map used as an input.
+func SecretKeys(keys ...string) PrepareOption {
+	return secretKeys(keys)
+}
+
+type jsonType struct {
+	typeSample any
+}
+
+// applyToPrepare marks the type of the sample as JSON.
+func (j jsonType) applyToPrepare(opts *prepareOptions) {
+	if ns, ok := j.typeSample.(typeinfo.NamedSample); ok {
+		opts.jsonTypes = append(opts.jsonTypes, ns.Name)
+		return
+	}
+	if t := typeinfo.SampleType(j.typeSample); t != nil {
+		opts.jsonTypes = append(opts.jsonTypes, t.Name())
+	}
+}
+
+// JSON marks the type of a struct sample so that the whole struct can be
+// passed to the database as a single JSON encoded value. It is passed to
+// [Prepare] in place of the type sample, e.g.
+//
+//	stmt, err := sqlair.Prepare(
+//		"INSERT INTO doc (id, data) VALUES ($Key.id, $Doc)",
+//		Key{}, sqlair.JSON(Doc{}),
+//	)
+//
+// An input expression that names the type without a member, "$Doc", passes
+// the struct encoded with [encoding/json] as a string. An output expression
+// with an explicit column, "data AS &Doc", decodes the JSON in the column
+// into the struct. A NULL column sets the struct to its zero value. The
+// members of the struct can still be used individually, as in "$Doc.title",
+// and are then matched against its db tags as usual.
+//
+// This is different to the text option of a db tag, which marshals a single
+// field with [encoding.TextMarshaler]. JSON encodes a whole struct, using its
+// json tags, into one column.
+func JSON(typeSample any) PrepareOption {
+	return jsonType{typeSample: typeSample}
+}
+
+// Named gives an anonymous struct a name so that it can be used in a query
+// without declaring a named type. It is passed to [Prepare] in place of the
+// type sample, e.g.
+//
+//	type row = struct {
+//		N int `db:"n"`
+//	}
+//	stmt, err := sqlair.Prepare("SELECT count(*) AS &Row.n FROM person", sqlair.Named("Row", row{}))
+//
+// The query arguments are values of, or pointers to, the anonymous struct
+// itself. Identical anonymous structs are the same Go type, so a struct can
+// only be given one name. Preparing a statement that gives it a different
+// name returns an error.
+func Named(name string, typeSample any) any {
+	return typeinfo.NamedSample{Name: name, Sample: typeSample}
+}
+
+// ColumnPrefix sets how the table name of columns generated from an asterisk,
+// such as "t" in "t.* AS &T.*", is added to the column names. A ColumnPrefix
+// is passed to [Prepare] alongside the type samples.
+type ColumnPrefix struct {
+	prefix expr.ColumnPrefix
+}
+
+var (
+	// TableQualifier qualifies the generated columns with the table name,
+	// e.g. "t.col". It is the default.
+	TableQualifier = ColumnPrefix{prefix: expr.TableQualifier}
+
+	// UnderscorePrefix joins the table name and the column name with an
+	// underscore, e.g. "t_col". It suits views and subqueries whose column
+	// names carry the name of the table they come from.
+	UnderscorePrefix = ColumnPrefix{prefix: expr.UnderscorePrefix}
+)
+
+// applyToPrepare sets the column prefix of the statement.
+func (p ColumnPrefix) applyToPrepare(opts *prepareOptions) {
+	opts.columnPrefix = p.prefix
+}
+
+// HasOutputs returns true if the Statement contains output expressions, that
+// is, if running it returns results that can be scanned into output arguments.
+func (s *Statement) HasOutputs() bool {
+	return s.te.HasOutputs()
+}
+
+// HasInputs returns true if the Statement contains input expressions, that is,
+// if input arguments must be passed when it is run.
+func (s *Statement) HasInputs() bool {
+	return s.te.HasInputs()
+}
+
+// StatementDescription describes the Go values used as the inputs and outputs
+// of a [Statement], in the order they appear in the query.
+type StatementDescription = expr.Description
+
+// MemberDescription describes a Go value used as an input or output of a
+// [Statement]: the SQLair expression and column it appears in, the type and
+// member that hold it and its kind.
+type MemberDescription = expr.MemberDescription
+
+// Describe returns a description of the inputs and outputs of the Statement.
+// It is intended for tools that generate documentation of queries.
+//
+// Members of a map used with an asterisk whose keys are only known when the
+// query is run, as in "SET (*) = ($M.*)", are described once with the member "*".
+func (s *Statement) Describe() StatementDescription {
+	return s.te.Describe()
+}
+
+// Bind binds the input arguments to the Statement as they are bound when it is
+// run, and returns the generated SQL and query parameters without running it.
+// The arguments are validated, slices are expanded and the parameters are
+// generated, so Bind can be used to test the queries of an application without
+// a database.
+//
+// The SQL is generated for the dialect passed to [Prepare], or otherwise the
+// default dialect set with [SetDefaultDialect].
+func (s *Statement) Bind(inputArgs ...any) (sql string, params []any, err error) {
+	dialect := s.dialect
+	if dialect == nil {
+		dialect = getDefaultDialect().dialect
+	}
+	pq, err := s.te.BindInputsWithDialect(dialect, inputArgs...)
+	if err != nil {
+		return "", nil, err
+	}
+	return pq.SQL(), pq.Params(), nil
+}
+
+// dialectOn returns the dialect used to run the Statement on the database.
+func (s *Statement) dialectOn(db *DB) *expr.Dialect {
+	if s.dialect != nil {
+		return s.dialect
+	}
+	return db.dialect
 }
 
-// MustPrepare is the same as [Prepare] except that it panics on error.
+// MustPrepare is the same as [Prepare] except that it panics on error. It
+// takes the same options as Prepare, passed along with the type samples.
 func MustPrepare(query string, typeSamples ...any) *Statement {
 	s, err := Prepare(query, typeSamples...)
 	if err != nil {
@@ -81,20 +574,110 @@ func MustPrepare(query string, typeSamples ...any) *Statement {
 	return s
 }
 
+// ParseError is an error at a position in a SQLair query. The errors returned
+// by [CheckSyntax] are of this type when their position is known.
+type ParseError = expr.ParseError
+
+// UnexportedFieldError is returned by [Prepare] when a struct type sample has
+// an unexported field with a db tag. Use [errors.As] to match it.
+type UnexportedFieldError = typeinfo.UnexportedFieldError
+
+// CheckSyntax parses the query and returns every syntax error found in it
+// rather than stopping at the first, as [Prepare] does. It is intended for
+// tooling that validates queries. It returns nil if the query has no syntax
+// errors. The type samples and options passed to Prepare may also be passed,
+// so that the types marked with [JSON] can be used without a member as they
+// are in Prepare. Types are not otherwise checked.
+func CheckSyntax(query string, typeSamples ...any) []error {
+	var opts prepareOptions
+	applyPrepareOptions(&opts, typeSamples)
+	_, errs := expr.NewParser().WithJSONTypes(opts.jsonTypes).ParseAll(query)
+	return errs
+}
+
+// InsertReturningPK prepares a [Statement] that inserts a row from a struct of
+// the same type as typeSample into table and returns the primary key generated
+// for it. The primary key field of the struct is marked with the "pk" option in
+// its db tag, e.g. `db:"id,pk"`. A primary key with a zero value is omitted from
+// the insert so that the database can generate it.
+//
+// The statement is run with the struct as the input and a pointer to the same
+// struct as the output to scan the primary key back into it:
+//
+//	stmt, err := sqlair.InsertReturningPK("person", Person{})
+//	err = db.Query(ctx, stmt, &p).Get(&p)
+//
+// The database must support the RETURNING clause.
+func InsertReturningPK(table string, typeSample any) (*Statement, error) {
+	if err := typeinfo.ValidateIdent(table); err != nil {
+		return nil, fmt.Errorf("cannot prepare insert statement: table name: %s", err)
+	}
+	// A struct given a name with Named is referred to by that name.
+	sample, name := typeSample, ""
+	if ns, ok := typeSample.(typeinfo.NamedSample); ok {
+		sample, name = ns.Sample, ns.Name
+	}
+	t := typeinfo.SampleType(sample)
+	if t == nil {
+		return nil, fmt.Errorf("cannot prepare insert statement: need struct, got nil")
+	}
+	if name == "" {
+		name = t.Name()
+	}
+	pk, err := typeinfo.PrimaryKey(t)
+	if err != nil {
+		return nil, fmt.Errorf("cannot prepare insert statement: %s", err)
+	}
+	query := fmt.Sprintf("INSERT INTO %s (*) VALUES ($%s.*) RETURNING &%s.%s", table, name, name, pk)
+	parsedExpr, err := expr.NewParser().Parse(query)
+	if err != nil {
+		return nil, err
+	}
+	return bindStatement(parsedExpr, prepareOptions{omitZeroPK: true}, []any{typeSample})
+}
+
 type DB struct {
 	// cacheID is used to look up the cached driver prepared statements prepared
 	// on this database.
 	cacheID uint64
 	// sqldb is the underlying database/sql DB object.
 	sqldb *sql.DB
+	// dialect is the SQL dialect of the database.
+	dialect *expr.Dialect
+	// sqlComment, if set, provides the key-values of a comment appended to
+	// the SQL of each query.
+	sqlComment sqlComment
+	// busyRetry, if set, is the policy for retrying queries that fail
+	// because the database is busy.
+	busyRetry *busyRetry
+	// prepareTimeout, if positive, limits the time spent preparing a driver
+	// statement.
+	prepareTimeout time.Duration
+	// maxCachedStmts, if positive, is the maximum number of driver
+	// statements prepared on this database that are kept in the cache.
+	maxCachedStmts int
+	// observer, if set, is notified before and after each query.
+	observer Observer
+}
+
+// DBOption configures a [DB] created with [NewDB].
+type DBOption interface {
+	applyToDB(*DB)
 }
 
-// NewDB creates a new [sqlair.DB] from a [sql.DB].
-func NewDB(sqldb *sql.DB) *DB {
+// NewDB creates a new [sqlair.DB] from a [sql.DB]. The options are applied to
+// the DB in order. If no [Dialect] is passed, the DB uses the default dialect
+// set with [SetDefaultDialect].
+func NewDB(sqldb *sql.DB, opts ...DBOption) *DB {
 	if sqldb == nil {
 		return nil
 	}
-	return stmtCache.newDB(sqldb)
+	db := stmtCache.newDB(sqldb)
+	db.dialect = getDefaultDialect().dialect
+	for _, opt := range opts {
+		opt.applyToDB(db)
+	}
+	return db
 }
 
 // PlainDB returns the underlying database object.
@@ -105,20 +688,42 @@ func (db *DB) PlainDB() *sql.DB {
 // Query represents a query on a database. It is designed to be run once and
 // used immediately since it contains the query context.
 type Query struct {
-	// run executes the Query against the DB or the TX. It returns the results
-	// and a pointer to the driverStmt used to run the query if it needs to be
-	// kept in memory.
-	run func(context.Context) (*sql.Rows, sql.Result, *driverStmt, error)
-	ctx context.Context
-	err error
-	pq  *expr.PrimedQuery
+	// run executes the primed query of the Query against the DB or the TX. It
+	// returns the results and a pointer to the driverStmt used to run the
+	// query if it needs to be kept in memory.
+	run func(context.Context, *expr.PrimedQuery) (*sql.Rows, sql.Result, *driverStmt, error)
+	// cacheState reports if running the Query will use a cached driverStmt.
+	cacheState func(context.Context) CacheState
+	ctx        context.Context
+	err        error
+	pq         *expr.PrimedQuery
+	// inputArgs are the input arguments the Query was built with.
+	inputArgs []any
+	// sliceInputTypes are the types of the slices used in slice input
+	// expressions in the Statement.
+	sliceInputTypes []reflect.Type
+	// rebind builds a new Query from the same Statement and context with
+	// different input arguments.
+	rebind func(inputArgs []any) *Query
+	// ignoreUnusedOutputs is copied from the Statement.
+	ignoreUnusedOutputs bool
+	// batchSize is the maximum number of bulk insert rows run in one
+	// statement. Zero means no limit.
+	batchSize int
+	// transact runs the batches of a bulk insert in a transaction.
+	transact transactFunc
+	// observer, if set, is notified before and after the query is run.
+	observer Observer
 }
 
 // Iterator is used to iterate over the results of the query.
 type Iterator struct {
-	pq      *expr.PrimedQuery
-	rows    *sql.Rows
-	cols    []string
+	pq   *expr.PrimedQuery
+	rows *sql.Rows
+	cols []string
+	// scanner generates the scan arguments of each row. It is created when
+	// the first row is read.
+	scanner *expr.RowScanner
 	err     error
 	result  sql.Result
 	started bool
@@ -126,6 +731,15 @@ type Iterator struct {
 	// so that it cannot be closed by finalizer while the rows are being
 	// iterated over. This finalizer can be set in the cache.
 	ds *driverStmt
+	// observed, if set, is called when the query is finished with the
+	// number of rows read or affected. It returns an error if the observer
+	// panics.
+	observed func(err error, rowsAffected int64) error
+	rowsRead int64
+	// copyRawBytes is set when the results are used after the rows are
+	// closed. The contents of sql.RawBytes fields are then copied out of the
+	// driver's memory.
+	copyRawBytes bool
 }
 
 // Query builds a new query from a context, a [Statement] and the input
@@ -139,16 +753,23 @@ func (db *DB) Query(ctx context.Context, s *Statement, inputArgs ...any) *Query
 		ctx = context.Background()
 	}
 
-	pq, err := s.te.BindInputs(inputArgs...)
+	pq, err := s.te.BindInputsWithDialect(s.dialectOn(db), inputArgs...)
 	if err != nil {
 		return &Query{ctx: ctx, err: err}
 	}
 
-	run := func(innerCtx context.Context) (rows *sql.Rows, result sql.Result, ds *driverStmt, err error) {
+	run := func(innerCtx context.Context, pq *expr.PrimedQuery) (rows *sql.Rows, result sql.Result, ds *driverStmt, err error) {
+		if comment := db.commentFor(innerCtx); comment != "" {
+			rows, result, err = runCommented(innerCtx, pq, comment, db.sqldb)
+			return rows, result, nil, err
+		}
 		primedSQL := pq.SQL()
 		ds, ok := stmtCache.lookupStmt(db, s, primedSQL)
-		if !ok {
-			ds, err = stmtCache.driverPrepareStmt(ctx, db, s, primedSQL)
+		stmtCache.countLookup(ok)
+		if ok {
+			stmtCache.markUsed(ds)
+		} else {
+			ds, err = stmtCache.driverPrepareStmt(innerCtx, db, s, primedSQL)
 			if err != nil {
 				return nil, nil, ds, err
 			}
@@ -162,7 +783,101 @@ func (db *DB) Query(ctx context.Context, s *Statement, inputArgs ...any) *Query
 		return rows, result, ds, err
 	}
 
-	return &Query{pq: pq, run: run, ctx: ctx, err: nil}
+	cacheState := func(ctx context.Context) CacheState {
+		if db.commentFor(ctx) != "" {
+			return CacheUncacheable
+		}
+		if _, ok := stmtCache.lookupStmt(db, s, pq.SQL()); ok {
+			return CacheHit
+		}
+		return CacheMiss
+	}
+
+	rebind := func(inputArgs []any) *Query {
+		return db.Query(ctx, s, inputArgs...)
+	}
+
+	return &Query{
+		pq:                  pq,
+		run:                 db.busyRetry.retry(run),
+		cacheState:          cacheState,
+		ctx:                 ctx,
+		err:                 nil,
+		inputArgs:           inputArgs,
+		sliceInputTypes:     s.te.SliceInputTypes(),
+		rebind:              rebind,
+		ignoreUnusedOutputs: s.ignoreUnusedOutputs,
+		observer:            db.observer,
+		transact: transactNew(db, s, func(ctx context.Context) (*sql.Tx, error) {
+			return db.sqldb.BeginTx(ctx, nil)
+		}),
+	}
+}
+
+// CacheState reports whether running the query will reuse a driver prepared
+// statement from the statement cache. It is intended as an aid for debugging
+// performance and does not run the query.
+//
+// Only the most recently generated SQL of each [Statement] is cached for each
+// [DB]. Statements with slice inputs, bulk inserts or identifiers generate SQL
+// that depends on the input arguments, so running them with different inputs
+// may require a new driver prepared statement.
+func (q *Query) CacheState() CacheState {
+	if q.err != nil || q.cacheState == nil {
+		return CacheUncacheable
+	}
+	return q.cacheState(q.ctx)
+}
+
+// DebugSQL returns the SQL generated for the query in a form intended for
+// logging and debugging. The rows of bulk inserts are written on separate
+// lines, each preceded by a comment with the row number, to make the
+// placeholders of each row easy to find. If the query could not be built, the
+// empty string is returned.
+func (q *Query) DebugSQL() string {
+	if q.err != nil {
+		return ""
+	}
+	return q.pq.DebugSQL()
+}
+
+// DebugParams returns the query parameters in a form intended for logging and
+// debugging, in the order they are passed to the database. The values of struct
+// fields with the "secret" option in their db tag, and of map keys passed to
+// [SecretKeys], are replaced with "***". The database still receives the real
+// values. If the query could not be built, nil is returned.
+func (q *Query) DebugParams() []any {
+	if q.err != nil {
+		return nil
+	}
+	return q.pq.DebugParams()
+}
+
+// WithContext returns a copy of the query that is run with ctx instead of the
+// context it was built with. This allows a query to be built once and run
+// several times, each under its own deadline:
+//
+//	q := db.Query(nil, stmt, Person{ID: 1})
+//	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+//	defer cancel()
+//	err := q.WithContext(ctx).Get(&p)
+//
+// Methods that take a context, such as [Query.Each], use the one passed to
+// them unless it is nil. The query is not modified. If ctx is nil the copy
+// keeps the context of the query.
+func (q *Query) WithContext(ctx context.Context) *Query {
+	if ctx == nil {
+		ctx = q.ctx
+	}
+	nq := *q
+	nq.ctx = ctx
+	if q.rebind != nil {
+		// The queries built for chunks and batches must also use ctx.
+		nq.rebind = func(inputArgs []any) *Query {
+			return q.rebind(inputArgs).WithContext(ctx)
+		}
+	}
+	return &nq
 }
 
 // Run is used to run a query on a database and disregard any results.
@@ -171,6 +886,57 @@ func (q *Query) Run() error {
 	return q.Get()
 }
 
+// RunAffected runs a query that has no output expressions and returns the
+// number of rows affected by it. It is a shorthand for running the query with
+// an [Outcome] and calling RowsAffected on the result. If ctx is nil the
+// context of the query is used.
+func (q *Query) RunAffected(ctx context.Context) (int64, error) {
+	if q.err != nil {
+		return 0, q.err
+	}
+	if q.pq.HasOutputs() {
+		return 0, fmt.Errorf("cannot get rows affected: query contains output expressions")
+	}
+	if ctx == nil {
+		ctx = q.ctx
+	}
+
+	var result sql.Result
+	batched := false
+	var err error
+	if q.batchSize > 0 {
+		result, batched, err = q.runBatches(ctx)
+	}
+	if !batched {
+		result, err = q.exec(ctx)
+	}
+	if err != nil {
+		return 0, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("cannot get rows affected: %s", err)
+	}
+	return affected, nil
+}
+
+// exec runs a query that has no output expressions and returns its result.
+func (q *Query) exec(ctx context.Context) (sql.Result, error) {
+	if q.err != nil {
+		return nil, q.err
+	}
+	var outcome Outcome
+	iter := q.iter(ctx)
+	err := iter.Get(&outcome)
+	if cerr := iter.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return nil, err
+	}
+	return outcome.Result(), nil
+}
+
 // Get runs the query and decodes the first row returned into the provided output
 // arguments. It returns [ErrNoRows] if output arguments were provided but no
 // results were found.
@@ -189,11 +955,25 @@ func (q *Query) Get(outputArgs ...any) error {
 		}
 	}
 	if !q.pq.HasOutputs() && len(outputArgs) > 0 {
-		return fmt.Errorf("cannot get results: output variables provided but not referenced in query")
+		if !q.ignoreUnusedOutputs {
+			return fmt.Errorf("cannot get results: output variables provided but not referenced in query")
+		}
+		outputArgs = nil
+	}
+
+	if q.batchSize > 0 {
+		result, batched, err := q.runBatches(q.ctx)
+		if batched {
+			if err == nil && outcome != nil {
+				outcome.result = result
+			}
+			return err
+		}
 	}
 
 	var err error
 	iter := q.Iter()
+	iter.copyRawBytes = true
 	if outcome != nil {
 		err = iter.Get(outcome)
 	}
@@ -213,25 +993,223 @@ func (q *Query) Get(outputArgs ...any) error {
 	return err
 }
 
+// GetScalar runs a query without output expressions, such as
+//
+//	SELECT COUNT(*) FROM person WHERE name = $Person.name
+//
+// and scans the single column of the first row returned into dest, which must
+// be a non-nil pointer, e.g. a *int or a *string. It returns an error if the
+// results have more than one column, and [ErrNoRows] if no rows are returned.
+// The value is converted into dest by [database/sql] as with [sql.Row.Scan].
+//
+// Queries with output expressions should be read with [Query.Get].
+func (q *Query) GetScalar(dest any) error {
+	if q.err != nil {
+		return q.err
+	}
+	pq, err := q.pq.ReadScalar()
+	if err != nil {
+		return fmt.Errorf("cannot get scalar: %s", err)
+	}
+	sq := *q
+	sq.pq = pq
+	return sq.Get(dest)
+}
+
 // Iter returns an [Iterator] to iterate through the results row by row.
 // [Iterator.Close] must be run once iteration is finished.
 func (q *Query) Iter() *Iterator {
+	return q.iter(q.ctx)
+}
+
+// Each runs the query and calls fn once for each row returned. The row can be
+// decoded by calling [Iterator.Get] on the iterator passed to fn. Iteration
+// stops early if fn returns an error. The iterator is always closed before
+// Each returns.
+//
+// The error returned from fn is returned from Each. Otherwise, any error
+// encountered during iteration or when closing the iterator is returned.
+// If ctx is nil the context of the query is used.
+func (q *Query) Each(ctx context.Context, fn func(*Iterator) error) (err error) {
+	if ctx == nil {
+		ctx = q.ctx
+	}
+	iter := q.iter(ctx)
+	defer func() {
+		if cerr := iter.Close(); err == nil {
+			err = cerr
+		}
+	}()
+	for iter.Next() {
+		if err := fn(iter); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Do runs the query and calls fn once for each row returned, with the row
+// scanned into a new value of type T. Iteration stops early if fn returns an
+// error. The rows are not collected into a slice, so Do can process large
+// results with little memory. It works as [Query.Each], which it is built on,
+// and the iterator is always closed before Do returns.
+//
+// T must be the only output type of the query. It may be a struct, a pointer
+// to a struct or a map. If T is a pointer, a new value is allocated for each
+// row. If ctx is nil the context of the query is used.
+//
+// Example:
+//
+//	err := sqlair.Do(ctx, db.Query(ctx, stmt), func(p Person) error {
+//		fmt.Println(p.Name)
+//		return nil
+//	})
+func Do[T any](ctx context.Context, q *Query, fn func(T) error) error {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	return q.Each(ctx, func(iter *Iterator) error {
+		// The row may be kept by fn after the iterator moves on.
+		iter.copyRawBytes = true
+		var row T
+		var outputArg any
+		switch t.Kind() {
+		case reflect.Pointer:
+			row = reflect.New(t.Elem()).Interface().(T)
+			outputArg = row
+		case reflect.Map:
+			row = reflect.MakeMap(t).Interface().(T)
+			outputArg = row
+		default:
+			outputArg = &row
+		}
+		if err := iter.Get(outputArg); err != nil {
+			return err
+		}
+		return fn(row)
+	})
+}
+
+// errStopRange is returned to Do by the callback of Range when the loop body
+// stops the iteration.
+var errStopRange = errors.New("range stopped")
+
+// Range returns a function that runs the query and yields each row, scanned
+// into a new value of type T as in [Do], along with a nil error. If an error
+// occurs it is yielded once with the zero value of T, after which iteration
+// ends. With Go 1.23 or later the function can be used in a range loop:
+//
+//	for p, err := range sqlair.Range[Person](db.Query(ctx, stmt)) {
+//		if err != nil {
+//			return err
+//		}
+//		fmt.Println(p.Name)
+//	}
+//
+// The query is run with its own context when iteration starts, and the rows
+// are closed when the loop ends, including when it is left early with break
+// or return.
+func Range[T any](q *Query) func(yield func(T, error) bool) {
+	return func(yield func(T, error) bool) {
+		err := Do(nil, q, func(row T) error {
+			if !yield(row, nil) {
+				return errStopRange
+			}
+			return nil
+		})
+		if err != nil && err != errStopRange {
+			var zero T
+			yield(zero, err)
+		}
+	}
+}
+
+// Stream runs the query and sends each row, scanned into a new value of type
+// T as in [Do], on the returned row channel. The row channel is closed when
+// the rows run out or an error occurs. The error channel then receives the
+// error, or nil, and is closed.
+//
+// To stop early, cancel ctx. The rows are closed and the error channel
+// receives the error of the context. The consumer must either read the row
+// channel until it is closed or cancel ctx, otherwise the goroutine sending
+// the rows and the database connection it holds are never released. If ctx
+// is nil the context of the query is used.
+//
+// Example:
+//
+//	ctx, cancel := context.WithCancel(ctx)
+//	defer cancel()
+//	rows, errs := sqlair.Stream[Person](ctx, db.Query(ctx, stmt))
+//	for p := range rows {
+//		fmt.Println(p.Name)
+//	}
+//	if err := <-errs; err != nil {
+//		return err
+//	}
+func Stream[T any](ctx context.Context, q *Query) (<-chan T, <-chan error) {
+	if ctx == nil {
+		ctx = q.ctx
+	}
+	rows := make(chan T)
+	errs := make(chan error, 1)
+	go func() {
+		defer close(errs)
+		err := Do(ctx, q, func(row T) error {
+			// Stop as soon as the context is done, even if the consumer is
+			// still reading rows.
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			select {
+			case rows <- row:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+		close(rows)
+		errs <- err
+	}()
+	return rows, errs
+}
+
+// iter runs the query with the given context and returns an Iterator over the
+// results.
+func (q *Query) iter(ctx context.Context) *Iterator {
 	if q.err != nil {
 		return &Iterator{err: q.err}
 	}
+	if q.batchSize > 0 && q.pq.HasOutputs() {
+		return &Iterator{err: fmt.Errorf("cannot run batched query: query contains output expressions")}
+	}
 
+	ctx, observed, err := q.observe(ctx)
+	if err != nil {
+		return &Iterator{pq: q.pq, err: err}
+	}
 	var cols []string
-	rows, result, ds, err := q.run(q.ctx)
+	rows, result, ds, err := q.run(ctx, q.pq)
 	if q.pq.HasOutputs() {
 		if err == nil { // if err IS nil
 			cols, err = rows.Columns()
 		}
 	}
 	if err != nil {
+		if observed != nil {
+			observed(err, -1)
+		}
 		return &Iterator{pq: q.pq, err: err}
 	}
 
-	return &Iterator{pq: q.pq, rows: rows, cols: cols, err: err, result: result, ds: ds}
+	iter := &Iterator{pq: q.pq, rows: rows, cols: cols, err: err, result: result, ds: ds}
+	if rows == nil {
+		if observed != nil {
+			if err := observed(nil, rowsAffected(result)); err != nil {
+				return &Iterator{pq: q.pq, err: err}
+			}
+		}
+	} else {
+		iter.observed = observed
+	}
+	return iter
 }
 
 // Next prepares the next row for [Iterator.Get]. If an error occurs during
@@ -241,12 +1219,36 @@ func (iter *Iterator) Next() bool {
 	if iter.err != nil || iter.rows == nil {
 		return false
 	}
-	return iter.rows.Next()
+	if !iter.rows.Next() {
+		return false
+	}
+	iter.rowsRead++
+	return true
+}
+
+// Columns returns the names of the columns in the query results, as returned
+// by the database. The columns generated by output expressions are aliased
+// by SQLair and appear under names such as "_sqlair_0", the others keep the
+// names given in the query. Columns returns nil if the query has no results
+// or failed. It can be called before the first call of [Iterator.Next].
+func (iter *Iterator) Columns() []string {
+	if iter.cols == nil {
+		return nil
+	}
+	cols := make([]string, len(iter.cols))
+	copy(cols, iter.cols)
+	return cols
 }
 
 // Get decodes the result from the previous [Iterator.Next] call into the
 // provided output arguments.
 //
+// Struct fields of type [sql.RawBytes] point at memory owned by the driver.
+// Their contents are only valid until the next call to [Iterator.Next] or
+// [Iterator.Close] and must be copied to be used after that. Methods that
+// close the rows themselves, such as [Query.Get] and [Query.GetAll], copy
+// them.
+//
 // Before the first call of [Iterator.Next] a pointer to an empty [Outcome]
 // struct may be passed to Get as the only argument to fill it information
 // about query execution.
@@ -274,17 +1276,35 @@ func (iter *Iterator) Get(outputArgs ...any) (err error) {
 		return fmt.Errorf("iteration ended")
 	}
 
-	ptrs, onSuccess, err := iter.pq.ScanArgs(iter.cols, outputArgs)
+	if iter.scanner == nil {
+		iter.scanner = iter.pq.NewRowScanner(iter.cols)
+	}
+	ptrs, onSuccess, err := iter.scanner.ScanArgs(outputArgs)
 	if err != nil {
 		return err
 	}
 	if err := iter.rows.Scan(ptrs...); err != nil {
+		return iter.pq.ScanError(iter.cols, err)
+	}
+	if err := onSuccess(); err != nil {
 		return err
 	}
-	onSuccess()
+	if iter.copyRawBytes {
+		copyRawBytes(ptrs)
+	}
 	return nil
 }
 
+// copyRawBytes replaces the sql.RawBytes scanned into by the scan arguments
+// with copies that do not point at the driver's memory.
+func copyRawBytes(ptrs []any) {
+	for _, ptr := range ptrs {
+		if rb, ok := ptr.(*sql.RawBytes); ok && *rb != nil {
+			*rb = append(sql.RawBytes{}, *rb...)
+		}
+	}
+}
+
 // Close finishes the iteration and returns any errors encountered. Close can
 // be called multiple times on the [Iterator] and the same error will be
 // returned.
@@ -296,7 +1316,14 @@ func (iter *Iterator) Close() error {
 	err := iter.rows.Close()
 	iter.rows = nil
 	if iter.err != nil {
-		return iter.err
+		err = iter.err
+	}
+	if iter.observed != nil {
+		if oerr := iter.observed(err, iter.rowsRead); oerr != nil && err == nil {
+			err = oerr
+			iter.err = err
+		}
+		iter.observed = nil
 	}
 	return err
 }
@@ -314,6 +1341,28 @@ func (o *Outcome) Result() sql.Result {
 	return o.result
 }
 
+// Labelled wraps an output argument with the label used to refer to it in the
+// query. This allows several output arguments of the same type to be used in
+// one query, e.g. "SELECT &Person:parent.* ...". Labelled arguments can be
+// passed to [Query.Get], [Iterator.Get] and, wrapping the slice pointer,
+// [Query.GetAll].
+func Labelled(label string, outputArg any) any {
+	return expr.LabelledArg{Label: label, Arg: outputArg}
+}
+
+// Unclaimed wraps a map so that it receives the columns in the query results
+// that are not read into any output expression when passed to [Query.Get] or
+// [Iterator.Get]. A column is unclaimed if it is not generated by an output
+// expression, for example a column written directly in the SQL without an
+// output expression. The map is keyed by column name. If several unclaimed
+// columns have the same name the last one is stored.
+//
+// The map must have string keys and interface values, such as [M]. The query
+// must contain at least one output expression.
+func Unclaimed(m any) any {
+	return expr.UnclaimedArg{Map: m}
+}
+
 // GetAll iterates over the query and scans all rows into the provided slices.
 // sliceArgs must contain pointers to slices of each of the output types.
 // A pointer to an empty [Outcome] struct may be provided as the first output
@@ -324,6 +1373,156 @@ func (q *Query) GetAll(sliceArgs ...any) (err error) {
 	if q.err != nil {
 		return q.err
 	}
+	_, err = q.getAll(-1, sliceArgs)
+	return err
+}
+
+// GetAllLimit is like [Query.GetAll] except that it stops iterating over the
+// query after n rows. This guards against reading a very large number of rows
+// into memory when the SQL cannot be given a LIMIT clause, for example because
+// it is generated.
+//
+// If the query returns more than n rows, the first n rows are scanned into
+// sliceArgs and [ErrTooManyRows] is returned. Callers that only want the
+// first n rows can ignore the error with errors.Is, while callers that treat
+// the extra rows as a failure can return it.
+func (q *Query) GetAllLimit(n int, sliceArgs ...any) error {
+	if q.err != nil {
+		return q.err
+	}
+	if n < 0 {
+		return fmt.Errorf("cannot get results: limit must not be negative, got %d", n)
+	}
+	tooMany, err := q.getAll(n, sliceArgs)
+	if err != nil {
+		return err
+	} else if tooMany {
+		return ErrTooManyRows
+	}
+	return nil
+}
+
+// MapOption is an option of [Query.GetAllMap].
+type MapOption interface {
+	applyToMap(opts *mapOptions)
+}
+
+// mapOptions holds the options of GetAllMap.
+type mapOptions struct {
+	// allowOverwrite is true if a row replaces an earlier row with the same
+	// key rather than causing an error.
+	allowOverwrite bool
+}
+
+type allowOverwrite struct{}
+
+// applyToMap lets later rows replace earlier rows with the same key.
+func (allowOverwrite) applyToMap(opts *mapOptions) {
+	opts.allowOverwrite = true
+}
+
+// AllowOverwrite returns a [MapOption] that lets [Query.GetAllMap] store the
+// last of several rows with the same key instead of returning an error.
+func AllowOverwrite() MapOption {
+	return allowOverwrite{}
+}
+
+// GetAllMap iterates over the query and stores each row in the map pointed to
+// by mapArg, keyed by the value of the key member of the row. The map values
+// must be structs or pointers to structs of an output type of the query, and
+// key must be a member of that type read by the query, e.g. "id" for
+// "SELECT &Person.* ..." with a map of type map[int]Person. The type of the
+// member must be assignable or convertible to the key type of the map.
+//
+// The rows are added to the map, which is created if it is nil. If two rows
+// have the same key an error is returned, unless the [AllowOverwrite] option
+// is passed, in which case the last row is stored. The map is only changed if
+// no error is returned.
+//
+// [ErrNoRows] will be returned if no rows are found.
+func (q *Query) GetAllMap(mapArg any, key string, opts ...MapOption) (err error) {
+	if q.err != nil {
+		return q.err
+	}
+	var mo mapOptions
+	for _, o := range opts {
+		o.applyToMap(&mo)
+	}
+
+	ptrVal := reflect.ValueOf(mapArg)
+	if ptrVal.Kind() != reflect.Pointer {
+		return fmt.Errorf("need pointer to map, got %s", ptrVal.Kind())
+	}
+	if ptrVal.IsNil() {
+		return fmt.Errorf("need pointer to map, got nil")
+	}
+	mapType := ptrVal.Type().Elem()
+	if mapType.Kind() != reflect.Map {
+		return fmt.Errorf("need pointer to map, got pointer to %s", mapType.Kind())
+	}
+	rowType := mapType.Elem()
+	if rowType.Kind() == reflect.Pointer {
+		rowType = rowType.Elem()
+	}
+	if rowType.Kind() != reflect.Struct {
+		return fmt.Errorf("need map of structs, got map of %s", mapType.Elem())
+	}
+	index, ok := q.pq.OutputField(rowType, key)
+	if !ok {
+		return fmt.Errorf("key %q is not a member of %q read by query", key, typeinfo.PrettyTypeName(rowType))
+	}
+	keyType := rowType.FieldByIndex(index).Type
+	if !keyType.AssignableTo(mapType.Key()) && !keyType.ConvertibleTo(mapType.Key()) {
+		return fmt.Errorf("cannot use key %q of type %s as key of %s", key, keyType, mapType)
+	}
+
+	rows := reflect.MakeMap(mapType)
+	iter := q.Iter()
+	iter.copyRawBytes = true
+	for iter.Next() {
+		row := reflect.New(rowType)
+		if err := iter.Get(row.Interface()); err != nil {
+			iter.Close()
+			return err
+		}
+		k, err := row.Elem().FieldByIndexErr(index)
+		if err != nil {
+			iter.Close()
+			return fmt.Errorf("cannot get key %q: %s", key, err)
+		}
+		k = k.Convert(mapType.Key())
+		if !mo.allowOverwrite && rows.MapIndex(k).IsValid() {
+			iter.Close()
+			return fmt.Errorf("key %q has value %v in more than one row", key, k.Interface())
+		}
+		if mapType.Elem().Kind() == reflect.Pointer {
+			rows.SetMapIndex(k, row)
+		} else {
+			rows.SetMapIndex(k, row.Elem())
+		}
+	}
+	if err := iter.Close(); err != nil {
+		return err
+	} else if rows.Len() == 0 {
+		return ErrNoRows
+	}
+
+	m := ptrVal.Elem()
+	if m.IsNil() {
+		m.Set(rows)
+		return nil
+	}
+	iterRows := rows.MapRange()
+	for iterRows.Next() {
+		m.SetMapIndex(iterRows.Key(), iterRows.Value())
+	}
+	return nil
+}
+
+// getAll scans the rows of the query into the slices in sliceArgs. If limit is
+// not negative at most limit rows are scanned and tooMany reports whether
+// the query had more rows. The slices are only set if err is nil.
+func (q *Query) getAll(limit int, sliceArgs []any) (tooMany bool, err error) {
 
 	if len(sliceArgs) > 0 {
 		if outcome, ok := sliceArgs[0].(*Outcome); ok {
@@ -332,80 +1531,201 @@ func (q *Query) GetAll(sliceArgs ...any) (err error) {
 		}
 	}
 	if !q.pq.HasOutputs() && len(sliceArgs) > 0 {
-		return fmt.Errorf("output variables provided but not referenced in query")
+		if !q.ignoreUnusedOutputs {
+			return false, fmt.Errorf("output variables provided but not referenced in query")
+		}
+		sliceArgs = nil
 	}
 	// Check slice inputs are valid using reflection.
 	var slicePtrVals = []reflect.Value{}
 	var sliceVals = []reflect.Value{}
+	var labels = []string{}
 	for _, ptr := range sliceArgs {
+		label := ""
+		if la, ok := ptr.(expr.LabelledArg); ok {
+			label, ptr = la.Label, la.Arg
+		}
+		labels = append(labels, label)
 		ptrVal := reflect.ValueOf(ptr)
 		if ptrVal.Kind() != reflect.Pointer {
-			return fmt.Errorf("need pointer to slice, got %s", ptrVal.Kind())
+			return false, fmt.Errorf("need pointer to slice, got %s", ptrVal.Kind())
 		}
 		if ptrVal.IsNil() {
-			return fmt.Errorf("need pointer to slice, got nil")
+			return false, fmt.Errorf("need pointer to slice, got nil")
 		}
 		slicePtrVals = append(slicePtrVals, ptrVal)
 		sliceVal := ptrVal.Elem()
 		if sliceVal.Kind() != reflect.Slice {
-			return fmt.Errorf("need pointer to slice, got pointer to %s", sliceVal.Kind())
+			return false, fmt.Errorf("need pointer to slice, got pointer to %s", sliceVal.Kind())
 		}
 		sliceVals = append(sliceVals, sliceVal)
 	}
 
 	// Iterate over the query results.
 	rowsReturned := false
+	numRows := 0
 	iter := q.Iter()
+	iter.copyRawBytes = true
+	var outputArgs []any
 	for iter.Next() {
 		rowsReturned = true
-		var outputArgs = []any{}
-		for _, sliceVal := range sliceVals {
+		if limit >= 0 && numRows == limit {
+			tooMany = true
+			break
+		}
+		numRows++
+		outputArgs = outputArgs[:0]
+		for i, sliceVal := range sliceVals {
 			elemType := sliceVal.Type().Elem()
 			var outputArg reflect.Value
 			switch elemType.Kind() {
 			case reflect.Pointer:
 				if elemType.Elem().Kind() != reflect.Struct {
 					iter.Close()
-					return fmt.Errorf("need slice of structs/maps, got slice of pointer to %s", elemType.Elem().Kind())
+					return false, fmt.Errorf("need slice of structs/maps, got slice of pointer to %s", elemType.Elem().Kind())
 				}
 				outputArg = reflect.New(elemType.Elem())
 			case reflect.Struct:
-				outputArg = reflect.New(elemType)
+				// The row is scanned straight into a new element of the
+				// slice rather than copied into it.
+				sliceVals[i] = reflect.Append(sliceVal, reflect.Zero(elemType))
+				outputArg = sliceVals[i].Index(sliceVals[i].Len() - 1).Addr()
 			case reflect.Map:
 				outputArg = reflect.MakeMap(elemType)
 			default:
 				iter.Close()
-				return fmt.Errorf("need slice of structs/maps, got slice of %s", elemType.Kind())
+				return false, fmt.Errorf("need slice of structs/maps, got slice of %s", elemType.Kind())
+			}
+			if labels[i] != "" {
+				outputArgs = append(outputArgs, expr.LabelledArg{Label: labels[i], Arg: outputArg.Interface()})
+			} else {
+				outputArgs = append(outputArgs, outputArg.Interface())
 			}
-			outputArgs = append(outputArgs, outputArg.Interface())
 		}
 		if err := iter.Get(outputArgs...); err != nil {
 			iter.Close()
-			return err
+			return false, err
 		}
 		for i, outputArg := range outputArgs {
+			if la, ok := outputArg.(expr.LabelledArg); ok {
+				outputArg = la.Arg
+			}
 			switch k := sliceVals[i].Type().Elem().Kind(); k {
 			case reflect.Pointer, reflect.Map:
 				sliceVals[i] = reflect.Append(sliceVals[i], reflect.ValueOf(outputArg))
 			case reflect.Struct:
-				sliceVals[i] = reflect.Append(sliceVals[i], reflect.ValueOf(outputArg).Elem())
+				// The row was scanned into the slice.
 			default:
 				iter.Close()
-				return fmt.Errorf("internal error: output arg has unexpected kind %s", k)
+				return false, fmt.Errorf("internal error: output arg has unexpected kind %s", k)
 			}
 		}
 	}
 	err = iter.Close()
 	if err != nil {
-		return err
+		return false, err
 	} else if !rowsReturned && q.pq.HasOutputs() {
-		return ErrNoRows
+		return false, ErrNoRows
 	}
 
 	for i, ptrVal := range slicePtrVals {
 		ptrVal.Elem().Set(sliceVals[i])
 	}
 
+	return tooMany, nil
+}
+
+// GetAllChunked is like [Query.GetAll] except that if the slice passed to the
+// slice input expression of the query, e.g. "$S[:]", has more than chunkSize
+// elements, the query is run once for each chunk of at most chunkSize
+// elements and the results are appended to sliceArgs. This keeps the number
+// of query parameters below the limit of the database.
+//
+// The query must have at most one slice input expression. The results of each
+// chunk are appended in the order of the chunks, so an ORDER BY clause in the
+// query only orders the rows within each chunk. Clauses such as LIMIT and
+// DISTINCT also apply to each chunk separately. The chunks are not run
+// atomically unless the query is run on a transaction.
+//
+// [ErrNoRows] is returned if none of the chunks return any rows. If an error
+// is returned the slices are left unchanged.
+func (q *Query) GetAllChunked(chunkSize int, sliceArgs ...any) error {
+	if q.err != nil {
+		return q.err
+	}
+	if chunkSize <= 0 {
+		return fmt.Errorf("cannot run chunked query: chunk size must be positive, got %d", chunkSize)
+	}
+	if len(sliceArgs) > 0 {
+		if _, ok := sliceArgs[0].(*Outcome); ok {
+			return fmt.Errorf("cannot run chunked query: cannot get outcome of chunked query")
+		}
+	}
+	if len(q.sliceInputTypes) > 1 {
+		return fmt.Errorf("cannot run chunked query: query has %d slice inputs, expected at most one", len(q.sliceInputTypes))
+	}
+
+	// Find the input argument used in the slice input expression.
+	sliceIdx := -1
+	var sliceVal reflect.Value
+	if len(q.sliceInputTypes) == 1 {
+		for i, arg := range q.inputArgs {
+			v := reflect.ValueOf(arg)
+			for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+				v = v.Elem()
+			}
+			if v.IsValid() && v.Type() == q.sliceInputTypes[0] {
+				sliceIdx, sliceVal = i, v
+				break
+			}
+		}
+	}
+	if sliceIdx == -1 || sliceVal.Len() <= chunkSize {
+		return q.GetAll(sliceArgs...)
+	}
+
+	// Save copies of the output slices so they can be restored on error.
+	var saved []reflect.Value
+	for _, arg := range sliceArgs {
+		if la, ok := arg.(expr.LabelledArg); ok {
+			arg = la.Arg
+		}
+		ptrVal := reflect.ValueOf(arg)
+		if ptrVal.Kind() != reflect.Pointer || ptrVal.IsNil() {
+			return q.GetAll(sliceArgs...)
+		}
+		saved = append(saved, reflect.ValueOf(ptrVal.Elem().Interface()))
+	}
+	restore := func() {
+		for i, arg := range sliceArgs {
+			if la, ok := arg.(expr.LabelledArg); ok {
+				arg = la.Arg
+			}
+			reflect.ValueOf(arg).Elem().Set(saved[i])
+		}
+	}
+
+	rowsReturned := false
+	inputArgs := make([]any, len(q.inputArgs))
+	copy(inputArgs, q.inputArgs)
+	for start := 0; start < sliceVal.Len(); start += chunkSize {
+		end := start + chunkSize
+		if end > sliceVal.Len() {
+			end = sliceVal.Len()
+		}
+		inputArgs[sliceIdx] = sliceVal.Slice(start, end).Interface()
+		err := q.rebind(inputArgs).GetAll(sliceArgs...)
+		if errors.Is(err, ErrNoRows) {
+			continue
+		} else if err != nil {
+			restore()
+			return err
+		}
+		rowsReturned = true
+	}
+	if !rowsReturned && q.pq.HasOutputs() {
+		return ErrNoRows
+	}
 	return nil
 }
 
@@ -414,6 +1734,14 @@ type TX struct {
 	sqltx *sql.Tx
 	db    *DB
 	done  int32
+	// timeoutSet is 1 if a server-side statement timeout has been set on
+	// the transaction.
+	timeoutSet int32
+
+	// stmts holds the statements registered on the transaction, keyed by the
+	// statement prepared on the DB. It is dropped when the transaction is done.
+	stmtsMutex sync.Mutex
+	stmts      map[*sql.Stmt]*sql.Stmt
 }
 
 func (tx *TX) isDone() bool {
@@ -424,9 +1752,68 @@ func (tx *TX) setDone() error {
 	if !atomic.CompareAndSwapInt32(&tx.done, 0, 1) {
 		return ErrTXDone
 	}
+	// The registered statements are closed by database/sql when the
+	// transaction is committed or rolled back.
+	tx.stmtsMutex.Lock()
+	tx.stmts = nil
+	tx.stmtsMutex.Unlock()
 	return nil
 }
 
+// stmt returns the statement prepared on the DB registered on the transaction.
+// The statement is registered once and reused until the transaction is done,
+// so that running a statement many times in a transaction does not register
+// it every time.
+func (tx *TX) stmt(ctx context.Context, dbstmt *sql.Stmt) *sql.Stmt {
+	tx.stmtsMutex.Lock()
+	defer tx.stmtsMutex.Unlock()
+	if txstmt, ok := tx.stmts[dbstmt]; ok {
+		return txstmt
+	}
+	// This does not resend the prepare request to the database unless the
+	// statement was not prepared on the connection of the transaction, in
+	// which case the prepare uses the context of the query.
+	txstmt := tx.sqltx.StmtContext(ctx, dbstmt)
+	if tx.isDone() {
+		return txstmt
+	}
+	if tx.stmts == nil {
+		tx.stmts = make(map[*sql.Stmt]*sql.Stmt)
+	}
+	tx.stmts[dbstmt] = txstmt
+	return txstmt
+}
+
+// forgetStmt removes the statement registered for dbstmt so that the next
+// query registers it again. It is called when a query on the registered
+// statement fails, since the error may come from registering it.
+func (tx *TX) forgetStmt(dbstmt *sql.Stmt) {
+	tx.stmtsMutex.Lock()
+	defer tx.stmtsMutex.Unlock()
+	delete(tx.stmts, dbstmt)
+}
+
+// setStatementTimeout sets the server-side timeout of the statements run on the
+// transaction to the time left until the deadline of ctx, if the dialect has
+// statement timeouts enabled. If ctx has no deadline, a timeout set by an
+// earlier query is reset.
+func (tx *TX) setStatementTimeout(ctx context.Context, dialect *expr.Dialect) error {
+	if !dialect.HasStatementTimeout() {
+		return nil
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		if atomic.CompareAndSwapInt32(&tx.timeoutSet, 1, 0) {
+			_, err := tx.sqltx.ExecContext(ctx, dialect.ResetStatementTimeoutSQL())
+			return err
+		}
+		return nil
+	}
+	atomic.StoreInt32(&tx.timeoutSet, 1)
+	_, err := tx.sqltx.ExecContext(ctx, dialect.StatementTimeoutSQL(time.Until(deadline)))
+	return err
+}
+
 // Begin starts a transaction. A transaction must be ended
 // with a [TX.Commit] or [TX.Rollback].
 func (db *DB) Begin(ctx context.Context, opts *TXOptions) (*TX, error) {
@@ -487,24 +1874,34 @@ func (tx *TX) Query(ctx context.Context, s *Statement, inputArgs ...any) *Query
 		return &Query{ctx: ctx, err: ErrTXDone}
 	}
 
-	pq, err := s.te.BindInputs(inputArgs...)
+	dialect := s.dialectOn(tx.db)
+	pq, err := s.te.BindInputsWithDialect(dialect, inputArgs...)
 	if err != nil {
 		return &Query{ctx: ctx, err: err}
 	}
 
-	run := func(innerCtx context.Context) (rows *sql.Rows, result sql.Result, ds *driverStmt, err error) {
+	run := func(innerCtx context.Context, pq *expr.PrimedQuery) (rows *sql.Rows, result sql.Result, ds *driverStmt, err error) {
+		if err := tx.setStatementTimeout(innerCtx, dialect); err != nil {
+			return nil, nil, nil, err
+		}
+		if comment := tx.db.commentFor(innerCtx); comment != "" {
+			rows, result, err = runCommented(innerCtx, pq, comment, tx.sqltx)
+			return rows, result, nil, err
+		}
 		ds, ok := stmtCache.lookupStmt(tx.db, s, pq.SQL())
 		if ok {
-			// Register the prepared statement on the transaction. This function
-			// does not resend the prepare request to the database.
-			// The txstmt is closed by database/sql when the transaction is
-			// commited or rolled back.
-			txstmt := tx.sqltx.Stmt(ds.stmt)
+			stmtCache.countLookup(true)
+			stmtCache.markUsed(ds)
+			// Use the prepared statement registered on the transaction.
+			txstmt := tx.stmt(innerCtx, ds.stmt)
 			if pq.HasOutputs() {
 				rows, err = txstmt.QueryContext(innerCtx, pq.Params()...)
 			} else {
 				result, err = txstmt.ExecContext(innerCtx, pq.Params()...)
 			}
+			if err != nil {
+				tx.forgetStmt(ds.stmt)
+			}
 			return rows, result, ds, err
 		}
 
@@ -516,5 +1913,33 @@ func (tx *TX) Query(ctx context.Context, s *Statement, inputArgs ...any) *Query
 		return rows, result, nil, err
 	}
 
-	return &Query{pq: pq, ctx: ctx, run: run, err: nil}
+	// Queries on a transaction only use statements already prepared on the DB,
+	// they do not prepare statements and add them to the cache.
+	cacheState := func(ctx context.Context) CacheState {
+		if tx.db.commentFor(ctx) != "" {
+			return CacheUncacheable
+		}
+		if _, ok := stmtCache.lookupStmt(tx.db, s, pq.SQL()); ok {
+			return CacheHit
+		}
+		return CacheUncacheable
+	}
+
+	rebind := func(inputArgs []any) *Query {
+		return tx.Query(ctx, s, inputArgs...)
+	}
+
+	return &Query{
+		pq:                  pq,
+		ctx:                 ctx,
+		run:                 run,
+		cacheState:          cacheState,
+		err:                 nil,
+		inputArgs:           inputArgs,
+		sliceInputTypes:     s.te.SliceInputTypes(),
+		rebind:              rebind,
+		ignoreUnusedOutputs: s.ignoreUnusedOutputs,
+		observer:            tx.db.observer,
+		transact:            transactSavepoint(tx, rebind),
+	}
 }
@@ -0,0 +1,63 @@
+// Copyright 2024 Canonical Ltd.
+// Licensed under Apache 2.0, see LICENCE file for details.
+
+package sqlair
+
+import (
+	"reflect"
+	"strings"
+	"unicode"
+)
+
+// TableNamer is implemented by types that specify the name of the database
+// table they are stored in. It is consulted by helpers that generate
+// statements for a type.
+type TableNamer interface {
+	TableName() string
+}
+
+// TableName returns the name of the table that values of the same type as
+// typeSample are stored in. If the type implements [TableNamer], with a value
+// or pointer receiver, its TableName method is used. Otherwise the name is
+// derived from the name of the type converted to snake case, e.g. "Person" is
+// stored in "person" and "OrderItem" in "order_item". Runs of upper case
+// letters are treated as a single word, so "HTTPRequest" is stored in
+// "http_request".
+//
+// The empty string is returned if typeSample is nil or its type has no name.
+func TableName(typeSample any) string {
+	if tn, ok := typeSample.(TableNamer); ok {
+		return tn.TableName()
+	}
+	t := reflect.TypeOf(typeSample)
+	if t == nil {
+		return ""
+	}
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if tn, ok := reflect.New(t).Interface().(TableNamer); ok {
+		return tn.TableName()
+	}
+	return snakeCase(t.Name())
+}
+
+// snakeCase converts a Go identifier in camel case to snake case.
+func snakeCase(name string) string {
+	runes := []rune(name)
+	var b strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) && i > 0 {
+			prev := runes[i-1]
+			nextIsLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			// Start a new word after a lower case letter or digit, or at the
+			// last upper case letter of an acronym followed by a lower case
+			// letter, e.g. the "R" in "HTTPRequest".
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextIsLower) {
+				b.WriteRune('_')
+			}
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}